@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/AlikhanF2006/Final_project/configs"
+	"github.com/AlikhanF2006/Final_project/internal/auth"
+	"github.com/AlikhanF2006/Final_project/internal/ginhandler"
+	"github.com/AlikhanF2006/Final_project/internal/service"
+	"github.com/AlikhanF2006/Final_project/internal/tmdb"
+)
+
+// database is the handle of a backend that has one: the Postgres pool or the
+// SQLite file.
+type database interface {
+	Ping(ctx context.Context) error
+	Close()
+}
+
+// App owns everything the server needs and tears it down in reverse order:
+// HTTP server first, then background workers, then the database pool.
+type App struct {
+	cfg configs.Config
+
+	db     database // nil for the memory backend
+	policy *auth.Policy
+	tokens *auth.TokenService
+
+	ratingWorker *service.RatingWorker
+	catalogSync  *service.CatalogSync
+	importer     *service.MovieImporter
+	tmdbCache    *tmdb.Cache
+
+	movieH  *ginhandler.MovieHandler
+	reviewH *ginhandler.ReviewHandler
+	userH   *ginhandler.UserHandler
+	authH   *ginhandler.AuthHandler
+	syncH   *ginhandler.SyncHandler
+	importH *ginhandler.ImportHandler
+	exportH *ginhandler.ExportHandler
+	genreH  *ginhandler.GenreHandler
+	personH *ginhandler.PersonHandler
+	watchH  *ginhandler.WatchlistHandler
+	diaryH  *ginhandler.DiaryHandler
+	listH   *ginhandler.MovieListHandler
+	feedH   *ginhandler.FeedHandler
+	authSvc *service.AuthService
+
+	server *http.Server
+}
+
+func NewApp(ctx context.Context, cfg configs.Config) (*App, error) {
+	app := &App{cfg: cfg}
+
+	repos, err := app.openStorage(ctx)
+	if err == nil {
+		err = app.wire(ctx, repos)
+	}
+	if err != nil {
+		if app.db != nil {
+			app.db.Close()
+		}
+		return nil, err
+	}
+
+	app.server = &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           app.routes(),
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+
+	return app, nil
+}
+
+func (a *App) wire(ctx context.Context, repos repositories) error {
+	grants, err := repos.roles.LoadGrants(ctx)
+	if err != nil {
+		return fmt.Errorf("load role permissions: %w", err)
+	}
+	a.policy = auth.NewPolicy(grants)
+
+	a.tokens, err = newTokenService(a.cfg.Auth)
+	if err != nil {
+		return fmt.Errorf("set up token keys: %w", err)
+	}
+
+	tmdbClient := newTMDBClient(a.cfg.TMDB)
+	a.tmdbCache = tmdb.NewCache(tmdbClient, tmdb.CacheConfig{
+		MaxEntries: a.cfg.TMDB.Cache.MaxEntries,
+		PopularTTL: a.cfg.TMDB.Cache.PopularTTL,
+		MovieTTL:   a.cfg.TMDB.Cache.MovieTTL,
+		StaleTTL:   a.cfg.TMDB.Cache.StaleTTL,
+	})
+
+	a.ratingWorker = service.NewRatingWorker(repos.ratingJobs, service.RatingWorkerConfig{
+		Workers:      a.cfg.RatingWorker.Workers,
+		PollInterval: a.cfg.RatingWorker.PollInterval,
+	})
+
+	lists := make([]tmdb.MovieList, len(a.cfg.TMDBSync.Lists))
+	for i, l := range a.cfg.TMDBSync.Lists {
+		lists[i] = tmdb.MovieList(l)
+	}
+	// The sync and imports read TMDB directly: they want current data, and
+	// caching thousands of movies would only push the popular ones out.
+	a.catalogSync = service.NewCatalogSync(tmdbClient, repos.movies, repos.syncRuns, service.CatalogSyncConfig{
+		Scheduled: a.cfg.TMDBSync.Enabled,
+		Interval:  a.cfg.TMDBSync.Interval,
+		Lists:     lists,
+		MaxPages:  a.cfg.TMDBSync.MaxPages,
+	})
+	a.importer = service.NewMovieImporter(tmdbClient, repos.movies, repos.genres, repos.people)
+
+	movieSvc := service.NewMovieService(repos.movies, repos.genres, repos.people, a.tmdbCache)
+	genreSvc := service.NewGenreService(repos.genres)
+	personSvc := service.NewPersonService(repos.people)
+	watchlistSvc := service.NewWatchlistService(repos.watchlists, repos.movies, repos.genres, a.importer)
+	feedSvc := service.NewFeedService(repos.feed, repos.users, repos.genres)
+	diarySvc := service.NewDiaryService(repos.diary, repos.genres, a.ratingWorker)
+	listSvc := service.NewMovieListService(repos.lists, repos.genres)
+	reviewSvc := service.NewReviewService(repos.reviews, repos.movies, a.ratingWorker)
+	a.authSvc = service.NewAuthService(repos.users, repos.tokens, a.tokens, a.cfg.Auth.RefreshTTL)
+	userSvc := service.NewUserService(repos.users, a.policy, a.authSvc, a.ratingWorker)
+	exportSvc := service.NewExportService(repos.exports, repos.genres)
+
+	a.movieH = ginhandler.NewMovieHandler(movieSvc, genreSvc)
+	a.genreH = ginhandler.NewGenreHandler(genreSvc)
+	a.personH = ginhandler.NewPersonHandler(personSvc)
+	a.watchH = ginhandler.NewWatchlistHandler(watchlistSvc, genreSvc)
+	a.diaryH = ginhandler.NewDiaryHandler(diarySvc)
+	a.listH = ginhandler.NewMovieListHandler(listSvc)
+	a.feedH = ginhandler.NewFeedHandler(feedSvc)
+	a.reviewH = ginhandler.NewReviewHandler(reviewSvc)
+	a.userH = ginhandler.NewUserHandler(userSvc)
+	a.authH = ginhandler.NewAuthHandler(a.authSvc, a.tokens)
+	a.syncH = ginhandler.NewSyncHandler(a.catalogSync)
+	a.importH = ginhandler.NewImportHandler(a.importer)
+	a.exportH = ginhandler.NewExportHandler(exportSvc, genreSvc, a.cfg.Server.WriteTimeout, a.cfg.Server.ExportTimeout)
+
+	return nil
+}
+
+func newTMDBClient(cfg configs.TMDBConfig) *tmdb.Client {
+	return tmdb.NewClient(tmdb.Config{
+		Token:           cfg.ApiKey,
+		BaseURL:         cfg.BaseURL,
+		Timeout:         cfg.Timeout,
+		MaxRetries:      cfg.MaxRetries,
+		RetryBackoff:    cfg.RetryBackoff,
+		MaxRetryBackoff: cfg.MaxRetryBackoff,
+		RateLimit:       cfg.RateLimit,
+		RateBurst:       cfg.RateBurst,
+	})
+}
+
+// Run serves HTTP until ctx is cancelled (SIGINT/SIGTERM) or the listener
+// fails, then shuts everything down within cfg.Server.ShutdownTimeout.
+func (a *App) Run(ctx context.Context) error {
+	a.ratingWorker.Start(context.Background())
+	a.catalogSync.Start(context.Background())
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("server running on %s", a.cfg.Server.Addr)
+		if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+		close(serveErr)
+	}()
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		log.Println("shutting down")
+	case runErr = <-serveErr:
+	}
+
+	if err := a.shutdown(); err != nil && runErr == nil {
+		runErr = err
+	}
+	return runErr
+}
+
+func (a *App) shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), a.cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+
+	if err := a.server.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("http server: %w", err))
+	}
+
+	if err := a.catalogSync.Stop(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("tmdb sync: %w", err))
+	}
+
+	if err := a.importer.Stop(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("movie import: %w", err))
+	}
+
+	if err := a.ratingWorker.Stop(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("rating worker: %w", err))
+	}
+
+	if a.db != nil {
+		a.db.Close()
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/AlikhanF2006/Final_project/configs"
+	"github.com/AlikhanF2006/Final_project/internal/importfile"
+	"github.com/AlikhanF2006/Final_project/internal/service"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+const importUsage = "usage: server import [--dry-run] [--format ids|csv|json|ndjson] file|- | --ids 550,603,..."
+
+// runImport implements `server import`: it imports movies into the
+// configured storage and prints every row as it finishes.
+func runImport(ctx context.Context, cfg configs.Config, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "report what would change without writing")
+	formatName := fs.String("format", "", "file format; guessed from the extension if empty")
+	ids := fs.String("ids", "", "comma separated TMDB ids to import instead of a file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if cfg.Storage == configs.StorageMemory {
+		return fmt.Errorf("storage %q does not keep what is imported", cfg.Storage)
+	}
+
+	entries, format, err := readImportEntries(fs.Args(), *ids, *formatName)
+	if err != nil {
+		return err
+	}
+
+	app := &App{cfg: cfg}
+	repos, err := app.openStorage(ctx)
+	if app.db != nil {
+		defer app.db.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	importer := service.NewMovieImporter(newTMDBClient(cfg.TMDB), repos.movies, repos.genres, repos.people)
+	done := 0
+	job := importer.Run(ctx, entries, service.ImportOptions{Format: format, DryRun: *dryRun}, func(row model.ImportRow) {
+		done++
+		printImportRow(done, len(entries), row)
+	})
+
+	verb := "imported"
+	if job.DryRun {
+		verb = "dry run"
+	}
+	fmt.Printf("%s %s: %d rows, %d created, %d updated, %d unchanged, %d duplicates, %d failed\n",
+		verb, job.Status, job.Total, job.Created, job.Updated, job.Unchanged, job.Duplicates, job.Failed)
+
+	if job.Failed > 0 {
+		return fmt.Errorf("%d of %d rows failed", job.Failed, job.Total)
+	}
+	return nil
+}
+
+func readImportEntries(args []string, ids, formatName string) ([]importfile.Entry, importfile.Format, error) {
+	if ids != "" {
+		if len(args) > 0 {
+			return nil, "", errors.New(importUsage)
+		}
+		entries, err := importfile.Parse(strings.NewReader(ids), importfile.FormatIDs)
+		return entries, importfile.FormatIDs, err
+	}
+	if len(args) != 1 {
+		return nil, "", errors.New(importUsage)
+	}
+
+	var (
+		format importfile.Format
+		err    error
+	)
+	switch {
+	case formatName != "":
+		format, err = importfile.ParseFormat(formatName)
+	case args[0] == "-":
+		err = errors.New("--format is required when reading stdin")
+	default:
+		format, err = importfile.FormatFromName(args[0])
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	var r io.Reader = os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return nil, "", err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	entries, err := importfile.Parse(r, format)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(entries) == 0 {
+		return nil, "", errors.New("import file has no entries")
+	}
+	return entries, format, nil
+}
+
+func printImportRow(done, total int, row model.ImportRow) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%d/%d] line %d", done, total, row.Line)
+	if row.TMDBID != 0 {
+		fmt.Fprintf(&b, " tmdb %d", row.TMDBID)
+	}
+	fmt.Fprintf(&b, ": %s", row.Status)
+	if row.MovieID != 0 {
+		fmt.Fprintf(&b, " movie %d", row.MovieID)
+	}
+	if row.Title != "" {
+		fmt.Fprintf(&b, " %q", row.Title)
+	}
+	if row.Error != "" {
+		fmt.Fprintf(&b, " (%s)", row.Error)
+	}
+	fmt.Println(b.String())
+}
@@ -2,104 +2,58 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/AlikhanF2006/Final_project/configs"
 	"github.com/AlikhanF2006/Final_project/pkg/db"
-
-	"github.com/AlikhanF2006/Final_project/internal/ginhandler"
-	"github.com/AlikhanF2006/Final_project/internal/middleware"
-	"github.com/AlikhanF2006/Final_project/internal/postgres"
-	"github.com/AlikhanF2006/Final_project/internal/service"
-	"github.com/AlikhanF2006/Final_project/internal/tmdb"
 )
 
 func main() {
-	configs.LoadConfig()
-
-	db.Connect()
-	defer db.Close()
-
-	gin.SetMode(gin.ReleaseMode)
-
-	movieRepo := postgres.NewMovieRepository()
-	reviewRepo := postgres.NewReviewRepository()
-	userRepo := postgres.NewUserRepository()
-
-	tmdbClient := tmdb.NewClient(
-		configs.AppConfig.TMDB.ApiKey,
-	)
-
-	movieSvc := service.NewMovieService(movieRepo, tmdbClient)
-	reviewSvc := service.NewReviewService(reviewRepo, movieRepo)
-	userSvc := service.NewUserService(userRepo)
-
-	reviewSvc.StartRatingWorker()
-
-	movieH := ginhandler.NewMovieHandler(movieSvc)
-	reviewH := ginhandler.NewReviewHandler(reviewSvc)
-	userH := ginhandler.NewUserHandler(userSvc)
-
-	r := gin.Default()
+	cfg, args, err := configs.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	r.LoadHTMLGlob("templates/*")
-	r.Static("/static", "./web/static")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	api := r.Group("/api")
-	{
-		authGroup := api.Group("/auth")
-		{
-			authGroup.POST("/register", userH.Register)
-			authGroup.POST("/login", userH.Login)
+	if len(args) > 0 && args[0] == "migrate" {
+		if cfg.Storage != configs.StoragePostgres {
+			log.Fatalf("migrate: storage %q has no migrations", cfg.Storage)
 		}
-
-		public := api.Group("")
-		{
-			public.GET("/movies", movieH.GetMovies)
-			public.GET("/movies/search", movieH.Search)
-			public.GET("/movies/:id", movieH.GetMovieByID)
-			public.GET("/movies/tmdb/popular", movieH.GetPopularFromTMDB)
-			public.GET("/movies/:id/reviews", reviewH.GetReviews)
-			public.GET("/tmdb/movies/:id", movieH.GetMovieWithTrailer)
+		database, err := db.Connect(ctx, cfg.Database)
+		if err != nil {
+			log.Fatal("cannot connect to database: ", err)
 		}
+		err = runMigrate(ctx, database, args[1:])
+		database.Close()
+		if err != nil {
+			log.Fatal("migrate: ", err)
+		}
+		return
+	}
 
-		protected := api.Group("")
-		protected.Use(middleware.AuthMiddleware(configs.AppConfig.Auth.JWTSecret))
-		{
-			protected.POST("/movies", movieH.CreateMovie)
-			protected.PUT("/movies/:id", movieH.UpdateMovie)
-			protected.DELETE("/movies/:id", movieH.DeleteMovie)
-
-			protected.POST("/movies/:id/reviews", reviewH.AddReview)
-			protected.PUT("/movies/:id/reviews", reviewH.UpdateReview)
-			protected.DELETE("/movies/:id/reviews", reviewH.DeleteReview)
-			protected.DELETE("/reviews/:review_id", reviewH.AdminDeleteReview)
-
-			protected.GET("/me", userH.Me)
-			protected.PUT("/me", userH.UpdateMe)
-			protected.PUT("/me/password", userH.ChangePassword)
-			protected.DELETE("/me", userH.DeleteMe)
-
-			protected.GET("/users/:id", userH.GetUserByID)
-			protected.DELETE("/users/:id", userH.AdminDeleteUser)
+	if len(args) > 0 && args[0] == "import" {
+		if err := runImport(ctx, cfg, args[1:]); err != nil {
+			log.Fatal("import: ", err)
 		}
+		return
 	}
 
-	/*
-		r.GET("/", func(c *gin.Context) {
-			movies := movieSvc.ListMovies()
-			c.HTML(200, "index.tmpl", gin.H{"Movies": movies})
-		})
-	*/
+	gin.SetMode(gin.ReleaseMode)
 
-	r.NoRoute(func(c *gin.Context) {
-		c.File("./web/index.html")
-	})
+	app, err := NewApp(ctx, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	log.Println("server running on http://localhost:8080")
-	if err := r.Run(":8080"); err != nil {
+	if err := app.Run(ctx); err != nil {
 		log.Fatal(err)
 	}
 }
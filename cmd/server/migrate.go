@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+const migrateUsage = "usage: server migrate up|down [steps]|status"
+
+// runMigrate implements `server migrate up|down [steps]|status`.
+func runMigrate(ctx context.Context, database *db.DB, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	migrator, err := db.NewMigrator(database)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		return migrateUp(ctx, migrator)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("invalid steps %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, v := range reverted {
+			fmt.Printf("reverted %04d\n", v)
+		}
+		return err
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			state := "pending"
+			if st.Applied {
+				state = "applied " + st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-24s %s\n", st.Version, st.Name, state)
+		}
+		return nil
+
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+func migrateUp(ctx context.Context, migrator *db.Migrator) error {
+	applied, err := migrator.Up(ctx)
+	for _, v := range applied {
+		fmt.Printf("applied %04d\n", v)
+	}
+	if err == nil && len(applied) == 0 {
+		fmt.Println("database is up to date")
+	}
+	return err
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/AlikhanF2006/Final_project/internal/auth"
+	"github.com/AlikhanF2006/Final_project/internal/middleware"
+)
+
+func (a *App) routes() *gin.Engine {
+	r := gin.Default()
+
+	r.LoadHTMLGlob("templates/*")
+	r.Static("/static", "./web/static")
+
+	r.GET("/healthz", func(c *gin.Context) {
+		if a.db != nil {
+			if err := a.db.Ping(c.Request.Context()); err != nil {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"status": "database unavailable"})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	if a.tokens.HasPublicKeys() {
+		r.GET("/.well-known/jwks.json", a.authH.JWKS)
+	}
+
+	api := r.Group("/api")
+	{
+		authGroup := api.Group("/auth")
+		{
+			authGroup.POST("/register", a.userH.Register)
+			authGroup.POST("/login", a.authH.Login)
+			authGroup.POST("/refresh", a.authH.Refresh)
+		}
+
+		public := api.Group("")
+		{
+			public.GET("/movies", a.movieH.GetMovies)
+			public.GET("/movies/search", a.movieH.Search)
+			public.GET("/movies/:id", a.movieH.GetMovieByID)
+			public.GET("/movies/tmdb/popular", a.movieH.GetPopularFromTMDB)
+			public.GET("/movies/:id/reviews", a.reviewH.GetReviews)
+			public.GET("/movies/:id/lists", a.listH.ForMovie)
+			public.GET("/tmdb/movies/:id", a.movieH.GetMovieWithTrailer)
+			public.GET("/genres", a.genreH.List)
+			public.GET("/people", a.personH.Search)
+			public.GET("/people/:id", a.personH.Get)
+		}
+
+		protected := api.Group("")
+		protected.Use(middleware.AuthMiddleware(a.tokens, a.authSvc))
+		{
+			protected.POST("/auth/logout", a.authH.Logout)
+
+			movieWrite := middleware.RequirePermission(a.policy, auth.PermMovieWrite)
+			protected.POST("/movies", movieWrite, a.movieH.CreateMovie)
+			protected.PUT("/movies/:id", movieWrite, a.movieH.UpdateMovie)
+			protected.DELETE("/movies/:id", movieWrite, a.movieH.DeleteMovie)
+			protected.DELETE("/movies/:id/overrides", movieWrite, a.movieH.ClearOverrides)
+			protected.PUT("/movies/:id/genres", movieWrite, a.movieH.SetGenres)
+			protected.POST("/genres", movieWrite, a.genreH.Create)
+			protected.PUT("/genres/:id", movieWrite, a.genreH.Update)
+			protected.DELETE("/genres/:id", movieWrite, a.genreH.Delete)
+
+			reviewWrite := middleware.RequirePermission(a.policy, auth.PermReviewWrite)
+			protected.POST("/movies/:id/reviews", reviewWrite, a.reviewH.AddReview)
+			protected.PUT("/movies/:id/reviews", reviewWrite, a.reviewH.UpdateReview)
+			protected.DELETE("/movies/:id/reviews", reviewWrite, a.reviewH.DeleteReview)
+			protected.DELETE("/reviews/:review_id",
+				middleware.RequirePermission(a.policy, auth.PermReviewModerate),
+				a.reviewH.AdminDeleteReview,
+			)
+
+			protected.GET("/me", a.userH.Me)
+			protected.PUT("/me", a.userH.UpdateMe)
+			protected.PUT("/me/password", a.userH.ChangePassword)
+			protected.DELETE("/me", a.userH.DeleteMe)
+
+			protected.GET("/me/watchlist", a.watchH.List)
+			protected.POST("/me/watchlist", a.watchH.Add)
+			protected.PATCH("/me/watchlist/:movie_id", a.watchH.Update)
+			protected.DELETE("/me/watchlist/:movie_id", a.watchH.Remove)
+
+			protected.GET("/me/diary", a.diaryH.List)
+			protected.GET("/me/diary/calendar", a.diaryH.Calendar)
+			protected.POST("/me/diary", a.diaryH.Add)
+			protected.PATCH("/me/diary/:id", a.diaryH.Update)
+			protected.DELETE("/me/diary/:id", a.diaryH.Delete)
+
+			protected.GET("/me/lists", a.listH.Mine)
+			protected.POST("/lists", a.listH.Create)
+			protected.GET("/lists/:id", a.listH.Get)
+			protected.PATCH("/lists/:id", a.listH.Update)
+			protected.DELETE("/lists/:id", a.listH.Delete)
+			protected.POST("/lists/:id/clone", a.listH.Clone)
+			protected.PUT("/lists/:id/collaborators/:user_id", a.listH.AddCollaborator)
+			protected.DELETE("/lists/:id/collaborators/:user_id", a.listH.RemoveCollaborator)
+			protected.GET("/lists/:id/entries", a.listH.Entries)
+			protected.POST("/lists/:id/entries", a.listH.AddEntry)
+			protected.PATCH("/lists/:id/entries/:movie_id", a.listH.UpdateEntry)
+			protected.DELETE("/lists/:id/entries/:movie_id", a.listH.RemoveEntry)
+
+			protected.GET("/users/:id", a.userH.GetUserByID)
+			protected.POST("/users/:id/follow", a.feedH.Follow)
+			protected.DELETE("/users/:id/follow", a.feedH.Unfollow)
+			protected.GET("/users/:id/followers", a.feedH.Followers)
+			protected.GET("/users/:id/following", a.feedH.Following)
+			protected.GET("/me/feed", a.feedH.Feed)
+
+			dataExport := middleware.RequirePermission(a.policy, auth.PermDataExport)
+			protected.GET("/export/movies", dataExport, a.exportH.Movies)
+			protected.GET("/export/reviews", dataExport, a.exportH.Reviews)
+			protected.GET("/export/ratings", dataExport, a.exportH.Ratings)
+
+			userAdmin := middleware.RequirePermission(a.policy, auth.PermUserAdmin)
+			protected.DELETE("/users/:id", userAdmin, a.userH.AdminDeleteUser)
+			protected.PUT("/users/:id/role", userAdmin, a.userH.AdminSetRole)
+			protected.GET("/admin/tmdb/cache", userAdmin, func(c *gin.Context) {
+				c.JSON(http.StatusOK, a.tmdbCache.Stats())
+			})
+			protected.POST("/admin/tmdb/sync", userAdmin, a.syncH.Trigger)
+			protected.GET("/admin/tmdb/sync/runs", userAdmin, a.syncH.Runs)
+			protected.POST("/admin/imports", userAdmin, a.importH.Start)
+			protected.GET("/admin/imports", userAdmin, a.importH.List)
+			protected.GET("/admin/imports/:id", userAdmin, a.importH.Get)
+		}
+	}
+
+	r.NoRoute(func(c *gin.Context) {
+		c.File("./web/index.html")
+	})
+
+	return r
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/AlikhanF2006/Final_project/configs"
+	"github.com/AlikhanF2006/Final_project/internal/memory"
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/internal/sqlite"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+// repositories is one storage backend, as the services see it.
+type repositories struct {
+	movies     postgres.MovieRepo
+	reviews    postgres.ReviewRepo
+	users      postgres.UserRepo
+	roles      postgres.RoleRepo
+	tokens     postgres.TokenRepo
+	ratingJobs postgres.RatingJobRepo
+	syncRuns   postgres.SyncRunRepo
+	exports    postgres.ExportRepo
+	genres     postgres.GenreRepo
+	people     postgres.PersonRepo
+	watchlists postgres.WatchlistRepo
+	diary      postgres.DiaryRepo
+	lists      postgres.MovieListRepo
+	feed       postgres.FeedRepo
+}
+
+// openStorage sets up the backend cfg.Storage names. For Postgres and SQLite
+// it also keeps the handle in a.db, for the health check and shutdown.
+func (a *App) openStorage(ctx context.Context) (repositories, error) {
+	switch a.cfg.Storage {
+	case configs.StorageMemory:
+		store := memory.NewStore()
+		return repositories{
+			movies:     memory.NewMovieRepository(store),
+			reviews:    memory.NewReviewRepository(store),
+			users:      memory.NewUserRepository(store),
+			roles:      memory.NewRoleRepository(store),
+			tokens:     memory.NewTokenRepository(store),
+			ratingJobs: memory.NewRatingJobRepository(store),
+			syncRuns:   memory.NewSyncRunRepository(store),
+			exports:    memory.NewExportRepository(store),
+			genres:     memory.NewGenreRepository(store),
+			people:     memory.NewPersonRepository(store),
+			watchlists: memory.NewWatchlistRepository(store),
+			diary:      memory.NewDiaryRepository(store),
+			lists:      memory.NewMovieListRepository(store),
+			feed:       memory.NewFeedRepository(store),
+		}, nil
+
+	case configs.StorageSQLite:
+		database, err := sqlite.Open(ctx, a.cfg.SQLite)
+		if err != nil {
+			return repositories{}, fmt.Errorf("open database: %w", err)
+		}
+		a.db = database
+
+		return repositories{
+			movies:     sqlite.NewMovieRepository(database),
+			reviews:    sqlite.NewReviewRepository(database),
+			users:      sqlite.NewUserRepository(database),
+			roles:      sqlite.NewRoleRepository(database),
+			tokens:     sqlite.NewTokenRepository(database),
+			ratingJobs: sqlite.NewRatingJobRepository(database),
+			syncRuns:   sqlite.NewSyncRunRepository(database),
+			exports:    sqlite.NewExportRepository(database),
+			genres:     sqlite.NewGenreRepository(database),
+			people:     sqlite.NewPersonRepository(database),
+			watchlists: sqlite.NewWatchlistRepository(database),
+			diary:      sqlite.NewDiaryRepository(database),
+			lists:      sqlite.NewMovieListRepository(database),
+			feed:       sqlite.NewFeedRepository(database),
+		}, nil
+
+	case configs.StoragePostgres:
+		database, err := db.Connect(ctx, a.cfg.Database)
+		if err != nil {
+			return repositories{}, fmt.Errorf("connect to database: %w", err)
+		}
+		a.db = database
+
+		if a.cfg.Database.AutoMigrate {
+			migrator, err := db.NewMigrator(database)
+			if err != nil {
+				return repositories{}, fmt.Errorf("migrate: %w", err)
+			}
+			if err := migrateUp(ctx, migrator); err != nil {
+				return repositories{}, fmt.Errorf("migrate: %w", err)
+			}
+		}
+
+		return repositories{
+			movies:     postgres.NewMovieRepository(database),
+			reviews:    postgres.NewReviewRepository(database),
+			users:      postgres.NewUserRepository(database),
+			roles:      postgres.NewRoleRepository(database),
+			tokens:     postgres.NewTokenRepository(database),
+			ratingJobs: postgres.NewRatingJobRepository(database),
+			syncRuns:   postgres.NewSyncRunRepository(database),
+			exports:    postgres.NewExportRepository(database),
+			genres:     postgres.NewGenreRepository(database),
+			people:     postgres.NewPersonRepository(database),
+			watchlists: postgres.NewWatchlistRepository(database),
+			diary:      postgres.NewDiaryRepository(database),
+			lists:      postgres.NewMovieListRepository(database),
+			feed:       postgres.NewFeedRepository(database),
+		}, nil
+	}
+
+	return repositories{}, fmt.Errorf("unknown storage %q", a.cfg.Storage)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/AlikhanF2006/Final_project/configs"
+	"github.com/AlikhanF2006/Final_project/internal/auth"
+)
+
+// newTokenService turns the auth section of the config into a token
+// service. Without auth.keys the legacy jwt_secret becomes one HS256 key.
+func newTokenService(cfg configs.AuthConfig) (*auth.TokenService, error) {
+	tc := auth.TokenConfig{
+		Issuer:       cfg.Issuer,
+		Audience:     cfg.Audience,
+		TTL:          cfg.AccessTTL,
+		Leeway:       cfg.Leeway,
+		SigningKeyID: cfg.SigningKey,
+	}
+
+	if len(cfg.Keys) == 0 {
+		tc.SigningKeyID = "default"
+		tc.Keys = []auth.Key{{
+			ID:        "default",
+			Algorithm: auth.AlgHS256,
+			Secret:    []byte(cfg.JWTSecret),
+		}}
+		return auth.NewTokenService(tc)
+	}
+
+	for _, kc := range cfg.Keys {
+		if kc.Algorithm == auth.AlgHS256 {
+			tc.Keys = append(tc.Keys, auth.Key{
+				ID:        kc.ID,
+				Algorithm: kc.Algorithm,
+				Secret:    []byte(kc.Secret),
+			})
+			continue
+		}
+
+		if kc.KeyFile == "" {
+			return nil, fmt.Errorf("auth key %q: key_file is required for %s", kc.ID, kc.Algorithm)
+		}
+		k, err := auth.LoadKeyFile(kc.ID, kc.Algorithm, kc.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tc.Keys = append(tc.Keys, k)
+	}
+
+	return auth.NewTokenService(tc)
+}
@@ -1,45 +1,183 @@
 package configs
 
 import (
-	"log"
-	"os"
-
-	"gopkg.in/yaml.v3"
+	"time"
 )
 
-type Config struct {
-	Database struct {
-		URL string `yaml:"url"`
-	} `yaml:"database"`
+type DatabaseConfig struct {
+	URL               string        `yaml:"url"`
+	MaxConns          int32         `yaml:"max_conns"`
+	MinConns          int32         `yaml:"min_conns"`
+	MaxConnLifetime   time.Duration `yaml:"max_conn_lifetime"`
+	MaxConnIdleTime   time.Duration `yaml:"max_conn_idle_time"`
+	HealthCheckPeriod time.Duration `yaml:"health_check_period"`
+	ConnectTimeout    time.Duration `yaml:"connect_timeout"`
+	ConnectAttempts   int           `yaml:"connect_attempts"`
+	ConnectBackoff    time.Duration `yaml:"connect_backoff"`
+	AutoMigrate       bool          `yaml:"auto_migrate"`
+	// Per-operation query deadlines, applied on top of the request context.
+	ReadTimeout   time.Duration `yaml:"read_timeout"`
+	WriteTimeout  time.Duration `yaml:"write_timeout"`
+	SearchTimeout time.Duration `yaml:"search_timeout"`
+}
 
-	TMDB struct {
-		ApiKey string `yaml:"api_key"`
-	} `yaml:"tmdb"`
+type SQLiteConfig struct {
+	Path        string        `yaml:"path"`
+	BusyTimeout time.Duration `yaml:"busy_timeout"`
+}
 
-	Auth struct {
-		JWTSecret string `yaml:"jwt_secret"`
-	} `yaml:"auth"`
+type ServerConfig struct {
+	Addr              string        `yaml:"addr"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`
+	// ExportTimeout caps a whole export, which is not bound by WriteTimeout:
+	// each batch of rows gets WriteTimeout to reach the client instead.
+	ExportTimeout time.Duration `yaml:"export_timeout"`
 }
 
-var AppConfig Config
+type TMDBConfig struct {
+	ApiKey  string        `yaml:"api_key"`
+	BaseURL string        `yaml:"base_url"`
+	Timeout time.Duration `yaml:"timeout"`
+	// Failed requests (network errors, 429 and 5xx) are retried up to
+	// MaxRetries times with exponential backoff starting at RetryBackoff.
+	MaxRetries      int           `yaml:"max_retries"`
+	RetryBackoff    time.Duration `yaml:"retry_backoff"`
+	MaxRetryBackoff time.Duration `yaml:"max_retry_backoff"`
+	// RateLimit is the client-side limit in requests per second, with bursts
+	// of up to RateBurst requests.
+	RateLimit float64         `yaml:"rate_limit"`
+	RateBurst int             `yaml:"rate_burst"`
+	Cache     TMDBCacheConfig `yaml:"cache"`
+}
 
-func LoadConfig() {
-	file, err := os.ReadFile("configs/config.yaml")
-	if err != nil {
-		log.Fatal("cannot read config.yaml:", err)
-	}
+type TMDBCacheConfig struct {
+	MaxEntries int           `yaml:"max_entries"`
+	PopularTTL time.Duration `yaml:"popular_ttl"`
+	MovieTTL   time.Duration `yaml:"movie_ttl"`
+	StaleTTL   time.Duration `yaml:"stale_ttl"`
+}
 
-	if err := yaml.Unmarshal(file, &AppConfig); err != nil {
-		log.Fatal("cannot parse config.yaml:", err)
-	}
+type TMDBSyncConfig struct {
+	// Enabled schedules a sync at startup and every Interval. Admins can
+	// start one by hand either way.
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+	// Lists are the TMDB lists to copy: popular, now_playing, top_rated,
+	// upcoming.
+	Lists []string `yaml:"lists"`
+	// MaxPages limits the pages read per list; 0 reads every page.
+	MaxPages int `yaml:"max_pages"`
+}
 
-	if AppConfig.Database.URL == "" {
-		log.Fatal("database.url is empty")
-	}
-	if AppConfig.TMDB.ApiKey == "" {
-		log.Fatal("tmdb.api_key is empty")
-	}
-	if AppConfig.Auth.JWTSecret == "" {
-		log.Fatal("auth.jwt_secret is empty")
+type RatingWorkerConfig struct {
+	Workers      int           `yaml:"workers"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+}
+
+// Storage backends.
+const (
+	StoragePostgres = "postgres"
+	StorageSQLite   = "sqlite"
+	StorageMemory   = "memory"
+)
+
+type Config struct {
+	// Storage selects the repository backend; the database section is only
+	// used by StoragePostgres and the sqlite section by StorageSQLite.
+	Storage      string             `yaml:"storage"`
+	Server       ServerConfig       `yaml:"server"`
+	Database     DatabaseConfig     `yaml:"database"`
+	SQLite       SQLiteConfig       `yaml:"sqlite"`
+	TMDB         TMDBConfig         `yaml:"tmdb"`
+	TMDBSync     TMDBSyncConfig     `yaml:"tmdb_sync"`
+	Auth         AuthConfig         `yaml:"auth"`
+	RatingWorker RatingWorkerConfig `yaml:"rating_worker"`
+}
+
+type AuthConfig struct {
+	// JWTSecret is used as a single HS256 key (kid "default") when Keys is empty.
+	JWTSecret  string        `yaml:"jwt_secret"`
+	Issuer     string        `yaml:"issuer"`
+	Audience   string        `yaml:"audience"`
+	AccessTTL  time.Duration `yaml:"access_ttl"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl"`
+	Leeway     time.Duration `yaml:"leeway"`
+	SigningKey string        `yaml:"signing_key"`
+	Keys       []KeyConfig   `yaml:"keys"`
+}
+
+// KeyConfig is one token key. HS256 keys take Secret; RS256 and EdDSA keys
+// take KeyFile, a PEM private key (signing) or public key (verify only).
+type KeyConfig struct {
+	ID        string `yaml:"id"`
+	Algorithm string `yaml:"algorithm"`
+	Secret    string `yaml:"secret"`
+	KeyFile   string `yaml:"key_file"`
+}
+
+// Default returns the configuration every layer is applied on top of.
+func Default() Config {
+	return Config{
+		Storage: StoragePostgres,
+		Server: ServerConfig{
+			Addr:              ":8080",
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			ExportTimeout:     10 * time.Minute,
+			IdleTimeout:       120 * time.Second,
+			ShutdownTimeout:   20 * time.Second,
+		},
+		Database: DatabaseConfig{
+			MaxConns:          10,
+			MinConns:          1,
+			MaxConnLifetime:   time.Hour,
+			MaxConnIdleTime:   30 * time.Minute,
+			HealthCheckPeriod: time.Minute,
+			ConnectTimeout:    5 * time.Second,
+			ConnectAttempts:   5,
+			ConnectBackoff:    500 * time.Millisecond,
+			ReadTimeout:       3 * time.Second,
+			WriteTimeout:      5 * time.Second,
+			SearchTimeout:     5 * time.Second,
+		},
+		SQLite: SQLiteConfig{
+			Path:        "data/movies.db",
+			BusyTimeout: 5 * time.Second,
+		},
+		TMDB: TMDBConfig{
+			BaseURL:         "https://api.themoviedb.org",
+			Timeout:         10 * time.Second,
+			MaxRetries:      3,
+			RetryBackoff:    500 * time.Millisecond,
+			MaxRetryBackoff: 10 * time.Second,
+			RateLimit:       20,
+			RateBurst:       10,
+			Cache: TMDBCacheConfig{
+				MaxEntries: 1000,
+				PopularTTL: 10 * time.Minute,
+				MovieTTL:   24 * time.Hour,
+				StaleTTL:   24 * time.Hour,
+			},
+		},
+		TMDBSync: TMDBSyncConfig{
+			Enabled:  true,
+			Interval: 6 * time.Hour,
+			Lists:    []string{"popular", "now_playing", "top_rated", "upcoming"},
+		},
+		Auth: AuthConfig{
+			Issuer:     "movie-platform",
+			Audience:   "movie-platform-api",
+			AccessTTL:  15 * time.Minute,
+			RefreshTTL: 30 * 24 * time.Hour,
+		},
+		RatingWorker: RatingWorkerConfig{
+			Workers:      2,
+			PollInterval: 5 * time.Second,
+		},
 	}
 }
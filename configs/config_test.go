@@ -0,0 +1,410 @@
+package configs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func lookupIn(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dir := t.TempDir()
+
+	// Every layer sets server.addr; the other fields show that a layer only
+	// changes what it sets.
+	base := writeFile(t, dir, "base.yaml", `
+storage: sqlite
+server:
+  addr: ":1001"
+  read_timeout: 1s
+tmdb:
+  api_key: from-base
+auth:
+  jwt_secret: base-secret
+`)
+	writeFile(t, dir, "base.prod.yaml", `
+server:
+  addr: ":1002"
+  write_timeout: 2s
+`)
+	second := writeFile(t, dir, "second.yaml", `
+server:
+  addr: ":1003"
+  idle_timeout: 3s
+`)
+	writeFile(t, dir, "second.prod.yaml", `
+server:
+  addr: ":1004"
+`)
+	addrFile := writeFile(t, dir, "addr", ":1006\n")
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		check   func(t *testing.T, cfg Config)
+		addr    string
+		storage string
+	}{
+		{
+			name:    "file over defaults",
+			args:    []string{"-config", base},
+			addr:    ":1001",
+			storage: StorageSQLite,
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Server.ReadTimeout != time.Second {
+					t.Errorf("read_timeout: got %v, want the file's 1s", cfg.Server.ReadTimeout)
+				}
+				if want := Default().Server.WriteTimeout; cfg.Server.WriteTimeout != want {
+					t.Errorf("write_timeout: got %v, want the default %v", cfg.Server.WriteTimeout, want)
+				}
+			},
+		},
+		{
+			name:    "overlay over its file",
+			args:    []string{"-config", base, "-env", "prod"},
+			addr:    ":1002",
+			storage: StorageSQLite,
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Server.ReadTimeout != time.Second || cfg.Server.WriteTimeout != 2*time.Second {
+					t.Errorf("timeouts: got read %v, write %v, want 1s from the file and 2s from the overlay", cfg.Server.ReadTimeout, cfg.Server.WriteTimeout)
+				}
+			},
+		},
+		{
+			name:    "MOVIE_ENV picks the overlay",
+			args:    []string{"-config", base},
+			env:     map[string]string{"MOVIE_ENV": "prod"},
+			addr:    ":1002",
+			storage: StorageSQLite,
+		},
+		{
+			name:    "env flag over MOVIE_ENV",
+			args:    []string{"-config", base, "-env", "prod"},
+			env:     map[string]string{"MOVIE_ENV": "staging"},
+			addr:    ":1002",
+			storage: StorageSQLite,
+		},
+		{
+			name:    "later file over earlier file",
+			args:    []string{"-config", base, "-config", second},
+			addr:    ":1003",
+			storage: StorageSQLite,
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Server.ReadTimeout != time.Second || cfg.Server.IdleTimeout != 3*time.Second {
+					t.Errorf("timeouts: got read %v, idle %v, want 1s and 3s", cfg.Server.ReadTimeout, cfg.Server.IdleTimeout)
+				}
+			},
+		},
+		{
+			name:    "later file over earlier overlay",
+			args:    []string{"-config", base, "-config", second, "-env", "prod"},
+			addr:    ":1004",
+			storage: StorageSQLite,
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Server.WriteTimeout != 2*time.Second {
+					t.Errorf("write_timeout: got %v, want 2s from base.prod.yaml", cfg.Server.WriteTimeout)
+				}
+			},
+		},
+		{
+			name:    "MOVIE_CONFIG lists the files",
+			env:     map[string]string{"MOVIE_CONFIG": base + "," + second},
+			addr:    ":1003",
+			storage: StorageSQLite,
+		},
+		{
+			name:    "config flag over MOVIE_CONFIG",
+			args:    []string{"-config", base},
+			env:     map[string]string{"MOVIE_CONFIG": second},
+			addr:    ":1001",
+			storage: StorageSQLite,
+		},
+		{
+			name: "environment over files",
+			args: []string{"-config", base, "-env", "prod"},
+			env: map[string]string{
+				"MOVIE_SERVER_ADDR":            ":1005",
+				"MOVIE_STORAGE":                StorageMemory,
+				"MOVIE_SERVER_READ_TIMEOUT":    "7s",
+				"MOVIE_TMDB_CACHE_MAX_ENTRIES": "5",
+				"MOVIE_TMDB_RATE_LIMIT":        "2.5",
+				"MOVIE_TMDB_SYNC_ENABLED":      "false",
+			},
+			addr:    ":1005",
+			storage: StorageMemory,
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Server.ReadTimeout != 7*time.Second || cfg.TMDB.Cache.MaxEntries != 5 || cfg.TMDB.RateLimit != 2.5 || cfg.TMDBSync.Enabled {
+					t.Errorf("typed env values not applied: %+v %+v %+v", cfg.Server, cfg.TMDB, cfg.TMDBSync)
+				}
+			},
+		},
+		{
+			name:    "_FILE variant",
+			args:    []string{"-config", base},
+			env:     map[string]string{"MOVIE_SERVER_ADDR_FILE": addrFile},
+			addr:    ":1006",
+			storage: StorageSQLite,
+		},
+		{
+			name:    "plain variable over _FILE",
+			args:    []string{"-config", base},
+			env:     map[string]string{"MOVIE_SERVER_ADDR": ":1005", "MOVIE_SERVER_ADDR_FILE": addrFile},
+			addr:    ":1005",
+			storage: StorageSQLite,
+		},
+		{
+			name:    "storage flag over everything",
+			args:    []string{"-config", base, "-storage", StorageMemory},
+			env:     map[string]string{"MOVIE_STORAGE": StoragePostgres},
+			addr:    ":1001",
+			storage: StorageMemory,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _, err := load(tt.args, lookupIn(tt.env))
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if cfg.Server.Addr != tt.addr {
+				t.Errorf("server.addr: got %q, want %q", cfg.Server.Addr, tt.addr)
+			}
+			if cfg.Storage != tt.storage {
+				t.Errorf("storage: got %q, want %q", cfg.Storage, tt.storage)
+			}
+			if cfg.TMDB.ApiKey != "from-base" {
+				t.Errorf("tmdb.api_key: got %q, want the file's", cfg.TMDB.ApiKey)
+			}
+			if tt.check != nil {
+				tt.check(t, cfg)
+			}
+		})
+	}
+}
+
+func TestLoadReturnsRemainingArgs(t *testing.T) {
+	cfg := writeFile(t, t.TempDir(), "config.yaml", "storage: memory\ntmdb:\n  api_key: k\nauth:\n  jwt_secret: s\n")
+
+	_, rest, err := load([]string{"-config", cfg, "import", "--dry-run", "movies.csv"}, lookupIn(nil))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if want := []string{"import", "--dry-run", "movies.csv"}; !reflect.DeepEqual(rest, want) {
+		t.Errorf("remaining args: got %q, want %q", rest, want)
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	good := writeFile(t, dir, "good.yaml", "storage: memory\ntmdb:\n  api_key: k\nauth:\n  jwt_secret: s\n")
+	bad := writeFile(t, dir, "bad.yaml", "server: [unclosed\n")
+	writeFile(t, dir, "good.broken.yaml", "server:\n  read_timeout: soon\n")
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "missing explicit file", args: []string{"-config", filepath.Join(dir, "nope.yaml")}, wantErr: "nope.yaml"},
+		{name: "malformed yaml", args: []string{"-config", bad}, wantErr: "bad.yaml"},
+		{name: "malformed overlay", args: []string{"-config", good, "-env", "broken"}, wantErr: "good.broken.yaml"},
+		{name: "unknown flag", args: []string{"-config", good, "-verbose"}, wantErr: "verbose"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := load(tt.args, lookupIn(nil))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("load: got error %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// A missing overlay is fine: not every environment needs one.
+	if _, _, err := load([]string{"-config", good, "-env", "dev"}, lookupIn(nil)); err != nil {
+		t.Errorf("load with a missing overlay: %v", err)
+	}
+}
+
+// validConfig is Default plus the fields it leaves empty on purpose.
+func validConfig() Config {
+	cfg := Default()
+	cfg.Database.URL = "postgres://localhost/movies"
+	cfg.TMDB.ApiKey = "key"
+	cfg.Auth.JWTSecret = "secret"
+	return cfg
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatalf("valid config: %v", err)
+	}
+
+	cfg := validConfig()
+	cfg.Server.Addr = ""
+	cfg.Server.ReadTimeout = 0
+	cfg.Server.ExportTimeout = -time.Second
+	cfg.Database.URL = ""
+	cfg.Database.MaxConns = 0
+	cfg.Database.MinConns = -1
+	cfg.TMDB.ApiKey = ""
+	cfg.TMDB.BaseURL = "api.themoviedb.org"
+	cfg.TMDB.MaxRetries = -1
+	cfg.TMDB.RateLimit = 0
+	cfg.TMDB.RateBurst = 0
+	cfg.TMDB.Cache.MaxEntries = 0
+	cfg.TMDB.Cache.MovieTTL = 0
+	cfg.TMDB.Cache.StaleTTL = -time.Second
+	cfg.TMDBSync.Interval = 0
+	cfg.TMDBSync.Lists = []string{"popular", "trending"}
+	cfg.TMDBSync.MaxPages = 501
+	cfg.Auth.Keys = []KeyConfig{
+		{Algorithm: "HS256"},
+		{ID: "rsa", Algorithm: "RS256"},
+		{ID: "x", Algorithm: "ES256"},
+	}
+	cfg.Auth.Issuer = ""
+	cfg.Auth.Audience = ""
+	cfg.Auth.AccessTTL = time.Hour
+	cfg.Auth.RefreshTTL = time.Minute
+	cfg.RatingWorker.Workers = 0
+	cfg.RatingWorker.PollInterval = 0
+
+	want := []string{
+		"server.addr is empty",
+		"server.read_timeout must be positive",
+		"server.export_timeout must be positive",
+		"tmdb.cache.movie_ttl must be positive",
+		"database.url is empty",
+		"database.max_conns must be positive",
+		"database.min_conns must be between 0 and database.max_conns",
+		"tmdb.api_key is empty",
+		"tmdb.base_url must be an absolute URL",
+		"tmdb.max_retries must not be negative",
+		"tmdb.rate_limit must be positive",
+		"tmdb.rate_burst must be positive",
+		"tmdb.cache.max_entries must be positive",
+		"tmdb.cache.stale_ttl must not be negative",
+		"tmdb_sync.interval must be positive",
+		`tmdb_sync.lists[1] must be popular, now_playing, top_rated or upcoming, got "trending"`,
+		"tmdb_sync.max_pages must be between 0 and 500",
+		"auth.signing_key is required when auth.keys is set",
+		"auth.keys[0].id is empty",
+		"auth.keys[0].secret is required for HS256",
+		"auth.keys[1].key_file is required for RS256",
+		"auth.keys[2].algorithm must be HS256, RS256 or EdDSA",
+		"auth.issuer is empty",
+		"auth.audience is empty",
+		"auth.refresh_ttl must be longer than auth.access_ttl",
+		"rating_worker.workers must be positive",
+		"rating_worker.poll_interval must be positive",
+	}
+
+	var verr *ValidationError
+	if err := cfg.Validate(); !errors.As(err, &verr) {
+		t.Fatalf("Validate: got %v, want a *ValidationError", err)
+	}
+	if !reflect.DeepEqual(verr.Problems, want) {
+		t.Errorf("problems:\n got %q\nwant %q", verr.Problems, want)
+	}
+	for _, p := range want {
+		if !strings.Contains(verr.Error(), p) {
+			t.Errorf("Error() leaves out %q", p)
+		}
+	}
+}
+
+func TestValidateStorage(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		problem string
+	}{
+		{name: "unknown backend", modify: func(c *Config) { c.Storage = "mysql" }, problem: `storage must be postgres, sqlite or memory, got "mysql"`},
+		{name: "sqlite without a path", modify: func(c *Config) { c.Storage = StorageSQLite; c.SQLite.Path = "" }, problem: "sqlite.path is empty"},
+		{name: "no secret and no keys", modify: func(c *Config) { c.Auth.JWTSecret = "" }, problem: "auth.jwt_secret is empty and no auth.keys are configured"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(&cfg)
+			var verr *ValidationError
+			if err := cfg.Validate(); !errors.As(err, &verr) {
+				t.Fatalf("Validate: got %v, want a *ValidationError", err)
+			}
+			if !reflect.DeepEqual(verr.Problems, []string{tt.problem}) {
+				t.Errorf("problems: got %q, want %q", verr.Problems, tt.problem)
+			}
+		})
+	}
+
+	// Postgres settings do not matter to the other backends.
+	cfg := validConfig()
+	cfg.Storage = StorageMemory
+	cfg.Database.URL = ""
+	cfg.Database.MaxConns = 0
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("memory storage without database settings: %v", err)
+	}
+}
+
+func TestLoadReportsEnvAndConfigProblemsTogether(t *testing.T) {
+	dir := t.TempDir()
+	cfg := writeFile(t, dir, "config.yaml", "storage: sqlite\nserver:\n  addr: \"\"\n")
+
+	_, _, err := load([]string{"-config", cfg}, lookupIn(map[string]string{
+		"MOVIE_SERVER_READ_TIMEOUT":   "soon",
+		"MOVIE_TMDB_MAX_RETRIES":      "three",
+		"MOVIE_TMDB_SYNC_ENABLED":     "maybe",
+		"MOVIE_AUTH_JWT_SECRET_FILE":  filepath.Join(dir, "missing-secret"),
+		"MOVIE_RATING_WORKER_WORKERS": "0",
+	}))
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("load: got %v, want a *ValidationError", err)
+	}
+	for _, want := range []string{
+		"MOVIE_SERVER_READ_TIMEOUT:",
+		"MOVIE_TMDB_MAX_RETRIES:",
+		"MOVIE_TMDB_SYNC_ENABLED:",
+		"MOVIE_AUTH_JWT_SECRET_FILE:",
+		"server.addr is empty",
+		"tmdb.api_key is empty",
+		"auth.jwt_secret is empty and no auth.keys are configured",
+		"rating_worker.workers must be positive",
+	} {
+		found := false
+		for _, p := range verr.Problems {
+			if strings.HasPrefix(p, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("problems %q leave out %q", verr.Problems, want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyEnv overrides cfg from environment variables named after the YAML
+// path of each field: database.url is MOVIE_DATABASE_URL. If the variable is
+// unset, NAME_FILE is tried and the file's content (trimmed) is used. List
+// fields such as auth.keys can only be set from files. It returns one
+// problem per variable that could not be applied.
+func applyEnv(cfg *Config, prefix string, lookup func(string) (string, bool)) []string {
+	var problems []string
+	walkEnv(reflect.ValueOf(cfg).Elem(), strings.TrimSuffix(prefix, "_"), lookup, &problems)
+	return problems
+}
+
+func walkEnv(v reflect.Value, name string, lookup func(string) (string, bool), problems *[]string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		envName := name + "_" + strings.ToUpper(tag)
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Struct {
+			walkEnv(fv, envName, lookup, problems)
+			continue
+		}
+
+		raw, ok := lookup(envName)
+		if !ok {
+			path, fileOK := lookup(envName + "_FILE")
+			if !fileOK {
+				continue
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				*problems = append(*problems, fmt.Sprintf("%s_FILE: %v", envName, err))
+				continue
+			}
+			raw = strings.TrimSpace(string(data))
+		}
+
+		if err := setField(fv, raw); err != nil {
+			*problems = append(*problems, fmt.Sprintf("%s: %v", envName, err))
+		}
+	}
+}
+
+func setField(fv reflect.Value, raw string) error {
+	if fv.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	default:
+		return fmt.Errorf("cannot be set from the environment")
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package configs
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DefaultConfigFile = "configs/config.yaml"
+	EnvPrefix         = "MOVIE_"
+)
+
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// Load builds the configuration from, in increasing priority:
+//
+//  1. Default()
+//  2. each --config file in order (configs/config.yaml if none is given)
+//  3. for each of those files, the overlay <name>.<env>.yaml next to it,
+//     where env comes from --env or MOVIE_ENV
+//  4. MOVIE_* environment variables (MOVIE_DATABASE_URL, MOVIE_AUTH_JWT_SECRET,
+//     ...); MOVIE_*_FILE reads the value from a file, for mounted secrets
+//  5. the --storage flag
+//
+// args are the command line arguments without the program name; whatever
+// follows the flags (a subcommand) is returned. The result is validated and
+// every problem is reported in one *ValidationError.
+func Load(args []string) (Config, []string, error) {
+	return load(args, os.LookupEnv)
+}
+
+func load(args []string, lookup func(string) (string, bool)) (Config, []string, error) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	var files stringList
+	fs.Var(&files, "config", "config file, may be repeated; later files override earlier ones")
+	envName := fs.String("env", "", "environment overlay to apply (e.g. prod loads config.prod.yaml)")
+	storage := fs.String("storage", "", "storage backend: postgres, sqlite or memory")
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	if *envName == "" {
+		*envName, _ = lookup(EnvPrefix + "ENV")
+	}
+
+	explicit := len(files) > 0
+	if !explicit {
+		if v, ok := lookup(EnvPrefix + "CONFIG"); ok && v != "" {
+			files = stringList(strings.Split(v, ","))
+			explicit = true
+		} else {
+			files = stringList{DefaultConfigFile}
+		}
+	}
+
+	cfg := Default()
+
+	for _, file := range files {
+		if err := applyFile(&cfg, file, explicit); err != nil {
+			return Config{}, nil, err
+		}
+		if *envName != "" {
+			if err := applyFile(&cfg, overlayPath(file, *envName), false); err != nil {
+				return Config{}, nil, err
+			}
+		}
+	}
+
+	problems := applyEnv(&cfg, EnvPrefix, lookup)
+	if *storage != "" {
+		cfg.Storage = *storage
+	}
+	problems = append(problems, cfg.problems()...)
+	if len(problems) > 0 {
+		return Config{}, nil, &ValidationError{Problems: problems}
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+// applyFile merges one YAML file into cfg. Fields missing from the file keep
+// their current value. A missing file is an error only if required is set.
+func applyFile(cfg *Config, path string, required bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !required {
+			return nil
+		}
+		return fmt.Errorf("read config %s: %w", path, err)
+	}
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return nil
+}
+
+func overlayPath(path, env string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + env + ext
+}
@@ -0,0 +1,161 @@
+package configs
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ValidationError lists every problem found in a configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate checks the whole configuration and returns a *ValidationError
+// describing every problem, or nil.
+func (c Config) Validate() error {
+	if p := c.problems(); len(p) > 0 {
+		return &ValidationError{Problems: p}
+	}
+	return nil
+}
+
+func (c Config) problems() []string {
+	var p []string
+
+	if c.Server.Addr == "" {
+		p = append(p, "server.addr is empty")
+	}
+	for _, t := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.read_header_timeout", c.Server.ReadHeaderTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.export_timeout", c.Server.ExportTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+		{"database.read_timeout", c.Database.ReadTimeout},
+		{"database.write_timeout", c.Database.WriteTimeout},
+		{"database.search_timeout", c.Database.SearchTimeout},
+		{"tmdb.timeout", c.TMDB.Timeout},
+		{"tmdb.retry_backoff", c.TMDB.RetryBackoff},
+		{"tmdb.max_retry_backoff", c.TMDB.MaxRetryBackoff},
+		{"tmdb.cache.popular_ttl", c.TMDB.Cache.PopularTTL},
+		{"tmdb.cache.movie_ttl", c.TMDB.Cache.MovieTTL},
+	} {
+		if t.d <= 0 {
+			p = append(p, t.name+" must be positive")
+		}
+	}
+
+	switch c.Storage {
+	case StoragePostgres:
+		if c.Database.URL == "" {
+			p = append(p, "database.url is empty")
+		}
+		if c.Database.MaxConns <= 0 {
+			p = append(p, "database.max_conns must be positive")
+		}
+		if c.Database.MinConns < 0 || c.Database.MinConns > c.Database.MaxConns {
+			p = append(p, "database.min_conns must be between 0 and database.max_conns")
+		}
+	case StorageSQLite:
+		if c.SQLite.Path == "" {
+			p = append(p, "sqlite.path is empty")
+		}
+	case StorageMemory:
+	default:
+		p = append(p, fmt.Sprintf("storage must be %s, %s or %s, got %q", StoragePostgres, StorageSQLite, StorageMemory, c.Storage))
+	}
+
+	if c.TMDB.ApiKey == "" {
+		p = append(p, "tmdb.api_key is empty")
+	}
+	if u, err := url.Parse(c.TMDB.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		p = append(p, "tmdb.base_url must be an absolute URL")
+	}
+	if c.TMDB.MaxRetries < 0 {
+		p = append(p, "tmdb.max_retries must not be negative")
+	}
+	if c.TMDB.RateLimit <= 0 {
+		p = append(p, "tmdb.rate_limit must be positive")
+	}
+	if c.TMDB.RateBurst <= 0 {
+		p = append(p, "tmdb.rate_burst must be positive")
+	}
+	if c.TMDB.Cache.MaxEntries <= 0 {
+		p = append(p, "tmdb.cache.max_entries must be positive")
+	}
+	if c.TMDB.Cache.StaleTTL < 0 {
+		p = append(p, "tmdb.cache.stale_ttl must not be negative")
+	}
+
+	if c.TMDBSync.Enabled && c.TMDBSync.Interval <= 0 {
+		p = append(p, "tmdb_sync.interval must be positive")
+	}
+	if len(c.TMDBSync.Lists) == 0 {
+		p = append(p, "tmdb_sync.lists is empty")
+	}
+	for i, l := range c.TMDBSync.Lists {
+		switch l {
+		case "popular", "now_playing", "top_rated", "upcoming":
+		default:
+			p = append(p, fmt.Sprintf("tmdb_sync.lists[%d] must be popular, now_playing, top_rated or upcoming, got %q", i, l))
+		}
+	}
+	if c.TMDBSync.MaxPages < 0 || c.TMDBSync.MaxPages > 500 {
+		p = append(p, "tmdb_sync.max_pages must be between 0 and 500")
+	}
+
+	if c.Auth.JWTSecret == "" && len(c.Auth.Keys) == 0 {
+		p = append(p, "auth.jwt_secret is empty and no auth.keys are configured")
+	}
+	if len(c.Auth.Keys) > 0 && c.Auth.SigningKey == "" {
+		p = append(p, "auth.signing_key is required when auth.keys is set")
+	}
+	for i, k := range c.Auth.Keys {
+		if k.ID == "" {
+			p = append(p, fmt.Sprintf("auth.keys[%d].id is empty", i))
+		}
+		switch k.Algorithm {
+		case "HS256":
+			if k.Secret == "" {
+				p = append(p, fmt.Sprintf("auth.keys[%d].secret is required for HS256", i))
+			}
+		case "RS256", "EdDSA":
+			if k.KeyFile == "" {
+				p = append(p, fmt.Sprintf("auth.keys[%d].key_file is required for %s", i, k.Algorithm))
+			}
+		default:
+			p = append(p, fmt.Sprintf("auth.keys[%d].algorithm must be HS256, RS256 or EdDSA", i))
+		}
+	}
+	if c.Auth.Issuer == "" {
+		p = append(p, "auth.issuer is empty")
+	}
+	if c.Auth.Audience == "" {
+		p = append(p, "auth.audience is empty")
+	}
+	if c.Auth.AccessTTL <= 0 {
+		p = append(p, "auth.access_ttl must be positive")
+	}
+	if c.Auth.RefreshTTL <= c.Auth.AccessTTL {
+		p = append(p, "auth.refresh_ttl must be longer than auth.access_ttl")
+	}
+
+	if c.RatingWorker.Workers <= 0 {
+		p = append(p, "rating_worker.workers must be positive")
+	}
+	if c.RatingWorker.PollInterval <= 0 {
+		p = append(p, "rating_worker.poll_interval must be positive")
+	}
+
+	return p
+}
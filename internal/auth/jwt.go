@@ -2,56 +2,207 @@ package auth
 
 import (
 	"errors"
+	"fmt"
+	"sort"
 	"time"
 
-	"github.com/AlikhanF2006/Final_project/configs"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var ErrInvalidToken = errors.New("invalid token")
+var (
+	ErrInvalidToken   = errors.New("invalid token")
+	ErrSessionRevoked = errors.New("session revoked")
+)
 
-func GenerateToken(userID int) (string, error) {
-	secret := configs.AppConfig.Auth.JWTSecret
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(),
-		"iat":     time.Now().Unix(),
-	}
-	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return t.SignedString([]byte(secret))
+// Claims are the claims carried by an access token.
+type Claims struct {
+	UserID  int    `json:"user_id"`
+	Role    string `json:"role"`
+	Version int    `json:"ver"`
+	jwt.RegisteredClaims
 }
 
-func GenerateTokenWithRole(userID int, role string) (string, error) {
-	secret := configs.AppConfig.Auth.JWTSecret
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"role":    role,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(),
-		"iat":     time.Now().Unix(),
-	}
-	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return t.SignedString([]byte(secret))
+type AccessToken struct {
+	Token     string
+	ID        string
+	ExpiresAt time.Time
+}
+
+type TokenConfig struct {
+	Issuer   string
+	Audience string
+	TTL      time.Duration
+	// Leeway is the clock skew tolerated when checking exp, nbf and iat.
+	Leeway time.Duration
+	// SigningKeyID selects the key new tokens are signed with. Every other
+	// key is only used to verify tokens issued before a rotation.
+	SigningKeyID string
+	Keys         []Key
+}
+
+// TokenService issues and verifies access tokens. It is the only place that
+// knows about signing keys; everything else goes through Issue and Parse.
+type TokenService struct {
+	issuer   string
+	audience string
+	ttl      time.Duration
+	leeway   time.Duration
+	signing  Key
+	keys     map[string]Key
+	methods  []string
 }
 
-func ParseToken(tokenStr string) (int, string, error) {
-	secret := configs.AppConfig.Auth.JWTSecret
-	tkn, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
+func NewTokenService(cfg TokenConfig) (*TokenService, error) {
+	if cfg.Issuer == "" || cfg.Audience == "" {
+		return nil, errors.New("token issuer and audience are required")
+	}
+	if cfg.TTL <= 0 {
+		return nil, errors.New("token ttl must be positive")
+	}
+	if len(cfg.Keys) == 0 {
+		return nil, errors.New("no token keys configured")
+	}
+
+	s := &TokenService{
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+		ttl:      cfg.TTL,
+		leeway:   cfg.Leeway,
+		keys:     make(map[string]Key, len(cfg.Keys)),
+	}
+
+	seenAlg := make(map[string]bool)
+	for _, k := range cfg.Keys {
+		if err := k.validate(); err != nil {
+			return nil, err
+		}
+		if _, dup := s.keys[k.ID]; dup {
+			return nil, fmt.Errorf("duplicate key id %q", k.ID)
+		}
+		s.keys[k.ID] = k
+		if !seenAlg[k.Algorithm] {
+			seenAlg[k.Algorithm] = true
+			s.methods = append(s.methods, k.Algorithm)
 		}
-		return []byte(secret), nil
-	})
-	if err != nil || !tkn.Valid {
-		return 0, "", ErrInvalidToken
 	}
-	claims, ok := tkn.Claims.(jwt.MapClaims)
+
+	signing, ok := s.keys[cfg.SigningKeyID]
 	if !ok {
-		return 0, "", ErrInvalidToken
+		return nil, fmt.Errorf("signing key %q is not configured", cfg.SigningKeyID)
+	}
+	if !signing.canSign() {
+		return nil, fmt.Errorf("signing key %q has no private key", cfg.SigningKeyID)
+	}
+	s.signing = signing
+
+	return s, nil
+}
+
+// Issue signs an access token for the user. version must be the user's
+// current token_version; bumping it in the database invalidates every token
+// issued before.
+func (s *TokenService) Issue(userID int, role string, version int) (AccessToken, error) {
+	jti, err := NewTokenID()
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	now := time.Now()
+	exp := now.Add(s.ttl)
+
+	claims := Claims{
+		UserID:  userID,
+		Role:    role,
+		Version: version,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Issuer:    s.issuer,
+			Audience:  jwt.ClaimStrings{s.audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
 	}
-	uidFloat, ok := claims["user_id"].(float64)
+
+	t := jwt.NewWithClaims(jwt.GetSigningMethod(s.signing.Algorithm), claims)
+	t.Header["kid"] = s.signing.ID
+
+	var signKey any = s.signing.PrivateKey
+	if s.signing.Algorithm == AlgHS256 {
+		signKey = s.signing.Secret
+	}
+
+	signed, err := t.SignedString(signKey)
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	return AccessToken{Token: signed, ID: jti, ExpiresAt: exp}, nil
+}
+
+// Parse verifies the signature against the key named by the kid header and
+// checks alg, iss, aud, exp, nbf and iat.
+func (s *TokenService) Parse(tokenStr string) (*Claims, error) {
+	claims := &Claims{}
+
+	token, err := jwt.ParseWithClaims(
+		tokenStr,
+		claims,
+		s.keyFunc,
+		jwt.WithValidMethods(s.methods),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(s.audience),
+		jwt.WithLeeway(s.leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+func (s *TokenService) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys[kid]
 	if !ok {
-		return 0, "", ErrInvalidToken
+		return nil, ErrInvalidToken
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, ErrInvalidToken
+	}
+	if key.Algorithm == AlgHS256 {
+		return key.Secret, nil
+	}
+	return key.PublicKey, nil
+}
+
+// JWKS returns the public asymmetric keys so other services can verify our
+// tokens. HS256 keys are never published.
+func (s *TokenService) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, k := range s.keys {
+		if !k.asymmetric() {
+			continue
+		}
+		if jwk, ok := toJWK(k); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].KeyID < set.Keys[j].KeyID
+	})
+	return set
+}
+
+// HasPublicKeys reports whether any asymmetric key is configured, i.e.
+// whether a JWKS endpoint is worth publishing.
+func (s *TokenService) HasPublicKeys() bool {
+	for _, k := range s.keys {
+		if k.asymmetric() {
+			return true
+		}
 	}
-	role, _ := claims["role"].(string)
-	return int(uidFloat), role, nil
+	return false
 }
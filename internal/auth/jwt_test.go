@@ -0,0 +1,292 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "movies-test"
+	testAudience = "movies-test-api"
+)
+
+func newRSAKey(t *testing.T, id string) Key {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Key{ID: id, Algorithm: AlgRS256, PrivateKey: priv, PublicKey: priv.Public()}
+}
+
+func newEdKey(t *testing.T, id string) Key {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Key{ID: id, Algorithm: AlgEdDSA, PrivateKey: priv, PublicKey: pub}
+}
+
+// verifyOnly drops the private key, as for a key rotated out of signing.
+func verifyOnly(k Key) Key {
+	k.PrivateKey = nil
+	return k
+}
+
+func newTestService(t *testing.T, signing string, keys ...Key) *TokenService {
+	t.Helper()
+	s, err := NewTokenService(TokenConfig{
+		Issuer:       testIssuer,
+		Audience:     testAudience,
+		TTL:          time.Minute,
+		Leeway:       5 * time.Second,
+		SigningKeyID: signing,
+		Keys:         keys,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// validClaims are the claims Issue would produce, for tests that tamper with
+// one of them.
+func validClaims() Claims {
+	now := time.Now()
+	return Claims{
+		UserID:  7,
+		Role:    RoleUser,
+		Version: 1,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "jti",
+			Issuer:    testIssuer,
+			Audience:  jwt.ClaimStrings{testAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, claims Claims) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(method, claims)
+	tok.Header["kid"] = kid
+	signed, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestIssueParseRoundTrip(t *testing.T) {
+	keys := []Key{
+		{ID: "hs", Algorithm: AlgHS256, Secret: []byte("test-secret")},
+		newRSAKey(t, "rs"),
+		newEdKey(t, "ed"),
+	}
+
+	for _, k := range keys {
+		t.Run(k.Algorithm, func(t *testing.T) {
+			s := newTestService(t, k.ID, k)
+			tok, err := s.Issue(7, RoleAdmin, 3)
+			if err != nil {
+				t.Fatalf("Issue: %v", err)
+			}
+
+			claims, err := s.Parse(tok.Token)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if claims.UserID != 7 || claims.Role != RoleAdmin || claims.Version != 3 || claims.ID != tok.ID {
+				t.Errorf("claims: got %+v", claims)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	old := newRSAKey(t, "2024-01")
+	next := newEdKey(t, "2024-06")
+
+	before := newTestService(t, old.ID, old)
+	oldToken, err := before.Issue(7, RoleUser, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// During the rotation the old key only verifies.
+	rotating := newTestService(t, next.ID, verifyOnly(old), next)
+	if _, err := rotating.Parse(oldToken.Token); err != nil {
+		t.Errorf("token signed with the old key during rotation: %v", err)
+	}
+	newToken, err := rotating.Issue(7, RoleUser, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken.Token, &Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := parsed.Header["kid"]; kid != next.ID {
+		t.Errorf("new tokens signed with kid %v, want %s", kid, next.ID)
+	}
+	if _, err := rotating.Parse(newToken.Token); err != nil {
+		t.Errorf("token signed with the new key: %v", err)
+	}
+
+	// Once the old key is retired its tokens stop verifying.
+	retired := newTestService(t, next.ID, next)
+	if _, err := retired.Parse(oldToken.Token); err == nil {
+		t.Error("token signed with a retired key was accepted")
+	}
+	if _, err := retired.Parse(newToken.Token); err != nil {
+		t.Errorf("token signed with the new key after retirement: %v", err)
+	}
+}
+
+func TestNewTokenServiceRejectsVerifyOnlySigningKey(t *testing.T) {
+	k := newRSAKey(t, "rs")
+	_, err := NewTokenService(TokenConfig{
+		Issuer:       testIssuer,
+		Audience:     testAudience,
+		TTL:          time.Minute,
+		SigningKeyID: k.ID,
+		Keys:         []Key{verifyOnly(k)},
+	})
+	if err == nil {
+		t.Error("a public-only signing key was accepted")
+	}
+}
+
+func TestParseRejectsAlgorithmConfusion(t *testing.T) {
+	rs := newRSAKey(t, "rs")
+	hs := Key{ID: "hs", Algorithm: AlgHS256, Secret: []byte("test-secret")}
+	s := newTestService(t, rs.ID, rs, hs)
+
+	der, err := x509.MarshalPKIXPublicKey(rs.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"HS256 keyed with the RSA public key PEM", sign(t, jwt.SigningMethodHS256, rs.ID, pubPEM, validClaims())},
+		{"HS256 keyed with the RSA public key DER", sign(t, jwt.SigningMethodHS256, rs.ID, der, validClaims())},
+		{"alg none", sign(t, jwt.SigningMethodNone, rs.ID, jwt.UnsafeAllowNoneSignatureType, validClaims())},
+		{"alg none on an HS256 kid", sign(t, jwt.SigningMethodNone, hs.ID, jwt.UnsafeAllowNoneSignatureType, validClaims())},
+		{"RS256 token naming the HS256 kid", sign(t, jwt.SigningMethodRS256, hs.ID, rs.PrivateKey, validClaims())},
+		{"unknown kid", sign(t, jwt.SigningMethodRS256, "other", rs.PrivateKey, validClaims())},
+		{"no kid", sign(t, jwt.SigningMethodRS256, "", rs.PrivateKey, validClaims())},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.Parse(tt.token); err != ErrInvalidToken {
+				t.Errorf("Parse: got error %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestParseValidatesRegisteredClaims(t *testing.T) {
+	rs := newRSAKey(t, "rs")
+	s := newTestService(t, rs.ID, rs)
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		modify func(c *Claims)
+		valid  bool
+	}{
+		{"valid", func(c *Claims) {}, true},
+		{"wrong issuer", func(c *Claims) { c.Issuer = "someone-else" }, false},
+		{"missing issuer", func(c *Claims) { c.Issuer = "" }, false},
+		{"wrong audience", func(c *Claims) { c.Audience = jwt.ClaimStrings{"other-api"} }, false},
+		{"audience among others", func(c *Claims) { c.Audience = jwt.ClaimStrings{"other-api", testAudience} }, true},
+		{"missing audience", func(c *Claims) { c.Audience = nil }, false},
+		{"not yet valid", func(c *Claims) { c.NotBefore = jwt.NewNumericDate(now.Add(time.Minute)) }, false},
+		{"nbf within leeway", func(c *Claims) { c.NotBefore = jwt.NewNumericDate(now.Add(2 * time.Second)) }, true},
+		{"expired", func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute)) }, false},
+		{"expired within leeway", func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-2 * time.Second)) }, true},
+		{"missing exp", func(c *Claims) { c.ExpiresAt = nil }, false},
+		{"issued in the future", func(c *Claims) { c.IssuedAt = jwt.NewNumericDate(now.Add(time.Minute)) }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.modify(&claims)
+			_, err := s.Parse(sign(t, jwt.SigningMethodRS256, rs.ID, rs.PrivateKey, claims))
+			if tt.valid && err != nil {
+				t.Errorf("Parse: %v", err)
+			}
+			if !tt.valid && err != ErrInvalidToken {
+				t.Errorf("Parse: got error %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	rs := newRSAKey(t, "b-rsa")
+	ed := newEdKey(t, "a-ed")
+	hs := Key{ID: "c-hs", Algorithm: AlgHS256, Secret: []byte("test-secret")}
+	s := newTestService(t, hs.ID, hs, verifyOnly(rs), ed)
+
+	if !s.HasPublicKeys() {
+		t.Error("HasPublicKeys: got false with asymmetric keys configured")
+	}
+
+	set := s.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("JWKS: got %d keys, want 2 (HS256 must not be published): %+v", len(set.Keys), set.Keys)
+	}
+
+	b64 := base64.RawURLEncoding
+	got := set.Keys[0]
+	if got.KeyID != ed.ID || got.KeyType != "OKP" || got.Curve != "Ed25519" || got.Algorithm != AlgEdDSA || got.Use != "sig" {
+		t.Errorf("Ed25519 JWK: got %+v", got)
+	}
+	if x, err := b64.DecodeString(got.X); err != nil || !ed25519.PublicKey(x).Equal(ed.PublicKey) {
+		t.Errorf("Ed25519 JWK x does not match the public key (decode error %v)", err)
+	}
+
+	got = set.Keys[1]
+	if got.KeyID != rs.ID || got.KeyType != "RSA" || got.Algorithm != AlgRS256 || got.Use != "sig" {
+		t.Errorf("RSA JWK: got %+v", got)
+	}
+	n, errN := b64.DecodeString(got.N)
+	e, errE := b64.DecodeString(got.E)
+	if errN != nil || errE != nil {
+		t.Fatalf("RSA JWK: decode n: %v, e: %v", errN, errE)
+	}
+	pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	if !pub.Equal(rs.PublicKey) {
+		t.Error("RSA JWK n/e do not match the public key")
+	}
+	if got.Curve != "" || got.X != "" {
+		t.Errorf("RSA JWK carries OKP fields: %+v", got)
+	}
+
+	hsOnly := newTestService(t, hs.ID, hs)
+	if hsOnly.HasPublicKeys() {
+		t.Error("HasPublicKeys: got true with only an HS256 key")
+	}
+	if keys := hsOnly.JWKS().Keys; keys == nil || len(keys) != 0 {
+		t.Errorf("JWKS with only an HS256 key: got %#v, want an empty list", keys)
+	}
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// Key is one entry of the token key ring. HS256 keys use Secret; RS256 and
+// EdDSA keys use PublicKey for verification and, if this instance signs with
+// the key, PrivateKey.
+type Key struct {
+	ID         string
+	Algorithm  string
+	Secret     []byte
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+}
+
+func (k Key) asymmetric() bool {
+	return k.Algorithm == AlgRS256 || k.Algorithm == AlgEdDSA
+}
+
+func (k Key) canSign() bool {
+	if k.Algorithm == AlgHS256 {
+		return len(k.Secret) > 0
+	}
+	return k.PrivateKey != nil
+}
+
+func (k Key) validate() error {
+	if k.ID == "" {
+		return errors.New("key id is empty")
+	}
+
+	switch k.Algorithm {
+	case AlgHS256:
+		if len(k.Secret) == 0 {
+			return fmt.Errorf("key %s: HS256 key needs a secret", k.ID)
+		}
+	case AlgRS256:
+		if _, ok := k.PublicKey.(*rsa.PublicKey); !ok {
+			return fmt.Errorf("key %s: RS256 key needs an RSA key", k.ID)
+		}
+	case AlgEdDSA:
+		if _, ok := k.PublicKey.(ed25519.PublicKey); !ok {
+			return fmt.Errorf("key %s: EdDSA key needs an Ed25519 key", k.ID)
+		}
+	default:
+		return fmt.Errorf("key %s: unsupported algorithm %q", k.ID, k.Algorithm)
+	}
+
+	return nil
+}
+
+// LoadKeyFile reads a PEM file holding a PKCS#8/PKCS#1 private key or a PKIX
+// public key and returns an asymmetric Key. A public key gives a
+// verification-only key, used for keys rotated out of signing.
+func LoadKeyFile(id, alg, path string) (Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Key{}, err
+	}
+	return ParseKeyPEM(id, alg, data)
+}
+
+func ParseKeyPEM(id, alg string, data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, fmt.Errorf("key %s: no PEM block found", id)
+	}
+
+	k := Key{ID: id, Algorithm: alg}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("key %s: %w", id, err)
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return Key{}, fmt.Errorf("key %s: unsupported private key", id)
+		}
+		k.PrivateKey = signer
+		k.PublicKey = signer.Public()
+	case "RSA PRIVATE KEY":
+		parsed, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("key %s: %w", id, err)
+		}
+		k.PrivateKey = parsed
+		k.PublicKey = parsed.Public()
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("key %s: %w", id, err)
+		}
+		k.PublicKey = parsed
+	default:
+		return Key{}, fmt.Errorf("key %s: unsupported PEM block %q", id, block.Type)
+	}
+
+	return k, k.validate()
+}
+
+// JWK is the public part of a key as published in a JWKS document.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func toJWK(k Key) (JWK, bool) {
+	b64 := base64.RawURLEncoding
+
+	switch pub := k.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			KeyType:   "RSA",
+			KeyID:     k.ID,
+			Use:       "sig",
+			Algorithm: k.Algorithm,
+			N:         b64.EncodeToString(pub.N.Bytes()),
+			E:         b64.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return JWK{
+			KeyType:   "OKP",
+			KeyID:     k.ID,
+			Use:       "sig",
+			Algorithm: k.Algorithm,
+			Curve:     "Ed25519",
+			X:         b64.EncodeToString(pub),
+		}, true
+	}
+
+	return JWK{}, false
+}
@@ -0,0 +1,65 @@
+package auth
+
+import "sync"
+
+type Permission string
+
+const (
+	PermReviewWrite    Permission = "review:write"
+	PermReviewModerate Permission = "review:moderate"
+	PermMovieWrite     Permission = "movie:write"
+	PermUserAdmin      Permission = "user:admin"
+	PermDataExport     Permission = "data:export"
+)
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+	RoleAnalyst   = "analyst"
+)
+
+// Policy maps roles to the permissions they grant. It is loaded from the
+// role_permissions table at startup and can be reloaded at runtime.
+type Policy struct {
+	mu     sync.RWMutex
+	grants map[string]map[Permission]struct{}
+}
+
+func NewPolicy(grants map[string][]string) *Policy {
+	p := &Policy{}
+	p.Replace(grants)
+	return p
+}
+
+// Replace swaps in a new role → permissions table.
+func (p *Policy) Replace(grants map[string][]string) {
+	table := make(map[string]map[Permission]struct{}, len(grants))
+	for role, perms := range grants {
+		set := make(map[Permission]struct{}, len(perms))
+		for _, perm := range perms {
+			set[Permission(perm)] = struct{}{}
+		}
+		table[role] = set
+	}
+
+	p.mu.Lock()
+	p.grants = table
+	p.mu.Unlock()
+}
+
+func (p *Policy) Can(role string, perm Permission) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	_, ok := p.grants[role][perm]
+	return ok
+}
+
+func (p *Policy) HasRole(role string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	_, ok := p.grants[role]
+	return ok
+}
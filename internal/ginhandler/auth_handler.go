@@ -0,0 +1,81 @@
+package ginhandler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/AlikhanF2006/Final_project/internal/auth"
+	"github.com/AlikhanF2006/Final_project/internal/middleware"
+	"github.com/AlikhanF2006/Final_project/internal/postgres/dto"
+	"github.com/AlikhanF2006/Final_project/internal/service"
+)
+
+type AuthHandler struct {
+	svc    *service.AuthService
+	tokens *auth.TokenService
+}
+
+func NewAuthHandler(s *service.AuthService, tokens *auth.TokenService) *AuthHandler {
+	return &AuthHandler{svc: s, tokens: tokens}
+}
+
+func (h *AuthHandler) Login(c *gin.Context) {
+	var req dto.LoginDTO
+	if c.ShouldBindJSON(&req) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid data"})
+		return
+	}
+	tokens, err := h.svc.Login(c.Request.Context(), req)
+	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "wrong credentials"})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req dto.RefreshDTO
+	if c.ShouldBindJSON(&req) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid data"})
+		return
+	}
+	tokens, err := h.svc.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req dto.LogoutDTO
+	if c.Request.ContentLength > 0 && c.ShouldBindJSON(&req) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid data"})
+		return
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	jti := c.GetString(middleware.TokenIDKey)
+	expires := c.GetTime(middleware.TokenExpiresKey)
+
+	if err := h.svc.Logout(c.Request.Context(), userID, jti, expires, req.RefreshToken, req.All); err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot log out"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.tokens.JWKS())
+}
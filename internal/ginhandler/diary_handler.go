@@ -0,0 +1,182 @@
+package ginhandler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/AlikhanF2006/Final_project/internal/middleware"
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/internal/service"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+type DiaryHandler struct {
+	diarySvc *service.DiaryService
+}
+
+func NewDiaryHandler(diarySvc *service.DiaryService) *DiaryHandler {
+	return &DiaryHandler{diarySvc: diarySvc}
+}
+
+type logWatchRequest struct {
+	MovieID   int    `json:"movie_id"`
+	WatchedOn string `json:"watched_on"`
+	Rewatch   *bool  `json:"rewatch"`
+	Score     *int   `json:"score"`
+	Note      string `json:"note"`
+}
+
+type updateDiaryRequest struct {
+	WatchedOn *string `json:"watched_on"`
+	Rewatch   *bool   `json:"rewatch"`
+	Score     *int    `json:"score"`
+	Note      *string `json:"note"`
+}
+
+// List takes movie_id, from and to (YYYY-MM-DD) plus the list parameters.
+// Without a sort, the latest watches come first.
+func (h *DiaryHandler) List(c *gin.Context) {
+	opts, err := parseListOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if opts.Sort == "" {
+		opts.Sort = "watched"
+		opts.Desc = c.Query("order") != "asc"
+	}
+
+	f := model.DiaryFilter{From: c.Query("from"), To: c.Query("to")}
+	if s := c.Query("movie_id"); s != "" {
+		if f.MovieID, err = strconv.Atoi(s); err != nil || f.MovieID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid movie_id"})
+			return
+		}
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	entries, total, err := h.diarySvc.ListDiary(c.Request.Context(), userID, f, opts)
+	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, postgres.ErrBadSort):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrBadDiaryData):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be YYYY-MM-DD"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot list diary"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, newPage(entries, total, opts))
+}
+
+// Calendar counts watches per month and day; from and to default to the
+// current year.
+func (h *DiaryHandler) Calendar(c *gin.Context) {
+	userID := c.GetInt(middleware.UserIDKey)
+	cal, err := h.diarySvc.Calendar(c.Request.Context(), userID, c.Query("from"), c.Query("to"))
+	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrBadDiaryData) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be YYYY-MM-DD, in order and at most 10 years apart"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot build calendar"})
+		return
+	}
+
+	c.JSON(http.StatusOK, cal)
+}
+
+// Add logs a watch. watched_on defaults to today; without rewatch, the
+// entry is a rewatch if the movie was logged on or before that date.
+func (h *DiaryHandler) Add(c *gin.Context) {
+	var req logWatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+		return
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	e, err := h.diarySvc.LogWatch(c.Request.Context(), userID, model.DiaryEntry{
+		Movie:     model.Movie{ID: req.MovieID},
+		WatchedOn: req.WatchedOn,
+		Score:     req.Score,
+		Note:      req.Note,
+	}, req.Rewatch)
+	if err != nil {
+		writeDiaryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, e)
+}
+
+// Update changes the fields given; a score of 0 clears it.
+func (h *DiaryHandler) Update(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid entry id"})
+		return
+	}
+
+	var req updateDiaryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+		return
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	e, err := h.diarySvc.UpdateDiaryEntry(c.Request.Context(), userID, id, service.DiaryUpdate{
+		WatchedOn: req.WatchedOn,
+		Rewatch:   req.Rewatch,
+		Score:     req.Score,
+		Note:      req.Note,
+	})
+	if err != nil {
+		writeDiaryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, e)
+}
+
+func (h *DiaryHandler) Delete(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid entry id"})
+		return
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	if err := h.diarySvc.DeleteDiaryEntry(c.Request.Context(), userID, id); err != nil {
+		writeDiaryError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func writeDiaryError(c *gin.Context, err error) {
+	if writeDBContextError(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrBadDiaryData):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "give movie_id, watched_on as YYYY-MM-DD and not in the future, a score from 1 to 5 and a note of at most 1000 bytes"})
+	case errors.Is(err, postgres.ErrMovieNotFound),
+		errors.Is(err, postgres.ErrDiaryEntryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "diary update failed"})
+	}
+}
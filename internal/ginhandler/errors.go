@@ -0,0 +1,61 @@
+package ginhandler
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/AlikhanF2006/Final_project/internal/tmdb"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+// statusClientClosedRequest is the non-standard status (from nginx) logged
+// when the client went away before the response was ready.
+const statusClientClosedRequest = 499
+
+// writeDBContextError answers a database timeout with 504 and a cancelled
+// request with 499. It reports whether err was one of them, so handlers can
+// check it before mapping the remaining errors to not found and the like.
+func writeDBContextError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, db.ErrTimeout):
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "database timeout"})
+		return true
+	case errors.Is(err, db.ErrCanceled):
+		c.AbortWithStatus(statusClientClosedRequest)
+		return true
+	}
+	return false
+}
+
+// writeTMDBError maps TMDB client failures: a missing movie is 404, TMDB
+// being down or rejecting our token is 502 (neither is the caller's fault),
+// and TMDB rate limiting us is 503 with TMDB's Retry-After passed on. It
+// reports whether err was one of them.
+func writeTMDBError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, context.Canceled):
+		c.AbortWithStatus(statusClientClosedRequest)
+	case errors.Is(err, context.DeadlineExceeded):
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "tmdb timeout"})
+	case errors.Is(err, tmdb.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "movie not found on tmdb"})
+	case errors.Is(err, tmdb.ErrUnauthorized):
+		c.JSON(http.StatusBadGateway, gin.H{"error": "tmdb rejected the access token"})
+	case errors.Is(err, tmdb.ErrRateLimited):
+		var statusErr *tmdb.StatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(statusErr.RetryAfter.Seconds()))))
+		}
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "tmdb rate limit exceeded"})
+	case errors.Is(err, tmdb.ErrUnavailable):
+		c.JSON(http.StatusBadGateway, gin.H{"error": "tmdb unavailable"})
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,372 @@
+package ginhandler
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/internal/service"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+const (
+	exportCSV    = "text/csv"
+	exportJSON   = "application/json"
+	exportNDJSON = "application/x-ndjson"
+)
+
+// Trailers sent after every export: the number of rows written and, when the
+// export broke off, why.
+const (
+	trailerExportRows  = "X-Export-Rows"
+	trailerExportError = "X-Export-Error"
+)
+
+// exportBatchRows is how many rows are written between extensions of the
+// write deadline.
+const exportBatchRows = 500
+
+var exportFormats = map[string]string{
+	"csv":    exportCSV,
+	"json":   exportJSON,
+	"ndjson": exportNDJSON,
+}
+
+type ExportHandler struct {
+	exportSvc *service.ExportService
+	genreSvc  *service.GenreService
+	// Every exportBatchRows rows get writeTimeout to reach the client, and
+	// the whole export gets timeout.
+	writeTimeout time.Duration
+	timeout      time.Duration
+}
+
+func NewExportHandler(exportSvc *service.ExportService, genreSvc *service.GenreService, writeTimeout, timeout time.Duration) *ExportHandler {
+	return &ExportHandler{exportSvc: exportSvc, genreSvc: genreSvc, writeTimeout: writeTimeout, timeout: timeout}
+}
+
+type exportColumn[T any] struct {
+	name  string
+	value func(T) string
+}
+
+var movieExportColumns = []exportColumn[model.Movie]{
+	{"id", func(m model.Movie) string { return strconv.Itoa(m.ID) }},
+	{"tmdb_id", func(m model.Movie) string { return strconv.Itoa(m.TMDBID) }},
+	{"title", func(m model.Movie) string { return m.Title }},
+	{"year", func(m model.Movie) string { return strconv.Itoa(m.Year) }},
+	{"description", func(m model.Movie) string { return m.Description }},
+	{"rating", func(m model.Movie) string { return formatFloat(m.Rating) }},
+	{"genres", func(m model.Movie) string {
+		names := make([]string, len(m.Genres))
+		for i, g := range m.Genres {
+			names[i] = g.Name
+		}
+		return strings.Join(names, "|")
+	}},
+}
+
+var reviewExportColumns = []exportColumn[model.ExportedReview]{
+	{"id", func(r model.ExportedReview) string { return strconv.Itoa(r.ID) }},
+	{"movie_id", func(r model.ExportedReview) string { return strconv.Itoa(r.MovieID) }},
+	{"tmdb_id", func(r model.ExportedReview) string { return strconv.Itoa(r.TMDBID) }},
+	{"movie_title", func(r model.ExportedReview) string { return r.MovieTitle }},
+	{"user_id", func(r model.ExportedReview) string { return strconv.Itoa(r.UserID) }},
+	{"username", func(r model.ExportedReview) string { return r.Username }},
+	{"score", func(r model.ExportedReview) string { return strconv.Itoa(r.Score) }},
+	{"text", func(r model.ExportedReview) string { return r.Text }},
+	{"created_at", func(r model.ExportedReview) string { return r.CreatedAt.UTC().Format(time.RFC3339) }},
+}
+
+var ratingExportColumns = []exportColumn[model.MovieRating]{
+	{"movie_id", func(r model.MovieRating) string { return strconv.Itoa(r.MovieID) }},
+	{"tmdb_id", func(r model.MovieRating) string { return strconv.Itoa(r.TMDBID) }},
+	{"title", func(r model.MovieRating) string { return r.Title }},
+	{"year", func(r model.MovieRating) string { return strconv.Itoa(r.Year) }},
+	{"rating", func(r model.MovieRating) string { return formatFloat(r.Rating) }},
+	{"reviews", func(r model.MovieRating) string { return strconv.Itoa(r.Reviews) }},
+	{"average_score", func(r model.MovieRating) string { return formatFloat(r.AverageScore) }},
+	{"min_score", func(r model.MovieRating) string { return strconv.Itoa(r.MinScore) }},
+	{"max_score", func(r model.MovieRating) string { return strconv.Itoa(r.MaxScore) }},
+}
+
+func (h *ExportHandler) Movies(c *gin.Context) {
+	streamExport(c, h, "movies", movieExportColumns, func(ctx context.Context, f model.ExportFilter, fn func(model.Movie) error) error {
+		return h.exportSvc.Movies(ctx, f, fn)
+	})
+}
+
+func (h *ExportHandler) Reviews(c *gin.Context) {
+	streamExport(c, h, "reviews", reviewExportColumns, func(ctx context.Context, f model.ExportFilter, fn func(model.ExportedReview) error) error {
+		return h.exportSvc.Reviews(ctx, f, fn)
+	})
+}
+
+func (h *ExportHandler) Ratings(c *gin.Context) {
+	streamExport(c, h, "ratings", ratingExportColumns, func(ctx context.Context, f model.ExportFilter, fn func(model.MovieRating) error) error {
+		return h.exportSvc.Ratings(ctx, f, fn)
+	})
+}
+
+// streamExport writes the rows run produces as they come, in the format
+// asked for by ?format= or the Accept header. Until the first row is out,
+// errors get a normal status; after it, the export ends early with the error
+// in the X-Export-Error trailer and the connection is dropped, so a
+// truncated file cannot pass for a complete one.
+//
+// The export runs under h.timeout, and the server's write timeout is
+// replaced by one extended for each batch of rows, so a slow reader cannot
+// hold the export, and the database connection behind it, open for long.
+func streamExport[T any](
+	c *gin.Context,
+	h *ExportHandler,
+	name string,
+	columns []exportColumn[T],
+	run func(context.Context, model.ExportFilter, func(T) error) error,
+) {
+	contentType, ok := exportContentType(c)
+	if !ok {
+		return
+	}
+	f, err := parseExportFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if f.MovieFilter, ok = parseMovieFilter(c, h.genreSvc); !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+
+	w := &exportWriter[T]{
+		c:            c,
+		name:         name,
+		contentType:  contentType,
+		columns:      columns,
+		writeTimeout: h.writeTimeout,
+		deadline:     deadline,
+	}
+	err = run(ctx, f, w.write)
+	if err == nil {
+		err = w.finish()
+	}
+	if err == nil {
+		return
+	}
+
+	if !w.started {
+		if writeDBContextError(c, err) {
+			return
+		}
+		if errors.Is(err, postgres.ErrBadSort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "export failed"})
+		return
+	}
+
+	log.Printf("export %s: stopped after %d rows: %v", name, w.rows, err)
+	w.abort(err)
+}
+
+func exportContentType(c *gin.Context) (string, bool) {
+	if s := c.Query("format"); s != "" {
+		contentType, ok := exportFormats[s]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, json or ndjson"})
+		}
+		return contentType, ok
+	}
+
+	contentType := c.NegotiateFormat(exportJSON, exportCSV, exportNDJSON)
+	if contentType == "" {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": "exports are available as text/csv, application/json or application/x-ndjson"})
+		return "", false
+	}
+	return contentType, true
+}
+
+// parseExportFilter reads the movie search's q or title, sort and order, and
+// the export-only movie_id and user_id. The movie filter is parsed
+// separately.
+func parseExportFilter(c *gin.Context) (model.ExportFilter, error) {
+	opts, err := parseListOptions(c)
+	if err != nil {
+		return model.ExportFilter{}, err
+	}
+
+	f := model.ExportFilter{
+		Query: c.Query("q"),
+		Sort:  opts.Sort,
+		Desc:  opts.Desc,
+	}
+	if f.Query == "" {
+		f.Query = c.Query("title")
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{
+		{"movie_id", &f.MovieID},
+		{"user_id", &f.UserID},
+	} {
+		if s := c.Query(p.name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				return model.ExportFilter{}, fmt.Errorf("invalid %s", p.name)
+			}
+			*p.dst = n
+		}
+	}
+
+	return f, nil
+}
+
+type exportWriter[T any] struct {
+	c           *gin.Context
+	name        string
+	contentType string
+	columns     []exportColumn[T]
+
+	writeTimeout time.Duration
+	deadline     time.Time
+
+	started bool
+	rows    int
+	csv     *csv.Writer
+	json    *json.Encoder
+}
+
+// start sends the headers and whatever opens the document. It runs on the
+// first row, or at the end for an empty export, so that errors before any
+// data still get a proper status.
+func (w *exportWriter[T]) start() error {
+	w.started = true
+
+	w.extendDeadline()
+
+	ext := map[string]string{exportCSV: "csv", exportJSON: "json", exportNDJSON: "ndjson"}[w.contentType]
+	header := w.c.Writer.Header()
+	header.Set("Content-Type", w.contentType+"; charset=utf-8")
+	header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.%s"`, w.name, time.Now().UTC().Format("20060102"), ext))
+	header.Set("Trailer", trailerExportRows+", "+trailerExportError)
+	w.c.Status(http.StatusOK)
+
+	switch w.contentType {
+	case exportCSV:
+		w.csv = csv.NewWriter(w.c.Writer)
+		names := make([]string, len(w.columns))
+		for i, col := range w.columns {
+			names[i] = col.name
+		}
+		return w.csv.Write(names)
+	case exportJSON:
+		w.json = json.NewEncoder(w.c.Writer)
+		_, err := w.c.Writer.WriteString("[\n")
+		return err
+	default:
+		w.json = json.NewEncoder(w.c.Writer)
+		return nil
+	}
+}
+
+func (w *exportWriter[T]) write(row T) error {
+	if !w.started {
+		if err := w.start(); err != nil {
+			return err
+		}
+	} else if w.rows%exportBatchRows == 0 {
+		w.extendDeadline()
+	}
+
+	switch w.contentType {
+	case exportCSV:
+		record := make([]string, len(w.columns))
+		for i, col := range w.columns {
+			record[i] = col.value(row)
+		}
+		if err := w.csv.Write(record); err != nil {
+			return err
+		}
+	case exportJSON:
+		if w.rows > 0 {
+			if _, err := w.c.Writer.WriteString(","); err != nil {
+				return err
+			}
+		}
+		if err := w.json.Encode(row); err != nil {
+			return err
+		}
+	default:
+		if err := w.json.Encode(row); err != nil {
+			return err
+		}
+	}
+
+	w.rows++
+	return nil
+}
+
+func (w *exportWriter[T]) finish() error {
+	if !w.started {
+		if err := w.start(); err != nil {
+			return err
+		}
+	}
+
+	switch w.contentType {
+	case exportCSV:
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	case exportJSON:
+		if _, err := w.c.Writer.WriteString("]\n"); err != nil {
+			return err
+		}
+	}
+
+	w.c.Writer.Header().Set(trailerExportRows, strconv.Itoa(w.rows))
+	return nil
+}
+
+// extendDeadline gives the next batch of rows the server's write timeout to
+// reach the client, but never goes past the deadline of the whole export.
+func (w *exportWriter[T]) extendDeadline() {
+	deadline := time.Now().Add(w.writeTimeout)
+	if deadline.After(w.deadline) {
+		deadline = w.deadline
+	}
+	_ = http.NewResponseController(w.c.Writer).SetWriteDeadline(deadline)
+}
+
+// abort reports err in the trailer and drops the connection where the
+// protocol allows it.
+func (w *exportWriter[T]) abort(err error) {
+	header := w.c.Writer.Header()
+	header.Set(trailerExportRows, strconv.Itoa(w.rows))
+	header.Set(trailerExportError, err.Error())
+
+	if conn, _, hijackErr := w.c.Writer.Hijack(); hijackErr == nil {
+		conn.Close()
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
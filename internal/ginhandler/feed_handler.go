@@ -0,0 +1,153 @@
+package ginhandler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/AlikhanF2006/Final_project/internal/middleware"
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/internal/postgres/dto"
+	"github.com/AlikhanF2006/Final_project/internal/service"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+type FeedHandler struct {
+	feedSvc *service.FeedService
+}
+
+func NewFeedHandler(feedSvc *service.FeedService) *FeedHandler {
+	return &FeedHandler{feedSvc: feedSvc}
+}
+
+// Follow makes the caller follow the user; following again is a no-op.
+func (h *FeedHandler) Follow(c *gin.Context) {
+	id, ok := followUserID(c)
+	if !ok {
+		return
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	if err := h.feedSvc.Follow(c.Request.Context(), userID, id); err != nil {
+		writeFeedError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *FeedHandler) Unfollow(c *gin.Context) {
+	id, ok := followUserID(c)
+	if !ok {
+		return
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	if err := h.feedSvc.Unfollow(c.Request.Context(), userID, id); err != nil {
+		writeFeedError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Followers lists who follows the user, most recent follows first without
+// a sort.
+func (h *FeedHandler) Followers(c *gin.Context) {
+	h.follows(c, h.feedSvc.Followers)
+}
+
+// Following lists whom the user follows, most recent follows first without
+// a sort.
+func (h *FeedHandler) Following(c *gin.Context) {
+	h.follows(c, h.feedSvc.Following)
+}
+
+func (h *FeedHandler) follows(c *gin.Context, list func(ctx context.Context, userID int, opts model.ListOptions) ([]model.Follow, int, error)) {
+	id, ok := followUserID(c)
+	if !ok {
+		return
+	}
+	opts, err := parseListOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if opts.Sort == "" {
+		opts.Sort = "followed"
+		opts.Desc = c.Query("order") != "asc"
+	}
+
+	follows, total, err := list(c.Request.Context(), id, opts)
+	if err != nil {
+		writeFeedError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newPage(follows, total, opts))
+}
+
+// Feed returns the caller's feed, newest first. Its cursor marks the last
+// activity returned rather than an offset, so new activities do not shift
+// the pages that follow.
+func (h *FeedHandler) Feed(c *gin.Context) {
+	limit := model.DefaultPageLimit
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errBadPageParams.Error()})
+			return
+		}
+		limit = min(n, model.MaxPageLimit)
+	}
+	before := 0
+	if s := c.Query("cursor"); s != "" {
+		n, err := decodeCursor(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errBadPageParams.Error()})
+			return
+		}
+		before = n
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	activities, err := h.feedSvc.Feed(c.Request.Context(), userID, before, limit)
+	if err != nil {
+		writeFeedError(c, err)
+		return
+	}
+
+	resp := dto.FeedResponse[model.Activity]{Items: activities, Limit: limit}
+	if len(activities) == limit {
+		resp.NextCursor = encodeCursor(activities[len(activities)-1].ID)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func followUserID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return 0, false
+	}
+	return id, true
+}
+
+func writeFeedError(c *gin.Context, err error) {
+	if writeDBContextError(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrFollowSelf),
+		errors.Is(err, postgres.ErrBadSort):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, postgres.ErrUserNotFound),
+		errors.Is(err, postgres.ErrNotFollowing):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "feed request failed"})
+	}
+}
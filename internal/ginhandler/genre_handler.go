@@ -0,0 +1,153 @@
+package ginhandler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/internal/service"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+type GenreHandler struct {
+	genreSvc *service.GenreService
+}
+
+func NewGenreHandler(genreSvc *service.GenreService) *GenreHandler {
+	return &GenreHandler{genreSvc: genreSvc}
+}
+
+type genreRequest struct {
+	Name string `json:"name"`
+}
+
+func (h *GenreHandler) List(c *gin.Context) {
+	genres, err := h.genreSvc.ListGenres(c.Request.Context())
+	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot list genres"})
+		return
+	}
+
+	c.JSON(http.StatusOK, genres)
+}
+
+func (h *GenreHandler) Create(c *gin.Context) {
+	var req genreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+		return
+	}
+
+	g, err := h.genreSvc.CreateGenre(c.Request.Context(), req.Name)
+	if err != nil {
+		writeGenreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, g)
+}
+
+func (h *GenreHandler) Update(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var req genreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+		return
+	}
+
+	g, err := h.genreSvc.RenameGenre(c.Request.Context(), id, req.Name)
+	if err != nil {
+		writeGenreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, g)
+}
+
+func (h *GenreHandler) Delete(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if err := h.genreSvc.DeleteGenre(c.Request.Context(), id); err != nil {
+		writeGenreError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func writeGenreError(c *gin.Context, err error) {
+	if writeDBContextError(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrBadGenreData):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "genre name must be 1 to 50 characters"})
+	case errors.Is(err, postgres.ErrGenreNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, postgres.ErrGenreExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "genre update failed"})
+	}
+}
+
+// parseMovieFilter reads year, year_from, year_to and genre (an id or a
+// name) from the query string. On a bad value it writes the error response
+// and returns false.
+func parseMovieFilter(c *gin.Context, genreSvc *service.GenreService) (model.MovieFilter, bool) {
+	var f model.MovieFilter
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{
+		{"year", &f.Year},
+		{"year_from", &f.YearFrom},
+		{"year_to", &f.YearTo},
+	} {
+		if s := c.Query(p.name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + p.name})
+				return model.MovieFilter{}, false
+			}
+			*p.dst = n
+		}
+	}
+	if f.YearFrom != 0 && f.YearTo != 0 && f.YearFrom > f.YearTo {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "year_from is after year_to"})
+		return model.MovieFilter{}, false
+	}
+
+	if s := c.Query("genre"); s != "" {
+		g, err := genreSvc.ResolveGenre(c.Request.Context(), s)
+		if err != nil {
+			if writeDBContextError(c, err) {
+				return model.MovieFilter{}, false
+			}
+			if errors.Is(err, postgres.ErrGenreNotFound) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "unknown genre"})
+				return model.MovieFilter{}, false
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot look up genre"})
+			return model.MovieFilter{}, false
+		}
+		f.GenreID = g.ID
+	}
+
+	return f, true
+}
@@ -0,0 +1,147 @@
+package ginhandler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/AlikhanF2006/Final_project/internal/importfile"
+	"github.com/AlikhanF2006/Final_project/internal/service"
+)
+
+// maxImportBytes bounds an uploaded import file.
+const maxImportBytes = 10 << 20
+
+type ImportHandler struct {
+	importer *service.MovieImporter
+}
+
+func NewImportHandler(importer *service.MovieImporter) *ImportHandler {
+	return &ImportHandler{importer: importer}
+}
+
+// Start reads an import file from the request body, or from the "file" field
+// of a multipart form, and imports it in the background. The format comes
+// from ?format=, else from the file name or the Content-Type.
+func (h *ImportHandler) Start(c *gin.Context) {
+	dryRun := false
+	if s := c.Query("dry_run"); s != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(s); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid dry_run"})
+			return
+		}
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+
+	body, format, err := importBody(c)
+	if err != nil {
+		writeImportError(c, err)
+		return
+	}
+	defer body.Close()
+
+	entries, err := importfile.Parse(body, format)
+	if err != nil {
+		writeImportError(c, err)
+		return
+	}
+	if len(entries) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "import file has no entries"})
+		return
+	}
+
+	job, err := h.importer.Start(entries, service.ImportOptions{Format: format, DryRun: dryRun})
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/api/admin/imports/%d", job.ID))
+	c.JSON(http.StatusAccepted, job)
+}
+
+func (h *ImportHandler) List(c *gin.Context) {
+	opts, err := parseListOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	jobs, total := h.importer.List(opts)
+	c.JSON(http.StatusOK, newPage(jobs, total, opts))
+}
+
+// Get returns an import with the outcome of every row so far.
+func (h *ImportHandler) Get(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	job, err := h.importer.Get(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// importBody picks the file out of the request and works out its format.
+func importBody(c *gin.Context) (io.ReadCloser, importfile.Format, error) {
+	var format importfile.Format
+	if s := c.Query("format"); s != "" {
+		f, err := importfile.ParseFormat(s)
+		if err != nil {
+			return nil, "", err
+		}
+		format = f
+	}
+
+	contentType := c.ContentType()
+	if strings.HasPrefix(contentType, "multipart/") {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return nil, "", fmt.Errorf("read form file: %w", err)
+		}
+		if format == "" {
+			if format, err = importfile.FormatFromName(fh.Filename); err != nil {
+				return nil, "", err
+			}
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return nil, "", fmt.Errorf("read form file: %w", err)
+		}
+		return f, format, nil
+	}
+
+	if format == "" {
+		f, err := importfile.FormatFromContentType(contentType)
+		if err != nil {
+			return nil, "", err
+		}
+		format = f
+	}
+	return c.Request.Body, format, nil
+}
+
+func writeImportError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge), errors.Is(err, importfile.ErrTooManyEntries):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, importfile.ErrUnknownFormat):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,394 @@
+package ginhandler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/AlikhanF2006/Final_project/internal/middleware"
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/internal/service"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+type MovieListHandler struct {
+	listSvc *service.MovieListService
+}
+
+func NewMovieListHandler(listSvc *service.MovieListService) *MovieListHandler {
+	return &MovieListHandler{listSvc: listSvc}
+}
+
+type createListRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Visibility  string `json:"visibility"`
+}
+
+type updateListRequest struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	Visibility  *string `json:"visibility"`
+}
+
+type addListEntryRequest struct {
+	MovieID int    `json:"movie_id"`
+	Comment string `json:"comment"`
+}
+
+type updateListEntryRequest struct {
+	Comment  *string `json:"comment"`
+	Position *int    `json:"position"`
+}
+
+// Mine lists the caller's own lists and those they collaborate on. Without
+// a sort, the most recently updated come first.
+func (h *MovieListHandler) Mine(c *gin.Context) {
+	opts, ok := parseMovieListOptions(c)
+	if !ok {
+		return
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	lists, total, err := h.listSvc.MyLists(c.Request.Context(), userID, opts)
+	if err != nil {
+		writeListPageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newPage(lists, total, opts))
+}
+
+// ForMovie lists the public lists a movie appears on, most recently
+// updated first without a sort.
+func (h *MovieListHandler) ForMovie(c *gin.Context) {
+	movieID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid movie id"})
+		return
+	}
+	opts, ok := parseMovieListOptions(c)
+	if !ok {
+		return
+	}
+
+	lists, total, err := h.listSvc.ListsWithMovie(c.Request.Context(), movieID, opts)
+	if err != nil {
+		writeListPageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newPage(lists, total, opts))
+}
+
+func (h *MovieListHandler) Get(c *gin.Context) {
+	id, ok := listID(c)
+	if !ok {
+		return
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	l, err := h.listSvc.GetList(c.Request.Context(), userID, id)
+	if err != nil {
+		writeListError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, l)
+}
+
+// Create makes a list for the caller; visibility defaults to private.
+func (h *MovieListHandler) Create(c *gin.Context) {
+	var req createListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+		return
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	l, err := h.listSvc.CreateList(c.Request.Context(), userID, model.MovieList{
+		Title:       req.Title,
+		Description: req.Description,
+		Visibility:  req.Visibility,
+	})
+	if err != nil {
+		writeListError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, l)
+}
+
+// Update changes the fields given; only the owner may.
+func (h *MovieListHandler) Update(c *gin.Context) {
+	id, ok := listID(c)
+	if !ok {
+		return
+	}
+
+	var req updateListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+		return
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	l, err := h.listSvc.UpdateList(c.Request.Context(), userID, id, service.ListUpdate{
+		Title:       req.Title,
+		Description: req.Description,
+		Visibility:  req.Visibility,
+	})
+	if err != nil {
+		writeListError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, l)
+}
+
+func (h *MovieListHandler) Delete(c *gin.Context) {
+	id, ok := listID(c)
+	if !ok {
+		return
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	if err := h.listSvc.DeleteList(c.Request.Context(), userID, id); err != nil {
+		writeListError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Clone copies a list the caller can read into a new list of theirs. The
+// body is optional; title and description default to the source's and
+// visibility to private.
+func (h *MovieListHandler) Clone(c *gin.Context) {
+	id, ok := listID(c)
+	if !ok {
+		return
+	}
+
+	var req createListRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	l, err := h.listSvc.CloneList(c.Request.Context(), userID, id, model.MovieList{
+		Title:       req.Title,
+		Description: req.Description,
+		Visibility:  req.Visibility,
+	})
+	if err != nil {
+		writeListError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, l)
+}
+
+// AddCollaborator lets a user edit the list's entries; only the owner may
+// add one. Adding the same user again is a no-op.
+func (h *MovieListHandler) AddCollaborator(c *gin.Context) {
+	id, ok := listID(c)
+	if !ok {
+		return
+	}
+	collaboratorID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	l, err := h.listSvc.AddCollaborator(c.Request.Context(), userID, id, collaboratorID)
+	if err != nil {
+		writeListError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, l)
+}
+
+// RemoveCollaborator is for the owner, or for collaborators removing
+// themselves.
+func (h *MovieListHandler) RemoveCollaborator(c *gin.Context) {
+	id, ok := listID(c)
+	if !ok {
+		return
+	}
+	collaboratorID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	if err := h.listSvc.RemoveCollaborator(c.Request.Context(), userID, id, collaboratorID); err != nil {
+		writeListError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Entries lists a list's movies, in list order without a sort.
+func (h *MovieListHandler) Entries(c *gin.Context) {
+	id, ok := listID(c)
+	if !ok {
+		return
+	}
+	opts, err := parseListOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	entries, total, err := h.listSvc.ListEntries(c.Request.Context(), userID, id, opts)
+	if err != nil {
+		writeListPageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newPage(entries, total, opts))
+}
+
+// AddEntry puts a movie at the end of the list.
+func (h *MovieListHandler) AddEntry(c *gin.Context) {
+	id, ok := listID(c)
+	if !ok {
+		return
+	}
+
+	var req addListEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+		return
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	e, err := h.listSvc.AddEntry(c.Request.Context(), userID, id, model.ListEntry{
+		Movie:   model.Movie{ID: req.MovieID},
+		Comment: req.Comment,
+	})
+	if err != nil {
+		writeListError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, e)
+}
+
+// UpdateEntry changes the comment or the position of an entry; fields left
+// out stay as they are.
+func (h *MovieListHandler) UpdateEntry(c *gin.Context) {
+	id, ok := listID(c)
+	if !ok {
+		return
+	}
+	movieID, err := strconv.Atoi(c.Param("movie_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid movie id"})
+		return
+	}
+
+	var req updateListEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+		return
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	e, err := h.listSvc.UpdateEntry(c.Request.Context(), userID, id, movieID, service.ListEntryUpdate{
+		Comment:  req.Comment,
+		Position: req.Position,
+	})
+	if err != nil {
+		writeListError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, e)
+}
+
+func (h *MovieListHandler) RemoveEntry(c *gin.Context) {
+	id, ok := listID(c)
+	if !ok {
+		return
+	}
+	movieID, err := strconv.Atoi(c.Param("movie_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid movie id"})
+		return
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	if err := h.listSvc.RemoveEntry(c.Request.Context(), userID, id, movieID); err != nil {
+		writeListError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// parseMovieListOptions is parseListOptions with lists sorted by last
+// update, newest first, by default. It answers 400 itself.
+func parseMovieListOptions(c *gin.Context) (model.ListOptions, bool) {
+	opts, err := parseListOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return opts, false
+	}
+	if opts.Sort == "" {
+		opts.Sort = "updated"
+		opts.Desc = c.Query("order") != "asc"
+	}
+	return opts, true
+}
+
+func listID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid list id"})
+		return 0, false
+	}
+	return id, true
+}
+
+func writeListPageError(c *gin.Context, err error) {
+	if errors.Is(err, postgres.ErrBadSort) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	writeListError(c, err)
+}
+
+func writeListError(c *gin.Context, err error) {
+	if writeDBContextError(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrBadListData):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "give a title of 1 to 200 bytes, a description of at most 2000, visibility public, unlisted or private, movie_id, a comment of at most 1000 bytes and a position from 1; the owner cannot be a collaborator"})
+	case errors.Is(err, service.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to change this list"})
+	case errors.Is(err, postgres.ErrListNotFound),
+		errors.Is(err, postgres.ErrListEntryNotFound),
+		errors.Is(err, postgres.ErrCollaboratorNotFound),
+		errors.Is(err, postgres.ErrMovieNotFound),
+		errors.Is(err, postgres.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, postgres.ErrListEntryExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list request failed"})
+	}
+}
@@ -1,21 +1,24 @@
 package ginhandler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
 	"github.com/AlikhanF2006/Final_project/internal/service"
 	"github.com/AlikhanF2006/Final_project/model"
 )
 
 type MovieHandler struct {
 	movieSvc *service.MovieService
+	genreSvc *service.GenreService
 }
 
-func NewMovieHandler(movieSvc *service.MovieService) *MovieHandler {
-	return &MovieHandler{movieSvc: movieSvc}
+func NewMovieHandler(movieSvc *service.MovieService, genreSvc *service.GenreService) *MovieHandler {
+	return &MovieHandler{movieSvc: movieSvc, genreSvc: genreSvc}
 }
 
 func (h *MovieHandler) CreateMovie(c *gin.Context) {
@@ -25,8 +28,15 @@ func (h *MovieHandler) CreateMovie(c *gin.Context) {
 		return
 	}
 
-	created, err := h.movieSvc.CreateMovie(req)
+	created, err := h.movieSvc.CreateMovie(c.Request.Context(), req)
 	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
+		if errors.Is(err, postgres.ErrMovieExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -35,7 +45,30 @@ func (h *MovieHandler) CreateMovie(c *gin.Context) {
 }
 
 func (h *MovieHandler) GetMovies(c *gin.Context) {
-	c.JSON(http.StatusOK, h.movieSvc.ListMovies())
+	opts, err := parseListOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	f, ok := parseMovieFilter(c, h.genreSvc)
+	if !ok {
+		return
+	}
+
+	movies, total, err := h.movieSvc.ListMovies(c.Request.Context(), f, opts)
+	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
+		if errors.Is(err, postgres.ErrBadSort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot list movies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newPage(movies, total, opts))
 }
 
 func (h *MovieHandler) GetMovieByID(c *gin.Context) {
@@ -45,8 +78,11 @@ func (h *MovieHandler) GetMovieByID(c *gin.Context) {
 		return
 	}
 
-	m, err := h.movieSvc.GetMovie(id)
+	m, err := h.movieSvc.GetMovieDetail(c.Request.Context(), id)
 	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
 		c.JSON(http.StatusNotFound, gin.H{"error": "movie not found"})
 		return
 	}
@@ -67,8 +103,11 @@ func (h *MovieHandler) UpdateMovie(c *gin.Context) {
 		return
 	}
 
-	updated, err := h.movieSvc.UpdateMovie(id, req)
+	updated, err := h.movieSvc.UpdateMovie(c.Request.Context(), id, req)
 	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
@@ -76,6 +115,62 @@ func (h *MovieHandler) UpdateMovie(c *gin.Context) {
 	c.JSON(http.StatusOK, updated)
 }
 
+func (h *MovieHandler) ClearOverrides(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	updated, err := h.movieSvc.ClearOverrides(c.Request.Context(), id)
+	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+type movieGenresRequest struct {
+	GenreIDs []int `json:"genre_ids"`
+}
+
+// SetGenres replaces the movie's genres with the ones listed.
+func (h *MovieHandler) SetGenres(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var req movieGenresRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.GenreIDs == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "genre_ids is required"})
+		return
+	}
+
+	updated, err := h.movieSvc.SetGenres(c.Request.Context(), id, req.GenreIDs)
+	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, postgres.ErrMovieNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, postgres.ErrGenreNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown genre id"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot set genres"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
 func (h *MovieHandler) DeleteMovie(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -83,7 +178,10 @@ func (h *MovieHandler) DeleteMovie(c *gin.Context) {
 		return
 	}
 
-	if err := h.movieSvc.DeleteMovie(id); err != nil {
+	if err := h.movieSvc.DeleteMovie(c.Request.Context(), id); err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
@@ -92,8 +190,11 @@ func (h *MovieHandler) DeleteMovie(c *gin.Context) {
 }
 
 func (h *MovieHandler) GetPopularFromTMDB(c *gin.Context) {
-	movies, err := h.movieSvc.GetPopularFromTMDB()
+	movies, err := h.movieSvc.GetPopularFromTMDB(c.Request.Context())
 	if err != nil {
+		if writeDBContextError(c, err) || writeTMDBError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -108,8 +209,11 @@ func (h *MovieHandler) GetMovieFromTMDB(c *gin.Context) {
 		return
 	}
 
-	result, err := h.movieSvc.GetMovieWithTrailer(tmdbID)
+	result, err := h.movieSvc.GetMovieWithTrailer(c.Request.Context(), tmdbID)
 	if err != nil {
+		if writeTMDBError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "tmdb error"})
 		return
 	}
@@ -122,21 +226,34 @@ func (h *MovieHandler) GetMovieWithTrailer(c *gin.Context) {
 }
 
 func (h *MovieHandler) Search(c *gin.Context) {
-	title := c.Query("title")
+	// "title" is the pre-full-text name of the parameter and is still accepted.
+	query := c.Query("q")
+	if query == "" {
+		query = c.Query("title")
+	}
 
-	yearStr := c.Query("year")
-	year := 0
-	if yearStr != "" {
-		if y, err := strconv.Atoi(yearStr); err == nil {
-			year = y
-		}
+	opts, err := parseListOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	f, ok := parseMovieFilter(c, h.genreSvc)
+	if !ok {
+		return
 	}
 
-	movies, err := h.movieSvc.SearchMovies(title, year)
+	movies, total, err := h.movieSvc.SearchMovies(c.Request.Context(), query, f, opts)
 	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
+		if errors.Is(err, postgres.ErrBadSort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "search failed"})
 		return
 	}
 
-	c.JSON(http.StatusOK, movies)
+	c.JSON(http.StatusOK, newPage(movies, total, opts))
 }
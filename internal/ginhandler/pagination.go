@@ -0,0 +1,100 @@
+package ginhandler
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres/dto"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+var errBadPageParams = errors.New("invalid pagination parameters")
+
+// parseListOptions reads limit, offset or cursor, sort and order from the
+// query string. Without an explicit order, title and name sort A-Z,
+// position and planned sort first to last, and every other key sorts
+// newest/highest first.
+func parseListOptions(c *gin.Context) (model.ListOptions, error) {
+	opts := model.ListOptions{
+		Limit: model.DefaultPageLimit,
+		Sort:  c.Query("sort"),
+	}
+
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return opts, errBadPageParams
+		}
+		opts.Limit = min(n, model.MaxPageLimit)
+	}
+
+	if s := c.Query("cursor"); s != "" {
+		n, err := decodeCursor(s)
+		if err != nil {
+			return opts, errBadPageParams
+		}
+		opts.Offset = n
+	} else if s := c.Query("offset"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return opts, errBadPageParams
+		}
+		opts.Offset = n
+	}
+
+	switch c.Query("order") {
+	case "asc":
+		opts.Desc = false
+	case "desc":
+		opts.Desc = true
+	case "":
+		switch opts.Sort {
+		case "", "title", "name", "position", "planned":
+			opts.Desc = false
+		default:
+			opts.Desc = true
+		}
+	default:
+		return opts, errBadPageParams
+	}
+
+	return opts, nil
+}
+
+func newPage[T any](items []T, total int, opts model.ListOptions) dto.PageResponse[T] {
+	if items == nil {
+		items = []T{}
+	}
+
+	page := dto.PageResponse[T]{
+		Items:  items,
+		Total:  total,
+		Limit:  opts.Limit,
+		Offset: opts.Offset,
+	}
+
+	if next := opts.Offset + len(items); len(items) > 0 && next < total {
+		page.NextCursor = encodeCursor(next)
+	}
+
+	return page
+}
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(string(raw))
+	if err != nil || n < 0 {
+		return 0, errBadPageParams
+	}
+	return n, nil
+}
@@ -0,0 +1,194 @@
+package ginhandler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	for _, offset := range []int{0, 1, 20, 99, 1 << 40} {
+		cursor := encodeCursor(offset)
+		if strings.ContainsAny(cursor, "+/=") {
+			t.Errorf("cursor %q for %d is not URL safe", cursor, offset)
+		}
+		got, err := decodeCursor(cursor)
+		if err != nil || got != offset {
+			t.Errorf("decodeCursor(encodeCursor(%d)): got %d, error %v", offset, got, err)
+		}
+	}
+}
+
+func TestDecodeCursorRejectsTampering(t *testing.T) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "!!!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte("20"))},
+		{"standard alphabet", base64.RawStdEncoding.EncodeToString([]byte{0xfb, 0xff})},
+		{"negative offset", b64([]byte("-1"))},
+		{"not a number", b64([]byte("abc"))},
+		{"fraction", b64([]byte("1.5"))},
+		{"surrounding space", b64([]byte(" 20"))},
+		{"overflow", b64([]byte("99999999999999999999999"))},
+		{"empty payload", b64(nil)},
+		{"truncated", encodeCursor(12345)[:5]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if n, err := decodeCursor(tt.cursor); err == nil {
+				t.Errorf("decodeCursor(%q): got %d, want an error", tt.cursor, n)
+			}
+		})
+	}
+}
+
+func TestParseListOptions(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    model.ListOptions
+		wantErr bool
+	}{
+		{query: "", want: model.ListOptions{Limit: model.DefaultPageLimit}},
+		{query: "limit=5&offset=10", want: model.ListOptions{Limit: 5, Offset: 10}},
+		{query: "limit=1000", want: model.ListOptions{Limit: model.MaxPageLimit}},
+		{query: "cursor=" + encodeCursor(40), want: model.ListOptions{Limit: model.DefaultPageLimit, Offset: 40}},
+		{query: "cursor=" + encodeCursor(40) + "&offset=5", want: model.ListOptions{Limit: model.DefaultPageLimit, Offset: 40}},
+		{query: "sort=created", want: model.ListOptions{Limit: model.DefaultPageLimit, Sort: "created", Desc: true}},
+		{query: "sort=title&order=desc", want: model.ListOptions{Limit: model.DefaultPageLimit, Sort: "title", Desc: true}},
+		{query: "cursor=" + base64.RawURLEncoding.EncodeToString([]byte("-20")), wantErr: true},
+		{query: "cursor=%21%21", wantErr: true},
+		{query: "offset=-1", wantErr: true},
+		{query: "limit=0", wantErr: true},
+		{query: "order=sideways", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+
+			got, err := parseListOptions(c)
+			if tt.wantErr {
+				if !errors.Is(err, errBadPageParams) {
+					t.Errorf("got error %v, want %v", err, errBadPageParams)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %+v, error %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPageNextCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset int
+		items  int
+		total  int
+		next   int // 0 means no next_cursor
+	}{
+		{name: "first page", offset: 0, items: 20, total: 45, next: 20},
+		{name: "middle page", offset: 20, items: 20, total: 45, next: 40},
+		{name: "last partial page", offset: 40, items: 5, total: 45},
+		{name: "last full page", offset: 20, items: 20, total: 40},
+		{name: "single page", offset: 0, items: 3, total: 3},
+		{name: "empty list", offset: 0, items: 0, total: 0},
+		{name: "past the end", offset: 60, items: 0, total: 45},
+		{name: "rows added since the count", offset: 0, items: 20, total: 21, next: 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := newPage(make([]int, tt.items), tt.total, model.ListOptions{Limit: 20, Offset: tt.offset})
+
+			body, err := json.Marshal(page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hasKey := strings.Contains(string(body), `"next_cursor"`)
+
+			if tt.next == 0 {
+				if page.NextCursor != "" || hasKey {
+					t.Errorf("got next_cursor %q in %s, want it omitted", page.NextCursor, body)
+				}
+				return
+			}
+			if !hasKey {
+				t.Errorf("next_cursor missing from %s", body)
+			}
+			if got, err := decodeCursor(page.NextCursor); err != nil || got != tt.next {
+				t.Errorf("next_cursor decodes to %d, error %v, want %d", got, err, tt.next)
+			}
+		})
+	}
+}
+
+func TestNewPageItemsNeverNull(t *testing.T) {
+	body, err := json.Marshal(newPage[int](nil, 0, model.ListOptions{Limit: 20}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), `"items":[]`) {
+		t.Errorf("empty page: got %s, want items to be []", body)
+	}
+}
+
+// Following next_cursor from the first page visits every item once.
+func TestCursorWalk(t *testing.T) {
+	const total = 45
+	all := make([]int, total)
+	for i := range all {
+		all[i] = i
+	}
+
+	var seen []int
+	query := "limit=20"
+	for pages := 0; ; pages++ {
+		if pages > total {
+			t.Fatal("cursor walk does not end")
+		}
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+		opts, err := parseListOptions(c)
+		if err != nil {
+			t.Fatalf("parse %q: %v", query, err)
+		}
+
+		end := min(opts.Offset+opts.Limit, total)
+		page := newPage(all[opts.Offset:end], total, opts)
+		seen = append(seen, page.Items...)
+		if page.NextCursor == "" {
+			break
+		}
+		query = "limit=20&cursor=" + page.NextCursor
+	}
+
+	if len(seen) != total {
+		t.Fatalf("walked %d items, want %d", len(seen), total)
+	}
+	for i, v := range seen {
+		if v != i {
+			t.Fatalf("item %d: got %d", i, v)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package ginhandler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/internal/service"
+)
+
+type PersonHandler struct {
+	personSvc *service.PersonService
+}
+
+func NewPersonHandler(personSvc *service.PersonService) *PersonHandler {
+	return &PersonHandler{personSvc: personSvc}
+}
+
+// Search lists people whose name matches q, or everyone without q.
+func (h *PersonHandler) Search(c *gin.Context) {
+	opts, err := parseListOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	people, total, err := h.personSvc.SearchPeople(c.Request.Context(), c.Query("q"), opts)
+	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
+		if errors.Is(err, postgres.ErrBadSort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "search failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newPage(people, total, opts))
+}
+
+func (h *PersonHandler) Get(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	p, err := h.personSvc.GetPerson(c.Request.Context(), id)
+	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
+		if errors.Is(err, postgres.ErrPersonNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot load person"})
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
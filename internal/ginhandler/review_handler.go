@@ -1,12 +1,14 @@
 package ginhandler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/AlikhanF2006/Final_project/internal/middleware"
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
 	"github.com/AlikhanF2006/Final_project/internal/postgres/dto"
 	"github.com/AlikhanF2006/Final_project/internal/service"
 	"github.com/AlikhanF2006/Final_project/model"
@@ -35,12 +37,19 @@ func (h *ReviewHandler) AddReview(c *gin.Context) {
 
 	userID := c.GetInt(middleware.UserIDKey)
 
-	created, err := h.reviewSvc.AddReview(movieID, model.Review{UserID: userID, Score: req.Score, Text: req.Text})
+	created, err := h.reviewSvc.AddReview(c.Request.Context(), movieID, model.Review{UserID: userID, Score: req.Score, Text: req.Text})
 	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
 		if err == service.ErrBadReviewData {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if errors.Is(err, postgres.ErrReviewExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusNotFound, gin.H{"error": "movie not found"})
 		return
 	}
@@ -55,13 +64,26 @@ func (h *ReviewHandler) GetReviews(c *gin.Context) {
 		return
 	}
 
-	revs, err := h.reviewSvc.ListReviews(movieID)
+	opts, err := parseListOptions(c)
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	revs, total, err := h.reviewSvc.ListReviews(c.Request.Context(), movieID, opts)
+	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
+		if errors.Is(err, postgres.ErrBadSort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusNotFound, gin.H{"error": "movie not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, revs)
+	c.JSON(http.StatusOK, newPage(revs, total, opts))
 }
 
 func (h *ReviewHandler) UpdateReview(c *gin.Context) {
@@ -79,7 +101,10 @@ func (h *ReviewHandler) UpdateReview(c *gin.Context) {
 
 	userID := c.GetInt(middleware.UserIDKey)
 
-	if err := h.reviewSvc.UpdateReview(movieID, userID, req.Score); err != nil {
+	if err := h.reviewSvc.UpdateReview(c.Request.Context(), movieID, userID, req.Score); err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
 		if err == service.ErrBadReviewData {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
@@ -100,7 +125,10 @@ func (h *ReviewHandler) DeleteReview(c *gin.Context) {
 
 	userID := c.GetInt(middleware.UserIDKey)
 
-	if err := h.reviewSvc.DeleteReview(movieID, userID); err != nil {
+	if err := h.reviewSvc.DeleteReview(c.Request.Context(), movieID, userID); err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
 		c.JSON(http.StatusForbidden, gin.H{"error": "cannot delete review"})
 		return
 	}
@@ -115,13 +143,10 @@ func (h *ReviewHandler) AdminDeleteReview(c *gin.Context) {
 		return
 	}
 
-	role := c.GetString(middleware.UserRoleKey)
-	if role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
-		return
-	}
-
-	if err := h.reviewSvc.DeleteReviewByID(reviewID); err != nil {
+	if err := h.reviewSvc.DeleteReviewByID(c.Request.Context(), reviewID); err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
 		c.JSON(http.StatusNotFound, gin.H{"error": "review not found"})
 		return
 	}
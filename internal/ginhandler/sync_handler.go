@@ -0,0 +1,52 @@
+package ginhandler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/AlikhanF2006/Final_project/internal/service"
+)
+
+type SyncHandler struct {
+	sync *service.CatalogSync
+}
+
+func NewSyncHandler(sync *service.CatalogSync) *SyncHandler {
+	return &SyncHandler{sync: sync}
+}
+
+// Trigger starts a TMDB sync in the background; GET .../runs shows how it
+// went.
+func (h *SyncHandler) Trigger(c *gin.Context) {
+	if err := h.sync.Trigger(); err != nil {
+		if errors.Is(err, service.ErrSyncRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"status": "started"})
+}
+
+func (h *SyncHandler) Runs(c *gin.Context) {
+	opts, err := parseListOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	runs, total, err := h.sync.Runs(c.Request.Context(), opts)
+	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot list sync runs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newPage(runs, total, opts))
+}
@@ -1,12 +1,14 @@
 package ginhandler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/AlikhanF2006/Final_project/internal/middleware"
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
 	"github.com/AlikhanF2006/Final_project/internal/postgres/dto"
 	"github.com/AlikhanF2006/Final_project/internal/service"
 )
@@ -25,31 +27,24 @@ func (h *UserHandler) Register(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid data"})
 		return
 	}
-	u, err := h.svc.Register(req)
+	u, err := h.svc.Register(c.Request.Context(), req)
 	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
+		if errors.Is(err, postgres.ErrUserExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusCreated, u)
 }
 
-func (h *UserHandler) Login(c *gin.Context) {
-	var req dto.LoginDTO
-	if c.ShouldBindJSON(&req) != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid data"})
-		return
-	}
-	token, err := h.svc.Login(req)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "wrong credentials"})
-		return
-	}
-	c.JSON(http.StatusOK, gin.H{"token": token})
-}
-
 func (h *UserHandler) Me(c *gin.Context) {
 	id := c.GetInt(middleware.UserIDKey)
-	u, _ := h.svc.GetProfile(id)
+	u, _ := h.svc.GetProfile(c.Request.Context(), id)
 	c.JSON(http.StatusOK, u)
 }
 
@@ -57,8 +52,19 @@ func (h *UserHandler) UpdateMe(c *gin.Context) {
 	id := c.GetInt(middleware.UserIDKey)
 	var req dto.UpdateProfileDTO
 	c.ShouldBindJSON(&req)
-	u, err := h.svc.UpdateProfile(id, req)
+	u, err := h.svc.UpdateProfile(c.Request.Context(), id, req)
 	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
+		if errors.Is(err, postgres.ErrUserExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, postgres.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -72,16 +78,26 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid data"})
 		return
 	}
-	if err := h.svc.ChangePassword(id, req.Password); err != nil {
+	tokens, err := h.svc.ChangePassword(c.Request.Context(), id, req.Password)
+	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.Status(http.StatusNoContent)
+	c.JSON(http.StatusOK, tokens)
 }
 
 func (h *UserHandler) DeleteMe(c *gin.Context) {
 	id := c.GetInt(middleware.UserIDKey)
-	h.svc.DeleteAccount(id)
+	if err := h.svc.DeleteAccount(c.Request.Context(), id); err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not delete account"})
+		return
+	}
 	c.Status(http.StatusNoContent)
 }
 
@@ -91,8 +107,11 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
-	u, err := h.svc.GetProfile(id)
+	u, err := h.svc.GetProfile(c.Request.Context(), id)
 	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
 		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		return
 	}
@@ -100,19 +119,43 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 }
 
 func (h *UserHandler) AdminDeleteUser(c *gin.Context) {
-	role := c.GetString(middleware.UserRoleKey)
-	if role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	if err := h.svc.AdminDeleteUser(c.Request.Context(), id); err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		return
 	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *UserHandler) AdminSetRole(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
-	if err := h.svc.AdminDeleteUser(id); err != nil {
+	var req dto.SetRoleDTO
+	if c.ShouldBindJSON(&req) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid data"})
+		return
+	}
+	u, err := h.svc.SetRole(c.Request.Context(), id, req.Role)
+	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrUnknownRole) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		return
 	}
-	c.Status(http.StatusNoContent)
+	c.JSON(http.StatusOK, u)
 }
@@ -0,0 +1,157 @@
+package ginhandler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/AlikhanF2006/Final_project/internal/middleware"
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/internal/service"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+type WatchlistHandler struct {
+	watchlistSvc *service.WatchlistService
+	genreSvc     *service.GenreService
+}
+
+func NewWatchlistHandler(watchlistSvc *service.WatchlistService, genreSvc *service.GenreService) *WatchlistHandler {
+	return &WatchlistHandler{watchlistSvc: watchlistSvc, genreSvc: genreSvc}
+}
+
+type addWatchlistRequest struct {
+	MovieID    int    `json:"movie_id"`
+	TMDBID     int    `json:"tmdb_id"`
+	Note       string `json:"note"`
+	PlannedFor string `json:"planned_for"`
+}
+
+type updateWatchlistRequest struct {
+	Note       *string `json:"note"`
+	PlannedFor *string `json:"planned_for"`
+	Position   *int    `json:"position"`
+}
+
+// List takes the movie filters and the list parameters, plus due=true for
+// the entries whose planned date has come.
+func (h *WatchlistHandler) List(c *gin.Context) {
+	opts, err := parseListOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	f, ok := parseMovieFilter(c, h.genreSvc)
+	if !ok {
+		return
+	}
+	due, err := strconv.ParseBool(c.DefaultQuery("due", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid due"})
+		return
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	entries, total, err := h.watchlistSvc.ListWatchlist(c.Request.Context(), userID, f, due, opts)
+	if err != nil {
+		if writeDBContextError(c, err) {
+			return
+		}
+		if errors.Is(err, postgres.ErrBadSort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot list watchlist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newPage(entries, total, opts))
+}
+
+func (h *WatchlistHandler) Add(c *gin.Context) {
+	var req addWatchlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+		return
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	e, err := h.watchlistSvc.AddToWatchlist(c.Request.Context(), userID, model.WatchlistEntry{
+		Movie:      model.Movie{ID: req.MovieID, TMDBID: req.TMDBID},
+		Note:       req.Note,
+		PlannedFor: req.PlannedFor,
+	})
+	if err != nil {
+		if writeTMDBError(c, err) {
+			return
+		}
+		writeWatchlistError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, e)
+}
+
+// Update changes the note, the planned date ("" clears it) or the position
+// of an entry; fields left out stay as they are.
+func (h *WatchlistHandler) Update(c *gin.Context) {
+	movieID, err := strconv.Atoi(c.Param("movie_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid movie id"})
+		return
+	}
+
+	var req updateWatchlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+		return
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	e, err := h.watchlistSvc.UpdateWatchlistEntry(c.Request.Context(), userID, movieID, service.WatchlistUpdate{
+		Note:       req.Note,
+		PlannedFor: req.PlannedFor,
+		Position:   req.Position,
+	})
+	if err != nil {
+		writeWatchlistError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, e)
+}
+
+func (h *WatchlistHandler) Remove(c *gin.Context) {
+	movieID, err := strconv.Atoi(c.Param("movie_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid movie id"})
+		return
+	}
+
+	userID := c.GetInt(middleware.UserIDKey)
+	if err := h.watchlistSvc.RemoveFromWatchlist(c.Request.Context(), userID, movieID); err != nil {
+		writeWatchlistError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func writeWatchlistError(c *gin.Context, err error) {
+	if writeDBContextError(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrBadWatchlistData):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "give movie_id or tmdb_id, a note of at most 1000 bytes, planned_for as YYYY-MM-DD and a position from 1"})
+	case errors.Is(err, postgres.ErrMovieNotFound),
+		errors.Is(err, postgres.ErrWatchlistEntryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, postgres.ErrWatchlistEntryExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "watchlist update failed"})
+	}
+}
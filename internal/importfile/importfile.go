@@ -0,0 +1,304 @@
+// Package importfile reads the files the bulk movie import accepts: a plain
+// list of TMDB ids, a CSV with a tmdb_id column, a JSON array or NDJSON. Only
+// the TMDB id is read from each entry; everything else comes from TMDB.
+package importfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Format names an import file format.
+type Format string
+
+const (
+	FormatIDs    Format = "ids"
+	FormatCSV    Format = "csv"
+	FormatJSON   Format = "json"
+	FormatNDJSON Format = "ndjson"
+)
+
+// MaxEntries bounds the entries of one import.
+const MaxEntries = 10000
+
+var (
+	ErrUnknownFormat  = errors.New("unknown import format")
+	ErrNoTMDBIDColumn = errors.New("csv has no tmdb_id column")
+	ErrTooManyEntries = fmt.Errorf("import has more than %d entries", MaxEntries)
+	ErrNotUTF8        = errors.New("import file is UTF-16; save it as UTF-8")
+)
+
+// Entry is one movie to import. Line is the line of the entry in the file,
+// or its position in a JSON array. Err is set, and TMDBID is 0, when the
+// entry has no valid id; the other entries are still imported.
+type Entry struct {
+	Line   int
+	TMDBID int
+	Err    error
+}
+
+// ParseFormat accepts a format name, with jsonl and txt as aliases.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatIDs, FormatCSV, FormatJSON, FormatNDJSON:
+		return f, nil
+	case "jsonl":
+		return FormatNDJSON, nil
+	case "txt", "text":
+		return FormatIDs, nil
+	}
+	return "", fmt.Errorf("%w %q", ErrUnknownFormat, s)
+}
+
+// FormatFromName guesses the format from a file extension.
+func FormatFromName(name string) (Format, error) {
+	ext := strings.TrimPrefix(filepath.Ext(name), ".")
+	if ext == "" {
+		return "", fmt.Errorf("%w: %s has no extension", ErrUnknownFormat, name)
+	}
+	return ParseFormat(ext)
+}
+
+// FormatFromContentType guesses the format from a request's Content-Type.
+func FormatFromContentType(contentType string) (Format, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("%w: content type %q", ErrUnknownFormat, contentType)
+	}
+
+	switch mediaType {
+	case "text/plain":
+		return FormatIDs, nil
+	case "text/csv":
+		return FormatCSV, nil
+	case "application/json":
+		return FormatJSON, nil
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return FormatNDJSON, nil
+	}
+	return "", fmt.Errorf("%w: content type %q", ErrUnknownFormat, contentType)
+}
+
+// Parse reads every entry of r. Entries with a bad id come back with Err
+// set; a file that cannot be read as format at all is an error. A leading
+// UTF-8 byte order mark, as Excel writes, is skipped.
+func Parse(r io.Reader, format Format) ([]Entry, error) {
+	r, err := skipBOM(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	switch format {
+	case FormatIDs:
+		entries, err = parseIDs(r)
+	case FormatCSV:
+		entries, err = parseCSV(r)
+	case FormatJSON:
+		entries, err = parseJSON(r)
+	case FormatNDJSON:
+		entries, err = parseNDJSON(r)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > MaxEntries {
+		return nil, ErrTooManyEntries
+	}
+	return entries, nil
+}
+
+// skipBOM drops a UTF-8 byte order mark and rejects files that start with a
+// UTF-16 one, which would otherwise fail on every line.
+func skipBOM(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(3)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(head, []byte("\xef\xbb\xbf")):
+		_, err = br.Discard(3)
+		return br, err
+	case bytes.HasPrefix(head, []byte("\xff\xfe")), bytes.HasPrefix(head, []byte("\xfe\xff")):
+		return nil, ErrNotUTF8
+	}
+	return br, nil
+}
+
+// parseIDs reads ids separated by whitespace or commas. Lines starting with
+// # are comments.
+func parseIDs(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(text, "#") {
+			continue
+		}
+		for _, field := range strings.FieldsFunc(text, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		}) {
+			entries = append(entries, newEntry(line, field))
+			if len(entries) > MaxEntries {
+				return entries, nil
+			}
+		}
+	}
+	return entries, sc.Err()
+}
+
+// parseCSV reads the tmdb_id column. A file whose first cell is a number has
+// no header, and the ids are in its first column.
+func parseCSV(r io.Reader) ([]Entry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.ReuseRecord = true
+
+	col := -1
+	var entries []Entry
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read csv: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+
+		if col < 0 {
+			col = headerColumn(record)
+			if col < 0 {
+				return nil, ErrNoTMDBIDColumn
+			}
+			if _, err := strconv.Atoi(strings.TrimSpace(record[0])); err != nil {
+				continue
+			}
+		}
+
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if col >= len(record) {
+			entries = append(entries, Entry{Line: line, Err: errors.New("missing tmdb_id")})
+		} else {
+			entries = append(entries, newEntry(line, record[col]))
+		}
+		if len(entries) > MaxEntries {
+			return entries, nil
+		}
+	}
+}
+
+// headerColumn finds the tmdb_id column of the first record, or -1 if it
+// has none. A record that starts with a number is data, with the ids in
+// column 0.
+func headerColumn(record []string) int {
+	if _, err := strconv.Atoi(strings.TrimSpace(record[0])); err == nil {
+		return 0
+	}
+	for i, name := range record {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "tmdb_id", "tmdbid", "tmdb":
+			return i
+		}
+	}
+	return -1
+}
+
+// parseJSON reads an array of ids or of objects with a tmdb_id field.
+func parseJSON(r io.Reader) ([]Entry, error) {
+	dec := json.NewDecoder(r)
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("read json: %w", err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("read json: want an array of movies")
+	}
+
+	var entries []Entry
+	for pos := 1; dec.More(); pos++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("read json: entry %d: %w", pos, err)
+		}
+		entries = append(entries, jsonEntry(pos, raw))
+		if len(entries) > MaxEntries {
+			return entries, nil
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("read json: %w", err)
+	}
+	return entries, nil
+}
+
+// parseNDJSON reads one id or object per line. A line that is not JSON is a
+// bad entry, not a bad file.
+func parseNDJSON(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		raw := bytes.TrimSpace(sc.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		entries = append(entries, jsonEntry(line, raw))
+		if len(entries) > MaxEntries {
+			return entries, nil
+		}
+	}
+	return entries, sc.Err()
+}
+
+func jsonEntry(line int, raw []byte) Entry {
+	if !json.Valid(raw) {
+		return Entry{Line: line, Err: errors.New("invalid json")}
+	}
+	if raw[0] == '{' {
+		var obj struct {
+			TMDBID json.RawMessage `json:"tmdb_id"`
+		}
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return Entry{Line: line, Err: fmt.Errorf("invalid json: %w", err)}
+		}
+		if obj.TMDBID == nil || string(obj.TMDBID) == "null" {
+			return Entry{Line: line, Err: errors.New("missing tmdb_id")}
+		}
+		raw = obj.TMDBID
+	}
+
+	// Accept ids written as strings, as spreadsheets tend to export them.
+	var s string
+	if raw[0] == '"' && json.Unmarshal(raw, &s) == nil {
+		return newEntry(line, s)
+	}
+	return newEntry(line, string(raw))
+}
+
+func newEntry(line int, field string) Entry {
+	field = strings.TrimSpace(field)
+	id, err := strconv.Atoi(field)
+	if err != nil || id <= 0 {
+		return Entry{Line: line, Err: fmt.Errorf("invalid tmdb id %q", field)}
+	}
+	return Entry{Line: line, TMDBID: id}
+}
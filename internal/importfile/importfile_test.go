@@ -0,0 +1,267 @@
+package importfile
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// row is what a test expects of one entry: an id, or an error whose message
+// contains errPart.
+type row struct {
+	line    int
+	id      int
+	errPart string
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		input  string
+		want   []row
+	}{
+		{
+			name:   "ids",
+			format: FormatIDs,
+			input:  "550\n# a comment\n603, 680\t13\n\n",
+			want:   []row{{line: 1, id: 550}, {line: 3, id: 603}, {line: 3, id: 680}, {line: 3, id: 13}},
+		},
+		{
+			name:   "ids with bad entries",
+			format: FormatIDs,
+			input:  "550 abc\n-3\n0\n1.5\n",
+			want: []row{
+				{line: 1, id: 550},
+				{line: 1, errPart: `"abc"`},
+				{line: 2, errPart: `"-3"`},
+				{line: 3, errPart: `"0"`},
+				{line: 4, errPart: `"1.5"`},
+			},
+		},
+		{
+			name:   "ids with a UTF-8 BOM",
+			format: FormatIDs,
+			input:  "\ufeff550\n603\n",
+			want:   []row{{line: 1, id: 550}, {line: 2, id: 603}},
+		},
+		{
+			name:   "ids with invalid UTF-8",
+			format: FormatIDs,
+			input:  "550\n\xff\xfe603\n",
+			want:   []row{{line: 1, id: 550}, {line: 2, errPart: "invalid tmdb id"}},
+		},
+		{
+			name:   "csv with header",
+			format: FormatCSV,
+			input:  "title,tmdb_id\nHeat,949\nAlien, abc\nShort\n\nNeg,-5\n",
+			want: []row{
+				{line: 2, id: 949},
+				{line: 3, errPart: `"abc"`},
+				{line: 4, errPart: "missing tmdb_id"},
+				{line: 6, errPart: `"-5"`},
+			},
+		},
+		{
+			name:   "csv header case and aliases",
+			format: FormatCSV,
+			input:  "Title, TMDB\n\"Heat, the movie\",949\n",
+			want:   []row{{line: 2, id: 949}},
+		},
+		{
+			name:   "csv without header",
+			format: FormatCSV,
+			input:  "949,Heat\n550,Fight Club\n",
+			want:   []row{{line: 1, id: 949}, {line: 2, id: 550}},
+		},
+		{
+			name:   "csv with a UTF-8 BOM before the header",
+			format: FormatCSV,
+			input:  "\ufefftmdb_id,title\n949,Heat\n",
+			want:   []row{{line: 2, id: 949}},
+		},
+		{
+			name:   "csv with a UTF-8 BOM and no header",
+			format: FormatCSV,
+			input:  "\ufeff949,Heat\n",
+			want:   []row{{line: 1, id: 949}},
+		},
+		{
+			name:   "csv with CRLF line endings",
+			format: FormatCSV,
+			input:  "tmdb_id\r\n949\r\n550\r\n",
+			want:   []row{{line: 2, id: 949}, {line: 3, id: 550}},
+		},
+		{
+			name:   "json",
+			format: FormatJSON,
+			input:  `[550, "603", {"tmdb_id": 680}, {"tmdb_id": "13", "title": "x"}, {"title": "x"}, 0, 1.5, null, true, {"tmdb_id": null}]`,
+			want: []row{
+				{line: 1, id: 550},
+				{line: 2, id: 603},
+				{line: 3, id: 680},
+				{line: 4, id: 13},
+				{line: 5, errPart: "missing tmdb_id"},
+				{line: 6, errPart: `"0"`},
+				{line: 7, errPart: `"1.5"`},
+				{line: 8, errPart: `"null"`},
+				{line: 9, errPart: `"true"`},
+				{line: 10, errPart: "missing tmdb_id"},
+			},
+		},
+		{
+			name:   "json with a UTF-8 BOM",
+			format: FormatJSON,
+			input:  "\ufeff[550]",
+			want:   []row{{line: 1, id: 550}},
+		},
+		{
+			name:   "empty json array",
+			format: FormatJSON,
+			input:  "[]",
+		},
+		{
+			name:   "ndjson",
+			format: FormatNDJSON,
+			input:  "550\n{bad\n\n{\"tmdb_id\": 603}\n\"680\"\n{\"id\": 1}\n",
+			want: []row{
+				{line: 1, id: 550},
+				{line: 2, errPart: "invalid json"},
+				{line: 4, id: 603},
+				{line: 5, id: 680},
+				{line: 6, errPart: "missing tmdb_id"},
+			},
+		},
+		{
+			name:   "ndjson with a UTF-8 BOM",
+			format: FormatNDJSON,
+			input:  "\ufeff{\"tmdb_id\": 550}\n",
+			want:   []row{{line: 1, id: 550}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.input), tt.format)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d entries, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, w := range tt.want {
+				e := got[i]
+				if e.Line != w.line {
+					t.Errorf("entry %d: line %d, want %d", i, e.Line, w.line)
+				}
+				switch {
+				case w.errPart == "" && (e.Err != nil || e.TMDBID != w.id):
+					t.Errorf("entry %d: got id %d, error %v, want id %d", i, e.TMDBID, e.Err, w.id)
+				case w.errPart != "" && (e.Err == nil || e.TMDBID != 0 || !strings.Contains(e.Err.Error(), w.errPart)):
+					t.Errorf("entry %d: got id %d, error %v, want an error containing %s", i, e.TMDBID, e.Err, w.errPart)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRejectsFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  Format
+		input   string
+		wantErr error
+	}{
+		{name: "csv without tmdb_id column", format: FormatCSV, input: "title,year\nHeat,1995\n", wantErr: ErrNoTMDBIDColumn},
+		{name: "csv with an unterminated quote", format: FormatCSV, input: "tmdb_id,title\n949,\"Heat\n"},
+		{name: "json object", format: FormatJSON, input: `{"tmdb_id": 550}`},
+		{name: "truncated json", format: FormatJSON, input: `[550, 603`},
+		{name: "json with trailing garbage in an entry", format: FormatJSON, input: `[550, {"tmdb_id": }]`},
+		{name: "empty json", format: FormatJSON, input: ""},
+		{name: "UTF-16 little endian", format: FormatIDs, input: "\xff\xfe5\x005\x000\x00", wantErr: ErrNotUTF8},
+		{name: "UTF-16 big endian", format: FormatCSV, input: "\xfe\xff\x005\x005\x000", wantErr: ErrNotUTF8},
+		{name: "unknown format", format: "xml", input: "<movies/>", wantErr: ErrUnknownFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := Parse(strings.NewReader(tt.input), tt.format)
+			if err == nil {
+				t.Fatalf("Parse: got %d entries and no error", len(entries))
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Parse: got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseMaxEntries(t *testing.T) {
+	// files builds the same n ids in every format.
+	files := func(n int) map[Format]string {
+		ids := make([]string, n)
+		for i := range ids {
+			ids[i] = fmt.Sprint(i + 1)
+		}
+		return map[Format]string{
+			FormatIDs:    strings.Join(ids, "\n"),
+			FormatCSV:    "tmdb_id\n" + strings.Join(ids, "\n"),
+			FormatJSON:   "[" + strings.Join(ids, ",") + "]",
+			FormatNDJSON: strings.Join(ids, "\n"),
+		}
+	}
+
+	for format, input := range files(MaxEntries) {
+		entries, err := Parse(strings.NewReader(input), format)
+		if err != nil || len(entries) != MaxEntries {
+			t.Errorf("%s with %d entries: got %d entries, error %v", format, MaxEntries, len(entries), err)
+		}
+	}
+	for format, input := range files(MaxEntries + 1) {
+		if _, err := Parse(strings.NewReader(input), format); !errors.Is(err, ErrTooManyEntries) {
+			t.Errorf("%s with %d entries: got error %v, want %v", format, MaxEntries+1, err, ErrTooManyEntries)
+		}
+	}
+
+	// Bad entries count towards the cap too.
+	input := strings.Repeat("x ", MaxEntries+1)
+	if _, err := Parse(strings.NewReader(input), FormatIDs); !errors.Is(err, ErrTooManyEntries) {
+		t.Errorf("%d bad ids: got error %v, want %v", MaxEntries+1, err, ErrTooManyEntries)
+	}
+}
+
+func TestFormatDetection(t *testing.T) {
+	tests := []struct {
+		name string
+		got  func() (Format, error)
+		want Format
+	}{
+		{"name csv", func() (Format, error) { return FormatFromName("movies.CSV") }, FormatCSV},
+		{"name jsonl", func() (Format, error) { return FormatFromName("movies.jsonl") }, FormatNDJSON},
+		{"name txt", func() (Format, error) { return FormatFromName("ids.txt") }, FormatIDs},
+		{"name without extension", func() (Format, error) { return FormatFromName("movies") }, ""},
+		{"name xlsx", func() (Format, error) { return FormatFromName("movies.xlsx") }, ""},
+		{"content type csv", func() (Format, error) { return FormatFromContentType("text/csv; charset=utf-8") }, FormatCSV},
+		{"content type json", func() (Format, error) { return FormatFromContentType("application/json") }, FormatJSON},
+		{"content type ndjson", func() (Format, error) { return FormatFromContentType("application/x-ndjson") }, FormatNDJSON},
+		{"content type text", func() (Format, error) { return FormatFromContentType("text/plain") }, FormatIDs},
+		{"content type xml", func() (Format, error) { return FormatFromContentType("application/xml") }, ""},
+		{"bad content type", func() (Format, error) { return FormatFromContentType(";;") }, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.got()
+			if tt.want == "" {
+				if !errors.Is(err, ErrUnknownFormat) {
+					t.Errorf("got %q, error %v, want %v", got, err, ErrUnknownFormat)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %q, error %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+type DiaryRepository struct {
+	store *Store
+}
+
+func NewDiaryRepository(store *Store) *DiaryRepository {
+	return &DiaryRepository{store: store}
+}
+
+type diaryRow struct {
+	id        int
+	userID    int
+	movieID   int
+	watchedOn string
+	rewatch   bool
+	score     *int
+	note      string
+	createdAt time.Time
+}
+
+var diarySortKeys = map[string]func(a, b model.DiaryEntry) int{
+	"watched": func(a, b model.DiaryEntry) int { return strings.Compare(a.WatchedOn, b.WatchedOn) },
+	"added":   func(a, b model.DiaryEntry) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	"score":   func(a, b model.DiaryEntry) int { return cmp.Compare(scoreOrZero(a.Score), scoreOrZero(b.Score)) },
+	"title":   func(a, b model.DiaryEntry) int { return strings.Compare(a.Movie.Title, b.Movie.Title) },
+	"year":    func(a, b model.DiaryEntry) int { return cmp.Compare(a.Movie.Year, b.Movie.Year) },
+}
+
+// scoreOrZero sorts unrated entries as the lowest scores, like the SQL
+// backends.
+func scoreOrZero(score *int) int {
+	if score == nil {
+		return 0
+	}
+	return *score
+}
+
+func (r *DiaryRepository) List(ctx context.Context, userID int, f model.DiaryFilter, opts model.ListOptions) ([]model.DiaryEntry, int, error) {
+	if err := live(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	r.store.mu.RLock()
+	entries := make([]model.DiaryEntry, 0)
+	for _, d := range r.store.diary {
+		if d.userID != userID || (f.MovieID != 0 && d.movieID != f.MovieID) {
+			continue
+		}
+		if (f.From != "" && d.watchedOn < f.From) || (f.To != "" && d.watchedOn > f.To) {
+			continue
+		}
+		entries = append(entries, r.store.diaryEntry(d))
+	}
+	r.store.mu.RUnlock()
+
+	if opts.Sort == "" {
+		opts.Sort = "watched"
+	}
+	page, err := sortPage(entries, diarySortKeys, func(e model.DiaryEntry) int { return e.ID }, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return page, len(entries), nil
+}
+
+func (r *DiaryRepository) Get(ctx context.Context, userID, id int) (model.DiaryEntry, error) {
+	if err := live(ctx); err != nil {
+		return model.DiaryEntry{}, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	d, ok := r.store.diary[id]
+	if !ok || d.userID != userID {
+		return model.DiaryEntry{}, postgres.ErrDiaryEntryNotFound
+	}
+	return r.store.diaryEntry(d), nil
+}
+
+func (r *DiaryRepository) Add(ctx context.Context, userID int, e model.DiaryEntry) (model.DiaryEntry, error) {
+	if err := live(ctx); err != nil {
+		return model.DiaryEntry{}, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.movies[e.Movie.ID]; !ok {
+		return model.DiaryEntry{}, postgres.ErrMovieNotFound
+	}
+
+	r.store.lastDiaryID++
+	d := diaryRow{
+		id:        r.store.lastDiaryID,
+		userID:    userID,
+		movieID:   e.Movie.ID,
+		watchedOn: e.WatchedOn,
+		rewatch:   e.Rewatch,
+		score:     copyScore(e.Score),
+		note:      e.Note,
+		createdAt: time.Now(),
+	}
+	r.store.diary[d.id] = d
+	r.store.enqueueRating(d.movieID)
+	r.store.publishActivity(model.ActivityDiary, userID, model.ActivitySubject{DiaryEntryID: d.id})
+	return r.store.diaryEntry(d), nil
+}
+
+func (r *DiaryRepository) Update(ctx context.Context, userID int, e model.DiaryEntry) (model.DiaryEntry, error) {
+	if err := live(ctx); err != nil {
+		return model.DiaryEntry{}, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	d, ok := r.store.diary[e.ID]
+	if !ok || d.userID != userID {
+		return model.DiaryEntry{}, postgres.ErrDiaryEntryNotFound
+	}
+	d.watchedOn, d.rewatch, d.score, d.note = e.WatchedOn, e.Rewatch, copyScore(e.Score), e.Note
+	r.store.diary[d.id] = d
+	r.store.enqueueRating(d.movieID)
+	return r.store.diaryEntry(d), nil
+}
+
+func (r *DiaryRepository) Delete(ctx context.Context, userID, id int) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	d, ok := r.store.diary[id]
+	if !ok || d.userID != userID {
+		return postgres.ErrDiaryEntryNotFound
+	}
+	delete(r.store.diary, id)
+	r.store.enqueueRating(d.movieID)
+	return nil
+}
+
+func (r *DiaryRepository) Days(ctx context.Context, userID int, from, to string) ([]model.DiaryDay, error) {
+	if err := live(ctx); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, d := range r.store.diary {
+		if d.userID == userID && d.watchedOn >= from && d.watchedOn <= to {
+			counts[d.watchedOn]++
+		}
+	}
+	days := make([]model.DiaryDay, 0, len(counts))
+	for _, date := range slices.Sorted(maps.Keys(counts)) {
+		days = append(days, model.DiaryDay{Date: date, Watches: counts[date]})
+	}
+	return days, nil
+}
+
+// latestScores returns, per user, the score of their latest scored diary
+// entry for the movie, as the rating query picks it; callers hold the lock.
+func (s *Store) latestScores(movieID int) map[int]int {
+	latest := make(map[int]diaryRow)
+	for _, d := range s.diary {
+		if d.movieID != movieID || d.score == nil {
+			continue
+		}
+		cur, ok := latest[d.userID]
+		if !ok || cmp.Or(strings.Compare(d.watchedOn, cur.watchedOn), cmp.Compare(d.id, cur.id)) > 0 {
+			latest[d.userID] = d
+		}
+	}
+	scores := make(map[int]int, len(latest))
+	for userID, d := range latest {
+		scores[userID] = *d.score
+	}
+	return scores
+}
+
+// diaryEntry joins an entry to its movie; callers hold the lock.
+func (s *Store) diaryEntry(d diaryRow) model.DiaryEntry {
+	return model.DiaryEntry{
+		ID:        d.id,
+		Movie:     s.movies[d.movieID].Movie,
+		WatchedOn: d.watchedOn,
+		Rewatch:   d.rewatch,
+		Score:     copyScore(d.score),
+		Note:      d.note,
+		CreatedAt: d.createdAt,
+	}
+}
+
+// copyScore keeps callers from sharing a stored score.
+func copyScore(score *int) *int {
+	if score == nil {
+		return nil
+	}
+	n := *score
+	return &n
+}
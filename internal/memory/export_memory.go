@@ -0,0 +1,179 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/textsearch"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+// ExportRepository copies the rows an export needs under the read lock and
+// hands them out after releasing it, so a slow reader never blocks writers.
+type ExportRepository struct {
+	store *Store
+}
+
+func NewExportRepository(store *Store) *ExportRepository {
+	return &ExportRepository{store: store}
+}
+
+var exportedReviewSortKeys = map[string]func(a, b model.ExportedReview) int{
+	"created": func(a, b model.ExportedReview) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	"score":   func(a, b model.ExportedReview) int { return cmp.Compare(a.Score, b.Score) },
+}
+
+var movieRatingSortKeys = map[string]func(a, b ratingRow) int{
+	"rating":  func(a, b ratingRow) int { return cmp.Compare(a.Rating, b.Rating) },
+	"year":    func(a, b ratingRow) int { return cmp.Compare(a.Year, b.Year) },
+	"title":   func(a, b ratingRow) int { return strings.Compare(a.Title, b.Title) },
+	"created": func(a, b ratingRow) int { return a.createdAt.Compare(b.createdAt) },
+}
+
+type ratingRow struct {
+	model.MovieRating
+	createdAt time.Time
+}
+
+func (r *ExportRepository) ExportMovies(ctx context.Context, f model.ExportFilter, fn func(model.Movie) error) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.RLock()
+	rows := r.store.exportMovies(f)
+	r.store.mu.RUnlock()
+
+	if err := sortItems(rows, movieSortKeys, movieRowID, exportSort(f)); err != nil {
+		return err
+	}
+	return each(ctx, rows, func(row movieRow) error { return fn(row.Movie) })
+}
+
+func (r *ExportRepository) ExportReviews(ctx context.Context, f model.ExportFilter, fn func(model.ExportedReview) error) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.RLock()
+	movies := make(map[int]model.Movie)
+	for _, row := range r.store.exportMovies(f) {
+		movies[row.ID] = row.Movie
+	}
+	var out []model.ExportedReview
+	for _, rev := range r.store.reviews {
+		m, ok := movies[rev.MovieID]
+		if !ok || (f.UserID != 0 && rev.UserID != f.UserID) {
+			continue
+		}
+		out = append(out, model.ExportedReview{
+			ID:         rev.ID,
+			MovieID:    rev.MovieID,
+			TMDBID:     m.TMDBID,
+			MovieTitle: m.Title,
+			UserID:     rev.UserID,
+			Username:   r.store.users[rev.UserID].Username,
+			Score:      rev.Score,
+			Text:       rev.Text,
+			CreatedAt:  rev.CreatedAt,
+		})
+	}
+	r.store.mu.RUnlock()
+
+	if err := sortItems(out, exportedReviewSortKeys, func(e model.ExportedReview) int { return e.ID }, exportSort(f)); err != nil {
+		return err
+	}
+	return each(ctx, out, fn)
+}
+
+func (r *ExportRepository) ExportRatings(ctx context.Context, f model.ExportFilter, fn func(model.MovieRating) error) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.RLock()
+	movies := r.store.exportMovies(f)
+	byMovie := make(map[int]*ratingRow, len(movies))
+	out := make([]ratingRow, len(movies))
+	for i, row := range movies {
+		out[i] = ratingRow{
+			MovieRating: model.MovieRating{
+				MovieID: row.ID,
+				TMDBID:  row.TMDBID,
+				Title:   row.Title,
+				Year:    row.Year,
+				Rating:  row.Rating,
+			},
+			createdAt: row.createdAt,
+		}
+		byMovie[row.ID] = &out[i]
+	}
+	for _, rev := range r.store.reviews {
+		mr, ok := byMovie[rev.MovieID]
+		if !ok {
+			continue
+		}
+		if mr.Reviews == 0 || rev.Score < mr.MinScore {
+			mr.MinScore = rev.Score
+		}
+		mr.MaxScore = max(mr.MaxScore, rev.Score)
+		mr.AverageScore += float64(rev.Score)
+		mr.Reviews++
+	}
+	r.store.mu.RUnlock()
+
+	for i := range out {
+		if out[i].Reviews > 0 {
+			out[i].AverageScore /= float64(out[i].Reviews)
+		}
+	}
+
+	if err := sortItems(out, movieRatingSortKeys, func(r ratingRow) int { return r.MovieID }, exportSort(f)); err != nil {
+		return err
+	}
+	return each(ctx, out, func(row ratingRow) error { return fn(row.MovieRating) })
+}
+
+// exportMovies returns the movies f selects, with the movie search's
+// semantics; callers hold the read lock.
+func (s *Store) exportMovies(f model.ExportFilter) []movieRow {
+	query := strings.TrimSpace(f.Query)
+	terms := textsearch.Terms(query)
+
+	var rows []movieRow
+	for _, row := range s.movies {
+		if f.MovieID != 0 && row.ID != f.MovieID {
+			continue
+		}
+		if !s.matches(row, f.MovieFilter) {
+			continue
+		}
+		if query != "" {
+			_, matched := textsearch.TextScore(terms, row.Title, row.Description)
+			if !matched && textsearch.WordSimilarity(query, row.Title) < textsearch.SimilarityThreshold {
+				continue
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func exportSort(f model.ExportFilter) model.ListOptions {
+	return model.ListOptions{Sort: f.Sort, Desc: f.Desc}
+}
+
+// each calls fn for every item, stopping early once ctx is done.
+func each[T any](ctx context.Context, items []T, fn func(T) error) error {
+	for _, item := range items {
+		if err := live(ctx); err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+type FeedRepository struct {
+	store *Store
+}
+
+func NewFeedRepository(store *Store) *FeedRepository {
+	return &FeedRepository{store: store}
+}
+
+// followKey is a follow's follower and followed user ids.
+type followKey [2]int
+
+// activityRow keeps what an activity is about. Nothing deletes activities:
+// ids are never reused, so one whose subject or actor is gone is skipped
+// where Postgres would have deleted it by cascade.
+type activityRow struct {
+	id        int
+	actorID   int
+	kind      string
+	subject   model.ActivitySubject
+	createdAt time.Time
+}
+
+var followSortKeys = map[string]func(a, b model.Follow) int{
+	"followed": func(a, b model.Follow) int { return a.Since.Compare(b.Since) },
+	"name":     func(a, b model.Follow) int { return strings.Compare(a.Username, b.Username) },
+}
+
+// Follow is idempotent; following a user again does not backfill twice.
+func (r *FeedRepository) Follow(ctx context.Context, followerID, followeeID int) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, id := range []int{followerID, followeeID} {
+		if _, ok := r.store.users[id]; !ok {
+			return postgres.ErrUserNotFound
+		}
+	}
+	key := followKey{followerID, followeeID}
+	if _, ok := r.store.follows[key]; ok {
+		return nil
+	}
+	r.store.follows[key] = time.Now()
+
+	var backfill []int
+	for _, a := range r.store.activities {
+		if a.actorID == followeeID && r.store.activityAlive(a) {
+			backfill = append(backfill, a.id)
+		}
+	}
+	slices.Sort(backfill)
+	for _, id := range backfill[max(len(backfill)-model.FeedBackfill, 0):] {
+		r.store.addFeedItem(followerID, id)
+	}
+	return nil
+}
+
+func (r *FeedRepository) Unfollow(ctx context.Context, followerID, followeeID int) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := followKey{followerID, followeeID}
+	if _, ok := r.store.follows[key]; !ok {
+		return postgres.ErrNotFollowing
+	}
+	delete(r.store.follows, key)
+	for id := range r.store.feedItems[followerID] {
+		if r.store.activities[id].actorID == followeeID {
+			delete(r.store.feedItems[followerID], id)
+		}
+	}
+	return nil
+}
+
+func (r *FeedRepository) Followers(ctx context.Context, userID int, opts model.ListOptions) ([]model.Follow, int, error) {
+	return r.follows(ctx, userID, 1, opts)
+}
+
+func (r *FeedRepository) Following(ctx context.Context, userID int, opts model.ListOptions) ([]model.Follow, int, error) {
+	return r.follows(ctx, userID, 0, opts)
+}
+
+// follows lists the other side of the follows whose side self is userID.
+func (r *FeedRepository) follows(ctx context.Context, userID, self int, opts model.ListOptions) ([]model.Follow, int, error) {
+	if err := live(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	r.store.mu.RLock()
+	follows := make([]model.Follow, 0)
+	for key, since := range r.store.follows {
+		if key[self] != userID {
+			continue
+		}
+		other := key[1-self]
+		follows = append(follows, model.Follow{UserID: other, Username: r.store.users[other].Username, Since: since})
+	}
+	r.store.mu.RUnlock()
+
+	if opts.Sort == "" {
+		opts.Sort = "followed"
+	}
+	page, err := sortPage(follows, followSortKeys, func(f model.Follow) int { return f.UserID }, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return page, len(follows), nil
+}
+
+// publishActivity records an activity and copies it into the feed of
+// everyone following the actor. Callers hold the write lock, and call it
+// with the write the activity reports, as they do enqueueRating.
+func (s *Store) publishActivity(kind string, userID int, subject model.ActivitySubject) {
+	s.lastActivityID++
+	a := activityRow{
+		id:        s.lastActivityID,
+		actorID:   userID,
+		kind:      kind,
+		subject:   subject,
+		createdAt: time.Now(),
+	}
+	s.activities[a.id] = a
+	for key := range s.follows {
+		if key[1] == userID {
+			s.addFeedItem(key[0], a.id)
+		}
+	}
+}
+
+// Feed returns up to limit activities from userID's feed older than before,
+// newest first. Activities on lists that are not public are left out.
+func (r *FeedRepository) Feed(ctx context.Context, userID, before, limit int) ([]model.Activity, error) {
+	if err := live(ctx); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var ids []int
+	for id := range r.store.feedItems[userID] {
+		if before <= 0 || id < before {
+			ids = append(ids, id)
+		}
+	}
+	slices.SortFunc(ids, func(a, b int) int { return cmp.Compare(b, a) })
+
+	activities := make([]model.Activity, 0)
+	for _, id := range ids {
+		if len(activities) == limit {
+			break
+		}
+		if a, ok := r.store.feedActivity(r.store.activities[id]); ok {
+			activities = append(activities, a)
+		}
+	}
+	return activities, nil
+}
+
+// addFeedItem puts an activity in a user's feed; callers hold the write
+// lock.
+func (s *Store) addFeedItem(userID, activityID int) {
+	if s.feedItems[userID] == nil {
+		s.feedItems[userID] = make(map[int]bool)
+	}
+	s.feedItems[userID][activityID] = true
+}
+
+// activityAlive reports whether an activity's actor and subject still
+// exist; callers hold the lock.
+func (s *Store) activityAlive(a activityRow) bool {
+	if _, ok := s.users[a.actorID]; !ok {
+		return false
+	}
+
+	var ok bool
+	switch {
+	case a.subject.ReviewID != 0:
+		_, ok = s.reviews[a.subject.ReviewID]
+	case a.subject.DiaryEntryID != 0:
+		_, ok = s.diary[a.subject.DiaryEntryID]
+	case a.subject.ListEntryID != 0:
+		_, ok = s.listEntries[a.subject.ListEntryID]
+	case a.subject.ListID != 0:
+		_, ok = s.lists[a.subject.ListID]
+	}
+	return ok
+}
+
+// feedActivity builds an activity as the feed shows it. It reports false
+// for one that is gone or is on a list that is not public; callers hold the
+// lock.
+func (s *Store) feedActivity(a activityRow) (model.Activity, bool) {
+	if !s.activityAlive(a) {
+		return model.Activity{}, false
+	}
+	out := model.Activity{
+		ID:        a.id,
+		Kind:      a.kind,
+		UserID:    a.actorID,
+		Username:  s.users[a.actorID].Username,
+		CreatedAt: a.createdAt,
+	}
+	movieID := 0
+
+	switch {
+	case a.subject.ReviewID != 0:
+		rev := s.reviews[a.subject.ReviewID]
+		movieID = rev.MovieID
+		out.Score, out.Text = copyScore(&rev.Score), rev.Text
+	case a.subject.DiaryEntryID != 0:
+		d := s.diary[a.subject.DiaryEntryID]
+		movieID = d.movieID
+		out.Score, out.WatchedOn, out.Rewatch = copyScore(d.score), d.watchedOn, d.rewatch
+	}
+	if a.subject.ListID != 0 {
+		l := s.lists[a.subject.ListID]
+		if l.visibility != model.ListPublic {
+			return model.Activity{}, false
+		}
+		out.List = &model.ListRef{ID: l.id, Title: l.title}
+		if a.subject.ListEntryID != 0 {
+			movieID = s.listEntries[a.subject.ListEntryID].movieID
+		}
+	}
+
+	if movieID != 0 {
+		m := s.movies[movieID].Movie
+		out.Movie = &m
+	}
+	return out, true
+}
@@ -0,0 +1,248 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+type GenreRepository struct {
+	store *Store
+}
+
+func NewGenreRepository(store *Store) *GenreRepository {
+	return &GenreRepository{store: store}
+}
+
+func (r *GenreRepository) List(ctx context.Context) ([]model.Genre, error) {
+	if err := live(ctx); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	counts := make(map[int]int, len(r.store.genres))
+	for _, ids := range r.store.movieGenres {
+		for _, id := range ids {
+			counts[id]++
+		}
+	}
+
+	genres := make([]model.Genre, 0, len(r.store.genres))
+	for _, g := range r.store.genres {
+		g.Movies = counts[g.ID]
+		genres = append(genres, g)
+	}
+	sortGenres(genres)
+	return genres, nil
+}
+
+func (r *GenreRepository) GetByID(ctx context.Context, id int) (model.Genre, error) {
+	if err := live(ctx); err != nil {
+		return model.Genre{}, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	g, ok := r.store.genres[id]
+	if !ok {
+		return model.Genre{}, postgres.ErrGenreNotFound
+	}
+	return g, nil
+}
+
+func (r *GenreRepository) GetByName(ctx context.Context, name string) (model.Genre, error) {
+	if err := live(ctx); err != nil {
+		return model.Genre{}, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	if g, ok := r.store.genreNamed(name, 0); ok {
+		return g, nil
+	}
+	return model.Genre{}, postgres.ErrGenreNotFound
+}
+
+func (r *GenreRepository) Create(ctx context.Context, g model.Genre) (model.Genre, error) {
+	if err := live(ctx); err != nil {
+		return model.Genre{}, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.genreNamed(g.Name, 0); ok {
+		return model.Genre{}, postgres.ErrGenreExists
+	}
+	if _, ok := r.store.genreByTMDBID(g.TMDBID); ok {
+		return model.Genre{}, postgres.ErrGenreExists
+	}
+	return r.store.insertGenre(g), nil
+}
+
+func (r *GenreRepository) Update(ctx context.Context, g model.Genre) (model.Genre, error) {
+	if err := live(ctx); err != nil {
+		return model.Genre{}, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	existing, ok := r.store.genres[g.ID]
+	if !ok {
+		return model.Genre{}, postgres.ErrGenreNotFound
+	}
+	if _, ok := r.store.genreNamed(g.Name, g.ID); ok {
+		return model.Genre{}, postgres.ErrGenreExists
+	}
+
+	existing.Name = g.Name
+	r.store.genres[g.ID] = existing
+	return existing, nil
+}
+
+// Delete removes the genre from every movie too, as the ON DELETE CASCADE
+// foreign key does.
+func (r *GenreRepository) Delete(ctx context.Context, id int) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.genres[id]; !ok {
+		return postgres.ErrGenreNotFound
+	}
+
+	delete(r.store.genres, id)
+	for movieID, ids := range r.store.movieGenres {
+		ids = slices.DeleteFunc(slices.Clone(ids), func(g int) bool { return g == id })
+		if len(ids) == 0 {
+			delete(r.store.movieGenres, movieID)
+		} else {
+			r.store.movieGenres[movieID] = ids
+		}
+	}
+	return nil
+}
+
+// EnsureTMDB returns the genre linked to TMDB genre g, linking a same-named
+// genre created by hand or creating a new one, like the Postgres query.
+func (r *GenreRepository) EnsureTMDB(ctx context.Context, g model.Genre) (model.Genre, error) {
+	if err := live(ctx); err != nil {
+		return model.Genre{}, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if existing, ok := r.store.genreByTMDBID(g.TMDBID); ok {
+		return existing, nil
+	}
+	if existing, ok := r.store.genreNamed(g.Name, 0); ok {
+		if existing.TMDBID == 0 {
+			existing.TMDBID = g.TMDBID
+			r.store.genres[existing.ID] = existing
+		}
+		return existing, nil
+	}
+	return r.store.insertGenre(g), nil
+}
+
+func (r *GenreRepository) ForMovies(ctx context.Context, movieIDs []int) (map[int][]model.Genre, error) {
+	if err := live(ctx); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	out := make(map[int][]model.Genre, len(movieIDs))
+	for _, movieID := range movieIDs {
+		ids := r.store.movieGenres[movieID]
+		if len(ids) == 0 {
+			continue
+		}
+		genres := make([]model.Genre, len(ids))
+		for i, id := range ids {
+			genres[i] = r.store.genres[id]
+		}
+		sortGenres(genres)
+		out[movieID] = genres
+	}
+	return out, nil
+}
+
+func (r *GenreRepository) SetMovieGenres(ctx context.Context, movieID int, genreIDs []int) (bool, error) {
+	if err := live(ctx); err != nil {
+		return false, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.movies[movieID]; !ok {
+		return false, postgres.ErrMovieNotFound
+	}
+	want := slices.Compact(slices.Sorted(slices.Values(genreIDs)))
+	for _, id := range want {
+		if _, ok := r.store.genres[id]; !ok {
+			return false, postgres.ErrGenreNotFound
+		}
+	}
+
+	if slices.Equal(r.store.movieGenres[movieID], want) {
+		return false, nil
+	}
+	if len(want) == 0 {
+		delete(r.store.movieGenres, movieID)
+	} else {
+		r.store.movieGenres[movieID] = want
+	}
+	return true, nil
+}
+
+// genreNamed finds a genre other than except by name, ignoring case;
+// callers hold the lock.
+func (s *Store) genreNamed(name string, except int) (model.Genre, bool) {
+	for _, g := range s.genres {
+		if g.ID != except && strings.EqualFold(g.Name, name) {
+			return g, true
+		}
+	}
+	return model.Genre{}, false
+}
+
+// genreByTMDBID finds the genre linked to a TMDB genre; callers hold the
+// lock.
+func (s *Store) genreByTMDBID(tmdbID int) (model.Genre, bool) {
+	if tmdbID == 0 {
+		return model.Genre{}, false
+	}
+	for _, g := range s.genres {
+		if g.TMDBID == tmdbID {
+			return g, true
+		}
+	}
+	return model.Genre{}, false
+}
+
+// insertGenre stores g under a new id; callers hold the write lock.
+func (s *Store) insertGenre(g model.Genre) model.Genre {
+	s.lastGenreID++
+	g.ID = s.lastGenreID
+	g.Movies = 0
+	s.genres[g.ID] = g
+	return g
+}
+
+// sortGenres orders genres like the Postgres queries: by lower-cased name,
+// then id.
+func sortGenres(genres []model.Genre) {
+	slices.SortFunc(genres, func(a, b model.Genre) int {
+		if c := strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+}
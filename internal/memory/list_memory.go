@@ -0,0 +1,493 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+type MovieListRepository struct {
+	store *Store
+}
+
+func NewMovieListRepository(store *Store) *MovieListRepository {
+	return &MovieListRepository{store: store}
+}
+
+type listRow struct {
+	id          int
+	ownerID     int
+	title       string
+	description string
+	visibility  string
+	clonedFrom  *int
+	createdAt   time.Time
+	updatedAt   time.Time
+}
+
+type listEntryRow struct {
+	id        int
+	listID    int
+	movieID   int
+	position  int
+	comment   string
+	addedBy   int
+	createdAt time.Time
+}
+
+var movieListSortKeys = map[string]func(a, b model.MovieList) int{
+	"updated": func(a, b model.MovieList) int { return a.UpdatedAt.Compare(b.UpdatedAt) },
+	"created": func(a, b model.MovieList) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	"title":   func(a, b model.MovieList) int { return strings.Compare(a.Title, b.Title) },
+}
+
+var listEntrySortKeys = map[string]func(a, b model.ListEntry) int{
+	"position": func(a, b model.ListEntry) int { return cmp.Compare(a.Position, b.Position) },
+	"added":    func(a, b model.ListEntry) int { return a.AddedAt.Compare(b.AddedAt) },
+	"title":    func(a, b model.ListEntry) int { return strings.Compare(a.Movie.Title, b.Movie.Title) },
+	"year":     func(a, b model.ListEntry) int { return cmp.Compare(a.Movie.Year, b.Movie.Year) },
+}
+
+func (r *MovieListRepository) List(ctx context.Context, f model.ListFilter, opts model.ListOptions) ([]model.MovieList, int, error) {
+	if err := live(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	r.store.mu.RLock()
+	lists := make([]model.MovieList, 0)
+	for _, l := range r.store.lists {
+		if f.MemberID != 0 && l.ownerID != f.MemberID {
+			if _, ok := r.store.listCollaborators[l.id][f.MemberID]; !ok {
+				continue
+			}
+		}
+		if f.MovieID != 0 {
+			if _, ok := r.store.listEntryRow(l.id, f.MovieID); !ok {
+				continue
+			}
+		}
+		if f.PublicOnly && l.visibility != model.ListPublic {
+			continue
+		}
+		lists = append(lists, r.store.movieList(l))
+	}
+	r.store.mu.RUnlock()
+
+	if opts.Sort == "" {
+		opts.Sort = "updated"
+	}
+	page, err := sortPage(lists, movieListSortKeys, func(l model.MovieList) int { return l.ID }, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return page, len(lists), nil
+}
+
+func (r *MovieListRepository) Get(ctx context.Context, id int) (model.MovieList, error) {
+	if err := live(ctx); err != nil {
+		return model.MovieList{}, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	l, ok := r.store.lists[id]
+	if !ok {
+		return model.MovieList{}, postgres.ErrListNotFound
+	}
+	return r.store.fullMovieList(l), nil
+}
+
+func (r *MovieListRepository) Create(ctx context.Context, l model.MovieList) (model.MovieList, error) {
+	if err := live(ctx); err != nil {
+		return model.MovieList{}, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.users[l.OwnerID]; !ok {
+		return model.MovieList{}, postgres.ErrUserNotFound
+	}
+	row := r.store.insertList(l, nil)
+	r.store.publishActivity(model.ActivityListCreated, l.OwnerID, model.ActivitySubject{ListID: row.id})
+	return r.store.fullMovieList(row), nil
+}
+
+func (r *MovieListRepository) Update(ctx context.Context, l model.MovieList) (model.MovieList, error) {
+	if err := live(ctx); err != nil {
+		return model.MovieList{}, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	row, ok := r.store.lists[l.ID]
+	if !ok {
+		return model.MovieList{}, postgres.ErrListNotFound
+	}
+	row.title, row.description, row.visibility = l.Title, l.Description, l.Visibility
+	row.updatedAt = time.Now()
+	r.store.lists[row.id] = row
+	return r.store.fullMovieList(row), nil
+}
+
+func (r *MovieListRepository) Delete(ctx context.Context, id int) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.lists[id]; !ok {
+		return postgres.ErrListNotFound
+	}
+	r.store.deleteList(id)
+	return nil
+}
+
+// Clone creates l as a copy of list srcID's entries, in the same order and
+// with the same comments, all added by l's owner.
+func (r *MovieListRepository) Clone(ctx context.Context, srcID int, l model.MovieList) (model.MovieList, error) {
+	if err := live(ctx); err != nil {
+		return model.MovieList{}, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.lists[srcID]; !ok {
+		return model.MovieList{}, postgres.ErrListNotFound
+	}
+	if _, ok := r.store.users[l.OwnerID]; !ok {
+		return model.MovieList{}, postgres.ErrUserNotFound
+	}
+
+	row := r.store.insertList(l, &srcID)
+	for n, e := range r.store.listEntryRows(srcID) {
+		r.store.lastListEntryID++
+		r.store.listEntries[r.store.lastListEntryID] = listEntryRow{
+			id:        r.store.lastListEntryID,
+			listID:    row.id,
+			movieID:   e.movieID,
+			position:  n + 1,
+			comment:   e.comment,
+			addedBy:   l.OwnerID,
+			createdAt: row.createdAt,
+		}
+	}
+	r.store.publishActivity(model.ActivityListCreated, l.OwnerID, model.ActivitySubject{ListID: row.id})
+	return r.store.fullMovieList(row), nil
+}
+
+// AddCollaborator is idempotent: adding a collaborator twice keeps the
+// first date.
+func (r *MovieListRepository) AddCollaborator(ctx context.Context, listID, userID int) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.lists[listID]; !ok {
+		return postgres.ErrListNotFound
+	}
+	if _, ok := r.store.users[userID]; !ok {
+		return postgres.ErrUserNotFound
+	}
+	if r.store.listCollaborators[listID] == nil {
+		r.store.listCollaborators[listID] = make(map[int]time.Time)
+	}
+	if _, ok := r.store.listCollaborators[listID][userID]; !ok {
+		r.store.listCollaborators[listID][userID] = time.Now()
+	}
+	return nil
+}
+
+func (r *MovieListRepository) RemoveCollaborator(ctx context.Context, listID, userID int) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.listCollaborators[listID][userID]; !ok {
+		return postgres.ErrCollaboratorNotFound
+	}
+	delete(r.store.listCollaborators[listID], userID)
+	return nil
+}
+
+func (r *MovieListRepository) Entries(ctx context.Context, listID int, opts model.ListOptions) ([]model.ListEntry, int, error) {
+	if err := live(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	r.store.mu.RLock()
+	entries := make([]model.ListEntry, 0)
+	for _, e := range r.store.listEntries {
+		if e.listID == listID {
+			entries = append(entries, r.store.listEntry(e))
+		}
+	}
+	r.store.mu.RUnlock()
+
+	if opts.Sort == "" {
+		opts.Sort = "position"
+	}
+	page, err := sortPage(entries, listEntrySortKeys, func(e model.ListEntry) int { return e.ID }, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return page, len(entries), nil
+}
+
+func (r *MovieListRepository) GetEntry(ctx context.Context, listID, movieID int) (model.ListEntry, error) {
+	if err := live(ctx); err != nil {
+		return model.ListEntry{}, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	e, ok := r.store.listEntryRow(listID, movieID)
+	if !ok {
+		return model.ListEntry{}, postgres.ErrListEntryNotFound
+	}
+	return r.store.listEntry(e), nil
+}
+
+func (r *MovieListRepository) AddEntry(ctx context.Context, listID int, e model.ListEntry) (model.ListEntry, error) {
+	if err := live(ctx); err != nil {
+		return model.ListEntry{}, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if err := r.store.touchList(listID); err != nil {
+		return model.ListEntry{}, err
+	}
+	if _, ok := r.store.movies[e.Movie.ID]; !ok {
+		return model.ListEntry{}, postgres.ErrMovieNotFound
+	}
+	if _, ok := r.store.listEntryRow(listID, e.Movie.ID); ok {
+		return model.ListEntry{}, postgres.ErrListEntryExists
+	}
+
+	last := 0
+	for _, row := range r.store.listEntries {
+		if row.listID == listID {
+			last = max(last, row.position)
+		}
+	}
+	r.store.lastListEntryID++
+	row := listEntryRow{
+		id:        r.store.lastListEntryID,
+		listID:    listID,
+		movieID:   e.Movie.ID,
+		position:  last + 1,
+		comment:   e.Comment,
+		addedBy:   e.AddedBy,
+		createdAt: time.Now(),
+	}
+	r.store.listEntries[row.id] = row
+	r.store.publishActivity(model.ActivityListEntry, e.AddedBy, model.ActivitySubject{ListID: listID, ListEntryID: row.id})
+	return r.store.listEntry(row), nil
+}
+
+func (r *MovieListRepository) UpdateEntry(ctx context.Context, listID int, e model.ListEntry) (model.ListEntry, error) {
+	if err := live(ctx); err != nil {
+		return model.ListEntry{}, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if err := r.store.touchList(listID); err != nil {
+		return model.ListEntry{}, err
+	}
+	row, ok := r.store.listEntryRow(listID, e.Movie.ID)
+	if !ok {
+		return model.ListEntry{}, postgres.ErrListEntryNotFound
+	}
+	row.comment = e.Comment
+	r.store.listEntries[row.id] = row
+	return r.store.listEntry(row), nil
+}
+
+// MoveEntry puts the movie's entry at position (clamped to the list) and
+// renumbers the list from 1, like the Postgres query.
+func (r *MovieListRepository) MoveEntry(ctx context.Context, listID, movieID, position int) (model.ListEntry, error) {
+	if err := live(ctx); err != nil {
+		return model.ListEntry{}, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if err := r.store.touchList(listID); err != nil {
+		return model.ListEntry{}, err
+	}
+	rows := r.store.listEntryRows(listID)
+	i := slices.IndexFunc(rows, func(e listEntryRow) bool { return e.movieID == movieID })
+	if i < 0 {
+		return model.ListEntry{}, postgres.ErrListEntryNotFound
+	}
+	moved := rows[i]
+	rows = slices.Delete(rows, i, i+1)
+	rows = slices.Insert(rows, min(max(position, 1), len(rows)+1)-1, moved)
+
+	for n, e := range rows {
+		e.position = n + 1
+		r.store.listEntries[e.id] = e
+	}
+	return r.store.listEntry(r.store.listEntries[moved.id]), nil
+}
+
+func (r *MovieListRepository) RemoveEntry(ctx context.Context, listID, movieID int) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if err := r.store.touchList(listID); err != nil {
+		return err
+	}
+	e, ok := r.store.listEntryRow(listID, movieID)
+	if !ok {
+		return postgres.ErrListEntryNotFound
+	}
+	delete(r.store.listEntries, e.id)
+	return nil
+}
+
+// insertList stores a new list; callers hold the write lock and have
+// checked the owner.
+func (s *Store) insertList(l model.MovieList, clonedFrom *int) listRow {
+	now := time.Now()
+	s.lastListID++
+	row := listRow{
+		id:          s.lastListID,
+		ownerID:     l.OwnerID,
+		title:       l.Title,
+		description: l.Description,
+		visibility:  l.Visibility,
+		clonedFrom:  clonedFrom,
+		createdAt:   now,
+		updatedAt:   now,
+	}
+	s.lists[row.id] = row
+	return row
+}
+
+// deleteList removes a list with its entries and collaborators, and clears
+// cloned_from on its copies; callers hold the write lock.
+func (s *Store) deleteList(id int) {
+	delete(s.lists, id)
+	delete(s.listCollaborators, id)
+	for entryID, e := range s.listEntries {
+		if e.listID == id {
+			delete(s.listEntries, entryID)
+		}
+	}
+	for _, l := range s.lists {
+		if l.clonedFrom != nil && *l.clonedFrom == id {
+			l.clonedFrom = nil
+			s.lists[l.id] = l
+		}
+	}
+}
+
+// touchList bumps a list's update time, as every entry write does; callers
+// hold the write lock.
+func (s *Store) touchList(id int) error {
+	l, ok := s.lists[id]
+	if !ok {
+		return postgres.ErrListNotFound
+	}
+	l.updatedAt = time.Now()
+	s.lists[id] = l
+	return nil
+}
+
+// listEntryRow finds a list's entry for a movie; callers hold the lock.
+func (s *Store) listEntryRow(listID, movieID int) (listEntryRow, bool) {
+	for _, e := range s.listEntries {
+		if e.listID == listID && e.movieID == movieID {
+			return e, true
+		}
+	}
+	return listEntryRow{}, false
+}
+
+// listEntryRows returns a list's entries in position order; callers hold
+// the lock.
+func (s *Store) listEntryRows(listID int) []listEntryRow {
+	var rows []listEntryRow
+	for _, e := range s.listEntries {
+		if e.listID == listID {
+			rows = append(rows, e)
+		}
+	}
+	slices.SortFunc(rows, func(a, b listEntryRow) int {
+		return cmp.Or(cmp.Compare(a.position, b.position), cmp.Compare(a.id, b.id))
+	})
+	return rows
+}
+
+// movieList joins a list to its owner and counts its entries; callers hold
+// the lock.
+func (s *Store) movieList(l listRow) model.MovieList {
+	entries := 0
+	for _, e := range s.listEntries {
+		if e.listID == l.id {
+			entries++
+		}
+	}
+	var clonedFrom *int
+	if l.clonedFrom != nil {
+		id := *l.clonedFrom
+		clonedFrom = &id
+	}
+	return model.MovieList{
+		ID:          l.id,
+		OwnerID:     l.ownerID,
+		Owner:       s.users[l.ownerID].Username,
+		Title:       l.title,
+		Description: l.description,
+		Visibility:  l.visibility,
+		ClonedFrom:  clonedFrom,
+		Entries:     entries,
+		CreatedAt:   l.createdAt,
+		UpdatedAt:   l.updatedAt,
+	}
+}
+
+// fullMovieList is movieList with the collaborators, earliest added first;
+// callers hold the lock.
+func (s *Store) fullMovieList(l listRow) model.MovieList {
+	list := s.movieList(l)
+	list.Collaborators = make([]model.ListCollaborator, 0, len(s.listCollaborators[l.id]))
+	for userID, added := range s.listCollaborators[l.id] {
+		list.Collaborators = append(list.Collaborators, model.ListCollaborator{
+			UserID:   userID,
+			Username: s.users[userID].Username,
+			AddedAt:  added,
+		})
+	}
+	slices.SortFunc(list.Collaborators, func(a, b model.ListCollaborator) int {
+		return cmp.Or(a.AddedAt.Compare(b.AddedAt), cmp.Compare(a.UserID, b.UserID))
+	})
+	return list
+}
+
+// listEntry joins an entry to its movie; callers hold the lock.
+func (s *Store) listEntry(e listEntryRow) model.ListEntry {
+	return model.ListEntry{
+		ID:       e.id,
+		Movie:    s.movies[e.movieID].Movie,
+		Position: e.position,
+		Comment:  e.comment,
+		AddedBy:  e.addedBy,
+		AddedAt:  e.createdAt,
+	}
+}
@@ -0,0 +1,29 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/AlikhanF2006/Final_project/internal/memory"
+	"github.com/AlikhanF2006/Final_project/internal/storagetest"
+)
+
+func TestStorage(t *testing.T) {
+	store := memory.NewStore()
+	err := storagetest.Run(context.Background(), storagetest.Repos{
+		Movies:     memory.NewMovieRepository(store),
+		Reviews:    memory.NewReviewRepository(store),
+		Users:      memory.NewUserRepository(store),
+		Exports:    memory.NewExportRepository(store),
+		Genres:     memory.NewGenreRepository(store),
+		People:     memory.NewPersonRepository(store),
+		Watchlists: memory.NewWatchlistRepository(store),
+		Diary:      memory.NewDiaryRepository(store),
+		Lists:      memory.NewMovieListRepository(store),
+		Feed:       memory.NewFeedRepository(store),
+		RatingJobs: memory.NewRatingJobRepository(store),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
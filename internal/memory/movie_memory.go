@@ -0,0 +1,331 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/internal/textsearch"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+type MovieRepository struct {
+	store *Store
+}
+
+func NewMovieRepository(store *Store) *MovieRepository {
+	return &MovieRepository{store: store}
+}
+
+var movieSortKeys = map[string]func(a, b movieRow) int{
+	"rating":  func(a, b movieRow) int { return cmp.Compare(a.Rating, b.Rating) },
+	"year":    func(a, b movieRow) int { return cmp.Compare(a.Year, b.Year) },
+	"title":   func(a, b movieRow) int { return strings.Compare(a.Title, b.Title) },
+	"created": func(a, b movieRow) int { return a.createdAt.Compare(b.createdAt) },
+}
+
+func movieRowID(m movieRow) int { return m.ID }
+
+func (r *MovieRepository) SetRating(ctx context.Context, movieID int, rating float64) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if row, ok := r.store.movies[movieID]; ok {
+		row.Rating = rating
+		r.store.movies[movieID] = row
+	}
+	return nil
+}
+
+// Create and Update never take the caller's rating; only SetRating writes
+// it.
+func (r *MovieRepository) Create(ctx context.Context, m model.Movie) (model.Movie, error) {
+	if err := live(ctx); err != nil {
+		return model.Movie{}, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if m.TMDBID != 0 {
+		for _, row := range r.store.movies {
+			if row.TMDBID == m.TMDBID {
+				return model.Movie{}, postgres.ErrMovieExists
+			}
+		}
+	}
+
+	r.store.lastMovieID++
+	m.ID = r.store.lastMovieID
+	m.Rating = 0
+	r.store.movies[m.ID] = movieRow{Movie: m, createdAt: time.Now()}
+	return m, nil
+}
+
+func (r *MovieRepository) GetAll(ctx context.Context) ([]model.Movie, error) {
+	if err := live(ctx); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return r.sorted(), nil
+}
+
+func (r *MovieRepository) GetByID(ctx context.Context, id int) (model.Movie, error) {
+	if err := live(ctx); err != nil {
+		return model.Movie{}, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	row, ok := r.store.movies[id]
+	if !ok {
+		return model.Movie{}, postgres.ErrMovieNotFound
+	}
+	return row.Movie, nil
+}
+
+func (r *MovieRepository) GetByTMDBID(ctx context.Context, tmdbID int) (model.Movie, error) {
+	if err := live(ctx); err != nil {
+		return model.Movie{}, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, row := range r.store.movies {
+		if row.TMDBID == tmdbID {
+			return row.Movie, nil
+		}
+	}
+	return model.Movie{}, postgres.ErrMovieNotFound
+}
+
+func (r *MovieRepository) ExistsByTMDBID(ctx context.Context, tmdbID int) (bool, error) {
+	_, err := r.GetByTMDBID(ctx, tmdbID)
+	if errors.Is(err, postgres.ErrMovieNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *MovieRepository) Update(ctx context.Context, m model.Movie) (model.Movie, error) {
+	if err := live(ctx); err != nil {
+		return model.Movie{}, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	row, ok := r.store.movies[m.ID]
+	if !ok {
+		return model.Movie{}, postgres.ErrMovieNotFound
+	}
+
+	row.Title = m.Title
+	row.Year = m.Year
+	row.Description = m.Description
+	row.Overrides = slices.Clip(slices.Clone(m.Overrides))
+	r.store.movies[m.ID] = row
+	m.Rating = row.Rating
+	return m, nil
+}
+
+// UpsertTMDB inserts a movie fetched from TMDB or refreshes the movie with
+// the same TMDB id, keeping overridden fields and the rating, like the
+// Postgres ON CONFLICT upsert.
+func (r *MovieRepository) UpsertTMDB(ctx context.Context, m model.Movie) (model.Movie, model.UpsertResult, error) {
+	if err := live(ctx); err != nil {
+		return model.Movie{}, 0, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for id, row := range r.store.movies {
+		if row.TMDBID != m.TMDBID {
+			continue
+		}
+
+		merged, changed := row.MergeTMDB(m)
+		if !changed {
+			return row.Movie, model.UpsertUnchanged, nil
+		}
+		next := row
+		next.Movie = merged
+		r.store.movies[id] = next
+		return next.Movie, model.UpsertUpdated, nil
+	}
+
+	r.store.lastMovieID++
+	m.ID = r.store.lastMovieID
+	m.Rating = 0
+	m.Overrides = nil
+	r.store.movies[m.ID] = movieRow{Movie: m, createdAt: time.Now()}
+	return m, model.UpsertCreated, nil
+}
+
+// Delete removes the movie together with its reviews, genres, credits,
+// watchlist and diary entries and pending rating job, as the ON DELETE
+// CASCADE foreign keys do.
+func (r *MovieRepository) Delete(ctx context.Context, id int) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.movies[id]; !ok {
+		return postgres.ErrMovieNotFound
+	}
+
+	delete(r.store.movies, id)
+	delete(r.store.ratingJobs, id)
+	delete(r.store.movieGenres, id)
+	delete(r.store.credits, id)
+	for entryID, w := range r.store.watchlist {
+		if w.movieID == id {
+			delete(r.store.watchlist, entryID)
+		}
+	}
+	for entryID, d := range r.store.diary {
+		if d.movieID == id {
+			delete(r.store.diary, entryID)
+		}
+	}
+	for entryID, e := range r.store.listEntries {
+		if e.movieID == id {
+			delete(r.store.listEntries, entryID)
+		}
+	}
+	for revID, rev := range r.store.reviews {
+		if rev.MovieID == id {
+			delete(r.store.reviews, revID)
+		}
+	}
+	return nil
+}
+
+func (r *MovieRepository) List(ctx context.Context, f model.MovieFilter, opts model.ListOptions) ([]model.Movie, int, error) {
+	if err := live(ctx); err != nil {
+		return nil, 0, err
+	}
+	r.store.mu.RLock()
+	rows := r.rows(f)
+	r.store.mu.RUnlock()
+
+	page, err := sortPage(rows, movieSortKeys, movieRowID, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	movies := make([]model.Movie, len(page))
+	for i, row := range page {
+		movies[i] = row.Movie
+	}
+	return movies, len(rows), nil
+}
+
+// Search approximates the Postgres full-text search: every query word (minus
+// common English stop words) must start a word of the title or description,
+// or the title must be trigram-similar to the query. Ranking and snippets
+// follow the same rules but the scores are not comparable to Postgres ones.
+func (r *MovieRepository) Search(ctx context.Context, query string, f model.MovieFilter, opts model.ListOptions) ([]model.MovieSearchHit, int, error) {
+	if err := live(ctx); err != nil {
+		return nil, 0, err
+	}
+	query = strings.TrimSpace(query)
+
+	byRelevance := opts.Sort == "relevance" || (opts.Sort == "" && query != "")
+
+	r.store.mu.RLock()
+	rows := r.rows(f)
+	r.store.mu.RUnlock()
+
+	terms := textsearch.Terms(query)
+	hits := make([]searchRow, 0)
+	for _, row := range rows {
+		hit := searchRow{movieRow: row}
+		if query != "" {
+			text, matched := textsearch.TextScore(terms, row.Title, row.Description)
+			sim := textsearch.WordSimilarity(query, row.Title)
+			if !matched && sim < textsearch.SimilarityThreshold {
+				continue
+			}
+			hit.rank = text*2 + sim
+			hit.snippet = textsearch.Snippet(terms, row.Title, row.Description)
+		}
+		hits = append(hits, hit)
+	}
+
+	keys := searchSortKeys
+	if byRelevance {
+		keys = map[string]func(a, b searchRow) int{
+			"relevance": func(a, b searchRow) int { return cmp.Compare(b.rank, a.rank) },
+		}
+		opts.Sort, opts.Desc = "relevance", false
+	}
+	page, err := sortPage(hits, keys, func(h searchRow) int { return h.ID }, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	out := make([]model.MovieSearchHit, len(page))
+	for i, h := range page {
+		out[i] = model.MovieSearchHit{Movie: h.Movie, Rank: h.rank, Snippet: h.snippet}
+	}
+	return out, len(hits), nil
+}
+
+type searchRow struct {
+	movieRow
+	rank    float64
+	snippet string
+}
+
+var searchSortKeys = func() map[string]func(a, b searchRow) int {
+	keys := make(map[string]func(a, b searchRow) int, len(movieSortKeys))
+	for name, key := range movieSortKeys {
+		keys[name] = func(a, b searchRow) int { return key(a.movieRow, b.movieRow) }
+	}
+	return keys
+}()
+
+// rows copies the movies f selects; callers hold the read lock.
+func (r *MovieRepository) rows(f model.MovieFilter) []movieRow {
+	rows := make([]movieRow, 0, len(r.store.movies))
+	for _, row := range r.store.movies {
+		if r.store.matches(row, f) {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// sorted returns every movie by id; callers hold the read lock.
+func (r *MovieRepository) sorted() []model.Movie {
+	movies := make([]model.Movie, 0, len(r.store.movies))
+	for _, row := range r.store.movies {
+		movies = append(movies, row.Movie)
+	}
+	slices.SortFunc(movies, func(a, b model.Movie) int { return cmp.Compare(a.ID, b.ID) })
+	return movies
+}
+
+// matches reports whether f selects row; callers hold the read lock.
+func (s *Store) matches(row movieRow, f model.MovieFilter) bool {
+	switch {
+	case f.Year != 0 && row.Year != f.Year,
+		f.YearFrom != 0 && row.Year < f.YearFrom,
+		f.YearTo != 0 && row.Year > f.YearTo:
+		return false
+	case f.GenreID != 0:
+		_, ok := slices.BinarySearch(s.movieGenres[row.ID], f.GenreID)
+		return ok
+	}
+	return true
+}
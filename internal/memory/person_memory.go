@@ -0,0 +1,220 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/internal/textsearch"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+type PersonRepository struct {
+	store *Store
+}
+
+func NewPersonRepository(store *Store) *PersonRepository {
+	return &PersonRepository{store: store}
+}
+
+var personSortKeys = map[string]func(a, b personHit) int{
+	"name": func(a, b personHit) int { return strings.Compare(a.Name, b.Name) },
+}
+
+type personHit struct {
+	model.Person
+	sim float64
+}
+
+func (r *PersonRepository) GetByID(ctx context.Context, id int) (model.Person, error) {
+	if err := live(ctx); err != nil {
+		return model.Person{}, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	p, ok := r.store.people[id]
+	if !ok {
+		return model.Person{}, postgres.ErrPersonNotFound
+	}
+	return p, nil
+}
+
+// Search matches names containing query, ignoring case, or trigram-similar
+// to it, like the Postgres query.
+func (r *PersonRepository) Search(ctx context.Context, query string, opts model.ListOptions) ([]model.Person, int, error) {
+	if err := live(ctx); err != nil {
+		return nil, 0, err
+	}
+	query = strings.TrimSpace(query)
+	lower := strings.ToLower(query)
+
+	r.store.mu.RLock()
+	hits := make([]personHit, 0, len(r.store.people))
+	for _, p := range r.store.people {
+		hit := personHit{Person: p}
+		if query != "" {
+			hit.sim = textsearch.WordSimilarity(query, p.Name)
+			if !strings.Contains(strings.ToLower(p.Name), lower) && hit.sim < textsearch.SimilarityThreshold {
+				continue
+			}
+		}
+		hits = append(hits, hit)
+	}
+	r.store.mu.RUnlock()
+
+	keys := personSortKeys
+	if opts.Sort == "relevance" || (opts.Sort == "" && query != "") {
+		keys = map[string]func(a, b personHit) int{
+			"relevance": func(a, b personHit) int {
+				return cmp.Or(cmp.Compare(b.sim, a.sim), strings.Compare(a.Name, b.Name))
+			},
+		}
+		opts.Sort, opts.Desc = "relevance", false
+	}
+	page, err := sortPage(hits, keys, func(h personHit) int { return h.ID }, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	people := make([]model.Person, len(page))
+	for i, h := range page {
+		people[i] = h.Person
+	}
+	return people, len(hits), nil
+}
+
+func (r *PersonRepository) Filmography(ctx context.Context, personID int) ([]model.FilmographyEntry, error) {
+	if err := live(ctx); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	type entry struct {
+		model.FilmographyEntry
+		order int
+	}
+	entries := make([]entry, 0)
+	for movieID, credits := range r.store.credits {
+		m := r.store.movies[movieID]
+		for _, c := range credits {
+			if c.PersonID != personID {
+				continue
+			}
+			entries = append(entries, entry{
+				FilmographyEntry: model.FilmographyEntry{
+					MovieID:    m.ID,
+					TMDBID:     m.TMDBID,
+					Title:      m.Title,
+					Year:       m.Year,
+					Role:       c.Role,
+					Character:  c.Character,
+					Job:        c.Job,
+					Department: c.Department,
+				},
+				order: c.Order,
+			})
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b entry) int {
+		return cmp.Or(
+			cmp.Compare(b.Year, a.Year),
+			strings.Compare(a.Title, b.Title),
+			cmp.Compare(a.MovieID, b.MovieID),
+			strings.Compare(a.Role, b.Role),
+			cmp.Compare(a.order, b.order),
+		)
+	})
+
+	out := make([]model.FilmographyEntry, len(entries))
+	for i, e := range entries {
+		out[i] = e.FilmographyEntry
+	}
+	return out, nil
+}
+
+func (r *PersonRepository) MovieCredits(ctx context.Context, movieID int) ([]model.Credit, error) {
+	if err := live(ctx); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return r.store.movieCredits(movieID), nil
+}
+
+// SetMovieCredits replaces the movie's credits, adding the people they name
+// and refreshing known ones, like the Postgres upsert.
+func (r *PersonRepository) SetMovieCredits(ctx context.Context, movieID int, credits []model.Credit) (bool, error) {
+	if err := live(ctx); err != nil {
+		return false, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.movies[movieID]; !ok {
+		return false, postgres.ErrMovieNotFound
+	}
+
+	byTMDB := make(map[int]int, len(r.store.people))
+	for id, p := range r.store.people {
+		byTMDB[p.TMDBID] = id
+	}
+	want := slices.SortedFunc(slices.Values(credits), model.CompareCredits)
+	for i, c := range want {
+		id, ok := byTMDB[c.TMDBID]
+		if !ok {
+			r.store.lastPersonID++
+			id = r.store.lastPersonID
+			byTMDB[c.TMDBID] = id
+			r.store.people[id] = model.Person{ID: id, TMDBID: c.TMDBID, Name: c.Name, ProfileURL: c.ProfileURL}
+		} else if p := r.store.people[id]; p.Name != c.Name || p.ProfileURL != c.ProfileURL {
+			p.Name, p.ProfileURL = c.Name, c.ProfileURL
+			r.store.people[id] = p
+		}
+		want[i].PersonID = id
+	}
+
+	current := r.store.movieCredits(movieID)
+	slices.SortFunc(current, model.CompareCredits)
+	if slices.EqualFunc(current, want, model.Credit.SamePart) {
+		return false, nil
+	}
+
+	stored := make([]model.Credit, len(want))
+	for i, c := range want {
+		stored[i] = model.Credit{
+			PersonID:   c.PersonID,
+			Role:       c.Role,
+			Character:  c.Character,
+			Job:        c.Job,
+			Department: c.Department,
+			Order:      c.Order,
+		}
+	}
+	if len(stored) == 0 {
+		delete(r.store.credits, movieID)
+	} else {
+		r.store.credits[movieID] = stored
+	}
+	return true, nil
+}
+
+// movieCredits returns the movie's credits with the people's details, cast
+// first in billing order; callers hold the read lock.
+func (s *Store) movieCredits(movieID int) []model.Credit {
+	stored := s.credits[movieID]
+	credits := make([]model.Credit, len(stored))
+	for i, c := range stored {
+		p := s.people[c.PersonID]
+		c.TMDBID, c.Name, c.ProfileURL = p.TMDBID, p.Name, p.ProfileURL
+		credits[i] = c
+	}
+	slices.SortStableFunc(credits, func(a, b model.Credit) int {
+		return cmp.Or(strings.Compare(a.Role, b.Role), cmp.Compare(a.Order, b.Order))
+	})
+	return credits
+}
@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"context"
+	"time"
+)
+
+type RatingJobRepository struct {
+	store *Store
+}
+
+func NewRatingJobRepository(store *Store) *RatingJobRepository {
+	return &RatingJobRepository{store: store}
+}
+
+// ProcessNext recomputes the rating of the movie that has waited longest and
+// drops its job. It returns false when no job is queued.
+func (r *RatingJobRepository) ProcessNext(ctx context.Context) (bool, error) {
+	if err := live(ctx); err != nil {
+		return false, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	movieID, found := 0, false
+	var oldest time.Time
+	for id, enqueued := range r.store.ratingJobs {
+		if !found || enqueued.Before(oldest) || (enqueued.Equal(oldest) && id < movieID) {
+			movieID, oldest, found = id, enqueued, true
+		}
+	}
+	if !found {
+		return false, nil
+	}
+	delete(r.store.ratingJobs, movieID)
+
+	// Each user counts once: their latest scored diary entry wins over
+	// their review.
+	scores := r.store.latestScores(movieID)
+	for _, rev := range r.store.reviews {
+		if _, ok := scores[rev.UserID]; rev.MovieID == movieID && !ok {
+			scores[rev.UserID] = rev.Score
+		}
+	}
+	var sum, n int
+	for _, score := range scores {
+		sum += score
+		n++
+	}
+	if row, ok := r.store.movies[movieID]; ok {
+		row.Rating = 0
+		if n > 0 {
+			row.Rating = float64(sum) / float64(n)
+		}
+		r.store.movies[movieID] = row
+	}
+	return true, nil
+}
+
+// Pending returns the number of queued jobs.
+func (r *RatingJobRepository) Pending(ctx context.Context) (int, error) {
+	if err := live(ctx); err != nil {
+		return 0, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return len(r.store.ratingJobs), nil
+}
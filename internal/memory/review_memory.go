@@ -0,0 +1,173 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+type ReviewRepository struct {
+	store *Store
+}
+
+func NewReviewRepository(store *Store) *ReviewRepository {
+	return &ReviewRepository{store: store}
+}
+
+var reviewSortKeys = map[string]func(a, b model.Review) int{
+	"created": func(a, b model.Review) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	"score":   func(a, b model.Review) int { return cmp.Compare(a.Score, b.Score) },
+}
+
+func reviewID(r model.Review) int { return r.ID }
+
+func (r *ReviewRepository) Add(ctx context.Context, movieID int, rev model.Review) (model.Review, error) {
+	if err := live(ctx); err != nil {
+		return model.Review{}, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.movies[movieID]; !ok {
+		return model.Review{}, postgres.ErrMovieNotFound
+	}
+	if _, ok := r.store.users[rev.UserID]; !ok {
+		return model.Review{}, postgres.ErrUserNotFound
+	}
+	if _, ok := r.find(movieID, rev.UserID); ok {
+		return model.Review{}, postgres.ErrReviewExists
+	}
+
+	r.store.lastReviewID++
+	rev.ID = r.store.lastReviewID
+	rev.MovieID = movieID
+	rev.CreatedAt = time.Now()
+	r.store.reviews[rev.ID] = rev
+	r.store.enqueueRating(movieID)
+	r.store.publishActivity(model.ActivityReview, rev.UserID, model.ActivitySubject{ReviewID: rev.ID})
+	return rev, nil
+}
+
+func (r *ReviewRepository) ListByMovieID(ctx context.Context, movieID int) ([]model.Review, error) {
+	if err := live(ctx); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	revs := r.byMovie(movieID)
+	r.store.mu.RUnlock()
+
+	slices.SortFunc(revs, func(a, b model.Review) int { return cmp.Compare(a.ID, b.ID) })
+	return revs, nil
+}
+
+func (r *ReviewRepository) PageByMovieID(ctx context.Context, movieID int, opts model.ListOptions) ([]model.Review, int, error) {
+	if err := live(ctx); err != nil {
+		return nil, 0, err
+	}
+	r.store.mu.RLock()
+	revs := r.byMovie(movieID)
+	r.store.mu.RUnlock()
+
+	page, err := sortPage(revs, reviewSortKeys, reviewID, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return page, len(revs), nil
+}
+
+func (r *ReviewRepository) UpdateByMovieAndUser(
+	ctx context.Context,
+	movieID int,
+	userID int,
+	score int,
+) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	rev, ok := r.find(movieID, userID)
+	if !ok {
+		return postgres.ErrReviewNotFound
+	}
+	rev.Score = score
+	r.store.reviews[rev.ID] = rev
+	r.store.enqueueRating(movieID)
+	return nil
+}
+
+func (r *ReviewRepository) DeleteByMovieAndUser(
+	ctx context.Context,
+	movieID int,
+	userID int,
+) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	rev, ok := r.find(movieID, userID)
+	if !ok {
+		return postgres.ErrReviewNotFound
+	}
+	delete(r.store.reviews, rev.ID)
+	r.store.enqueueRating(movieID)
+	return nil
+}
+
+func (r *ReviewRepository) GetByID(ctx context.Context, id int) (model.Review, error) {
+	if err := live(ctx); err != nil {
+		return model.Review{}, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	rev, ok := r.store.reviews[id]
+	if !ok {
+		return model.Review{}, postgres.ErrReviewNotFound
+	}
+	return rev, nil
+}
+
+func (r *ReviewRepository) DeleteByID(ctx context.Context, id int) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	rev, ok := r.store.reviews[id]
+	if !ok {
+		return postgres.ErrReviewNotFound
+	}
+	delete(r.store.reviews, id)
+	r.store.enqueueRating(rev.MovieID)
+	return nil
+}
+
+// find looks up the review userID wrote for movieID; callers hold the lock.
+func (r *ReviewRepository) find(movieID, userID int) (model.Review, bool) {
+	for _, rev := range r.store.reviews {
+		if rev.MovieID == movieID && rev.UserID == userID {
+			return rev, true
+		}
+	}
+	return model.Review{}, false
+}
+
+// byMovie copies the reviews of movieID; callers hold the read lock.
+func (r *ReviewRepository) byMovie(movieID int) []model.Review {
+	revs := make([]model.Review, 0)
+	for _, rev := range r.store.reviews {
+		if rev.MovieID == movieID {
+			revs = append(revs, rev)
+		}
+	}
+	return revs
+}
@@ -0,0 +1,27 @@
+package memory
+
+import "context"
+
+type RoleRepository struct {
+	store *Store
+}
+
+func NewRoleRepository(store *Store) *RoleRepository {
+	return &RoleRepository{store: store}
+}
+
+// LoadGrants returns every role with the permissions it grants: the roles
+// the Postgres migrations seed.
+func (r *RoleRepository) LoadGrants(ctx context.Context) (map[string][]string, error) {
+	if err := live(ctx); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	grants := make(map[string][]string, len(r.store.grants))
+	for role, perms := range r.store.grants {
+		grants[role] = append([]string{}, perms...)
+	}
+	return grants, nil
+}
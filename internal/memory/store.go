@@ -0,0 +1,197 @@
+// Package memory is a storage backend that keeps everything in process
+// memory. It implements the repository interfaces from internal/postgres with
+// the same errors, uniqueness rules and cascades, for unit tests and for
+// running the server without a database (--storage=memory). Nothing survives
+// a restart.
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+// Store holds every table behind one lock, so that cascades and uniqueness
+// checks see a consistent state the way a Postgres transaction would. It is
+// safe for concurrent use; the repositories are thin views over it.
+type Store struct {
+	mu sync.RWMutex
+
+	movies            map[int]movieRow
+	reviews           map[int]model.Review
+	users             map[int]model.User
+	refreshTokens     map[int]model.RefreshToken
+	revokedTokens     map[string]time.Time
+	grants            map[string][]string
+	ratingJobs        map[int]time.Time
+	syncRuns          map[int]model.SyncRun
+	genres            map[int]model.Genre
+	movieGenres       map[int][]int // movie id -> sorted genre ids
+	people            map[int]model.Person
+	credits           map[int][]model.Credit // movie id -> credits, person id only
+	watchlist         map[int]watchlistRow
+	diary             map[int]diaryRow
+	lists             map[int]listRow
+	listEntries       map[int]listEntryRow
+	listCollaborators map[int]map[int]time.Time // list id -> user id -> added at
+	follows           map[followKey]time.Time
+	activities        map[int]activityRow
+	feedItems         map[int]map[int]bool // user id -> activity ids
+
+	lastMovieID     int
+	lastReviewID    int
+	lastUserID      int
+	lastRefreshID   int
+	lastSyncRunID   int
+	lastGenreID     int
+	lastPersonID    int
+	lastWatchID     int
+	lastDiaryID     int
+	lastListID      int
+	lastListEntryID int
+	lastActivityID  int
+}
+
+var (
+	_ postgres.MovieRepo     = (*MovieRepository)(nil)
+	_ postgres.ReviewRepo    = (*ReviewRepository)(nil)
+	_ postgres.UserRepo      = (*UserRepository)(nil)
+	_ postgres.RoleRepo      = (*RoleRepository)(nil)
+	_ postgres.TokenRepo     = (*TokenRepository)(nil)
+	_ postgres.RatingJobRepo = (*RatingJobRepository)(nil)
+	_ postgres.SyncRunRepo   = (*SyncRunRepository)(nil)
+	_ postgres.ExportRepo    = (*ExportRepository)(nil)
+	_ postgres.GenreRepo     = (*GenreRepository)(nil)
+	_ postgres.PersonRepo    = (*PersonRepository)(nil)
+	_ postgres.WatchlistRepo = (*WatchlistRepository)(nil)
+	_ postgres.DiaryRepo     = (*DiaryRepository)(nil)
+	_ postgres.MovieListRepo = (*MovieListRepository)(nil)
+	_ postgres.FeedRepo      = (*FeedRepository)(nil)
+)
+
+type movieRow struct {
+	model.Movie
+	createdAt time.Time
+}
+
+// defaultGrants mirrors the roles seeded by migrations 0005 and 0015.
+var defaultGrants = map[string][]string{
+	"user":      {"review:write"},
+	"analyst":   {"review:write", "data:export"},
+	"moderator": {"review:write", "review:moderate", "movie:write"},
+	"admin":     {"review:write", "review:moderate", "movie:write", "user:admin", "data:export"},
+}
+
+func NewStore() *Store {
+	grants := make(map[string][]string, len(defaultGrants))
+	for role, perms := range defaultGrants {
+		grants[role] = append([]string(nil), perms...)
+	}
+
+	return &Store{
+		movies:            make(map[int]movieRow),
+		reviews:           make(map[int]model.Review),
+		users:             make(map[int]model.User),
+		refreshTokens:     make(map[int]model.RefreshToken),
+		revokedTokens:     make(map[string]time.Time),
+		grants:            grants,
+		ratingJobs:        make(map[int]time.Time),
+		syncRuns:          make(map[int]model.SyncRun),
+		genres:            make(map[int]model.Genre),
+		movieGenres:       make(map[int][]int),
+		people:            make(map[int]model.Person),
+		credits:           make(map[int][]model.Credit),
+		watchlist:         make(map[int]watchlistRow),
+		diary:             make(map[int]diaryRow),
+		lists:             make(map[int]listRow),
+		listEntries:       make(map[int]listEntryRow),
+		listCollaborators: make(map[int]map[int]time.Time),
+		follows:           make(map[followKey]time.Time),
+		activities:        make(map[int]activityRow),
+		feedItems:         make(map[int]map[int]bool),
+	}
+}
+
+// live returns the error a database call would fail with once ctx is done.
+func live(ctx context.Context) error {
+	return db.Classify(ctx.Err())
+}
+
+// enqueueRating is the counterpart of the rating_jobs upsert; callers hold
+// the write lock.
+func (s *Store) enqueueRating(movieID int) {
+	s.ratingJobs[movieID] = time.Now()
+}
+
+// sortPage orders items like postgres.orderBy would (by id without a sort
+// key, with id as the tie-breaker otherwise) and cuts out the requested page.
+func sortPage[T any](
+	items []T,
+	keys map[string]func(a, b T) int,
+	id func(T) int,
+	opts model.ListOptions,
+) ([]T, error) {
+	if err := sortItems(items, keys, id, opts); err != nil {
+		return nil, err
+	}
+
+	limit, offset := pageLimit(opts)
+	if offset >= len(items) {
+		return []T{}, nil
+	}
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[offset:end], nil
+}
+
+// sortItems orders items in place the way sortPage does.
+func sortItems[T any](
+	items []T,
+	keys map[string]func(a, b T) int,
+	id func(T) int,
+	opts model.ListOptions,
+) error {
+	compare := func(a, b T) int { return 0 }
+	if opts.Sort != "" {
+		key, ok := keys[opts.Sort]
+		if !ok {
+			return postgres.ErrBadSort
+		}
+		compare = key
+	}
+
+	slices.SortStableFunc(items, func(a, b T) int {
+		c := compare(a, b)
+		if c == 0 {
+			c = cmp.Compare(id(a), id(b))
+		}
+		if opts.Desc {
+			return -c
+		}
+		return c
+	})
+	return nil
+}
+
+func pageLimit(opts model.ListOptions) (int, int) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = model.DefaultPageLimit
+	}
+	if limit > model.MaxPageLimit {
+		limit = model.MaxPageLimit
+	}
+	offset := opts.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
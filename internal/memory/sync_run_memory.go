@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+type SyncRunRepository struct {
+	store *Store
+}
+
+func NewSyncRunRepository(store *Store) *SyncRunRepository {
+	return &SyncRunRepository{store: store}
+}
+
+func (r *SyncRunRepository) Create(ctx context.Context, run model.SyncRun) (model.SyncRun, error) {
+	if err := live(ctx); err != nil {
+		return model.SyncRun{}, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.lastSyncRunID++
+	run.ID = r.store.lastSyncRunID
+	run.StartedAt = time.Now()
+	run.Lists = slices.Clone(run.Lists)
+	run.Errors = []string{}
+	r.store.syncRuns[run.ID] = run
+	return run, nil
+}
+
+func (r *SyncRunRepository) Finish(ctx context.Context, run model.SyncRun) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.syncRuns[run.ID]
+	if !ok {
+		return nil
+	}
+
+	now := time.Now()
+	run.StartedAt = stored.StartedAt
+	run.Trigger = stored.Trigger
+	run.Lists = stored.Lists
+	run.FinishedAt = &now
+	run.Errors = append([]string{}, run.Errors...)
+	r.store.syncRuns[run.ID] = run
+	return nil
+}
+
+// List returns runs newest first.
+func (r *SyncRunRepository) List(ctx context.Context, opts model.ListOptions) ([]model.SyncRun, int, error) {
+	if err := live(ctx); err != nil {
+		return nil, 0, err
+	}
+	r.store.mu.RLock()
+	runs := make([]model.SyncRun, 0, len(r.store.syncRuns))
+	for _, run := range r.store.syncRuns {
+		runs = append(runs, run)
+	}
+	r.store.mu.RUnlock()
+
+	opts.Sort, opts.Desc = "", true
+	page, err := sortPage(runs, nil, func(run model.SyncRun) int { return run.ID }, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return page, len(runs), nil
+}
@@ -0,0 +1,154 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+type TokenRepository struct {
+	store *Store
+}
+
+func NewTokenRepository(store *Store) *TokenRepository {
+	return &TokenRepository{store: store}
+}
+
+func (r *TokenRepository) CreateRefreshToken(ctx context.Context, t model.RefreshToken) (model.RefreshToken, error) {
+	if err := live(ctx); err != nil {
+		return model.RefreshToken{}, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	return r.insert(t), nil
+}
+
+func (r *TokenRepository) GetRefreshToken(ctx context.Context, hash string) (model.RefreshToken, error) {
+	if err := live(ctx); err != nil {
+		return model.RefreshToken{}, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, t := range r.store.refreshTokens {
+		if t.TokenHash == hash {
+			return cloneRefreshToken(t), nil
+		}
+	}
+	return model.RefreshToken{}, postgres.ErrRefreshTokenNotFound
+}
+
+// RotateRefreshToken revokes oldID and stores next in its place. If oldID was
+// already revoked it returns postgres.ErrRefreshTokenReused and stores
+// nothing.
+func (r *TokenRepository) RotateRefreshToken(ctx context.Context, oldID int, next model.RefreshToken) (model.RefreshToken, error) {
+	if err := live(ctx); err != nil {
+		return model.RefreshToken{}, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	old, ok := r.store.refreshTokens[oldID]
+	if !ok || old.RevokedAt != nil {
+		return model.RefreshToken{}, postgres.ErrRefreshTokenReused
+	}
+
+	next = r.insert(next)
+	now := time.Now()
+	old.RevokedAt = &now
+	old.ReplacedBy = &next.ID
+	r.store.refreshTokens[oldID] = old
+	return next, nil
+}
+
+func (r *TokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	return r.revokeWhere(ctx, func(t model.RefreshToken) bool { return t.FamilyID == familyID })
+}
+
+func (r *TokenRepository) RevokeAllForUser(ctx context.Context, userID int) error {
+	return r.revokeWhere(ctx, func(t model.RefreshToken) bool { return t.UserID == userID })
+}
+
+// RevokeAccessToken denylists one access token until it expires. Expired
+// denylist entries are pruned on the way.
+func (r *TokenRepository) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := time.Now()
+	for id, exp := range r.store.revokedTokens {
+		if exp.Before(now) {
+			delete(r.store.revokedTokens, id)
+		}
+	}
+	if _, ok := r.store.revokedTokens[jti]; !ok {
+		r.store.revokedTokens[jti] = expiresAt
+	}
+	return nil
+}
+
+// SessionState returns the user's current token version and whether jti has
+// been denylisted. It returns postgres.ErrUserNotFound once the user is
+// deleted.
+func (r *TokenRepository) SessionState(ctx context.Context, userID int, jti string) (int, bool, error) {
+	if err := live(ctx); err != nil {
+		return 0, false, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	u, ok := r.store.users[userID]
+	if !ok {
+		return 0, false, postgres.ErrUserNotFound
+	}
+	_, revoked := r.store.revokedTokens[jti]
+	return u.TokenVersion, revoked, nil
+}
+
+// insert stores t under a new id; callers hold the lock.
+func (r *TokenRepository) insert(t model.RefreshToken) model.RefreshToken {
+	r.store.lastRefreshID++
+	t.ID = r.store.lastRefreshID
+	t.CreatedAt = time.Now()
+	t.RevokedAt, t.ReplacedBy = nil, nil
+	r.store.refreshTokens[t.ID] = t
+	return t
+}
+
+func (r *TokenRepository) revokeWhere(ctx context.Context, match func(model.RefreshToken) bool) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := time.Now()
+	for id, t := range r.store.refreshTokens {
+		if t.RevokedAt == nil && match(t) {
+			revokedAt := now
+			t.RevokedAt = &revokedAt
+			r.store.refreshTokens[id] = t
+		}
+	}
+	return nil
+}
+
+// cloneRefreshToken copies the pointer fields so callers cannot change the
+// stored token.
+func cloneRefreshToken(t model.RefreshToken) model.RefreshToken {
+	if t.RevokedAt != nil {
+		revokedAt := *t.RevokedAt
+		t.RevokedAt = &revokedAt
+	}
+	if t.ReplacedBy != nil {
+		replacedBy := *t.ReplacedBy
+		t.ReplacedBy = &replacedBy
+	}
+	return t
+}
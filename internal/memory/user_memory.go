@@ -0,0 +1,214 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+type UserRepository struct {
+	store *Store
+}
+
+func NewUserRepository(store *Store) *UserRepository {
+	return &UserRepository{store: store}
+}
+
+func (r *UserRepository) Create(ctx context.Context, u model.User) (model.User, error) {
+	if err := live(ctx); err != nil {
+		return model.User{}, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if r.taken(u) {
+		return model.User{}, postgres.ErrUserExists
+	}
+	if u.Role == "" {
+		u.Role = "user"
+	}
+
+	r.store.lastUserID++
+	u.ID = r.store.lastUserID
+	u.TokenVersion = 0
+	u.CreatedAt = time.Now()
+	r.store.users[u.ID] = u
+	return u, nil
+}
+
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (model.User, error) {
+	if err := live(ctx); err != nil {
+		return model.User{}, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, u := range r.store.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return model.User{}, postgres.ErrUserNotFound
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id int) (model.User, error) {
+	if err := live(ctx); err != nil {
+		return model.User{}, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	u, ok := r.store.users[id]
+	if !ok {
+		return model.User{}, postgres.ErrUserNotFound
+	}
+	return u, nil
+}
+
+// Update changes the username and email. Like the UPDATE it mirrors, it does
+// nothing for an unknown id.
+func (r *UserRepository) Update(ctx context.Context, u model.User) (model.User, error) {
+	if err := live(ctx); err != nil {
+		return model.User{}, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.users[u.ID]
+	if !ok {
+		return model.User{}, postgres.ErrUserNotFound
+	}
+	if r.taken(u) {
+		return model.User{}, postgres.ErrUserExists
+	}
+	stored.Username = u.Username
+	stored.Email = u.Email
+	r.store.users[u.ID] = stored
+	return u, nil
+}
+
+// UpdatePassword also bumps the token version, which invalidates every access
+// token issued before the change.
+func (r *UserRepository) UpdatePassword(ctx context.Context, id int, hash string) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	u, ok := r.store.users[id]
+	if !ok {
+		return postgres.ErrUserNotFound
+	}
+	u.PasswordHash = hash
+	u.TokenVersion++
+	r.store.users[id] = u
+	return nil
+}
+
+func (r *UserRepository) BumpTokenVersion(ctx context.Context, id int) error {
+	return r.modify(ctx, id, func(u *model.User) {
+		u.TokenVersion++
+	})
+}
+
+func (r *UserRepository) UpdateRole(ctx context.Context, id int, role string) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	u, ok := r.store.users[id]
+	if !ok {
+		return postgres.ErrUserNotFound
+	}
+	u.Role = role
+	u.TokenVersion++
+	r.store.users[id] = u
+	return nil
+}
+
+// Delete removes the user with their reviews, refresh tokens, watchlist and
+// diary, as the ON DELETE CASCADE foreign keys do, and queues a rating
+// recomputation for every movie those reviews and diary entries scored.
+func (r *UserRepository) Delete(ctx context.Context, id int) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	delete(r.store.users, id)
+	for revID, rev := range r.store.reviews {
+		if rev.UserID == id {
+			delete(r.store.reviews, revID)
+			r.store.enqueueRating(rev.MovieID)
+		}
+	}
+	for tokenID, t := range r.store.refreshTokens {
+		if t.UserID == id {
+			delete(r.store.refreshTokens, tokenID)
+		}
+	}
+	for entryID, w := range r.store.watchlist {
+		if w.userID == id {
+			delete(r.store.watchlist, entryID)
+		}
+	}
+	for entryID, d := range r.store.diary {
+		if d.userID == id {
+			delete(r.store.diary, entryID)
+			r.store.enqueueRating(d.movieID)
+		}
+	}
+	for listID, l := range r.store.lists {
+		if l.ownerID == id {
+			r.store.deleteList(listID)
+		}
+	}
+	for _, collaborators := range r.store.listCollaborators {
+		delete(collaborators, id)
+	}
+	for entryID, e := range r.store.listEntries {
+		if e.addedBy == id {
+			e.addedBy = 0
+			r.store.listEntries[entryID] = e
+		}
+	}
+	for key := range r.store.follows {
+		if key[0] == id || key[1] == id {
+			delete(r.store.follows, key)
+		}
+	}
+	delete(r.store.feedItems, id)
+	return nil
+}
+
+// modify applies fn to a stored user; an unknown id is not an error.
+func (r *UserRepository) modify(ctx context.Context, id int, fn func(*model.User)) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if u, ok := r.store.users[id]; ok {
+		fn(&u)
+		r.store.users[id] = u
+	}
+	return nil
+}
+
+// taken reports whether another user already has u's username or email;
+// callers hold the lock.
+func (r *UserRepository) taken(u model.User) bool {
+	for _, other := range r.store.users {
+		if other.ID != u.ID && (other.Username == u.Username || other.Email == u.Email) {
+			return true
+		}
+	}
+	return false
+}
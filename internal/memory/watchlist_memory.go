@@ -0,0 +1,215 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+type WatchlistRepository struct {
+	store *Store
+}
+
+func NewWatchlistRepository(store *Store) *WatchlistRepository {
+	return &WatchlistRepository{store: store}
+}
+
+type watchlistRow struct {
+	id         int
+	userID     int
+	movieID    int
+	position   int
+	note       string
+	plannedFor string
+	createdAt  time.Time
+}
+
+var watchlistSortKeys = map[string]func(a, b model.WatchlistEntry) int{
+	"position": func(a, b model.WatchlistEntry) int { return cmp.Compare(a.Position, b.Position) },
+	"added":    func(a, b model.WatchlistEntry) int { return a.AddedAt.Compare(b.AddedAt) },
+	"planned":  func(a, b model.WatchlistEntry) int { return comparePlanned(a.PlannedFor, b.PlannedFor) },
+	"title":    func(a, b model.WatchlistEntry) int { return strings.Compare(a.Movie.Title, b.Movie.Title) },
+	"year":     func(a, b model.WatchlistEntry) int { return cmp.Compare(a.Movie.Year, b.Movie.Year) },
+}
+
+// comparePlanned orders entries without a date last, as Postgres orders
+// NULLs.
+func comparePlanned(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+	return strings.Compare(a, b)
+}
+
+func (r *WatchlistRepository) List(ctx context.Context, userID int, f model.WatchlistFilter, opts model.ListOptions) ([]model.WatchlistEntry, int, error) {
+	if err := live(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	r.store.mu.RLock()
+	entries := make([]model.WatchlistEntry, 0)
+	for _, w := range r.store.watchlist {
+		if w.userID != userID || !r.store.matches(r.store.movies[w.movieID], f.MovieFilter) {
+			continue
+		}
+		if f.DueBy != "" && (w.plannedFor == "" || w.plannedFor > f.DueBy) {
+			continue
+		}
+		entries = append(entries, r.store.watchlistEntry(w))
+	}
+	r.store.mu.RUnlock()
+
+	if opts.Sort == "" {
+		opts.Sort = "position"
+	}
+	page, err := sortPage(entries, watchlistSortKeys, func(e model.WatchlistEntry) int { return e.ID }, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return page, len(entries), nil
+}
+
+func (r *WatchlistRepository) Get(ctx context.Context, userID, movieID int) (model.WatchlistEntry, error) {
+	if err := live(ctx); err != nil {
+		return model.WatchlistEntry{}, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	w, ok := r.store.watchlistRow(userID, movieID)
+	if !ok {
+		return model.WatchlistEntry{}, postgres.ErrWatchlistEntryNotFound
+	}
+	return r.store.watchlistEntry(w), nil
+}
+
+func (r *WatchlistRepository) Add(ctx context.Context, userID int, e model.WatchlistEntry) (model.WatchlistEntry, error) {
+	if err := live(ctx); err != nil {
+		return model.WatchlistEntry{}, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.movies[e.Movie.ID]; !ok {
+		return model.WatchlistEntry{}, postgres.ErrMovieNotFound
+	}
+	if _, ok := r.store.watchlistRow(userID, e.Movie.ID); ok {
+		return model.WatchlistEntry{}, postgres.ErrWatchlistEntryExists
+	}
+
+	last := 0
+	for _, w := range r.store.watchlist {
+		if w.userID == userID {
+			last = max(last, w.position)
+		}
+	}
+	r.store.lastWatchID++
+	w := watchlistRow{
+		id:         r.store.lastWatchID,
+		userID:     userID,
+		movieID:    e.Movie.ID,
+		position:   last + 1,
+		note:       e.Note,
+		plannedFor: e.PlannedFor,
+		createdAt:  time.Now(),
+	}
+	r.store.watchlist[w.id] = w
+	return r.store.watchlistEntry(w), nil
+}
+
+func (r *WatchlistRepository) Update(ctx context.Context, userID int, e model.WatchlistEntry) (model.WatchlistEntry, error) {
+	if err := live(ctx); err != nil {
+		return model.WatchlistEntry{}, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	w, ok := r.store.watchlistRow(userID, e.Movie.ID)
+	if !ok {
+		return model.WatchlistEntry{}, postgres.ErrWatchlistEntryNotFound
+	}
+	w.note, w.plannedFor = e.Note, e.PlannedFor
+	r.store.watchlist[w.id] = w
+	return r.store.watchlistEntry(w), nil
+}
+
+// Move puts the movie's entry at position (clamped to the list) and
+// renumbers the watchlist from 1, like the Postgres query.
+func (r *WatchlistRepository) Move(ctx context.Context, userID, movieID, position int) (model.WatchlistEntry, error) {
+	if err := live(ctx); err != nil {
+		return model.WatchlistEntry{}, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var rows []watchlistRow
+	for _, w := range r.store.watchlist {
+		if w.userID == userID {
+			rows = append(rows, w)
+		}
+	}
+	slices.SortFunc(rows, func(a, b watchlistRow) int {
+		return cmp.Or(cmp.Compare(a.position, b.position), cmp.Compare(a.id, b.id))
+	})
+
+	i := slices.IndexFunc(rows, func(w watchlistRow) bool { return w.movieID == movieID })
+	if i < 0 {
+		return model.WatchlistEntry{}, postgres.ErrWatchlistEntryNotFound
+	}
+	moved := rows[i]
+	rows = slices.Delete(rows, i, i+1)
+	rows = slices.Insert(rows, min(max(position, 1), len(rows)+1)-1, moved)
+
+	for n, w := range rows {
+		w.position = n + 1
+		r.store.watchlist[w.id] = w
+	}
+	return r.store.watchlistEntry(r.store.watchlist[moved.id]), nil
+}
+
+func (r *WatchlistRepository) Remove(ctx context.Context, userID, movieID int) error {
+	if err := live(ctx); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	w, ok := r.store.watchlistRow(userID, movieID)
+	if !ok {
+		return postgres.ErrWatchlistEntryNotFound
+	}
+	delete(r.store.watchlist, w.id)
+	return nil
+}
+
+// watchlistRow finds the user's entry for a movie; callers hold the lock.
+func (s *Store) watchlistRow(userID, movieID int) (watchlistRow, bool) {
+	for _, w := range s.watchlist {
+		if w.userID == userID && w.movieID == movieID {
+			return w, true
+		}
+	}
+	return watchlistRow{}, false
+}
+
+// watchlistEntry joins an entry to its movie; callers hold the lock.
+func (s *Store) watchlistEntry(w watchlistRow) model.WatchlistEntry {
+	return model.WatchlistEntry{
+		ID:         w.id,
+		Movie:      s.movies[w.movieID].Movie,
+		Position:   w.position,
+		Note:       w.note,
+		PlannedFor: w.plannedFor,
+		AddedAt:    w.createdAt,
+	}
+}
@@ -1,25 +1,32 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/AlikhanF2006/Final_project/internal/auth"
 )
 
 const (
-	UserIDKey   = "user_id"
-	UserRoleKey = "user_role"
+	UserIDKey       = "user_id"
+	UserRoleKey     = "user_role"
+	TokenIDKey      = "token_id"
+	TokenExpiresKey = "token_expires"
 )
 
-type JWTClaims struct {
-	UserID int    `json:"user_id"`
-	Role   string `json:"role"`
-	jwt.RegisteredClaims
+// SessionChecker decides whether a signature-valid token has been revoked
+// (logout, password change, deleted account).
+type SessionChecker interface {
+	CheckSession(ctx context.Context, userID int, version int, jti string) error
 }
 
-func AuthMiddleware(secret string) gin.HandlerFunc {
+// AuthMiddleware verifies the bearer token with tokens and then asks sessions
+// whether it has been revoked.
+func AuthMiddleware(tokens *auth.TokenService, sessions SessionChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -39,31 +46,33 @@ func AuthMiddleware(secret string) gin.HandlerFunc {
 
 		tokenStr := parts[1]
 
-		token, err := jwt.ParseWithClaims(
-			tokenStr,
-			&JWTClaims{},
-			func(token *jwt.Token) (interface{}, error) {
-				return []byte(secret), nil
-			},
-		)
-
-		if err != nil || !token.Valid {
+		claims, err := tokens.Parse(tokenStr)
+		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error": "invalid or expired token",
 			})
 			return
 		}
 
-		claims, ok := token.Claims.(*JWTClaims)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "invalid token claims",
+		if err := sessions.CheckSession(c.Request.Context(), claims.UserID, claims.Version, claims.ID); err != nil {
+			if errors.Is(err, auth.ErrSessionRevoked) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+					"error": "token revoked",
+				})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error": "cannot verify session",
 			})
 			return
 		}
 
 		c.Set(UserIDKey, claims.UserID)
 		c.Set(UserRoleKey, claims.Role)
+		c.Set(TokenIDKey, claims.ID)
+		if claims.ExpiresAt != nil {
+			c.Set(TokenExpiresKey, claims.ExpiresAt.Time)
+		}
 
 		c.Next()
 	}
@@ -0,0 +1,114 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/AlikhanF2006/Final_project/internal/auth"
+	"github.com/AlikhanF2006/Final_project/internal/memory"
+	"github.com/AlikhanF2006/Final_project/internal/middleware"
+	"github.com/AlikhanF2006/Final_project/internal/service"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+type failingSessions struct{}
+
+func (failingSessions) CheckSession(context.Context, int, int, string) error {
+	return errors.New("connection refused")
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+
+	tokens, err := auth.NewTokenService(auth.TokenConfig{
+		Issuer:       "movies-test",
+		Audience:     "movies-test",
+		TTL:          time.Minute,
+		SigningKeyID: "k1",
+		Keys:         []auth.Key{{ID: "k1", Algorithm: auth.AlgHS256, Secret: []byte("test-secret")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := memory.NewStore()
+	users := memory.NewUserRepository(store)
+	sessions := service.NewAuthService(users, memory.NewTokenRepository(store), tokens, time.Hour)
+	u, err := users.Create(ctx, model.User{Username: "ann", Email: "ann@example.com", PasswordHash: "x", Role: auth.RoleUser})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issue := func() string {
+		tok, err := sessions.IssueTokens(ctx, u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok.AccessToken
+	}
+	loggedOut := issue()
+	claims, err := tokens.Parse(loggedOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sessions.Logout(ctx, u.ID, claims.ID, claims.ExpiresAt.Time, "", false); err != nil {
+		t.Fatal(err)
+	}
+	live := issue()
+
+	tests := []struct {
+		name     string
+		header   string
+		sessions middleware.SessionChecker
+		want     int
+		// wantError is the error message, for cases where the status alone
+		// does not say which check failed.
+		wantError string
+	}{
+		{name: "valid token", header: "Bearer " + live, want: http.StatusOK},
+		{name: "missing header", want: http.StatusUnauthorized},
+		{name: "wrong scheme", header: "Basic " + live, want: http.StatusUnauthorized},
+		{name: "garbage token", header: "Bearer not-a-jwt", want: http.StatusUnauthorized},
+		{name: "denylisted jti", header: "Bearer " + loggedOut, want: http.StatusUnauthorized, wantError: "token revoked"},
+		{name: "session store down", header: "Bearer " + live, sessions: failingSessions{}, want: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := tt.sessions
+			if checker == nil {
+				checker = sessions
+			}
+
+			r := gin.New()
+			r.GET("/me", middleware.AuthMiddleware(tokens, checker), func(c *gin.Context) {
+				if c.GetInt(middleware.UserIDKey) != u.ID || c.GetString(middleware.TokenIDKey) == "" {
+					t.Errorf("claims not set on the context: %v", c.Keys)
+				}
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("status: got %d, want %d (body %s)", w.Code, tt.want, w.Body)
+			}
+			if tt.wantError != "" && !strings.Contains(w.Body.String(), tt.wantError) {
+				t.Errorf("body: got %s, want error %q", w.Body, tt.wantError)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/AlikhanF2006/Final_project/internal/auth"
+)
+
+// RequireRole lets the request through only if the token's role is one of
+// roles. It must run after AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !slices.Contains(roles, c.GetString(UserRoleKey)) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "forbidden",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission lets the request through only if the token's role is
+// granted perm by policy. It must run after AuthMiddleware.
+func RequirePermission(policy *auth.Policy, perm auth.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !policy.Can(c.GetString(UserRoleKey), perm) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "missing permission " + string(perm),
+			})
+			return
+		}
+		c.Next()
+	}
+}
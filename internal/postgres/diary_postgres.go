@@ -0,0 +1,216 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/AlikhanF2006/Final_project/model"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+var ErrDiaryEntryNotFound = errors.New("diary entry not found")
+
+const diaryColumns = `d.id, to_char(d.watched_on, 'YYYY-MM-DD'), d.rewatch, d.score, d.note, d.created_at,
+	movies.id, movies.tmdb_id, movies.title, movies.year, movies.description, movies.rating, movies.overrides`
+
+const diaryFrom = `
+	FROM diary_entries d
+	JOIN movies ON movies.id = d.movie_id`
+
+// diaryFields returns scan targets for diaryColumns.
+func diaryFields(e *model.DiaryEntry) []any {
+	return append([]any{&e.ID, &e.WatchedOn, &e.Rewatch, &e.Score, &e.Note, &e.CreatedAt}, movieFields(&e.Movie)...)
+}
+
+type DiaryRepository struct {
+	db *db.DB
+}
+
+func NewDiaryRepository(database *db.DB) *DiaryRepository {
+	return &DiaryRepository{db: database}
+}
+
+// List returns the user's entries in f, oldest watch first unless sort is
+// given.
+func (r *DiaryRepository) List(ctx context.Context, userID int, f model.DiaryFilter, opts model.ListOptions) ([]model.DiaryEntry, int, error) {
+	order, err := qualifiedOrder(diarySortColumns, "watched", "d.id", opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	limit, offset := pageLimit(opts)
+
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	where := ""
+	args := []any{userID}
+	if f.MovieID != 0 {
+		args = append(args, f.MovieID)
+		where += fmt.Sprintf("\n\t\t  AND d.movie_id = $%d", len(args))
+	}
+	if f.From != "" {
+		args = append(args, f.From)
+		where += fmt.Sprintf("\n\t\t  AND d.watched_on >= $%d::date", len(args))
+	}
+	if f.To != "" {
+		args = append(args, f.To)
+		where += fmt.Sprintf("\n\t\t  AND d.watched_on <= $%d::date", len(args))
+	}
+	from := diaryFrom + `
+		WHERE d.user_id = $1` + where
+
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*)`+from, args...).Scan(&total); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	rows, err := r.db.Query(
+		ctx,
+		fmt.Sprintf(`SELECT `+diaryColumns+from+`
+		ORDER BY `+order+`
+		LIMIT $%d OFFSET $%d`, len(args)+1, len(args)+2),
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, db.Classify(err)
+	}
+	defer rows.Close()
+
+	entries := make([]model.DiaryEntry, 0)
+	for rows.Next() {
+		var e model.DiaryEntry
+		if err := rows.Scan(diaryFields(&e)...); err != nil {
+			return nil, 0, db.Classify(err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, db.Classify(rows.Err())
+}
+
+func (r *DiaryRepository) Get(ctx context.Context, userID, id int) (model.DiaryEntry, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	var e model.DiaryEntry
+	err := r.db.QueryRow(
+		ctx,
+		`SELECT `+diaryColumns+diaryFrom+`
+		WHERE d.user_id = $1 AND d.id = $2`,
+		userID,
+		id,
+	).Scan(diaryFields(&e)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.DiaryEntry{}, ErrDiaryEntryNotFound
+	}
+	return e, db.Classify(err)
+}
+
+func (r *DiaryRepository) Add(ctx context.Context, userID int, e model.DiaryEntry) (model.DiaryEntry, error) {
+	var id int
+	err := withRatingJob(ctx, r.db, func(ctx context.Context, tx pgx.Tx) (int, error) {
+		if err := tx.QueryRow(
+			ctx,
+			`INSERT INTO diary_entries (user_id, movie_id, watched_on, rewatch, score, note)
+			VALUES ($1, $2, $3::date, $4, $5, $6)
+			RETURNING id`,
+			userID,
+			e.Movie.ID,
+			e.WatchedOn,
+			e.Rewatch,
+			e.Score,
+			e.Note,
+		).Scan(&id); err != nil {
+			return 0, err
+		}
+		return e.Movie.ID, publishActivity(ctx, tx, model.ActivityDiary, userID, model.ActivitySubject{DiaryEntryID: id})
+	})
+	switch {
+	case violates(err, foreignKeyViolationCode, "diary_entries_movie_id_fkey"):
+		return model.DiaryEntry{}, ErrMovieNotFound
+	case err != nil:
+		return model.DiaryEntry{}, err
+	}
+
+	return r.Get(ctx, userID, id)
+}
+
+// Update sets the date, rewatch flag, score and note of entry e.ID.
+func (r *DiaryRepository) Update(ctx context.Context, userID int, e model.DiaryEntry) (model.DiaryEntry, error) {
+	err := withRatingJob(ctx, r.db, func(ctx context.Context, tx pgx.Tx) (int, error) {
+		var movieID int
+		err := tx.QueryRow(
+			ctx,
+			`UPDATE diary_entries SET watched_on = $3::date, rewatch = $4, score = $5, note = $6
+			WHERE user_id = $1 AND id = $2
+			RETURNING movie_id`,
+			userID,
+			e.ID,
+			e.WatchedOn,
+			e.Rewatch,
+			e.Score,
+			e.Note,
+		).Scan(&movieID)
+		return movieID, err
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.DiaryEntry{}, ErrDiaryEntryNotFound
+	}
+	if err != nil {
+		return model.DiaryEntry{}, err
+	}
+
+	return r.Get(ctx, userID, e.ID)
+}
+
+func (r *DiaryRepository) Delete(ctx context.Context, userID, id int) error {
+	err := withRatingJob(ctx, r.db, func(ctx context.Context, tx pgx.Tx) (int, error) {
+		var movieID int
+		err := tx.QueryRow(
+			ctx,
+			`DELETE FROM diary_entries WHERE user_id = $1 AND id = $2 RETURNING movie_id`,
+			userID,
+			id,
+		).Scan(&movieID)
+		return movieID, err
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrDiaryEntryNotFound
+	}
+	return err
+}
+
+// Days counts the user's watches per date from from to to, inclusive,
+// skipping the dates without any.
+func (r *DiaryRepository) Days(ctx context.Context, userID int, from, to string) ([]model.DiaryDay, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	rows, err := r.db.Query(
+		ctx,
+		`SELECT to_char(watched_on, 'YYYY-MM-DD'), COUNT(*)
+		FROM diary_entries
+		WHERE user_id = $1 AND watched_on BETWEEN $2::date AND $3::date
+		GROUP BY watched_on
+		ORDER BY watched_on`,
+		userID,
+		from,
+		to,
+	)
+	if err != nil {
+		return nil, db.Classify(err)
+	}
+	defer rows.Close()
+
+	days := make([]model.DiaryDay, 0)
+	for rows.Next() {
+		var d model.DiaryDay
+		if err := rows.Scan(&d.Date, &d.Watches); err != nil {
+			return nil, db.Classify(err)
+		}
+		days = append(days, d)
+	}
+	return days, db.Classify(rows.Err())
+}
@@ -0,0 +1,17 @@
+package dto
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type RefreshDTO struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutDTO struct {
+	RefreshToken string `json:"refresh_token"`
+	All          bool   `json:"all"`
+}
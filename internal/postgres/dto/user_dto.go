@@ -27,3 +27,7 @@ type UpdateProfileDTO struct {
 type ChangePasswordDTO struct {
 	Password string `json:"password" binding:"required,min=6"`
 }
+
+type SetRoleDTO struct {
+	Role string `json:"role" binding:"required"`
+}
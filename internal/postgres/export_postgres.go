@@ -0,0 +1,187 @@
+package postgres
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/AlikhanF2006/Final_project/model"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+// exportBatchSize is how many rows an export fetches from its cursor at a
+// time, and so about how many it holds in memory.
+const exportBatchSize = 500
+
+// exportMovieFilter returns the movie search's WHERE clause for f ($1
+// query, $2 a single movie id, then the movie filter's values after args)
+// and the arguments to go with it.
+func exportMovieFilter(f model.ExportFilter, args ...any) (string, []any) {
+	where, args := movieFilterSQL(f.MovieFilter, append([]any{f.Query, f.MovieID}, args...))
+	return `($1 = '' OR search_vector @@ websearch_to_tsquery('english', $1) OR $1 <% title)
+		AND ($2 = 0 OR id = $2)` + where, args
+}
+
+type ExportRepository struct {
+	db *db.DB
+}
+
+func NewExportRepository(database *db.DB) *ExportRepository {
+	return &ExportRepository{db: database}
+}
+
+func (r *ExportRepository) ExportMovies(ctx context.Context, f model.ExportFilter, fn func(model.Movie) error) error {
+	order, err := orderBy(movieSortColumns, model.ListOptions{Sort: f.Sort, Desc: f.Desc})
+	if err != nil {
+		return err
+	}
+	where, args := exportMovieFilter(f)
+
+	return exportCursor(ctx, r.db,
+		`SELECT `+movieColumns+`
+		FROM movies
+		WHERE `+where+`
+		ORDER BY `+order,
+		args,
+		func(rows pgx.Rows) (model.Movie, error) {
+			var m model.Movie
+			err := rows.Scan(movieFields(&m)...)
+			return m, err
+		},
+		fn,
+	)
+}
+
+func (r *ExportRepository) ExportReviews(ctx context.Context, f model.ExportFilter, fn func(model.ExportedReview) error) error {
+	order, err := orderBy(reviewSortColumns, model.ListOptions{Sort: f.Sort, Desc: f.Desc})
+	if err != nil {
+		return err
+	}
+	where, args := exportMovieFilter(f, f.UserID)
+
+	return exportCursor(ctx, r.db,
+		`SELECT * FROM (
+			SELECT r.id, r.movie_id, m.tmdb_id, m.title, r.user_id, u.username,
+			       r.score, r.text, r.created_at
+			FROM reviews r
+			JOIN movies m ON m.id = r.movie_id
+			JOIN users u ON u.id = r.user_id
+			WHERE r.movie_id IN (SELECT id FROM movies WHERE `+where+`)
+			  AND ($3 = 0 OR r.user_id = $3)
+		) AS e
+		ORDER BY `+order,
+		args,
+		func(rows pgx.Rows) (model.ExportedReview, error) {
+			var e model.ExportedReview
+			err := rows.Scan(&e.ID, &e.MovieID, &e.TMDBID, &e.MovieTitle, &e.UserID, &e.Username, &e.Score, &e.Text, &e.CreatedAt)
+			return e, err
+		},
+		fn,
+	)
+}
+
+func (r *ExportRepository) ExportRatings(ctx context.Context, f model.ExportFilter, fn func(model.MovieRating) error) error {
+	order, err := orderBy(movieSortColumns, model.ListOptions{Sort: f.Sort, Desc: f.Desc})
+	if err != nil {
+		return err
+	}
+	where, args := exportMovieFilter(f)
+
+	return exportCursor(ctx, r.db,
+		`SELECT id, tmdb_id, title, year, rating,
+		        COALESCE(s.reviews, 0), COALESCE(s.average, 0),
+		        COALESCE(s.min_score, 0), COALESCE(s.max_score, 0)
+		FROM movies
+		LEFT JOIN (
+			SELECT movie_id, COUNT(*) AS reviews, AVG(score)::float8 AS average,
+			       MIN(score) AS min_score, MAX(score) AS max_score
+			FROM reviews
+			GROUP BY movie_id
+		) AS s ON s.movie_id = movies.id
+		WHERE `+where+`
+		ORDER BY `+order,
+		args,
+		func(rows pgx.Rows) (model.MovieRating, error) {
+			var mr model.MovieRating
+			err := rows.Scan(&mr.MovieID, &mr.TMDBID, &mr.Title, &mr.Year, &mr.Rating,
+				&mr.Reviews, &mr.AverageScore, &mr.MinScore, &mr.MaxScore)
+			return mr, err
+		},
+		fn,
+	)
+}
+
+// exportCursor runs query through a server-side cursor in a read-only
+// transaction and hands every row to fn. Rows are fetched exportBatchSize at
+// a time, each fetch under the search timeout, so an export of any size
+// holds one batch in memory and runs for as long as ctx allows. fn is never
+// called while a fetch is in flight, so a slow reader does not hold a
+// statement open.
+func exportCursor[T any](
+	ctx context.Context,
+	database *db.DB,
+	query string,
+	args []any,
+	scan func(pgx.Rows) (T, error),
+	fn func(T) error,
+) error {
+	tx, err := database.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return db.Classify(err)
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	if _, err := tx.Exec(
+		ctx,
+		`SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)`,
+		strconv.FormatFloat(searchSimilarityThreshold, 'f', -1, 64),
+	); err != nil {
+		return db.Classify(err)
+	}
+
+	declareCtx, cancel := database.WithTimeout(ctx, db.OpSearch)
+	_, err = tx.Exec(declareCtx, `DECLARE export_rows NO SCROLL CURSOR FOR `+query, args...)
+	cancel()
+	if err != nil {
+		return db.Classify(err)
+	}
+
+	batch := make([]T, 0, exportBatchSize)
+	for {
+		batch, err = fetchBatch(ctx, database, tx, batch[:0], scan)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range batch {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+
+		if len(batch) < exportBatchSize {
+			return db.Classify(tx.Commit(ctx))
+		}
+	}
+}
+
+func fetchBatch[T any](ctx context.Context, database *db.DB, tx pgx.Tx, batch []T, scan func(pgx.Rows) (T, error)) ([]T, error) {
+	ctx, cancel := database.WithTimeout(ctx, db.OpSearch)
+	defer cancel()
+
+	rows, err := tx.Query(ctx, `FETCH FORWARD `+strconv.Itoa(exportBatchSize)+` FROM export_rows`)
+	if err != nil {
+		return nil, db.Classify(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row, err := scan(rows)
+		if err != nil {
+			return nil, db.Classify(err)
+		}
+		batch = append(batch, row)
+	}
+	return batch, db.Classify(rows.Err())
+}
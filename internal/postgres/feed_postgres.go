@@ -0,0 +1,281 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/AlikhanF2006/Final_project/model"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+var ErrNotFollowing = errors.New("not following this user")
+
+type FeedRepository struct {
+	db *db.DB
+}
+
+func NewFeedRepository(database *db.DB) *FeedRepository {
+	return &FeedRepository{db: database}
+}
+
+// Follow is idempotent; following a user again does not backfill twice.
+func (r *FeedRepository) Follow(ctx context.Context, followerID, followeeID int) error {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return db.Classify(err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(
+		ctx,
+		`INSERT INTO follows (follower_id, followee_id) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`,
+		followerID,
+		followeeID,
+	)
+	if violates(err, foreignKeyViolationCode, "") {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return db.Classify(err)
+	}
+
+	if tag.RowsAffected() > 0 {
+		if _, err := tx.Exec(
+			ctx,
+			`INSERT INTO feed_items (user_id, activity_id)
+			SELECT $1, id FROM activities
+			WHERE actor_id = $2
+			ORDER BY id DESC
+			LIMIT $3
+			ON CONFLICT DO NOTHING`,
+			followerID,
+			followeeID,
+			model.FeedBackfill,
+		); err != nil {
+			return db.Classify(err)
+		}
+	}
+	return db.Classify(tx.Commit(ctx))
+}
+
+func (r *FeedRepository) Unfollow(ctx context.Context, followerID, followeeID int) error {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return db.Classify(err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(
+		ctx,
+		`DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2`,
+		followerID,
+		followeeID,
+	)
+	if err != nil {
+		return db.Classify(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFollowing
+	}
+
+	if _, err := tx.Exec(
+		ctx,
+		`DELETE FROM feed_items f
+		USING activities a
+		WHERE f.user_id = $1 AND a.id = f.activity_id AND a.actor_id = $2`,
+		followerID,
+		followeeID,
+	); err != nil {
+		return db.Classify(err)
+	}
+	return db.Classify(tx.Commit(ctx))
+}
+
+// Followers returns the users following userID.
+func (r *FeedRepository) Followers(ctx context.Context, userID int, opts model.ListOptions) ([]model.Follow, int, error) {
+	return r.follows(ctx, "f.follower_id", "f.followee_id", userID, opts)
+}
+
+// Following returns the users userID follows.
+func (r *FeedRepository) Following(ctx context.Context, userID int, opts model.ListOptions) ([]model.Follow, int, error) {
+	return r.follows(ctx, "f.followee_id", "f.follower_id", userID, opts)
+}
+
+// follows lists the users in column other of the follows where column self
+// is userID.
+func (r *FeedRepository) follows(ctx context.Context, other, self string, userID int, opts model.ListOptions) ([]model.Follow, int, error) {
+	order, err := qualifiedOrder(followSortColumns, "followed", "u.id", opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	limit, offset := pageLimit(opts)
+
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	from := `
+		FROM follows f
+		JOIN users u ON u.id = ` + other + `
+		WHERE ` + self + ` = $1`
+
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*)`+from, userID).Scan(&total); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	rows, err := r.db.Query(
+		ctx,
+		`SELECT u.id, u.username, f.created_at`+from+`
+		ORDER BY `+order+`
+		LIMIT $2 OFFSET $3`,
+		userID,
+		limit,
+		offset,
+	)
+	if err != nil {
+		return nil, 0, db.Classify(err)
+	}
+	defer rows.Close()
+
+	follows := make([]model.Follow, 0)
+	for rows.Next() {
+		var f model.Follow
+		if err := rows.Scan(&f.UserID, &f.Username, &f.Since); err != nil {
+			return nil, 0, db.Classify(err)
+		}
+		follows = append(follows, f)
+	}
+	return follows, total, db.Classify(rows.Err())
+}
+
+// publishActivity records an activity and copies it into the feed of
+// everyone following the actor. Like enqueueRating, it runs in the caller's
+// transaction, so the activity is stored if and only if the write it
+// reports is.
+func publishActivity(ctx context.Context, tx pgx.Tx, kind string, userID int, s model.ActivitySubject) error {
+	var id int
+	if err := tx.QueryRow(
+		ctx,
+		`INSERT INTO activities (actor_id, kind, review_id, diary_entry_id, list_id, list_entry_id)
+		VALUES ($1, $2, nullif($3, 0), nullif($4, 0), nullif($5, 0), nullif($6, 0))
+		RETURNING id`,
+		userID,
+		kind,
+		s.ReviewID,
+		s.DiaryEntryID,
+		s.ListID,
+		s.ListEntryID,
+	).Scan(&id); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(
+		ctx,
+		`INSERT INTO feed_items (user_id, activity_id)
+		SELECT follower_id, $2 FROM follows WHERE followee_id = $1`,
+		userID,
+		id,
+	)
+	return err
+}
+
+// Feed returns up to limit activities from userID's feed older than before,
+// newest first. Activities on lists that are not public are left out.
+func (r *FeedRepository) Feed(ctx context.Context, userID, before, limit int) ([]model.Activity, error) {
+	if before <= 0 {
+		before = math.MaxInt64
+	}
+
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	rows, err := r.db.Query(
+		ctx,
+		`SELECT a.id, a.kind, a.actor_id, u.username, a.created_at,
+			coalesce(rev.movie_id, d.movie_id, e.movie_id, 0),
+			coalesce(rev.score, d.score), coalesce(rev.text, ''),
+			coalesce(to_char(d.watched_on, 'YYYY-MM-DD'), ''), coalesce(d.rewatch, false),
+			coalesce(l.id, 0), coalesce(l.title, '')
+		FROM feed_items f
+		JOIN activities a ON a.id = f.activity_id
+		JOIN users u ON u.id = a.actor_id
+		LEFT JOIN reviews rev ON rev.id = a.review_id
+		LEFT JOIN diary_entries d ON d.id = a.diary_entry_id
+		LEFT JOIN movie_list_entries e ON e.id = a.list_entry_id
+		LEFT JOIN movie_lists l ON l.id = a.list_id
+		WHERE f.user_id = $1 AND f.activity_id < $2
+		  AND (a.list_id IS NULL OR l.visibility = $3)
+		ORDER BY f.activity_id DESC
+		LIMIT $4`,
+		userID,
+		before,
+		model.ListPublic,
+		limit,
+	)
+	if err != nil {
+		return nil, db.Classify(err)
+	}
+	defer rows.Close()
+
+	activities := make([]model.Activity, 0)
+	var movieIDs []int
+	for rows.Next() {
+		var (
+			a       model.Activity
+			movieID int
+			list    model.ListRef
+		)
+		if err := rows.Scan(
+			&a.ID, &a.Kind, &a.UserID, &a.Username, &a.CreatedAt,
+			&movieID, &a.Score, &a.Text, &a.WatchedOn, &a.Rewatch, &list.ID, &list.Title,
+		); err != nil {
+			return nil, db.Classify(err)
+		}
+		if movieID != 0 {
+			a.Movie = &model.Movie{ID: movieID}
+			movieIDs = append(movieIDs, movieID)
+		}
+		if list.ID != 0 {
+			a.List = &list
+		}
+		activities = append(activities, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, db.Classify(err)
+	}
+	if len(movieIDs) == 0 {
+		return activities, nil
+	}
+
+	rows, err = r.db.Query(ctx, `SELECT `+movieColumns+` FROM movies WHERE id = ANY($1)`, movieIDs)
+	if err != nil {
+		return nil, db.Classify(err)
+	}
+	movies, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Movie, error) {
+		var m model.Movie
+		return m, row.Scan(movieFields(&m)...)
+	})
+	if err != nil {
+		return nil, db.Classify(err)
+	}
+	byID := make(map[int]model.Movie, len(movies))
+	for _, m := range movies {
+		byID[m.ID] = m
+	}
+	for _, a := range activities {
+		if a.Movie != nil {
+			*a.Movie = byID[a.Movie.ID]
+		}
+	}
+	return activities, nil
+}
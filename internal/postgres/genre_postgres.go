@@ -0,0 +1,267 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/AlikhanF2006/Final_project/model"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+var (
+	ErrGenreNotFound = errors.New("genre not found")
+	ErrGenreExists   = errors.New("a genre with this name already exists")
+)
+
+type GenreRepository struct {
+	db *db.DB
+}
+
+func NewGenreRepository(database *db.DB) *GenreRepository {
+	return &GenreRepository{db: database}
+}
+
+// List returns every genre by name, with the number of movies in it.
+func (r *GenreRepository) List(ctx context.Context) ([]model.Genre, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	rows, err := r.db.Query(
+		ctx,
+		`SELECT g.id, COALESCE(g.tmdb_id, 0), g.name, COUNT(mg.movie_id)
+		FROM genres g
+		LEFT JOIN movie_genres mg ON mg.genre_id = g.id
+		GROUP BY g.id
+		ORDER BY lower(g.name), g.id`,
+	)
+	if err != nil {
+		return nil, db.Classify(err)
+	}
+	defer rows.Close()
+
+	genres := make([]model.Genre, 0)
+	for rows.Next() {
+		var g model.Genre
+		if err := rows.Scan(&g.ID, &g.TMDBID, &g.Name, &g.Movies); err != nil {
+			return nil, db.Classify(err)
+		}
+		genres = append(genres, g)
+	}
+	return genres, db.Classify(rows.Err())
+}
+
+func (r *GenreRepository) GetByID(ctx context.Context, id int) (model.Genre, error) {
+	return r.getOne(ctx, `WHERE id = $1`, id)
+}
+
+// GetByName finds a genre by name, ignoring case.
+func (r *GenreRepository) GetByName(ctx context.Context, name string) (model.Genre, error) {
+	return r.getOne(ctx, `WHERE lower(name) = lower($1)`, name)
+}
+
+func (r *GenreRepository) getOne(ctx context.Context, where string, arg any) (model.Genre, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	var g model.Genre
+	err := r.db.QueryRow(
+		ctx,
+		`SELECT id, COALESCE(tmdb_id, 0), name FROM genres `+where,
+		arg,
+	).Scan(&g.ID, &g.TMDBID, &g.Name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Genre{}, ErrGenreNotFound
+	}
+	return g, db.Classify(err)
+}
+
+func (r *GenreRepository) Create(ctx context.Context, g model.Genre) (model.Genre, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	err := r.db.QueryRow(
+		ctx,
+		`INSERT INTO genres (tmdb_id, name) VALUES (NULLIF($1, 0), $2) RETURNING id`,
+		g.TMDBID,
+		g.Name,
+	).Scan(&g.ID)
+	if violates(err, uniqueViolationCode, "") {
+		return model.Genre{}, ErrGenreExists
+	}
+	return g, db.Classify(err)
+}
+
+// Update renames a genre. Its TMDB link stays.
+func (r *GenreRepository) Update(ctx context.Context, g model.Genre) (model.Genre, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	err := r.db.QueryRow(
+		ctx,
+		`UPDATE genres SET name = $1 WHERE id = $2 RETURNING COALESCE(tmdb_id, 0)`,
+		g.Name,
+		g.ID,
+	).Scan(&g.TMDBID)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return model.Genre{}, ErrGenreNotFound
+	case violates(err, uniqueViolationCode, ""):
+		return model.Genre{}, ErrGenreExists
+	}
+	return g, db.Classify(err)
+}
+
+// Delete removes a genre; its movies simply lose it.
+func (r *GenreRepository) Delete(ctx context.Context, id int) error {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	cmd, err := r.db.Exec(ctx, `DELETE FROM genres WHERE id = $1`, id)
+	if err != nil {
+		return db.Classify(err)
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrGenreNotFound
+	}
+	return nil
+}
+
+// EnsureTMDB returns the local genre linked to TMDB genre g, creating it if
+// needed. A genre created by hand with the same name is linked rather than
+// duplicated. Existing genres keep their local name.
+func (r *GenreRepository) EnsureTMDB(ctx context.Context, g model.Genre) (model.Genre, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	var local model.Genre
+	err := r.db.QueryRow(
+		ctx,
+		`WITH existing AS (
+			SELECT id, tmdb_id, name FROM genres WHERE tmdb_id = $1
+		), linked AS (
+			UPDATE genres SET tmdb_id = $1
+			WHERE lower(name) = lower($2) AND tmdb_id IS NULL
+			  AND NOT EXISTS (SELECT 1 FROM existing)
+			RETURNING id, tmdb_id, name
+		), inserted AS (
+			INSERT INTO genres (tmdb_id, name)
+			SELECT $1, $2
+			WHERE NOT EXISTS (SELECT 1 FROM existing)
+			  AND NOT EXISTS (SELECT 1 FROM linked)
+			ON CONFLICT DO NOTHING
+			RETURNING id, tmdb_id, name
+		)
+		SELECT id, tmdb_id, name FROM existing
+		UNION ALL SELECT id, tmdb_id, name FROM linked
+		UNION ALL SELECT id, tmdb_id, name FROM inserted`,
+		g.TMDBID,
+		g.Name,
+	).Scan(&local.ID, &local.TMDBID, &local.Name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Another writer got there first, or a genre of that name is
+		// linked to a different TMDB genre: use whichever now exists.
+		if local, err = r.getOne(ctx, `WHERE tmdb_id = $1`, g.TMDBID); errors.Is(err, ErrGenreNotFound) {
+			return r.GetByName(ctx, g.Name)
+		}
+		return local, err
+	}
+	return local, db.Classify(err)
+}
+
+// ForMovies returns the genres of each of movieIDs, by name.
+func (r *GenreRepository) ForMovies(ctx context.Context, movieIDs []int) (map[int][]model.Genre, error) {
+	out := make(map[int][]model.Genre, len(movieIDs))
+	if len(movieIDs) == 0 {
+		return out, nil
+	}
+
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	rows, err := r.db.Query(
+		ctx,
+		`SELECT mg.movie_id, g.id, COALESCE(g.tmdb_id, 0), g.name
+		FROM movie_genres mg
+		JOIN genres g ON g.id = mg.genre_id
+		WHERE mg.movie_id = ANY($1)
+		ORDER BY lower(g.name), g.id`,
+		movieIDs,
+	)
+	if err != nil {
+		return nil, db.Classify(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			movieID int
+			g       model.Genre
+		)
+		if err := rows.Scan(&movieID, &g.ID, &g.TMDBID, &g.Name); err != nil {
+			return nil, db.Classify(err)
+		}
+		out[movieID] = append(out[movieID], g)
+	}
+	return out, db.Classify(rows.Err())
+}
+
+// SetMovieGenres replaces a movie's genres and reports whether they
+// changed.
+func (r *GenreRepository) SetMovieGenres(ctx context.Context, movieID int, genreIDs []int) (bool, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, db.Classify(err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Locking the movie row serialises concurrent replacements and
+	// confirms the movie exists.
+	err = tx.QueryRow(ctx, `SELECT id FROM movies WHERE id = $1 FOR UPDATE`, movieID).Scan(&movieID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, ErrMovieNotFound
+	}
+	if err != nil {
+		return false, db.Classify(err)
+	}
+
+	rows, err := tx.Query(ctx, `SELECT genre_id FROM movie_genres WHERE movie_id = $1`, movieID)
+	if err != nil {
+		return false, db.Classify(err)
+	}
+	current, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return false, db.Classify(err)
+	}
+
+	want := slices.Compact(slices.Sorted(slices.Values(genreIDs)))
+	slices.Sort(current)
+	if slices.Equal(current, want) {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM movie_genres WHERE movie_id = $1`, movieID); err != nil {
+		return false, db.Classify(err)
+	}
+	if len(want) > 0 {
+		_, err := tx.Exec(
+			ctx,
+			`INSERT INTO movie_genres (movie_id, genre_id) SELECT $1, unnest($2::int[])`,
+			movieID,
+			want,
+		)
+		if violates(err, foreignKeyViolationCode, "movie_genres_genre_id_fkey") {
+			return false, ErrGenreNotFound
+		}
+		if err != nil {
+			return false, db.Classify(err)
+		}
+	}
+
+	return true, db.Classify(tx.Commit(ctx))
+}
@@ -1,32 +1,160 @@
 package postgres
 
-import "github.com/AlikhanF2006/Final_project/model"
+import (
+	"context"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/model"
+)
 
 type MovieRepo interface {
-	Create(model.Movie) (model.Movie, error)
-	GetAll() []model.Movie
-	GetByID(int) (model.Movie, error)
-	GetByTMDBID(int) (model.Movie, error)
-	ExistsByTMDBID(int) bool
-	Update(model.Movie) (model.Movie, error)
-	Delete(int) error
-	SetRating(int, float64) error
+	Create(context.Context, model.Movie) (model.Movie, error)
+	GetAll(context.Context) ([]model.Movie, error)
+	List(context.Context, model.MovieFilter, model.ListOptions) ([]model.Movie, int, error)
+	Search(context.Context, string, model.MovieFilter, model.ListOptions) ([]model.MovieSearchHit, int, error)
+	GetByID(context.Context, int) (model.Movie, error)
+	GetByTMDBID(context.Context, int) (model.Movie, error)
+	ExistsByTMDBID(context.Context, int) (bool, error)
+	Update(context.Context, model.Movie) (model.Movie, error)
+	UpsertTMDB(context.Context, model.Movie) (model.Movie, model.UpsertResult, error)
+	Delete(context.Context, int) error
+	SetRating(context.Context, int, float64) error
+}
+
+type GenreRepo interface {
+	List(context.Context) ([]model.Genre, error)
+	GetByID(context.Context, int) (model.Genre, error)
+	GetByName(context.Context, string) (model.Genre, error)
+	Create(context.Context, model.Genre) (model.Genre, error)
+	Update(context.Context, model.Genre) (model.Genre, error)
+	Delete(context.Context, int) error
+	EnsureTMDB(context.Context, model.Genre) (model.Genre, error)
+	ForMovies(context.Context, []int) (map[int][]model.Genre, error)
+	SetMovieGenres(context.Context, int, []int) (bool, error)
+}
+
+type PersonRepo interface {
+	GetByID(context.Context, int) (model.Person, error)
+	Search(context.Context, string, model.ListOptions) ([]model.Person, int, error)
+	Filmography(context.Context, int) ([]model.FilmographyEntry, error)
+	MovieCredits(context.Context, int) ([]model.Credit, error)
+	SetMovieCredits(context.Context, int, []model.Credit) (bool, error)
+}
+
+// WatchlistRepo keys entries by user and movie id; an entry's own id is for
+// display only.
+type WatchlistRepo interface {
+	List(context.Context, int, model.WatchlistFilter, model.ListOptions) ([]model.WatchlistEntry, int, error)
+	Get(context.Context, int, int) (model.WatchlistEntry, error)
+	Add(context.Context, int, model.WatchlistEntry) (model.WatchlistEntry, error)
+	Update(context.Context, int, model.WatchlistEntry) (model.WatchlistEntry, error)
+	Move(context.Context, int, int, int) (model.WatchlistEntry, error)
+	Remove(context.Context, int, int) error
+}
+
+// DiaryRepo keys entries by user and entry id, so a user can only reach
+// their own. Writes queue a rating recomputation for the entry's movie, and
+// Add publishes the entry to the user's followers in the same transaction.
+type DiaryRepo interface {
+	List(context.Context, int, model.DiaryFilter, model.ListOptions) ([]model.DiaryEntry, int, error)
+	Get(context.Context, int, int) (model.DiaryEntry, error)
+	Add(context.Context, int, model.DiaryEntry) (model.DiaryEntry, error)
+	Update(context.Context, int, model.DiaryEntry) (model.DiaryEntry, error)
+	Delete(context.Context, int, int) error
+	Days(context.Context, int, string, string) ([]model.DiaryDay, error)
 }
 
+// MovieListRepo stores lists without checking who may read or change them;
+// the service does. Entries are keyed by list and movie id, and writing them
+// bumps the list's updated_at. Create, Clone and AddEntry publish what they
+// add to the followers of whoever added it, in the same transaction.
+type MovieListRepo interface {
+	List(context.Context, model.ListFilter, model.ListOptions) ([]model.MovieList, int, error)
+	Get(context.Context, int) (model.MovieList, error)
+	Create(context.Context, model.MovieList) (model.MovieList, error)
+	Update(context.Context, model.MovieList) (model.MovieList, error)
+	Delete(context.Context, int) error
+	Clone(context.Context, int, model.MovieList) (model.MovieList, error)
+	AddCollaborator(context.Context, int, int) error
+	RemoveCollaborator(context.Context, int, int) error
+	Entries(context.Context, int, model.ListOptions) ([]model.ListEntry, int, error)
+	GetEntry(context.Context, int, int) (model.ListEntry, error)
+	AddEntry(context.Context, int, model.ListEntry) (model.ListEntry, error)
+	UpdateEntry(context.Context, int, model.ListEntry) (model.ListEntry, error)
+	MoveEntry(context.Context, int, int, int) (model.ListEntry, error)
+	RemoveEntry(context.Context, int, int) error
+}
+
+// FeedRepo stores the follow graph and each user's feed. Activities are
+// published by the review, diary and list writes themselves; Follow
+// backfills the followed user's latest activities and Unfollow takes theirs
+// out again.
+// Feed pages by activity id, newest first: before is the last id of the
+// previous page, or 0 for the first.
+type FeedRepo interface {
+	Follow(context.Context, int, int) error
+	Unfollow(context.Context, int, int) error
+	Followers(context.Context, int, model.ListOptions) ([]model.Follow, int, error)
+	Following(context.Context, int, model.ListOptions) ([]model.Follow, int, error)
+	Feed(context.Context, int, int, int) ([]model.Activity, error)
+}
+
+// ReviewRepo queues a rating recomputation for the movie with every write,
+// and Add publishes the new review to the reviewer's followers in the same
+// transaction.
 type ReviewRepo interface {
-	Add(int, model.Review) (model.Review, error)
-	ListByMovieID(int) ([]model.Review, error)
-	UpdateByMovieAndUser(int, int, int) error
-	DeleteByMovieAndUser(int, int) error
-	GetByID(int) (model.Review, error)
-	DeleteByID(int) error
+	Add(context.Context, int, model.Review) (model.Review, error)
+	ListByMovieID(context.Context, int) ([]model.Review, error)
+	PageByMovieID(context.Context, int, model.ListOptions) ([]model.Review, int, error)
+	UpdateByMovieAndUser(context.Context, int, int, int) error
+	DeleteByMovieAndUser(context.Context, int, int) error
+	GetByID(context.Context, int) (model.Review, error)
+	DeleteByID(context.Context, int) error
 }
 
 type UserRepo interface {
-	Create(model.User) (model.User, error)
-	GetByEmail(string) (model.User, error)
-	GetByID(int) (model.User, error)
-	Update(model.User) (model.User, error)
-	UpdatePassword(int, string) error
-	Delete(int) error
+	Create(context.Context, model.User) (model.User, error)
+	GetByEmail(context.Context, string) (model.User, error)
+	GetByID(context.Context, int) (model.User, error)
+	Update(context.Context, model.User) (model.User, error)
+	UpdatePassword(context.Context, int, string) error
+	UpdateRole(context.Context, int, string) error
+	BumpTokenVersion(context.Context, int) error
+	Delete(context.Context, int) error
+}
+
+type RoleRepo interface {
+	LoadGrants(context.Context) (map[string][]string, error)
+}
+
+type TokenRepo interface {
+	CreateRefreshToken(context.Context, model.RefreshToken) (model.RefreshToken, error)
+	GetRefreshToken(context.Context, string) (model.RefreshToken, error)
+	RotateRefreshToken(context.Context, int, model.RefreshToken) (model.RefreshToken, error)
+	RevokeFamily(context.Context, string) error
+	RevokeAllForUser(context.Context, int) error
+	RevokeAccessToken(context.Context, string, time.Time) error
+	SessionState(context.Context, int, string) (int, bool, error)
+}
+
+// RatingJobRepo works through the rating outbox. ProcessNext reports a job
+// whose recomputation failed, and was put back for a retry, as
+// ErrRatingJobFailed; any other error means the queue could not be read.
+type RatingJobRepo interface {
+	ProcessNext(context.Context) (bool, error)
+	Pending(context.Context) (int, error)
+}
+
+type SyncRunRepo interface {
+	Create(context.Context, model.SyncRun) (model.SyncRun, error)
+	Finish(context.Context, model.SyncRun) error
+	List(context.Context, model.ListOptions) ([]model.SyncRun, int, error)
+}
+
+// ExportRepo streams rows for exports: fn gets them one at a time, and an
+// error from fn stops the export and is returned as is.
+type ExportRepo interface {
+	ExportMovies(context.Context, model.ExportFilter, func(model.Movie) error) error
+	ExportReviews(context.Context, model.ExportFilter, func(model.ExportedReview) error) error
+	ExportRatings(context.Context, model.ExportFilter, func(model.MovieRating) error) error
 }
@@ -0,0 +1,529 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/AlikhanF2006/Final_project/model"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+var (
+	ErrListNotFound         = errors.New("list not found")
+	ErrListEntryNotFound    = errors.New("movie is not on the list")
+	ErrListEntryExists      = errors.New("movie is already on the list")
+	ErrCollaboratorNotFound = errors.New("user is not a collaborator on the list")
+)
+
+const movieListColumns = `l.id, l.owner_id, u.username, l.title, l.description, l.visibility, l.cloned_from,
+	(SELECT COUNT(*) FROM movie_list_entries e WHERE e.list_id = l.id), l.created_at, l.updated_at`
+
+const movieListFrom = `
+	FROM movie_lists l
+	JOIN users u ON u.id = l.owner_id`
+
+// movieListFields returns scan targets for movieListColumns.
+func movieListFields(l *model.MovieList) []any {
+	return []any{&l.ID, &l.OwnerID, &l.Owner, &l.Title, &l.Description, &l.Visibility, &l.ClonedFrom, &l.Entries, &l.CreatedAt, &l.UpdatedAt}
+}
+
+const listEntryColumns = `e.id, e.position, e.comment, coalesce(e.added_by, 0), e.created_at,
+	movies.id, movies.tmdb_id, movies.title, movies.year, movies.description, movies.rating, movies.overrides`
+
+const listEntryFrom = `
+	FROM movie_list_entries e
+	JOIN movies ON movies.id = e.movie_id`
+
+// listEntryFields returns scan targets for listEntryColumns.
+func listEntryFields(e *model.ListEntry) []any {
+	return append([]any{&e.ID, &e.Position, &e.Comment, &e.AddedBy, &e.AddedAt}, movieFields(&e.Movie)...)
+}
+
+type MovieListRepository struct {
+	db *db.DB
+}
+
+func NewMovieListRepository(database *db.DB) *MovieListRepository {
+	return &MovieListRepository{db: database}
+}
+
+// List returns the lists matching f, by last update unless sort is given.
+func (r *MovieListRepository) List(ctx context.Context, f model.ListFilter, opts model.ListOptions) ([]model.MovieList, int, error) {
+	order, err := qualifiedOrder(movieListSortColumns, "updated", "l.id", opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	limit, offset := pageLimit(opts)
+
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	where := ""
+	var args []any
+	if f.MemberID != 0 {
+		args = append(args, f.MemberID)
+		where += fmt.Sprintf(`
+		  AND (l.owner_id = $%[1]d OR EXISTS (
+		      SELECT 1 FROM movie_list_collaborators c WHERE c.list_id = l.id AND c.user_id = $%[1]d))`, len(args))
+	}
+	if f.MovieID != 0 {
+		args = append(args, f.MovieID)
+		where += fmt.Sprintf(`
+		  AND EXISTS (SELECT 1 FROM movie_list_entries e WHERE e.list_id = l.id AND e.movie_id = $%d)`, len(args))
+	}
+	if f.PublicOnly {
+		args = append(args, model.ListPublic)
+		where += fmt.Sprintf("\n\t\t  AND l.visibility = $%d", len(args))
+	}
+	from := movieListFrom + `
+		WHERE true` + where
+
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*)`+from, args...).Scan(&total); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	rows, err := r.db.Query(
+		ctx,
+		fmt.Sprintf(`SELECT `+movieListColumns+from+`
+		ORDER BY `+order+`
+		LIMIT $%d OFFSET $%d`, len(args)+1, len(args)+2),
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, db.Classify(err)
+	}
+	defer rows.Close()
+
+	lists := make([]model.MovieList, 0)
+	for rows.Next() {
+		var l model.MovieList
+		if err := rows.Scan(movieListFields(&l)...); err != nil {
+			return nil, 0, db.Classify(err)
+		}
+		lists = append(lists, l)
+	}
+	return lists, total, db.Classify(rows.Err())
+}
+
+// Get returns the list with its collaborators, earliest added first.
+func (r *MovieListRepository) Get(ctx context.Context, id int) (model.MovieList, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	var l model.MovieList
+	err := r.db.QueryRow(
+		ctx,
+		`SELECT `+movieListColumns+movieListFrom+`
+		WHERE l.id = $1`,
+		id,
+	).Scan(movieListFields(&l)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.MovieList{}, ErrListNotFound
+	}
+	if err != nil {
+		return model.MovieList{}, db.Classify(err)
+	}
+
+	rows, err := r.db.Query(
+		ctx,
+		`SELECT c.user_id, u.username, c.added_at
+		FROM movie_list_collaborators c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.list_id = $1
+		ORDER BY c.added_at, c.user_id`,
+		id,
+	)
+	if err != nil {
+		return model.MovieList{}, db.Classify(err)
+	}
+	defer rows.Close()
+
+	l.Collaborators = make([]model.ListCollaborator, 0)
+	for rows.Next() {
+		var c model.ListCollaborator
+		if err := rows.Scan(&c.UserID, &c.Username, &c.AddedAt); err != nil {
+			return model.MovieList{}, db.Classify(err)
+		}
+		l.Collaborators = append(l.Collaborators, c)
+	}
+	return l, db.Classify(rows.Err())
+}
+
+// Create stores list l and publishes it to the owner's followers.
+func (r *MovieListRepository) Create(ctx context.Context, l model.MovieList) (model.MovieList, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return model.MovieList{}, db.Classify(err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(
+		ctx,
+		`INSERT INTO movie_lists (owner_id, title, description, visibility)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`,
+		l.OwnerID,
+		l.Title,
+		l.Description,
+		l.Visibility,
+	).Scan(&l.ID)
+	if violates(err, foreignKeyViolationCode, "movie_lists_owner_id_fkey") {
+		return model.MovieList{}, ErrUserNotFound
+	}
+	if err != nil {
+		return model.MovieList{}, db.Classify(err)
+	}
+
+	if err := publishActivity(ctx, tx, model.ActivityListCreated, l.OwnerID, model.ActivitySubject{ListID: l.ID}); err != nil {
+		return model.MovieList{}, db.Classify(err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return model.MovieList{}, db.Classify(err)
+	}
+
+	return r.Get(ctx, l.ID)
+}
+
+// Update sets the title, description and visibility of list l.ID.
+func (r *MovieListRepository) Update(ctx context.Context, l model.MovieList) (model.MovieList, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	tag, err := r.db.Exec(
+		ctx,
+		`UPDATE movie_lists SET title = $2, description = $3, visibility = $4, updated_at = now()
+		WHERE id = $1`,
+		l.ID,
+		l.Title,
+		l.Description,
+		l.Visibility,
+	)
+	if err != nil {
+		return model.MovieList{}, db.Classify(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return model.MovieList{}, ErrListNotFound
+	}
+
+	return r.Get(ctx, l.ID)
+}
+
+func (r *MovieListRepository) Delete(ctx context.Context, id int) error {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	tag, err := r.db.Exec(ctx, `DELETE FROM movie_lists WHERE id = $1`, id)
+	if err != nil {
+		return db.Classify(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrListNotFound
+	}
+	return nil
+}
+
+// Clone creates l as a copy of list srcID's entries, in the same order and
+// with the same comments, all added by l's owner, and publishes it to the
+// owner's followers.
+func (r *MovieListRepository) Clone(ctx context.Context, srcID int, l model.MovieList) (model.MovieList, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return model.MovieList{}, db.Classify(err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(
+		ctx,
+		`INSERT INTO movie_lists (owner_id, title, description, visibility, cloned_from)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`,
+		l.OwnerID,
+		l.Title,
+		l.Description,
+		l.Visibility,
+		srcID,
+	).Scan(&l.ID)
+	switch {
+	case violates(err, foreignKeyViolationCode, "movie_lists_cloned_from_fkey"):
+		return model.MovieList{}, ErrListNotFound
+	case violates(err, foreignKeyViolationCode, "movie_lists_owner_id_fkey"):
+		return model.MovieList{}, ErrUserNotFound
+	case err != nil:
+		return model.MovieList{}, db.Classify(err)
+	}
+
+	if _, err := tx.Exec(
+		ctx,
+		`INSERT INTO movie_list_entries (list_id, movie_id, position, comment, added_by)
+		SELECT $1, movie_id, row_number() OVER (ORDER BY position, id), comment, $2
+		FROM movie_list_entries
+		WHERE list_id = $3`,
+		l.ID,
+		l.OwnerID,
+		srcID,
+	); err != nil {
+		return model.MovieList{}, db.Classify(err)
+	}
+	if err := publishActivity(ctx, tx, model.ActivityListCreated, l.OwnerID, model.ActivitySubject{ListID: l.ID}); err != nil {
+		return model.MovieList{}, db.Classify(err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return model.MovieList{}, db.Classify(err)
+	}
+
+	return r.Get(ctx, l.ID)
+}
+
+// AddCollaborator is idempotent: adding a collaborator twice keeps the
+// first date.
+func (r *MovieListRepository) AddCollaborator(ctx context.Context, listID, userID int) error {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	_, err := r.db.Exec(
+		ctx,
+		`INSERT INTO movie_list_collaborators (list_id, user_id) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`,
+		listID,
+		userID,
+	)
+	switch {
+	case violates(err, foreignKeyViolationCode, "movie_list_collaborators_list_id_fkey"):
+		return ErrListNotFound
+	case violates(err, foreignKeyViolationCode, "movie_list_collaborators_user_id_fkey"):
+		return ErrUserNotFound
+	}
+	return db.Classify(err)
+}
+
+func (r *MovieListRepository) RemoveCollaborator(ctx context.Context, listID, userID int) error {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	tag, err := r.db.Exec(
+		ctx,
+		`DELETE FROM movie_list_collaborators WHERE list_id = $1 AND user_id = $2`,
+		listID,
+		userID,
+	)
+	if err != nil {
+		return db.Classify(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrCollaboratorNotFound
+	}
+	return nil
+}
+
+// Entries returns the list's entries, by position unless sort is given.
+func (r *MovieListRepository) Entries(ctx context.Context, listID int, opts model.ListOptions) ([]model.ListEntry, int, error) {
+	order, err := qualifiedOrder(listEntrySortColumns, "position", "e.id", opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	limit, offset := pageLimit(opts)
+
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	var total int
+	if err := r.db.QueryRow(
+		ctx,
+		`SELECT COUNT(*) FROM movie_list_entries WHERE list_id = $1`,
+		listID,
+	).Scan(&total); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	rows, err := r.db.Query(
+		ctx,
+		`SELECT `+listEntryColumns+listEntryFrom+`
+		WHERE e.list_id = $1
+		ORDER BY `+order+`
+		LIMIT $2 OFFSET $3`,
+		listID,
+		limit,
+		offset,
+	)
+	if err != nil {
+		return nil, 0, db.Classify(err)
+	}
+	defer rows.Close()
+
+	entries := make([]model.ListEntry, 0)
+	for rows.Next() {
+		var e model.ListEntry
+		if err := rows.Scan(listEntryFields(&e)...); err != nil {
+			return nil, 0, db.Classify(err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, db.Classify(rows.Err())
+}
+
+func (r *MovieListRepository) GetEntry(ctx context.Context, listID, movieID int) (model.ListEntry, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	var e model.ListEntry
+	err := r.db.QueryRow(
+		ctx,
+		`SELECT `+listEntryColumns+listEntryFrom+`
+		WHERE e.list_id = $1 AND e.movie_id = $2`,
+		listID,
+		movieID,
+	).Scan(listEntryFields(&e)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.ListEntry{}, ErrListEntryNotFound
+	}
+	return e, db.Classify(err)
+}
+
+// AddEntry puts e.Movie at the end of the list and publishes the addition
+// to the followers of whoever added it.
+func (r *MovieListRepository) AddEntry(ctx context.Context, listID int, e model.ListEntry) (model.ListEntry, error) {
+	err := r.withTouch(ctx, listID, func(ctx context.Context, tx pgx.Tx) error {
+		var id int
+		if err := tx.QueryRow(
+			ctx,
+			`INSERT INTO movie_list_entries (list_id, movie_id, position, comment, added_by)
+			SELECT $1, $2, coalesce(max(position), 0) + 1, $3, $4
+			FROM movie_list_entries
+			WHERE list_id = $1
+			RETURNING id`,
+			listID,
+			e.Movie.ID,
+			e.Comment,
+			e.AddedBy,
+		).Scan(&id); err != nil {
+			return err
+		}
+		return publishActivity(ctx, tx, model.ActivityListEntry, e.AddedBy, model.ActivitySubject{ListID: listID, ListEntryID: id})
+	})
+	switch {
+	case violates(err, uniqueViolationCode, "movie_list_entries_list_movie_key"):
+		return model.ListEntry{}, ErrListEntryExists
+	case violates(err, foreignKeyViolationCode, "movie_list_entries_movie_id_fkey"):
+		return model.ListEntry{}, ErrMovieNotFound
+	case err != nil:
+		return model.ListEntry{}, err
+	}
+
+	return r.GetEntry(ctx, listID, e.Movie.ID)
+}
+
+// UpdateEntry sets the comment of the entry for e.Movie.
+func (r *MovieListRepository) UpdateEntry(ctx context.Context, listID int, e model.ListEntry) (model.ListEntry, error) {
+	err := r.withTouch(ctx, listID, func(ctx context.Context, tx pgx.Tx) error {
+		tag, err := tx.Exec(
+			ctx,
+			`UPDATE movie_list_entries SET comment = $3 WHERE list_id = $1 AND movie_id = $2`,
+			listID,
+			e.Movie.ID,
+			e.Comment,
+		)
+		if err == nil && tag.RowsAffected() == 0 {
+			return ErrListEntryNotFound
+		}
+		return err
+	})
+	if err != nil {
+		return model.ListEntry{}, err
+	}
+
+	return r.GetEntry(ctx, listID, e.Movie.ID)
+}
+
+// MoveEntry puts the movie's entry at position (clamped to the list) and
+// renumbers the list from 1, as WatchlistRepository.Move does.
+func (r *MovieListRepository) MoveEntry(ctx context.Context, listID, movieID, position int) (model.ListEntry, error) {
+	err := r.withTouch(ctx, listID, func(ctx context.Context, tx pgx.Tx) error {
+		rows, err := tx.Query(
+			ctx,
+			`SELECT movie_id FROM movie_list_entries WHERE list_id = $1 ORDER BY position, id`,
+			listID,
+		)
+		if err != nil {
+			return err
+		}
+		order, err := pgx.CollectRows(rows, pgx.RowTo[int])
+		if err != nil {
+			return err
+		}
+
+		i := slices.Index(order, movieID)
+		if i < 0 {
+			return ErrListEntryNotFound
+		}
+		order = slices.Delete(order, i, i+1)
+		order = slices.Insert(order, min(max(position, 1), len(order)+1)-1, movieID)
+
+		_, err = tx.Exec(
+			ctx,
+			`UPDATE movie_list_entries e SET position = o.n
+			FROM unnest($2::int[]) WITH ORDINALITY AS o(movie_id, n)
+			WHERE e.list_id = $1 AND e.movie_id = o.movie_id AND e.position <> o.n`,
+			listID,
+			order,
+		)
+		return err
+	})
+	if err != nil {
+		return model.ListEntry{}, err
+	}
+
+	return r.GetEntry(ctx, listID, movieID)
+}
+
+func (r *MovieListRepository) RemoveEntry(ctx context.Context, listID, movieID int) error {
+	return r.withTouch(ctx, listID, func(ctx context.Context, tx pgx.Tx) error {
+		tag, err := tx.Exec(
+			ctx,
+			`DELETE FROM movie_list_entries WHERE list_id = $1 AND movie_id = $2`,
+			listID,
+			movieID,
+		)
+		if err == nil && tag.RowsAffected() == 0 {
+			return ErrListEntryNotFound
+		}
+		return err
+	})
+}
+
+// withTouch runs fn in a transaction after bumping the list's updated_at.
+// The bump locks the list row, so concurrent entry writes to one list take
+// turns and positions stay unique.
+func (r *MovieListRepository) withTouch(ctx context.Context, listID int, fn func(ctx context.Context, tx pgx.Tx) error) error {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return db.Classify(err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `UPDATE movie_lists SET updated_at = now() WHERE id = $1`, listID)
+	if err != nil {
+		return db.Classify(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrListNotFound
+	}
+
+	if err := fn(ctx, tx); err != nil {
+		return db.Classify(err)
+	}
+	return db.Classify(tx.Commit(ctx))
+}
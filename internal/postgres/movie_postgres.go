@@ -3,6 +3,11 @@ package postgres
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
 
 	"github.com/AlikhanF2006/Final_project/model"
 	"github.com/AlikhanF2006/Final_project/pkg/db"
@@ -10,161 +15,227 @@ import (
 
 var (
 	ErrMovieNotFound = errors.New("movie not found")
+	ErrMovieExists   = errors.New("a movie with this tmdb id already exists")
 )
 
-type MovieRepository struct{}
+// searchSimilarityThreshold is the pg_trgm word similarity a title needs to
+// match a query that the full-text index missed (typos, partial words).
+const searchSimilarityThreshold = 0.4
+
+const movieColumns = `id, tmdb_id, title, year, description, rating, overrides`
+
+// movieFields returns scan targets for movieColumns.
+func movieFields(m *model.Movie) []any {
+	return []any{&m.ID, &m.TMDBID, &m.Title, &m.Year, &m.Description, &m.Rating, &m.Overrides}
+}
+
+type MovieRepository struct {
+	db *db.DB
+}
 
-func NewMovieRepository() *MovieRepository {
-	return &MovieRepository{}
+func NewMovieRepository(database *db.DB) *MovieRepository {
+	return &MovieRepository{db: database}
 }
 
-func (r *MovieRepository) SetRating(movieID int, rating float64) error {
-	_, err := db.DB.Exec(
-		context.Background(),
+func (r *MovieRepository) SetRating(ctx context.Context, movieID int, rating float64) error {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	_, err := r.db.Exec(
+		ctx,
 		`UPDATE movies SET rating = $1 WHERE id = $2`,
 		rating,
 		movieID,
 	)
-	return err
+	return db.Classify(err)
 }
 
-func (r *MovieRepository) Create(m model.Movie) (model.Movie, error) {
+// Create ignores m.Rating: ratings are only written by SetRating, from the
+// rating jobs, so a new movie starts unrated.
+func (r *MovieRepository) Create(ctx context.Context, m model.Movie) (model.Movie, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
 	query := `
-		INSERT INTO movies (tmdb_id, title, year, description, rating)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO movies (tmdb_id, title, year, description)
+		VALUES ($1, $2, $3, $4)
 		RETURNING id
 	`
 
-	err := db.DB.QueryRow(
-		context.Background(),
+	err := r.db.QueryRow(
+		ctx,
 		query,
 		m.TMDBID,
 		m.Title,
 		m.Year,
 		m.Description,
-		m.Rating,
 	).Scan(&m.ID)
+	if violates(err, uniqueViolationCode, "") {
+		return model.Movie{}, ErrMovieExists
+	}
 
-	return m, err
+	m.Rating = 0
+	return m, db.Classify(err)
 }
 
-func (r *MovieRepository) GetAll() []model.Movie {
-	rows, err := db.DB.Query(
-		context.Background(),
-		`SELECT id, tmdb_id, title, year, description, rating FROM movies`,
-	)
+// UpsertTMDB inserts a movie fetched from TMDB or refreshes the row with the
+// same tmdb_id. Fields listed in the row's overrides keep their local value,
+// and rating is never touched: it comes from local reviews. A row that would
+// not change is not written at all.
+func (r *MovieRepository) UpsertTMDB(ctx context.Context, m model.Movie) (model.Movie, model.UpsertResult, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	var inserted bool
+	err := r.db.QueryRow(
+		ctx,
+		`INSERT INTO movies AS m (tmdb_id, title, year, description)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (tmdb_id) WHERE tmdb_id <> 0 DO UPDATE SET
+			title = CASE WHEN 'title' = ANY(m.overrides) THEN m.title ELSE EXCLUDED.title END,
+			year = CASE WHEN 'year' = ANY(m.overrides) THEN m.year ELSE EXCLUDED.year END,
+			description = CASE WHEN 'description' = ANY(m.overrides) THEN m.description ELSE EXCLUDED.description END
+		WHERE (NOT 'title' = ANY(m.overrides) AND m.title <> EXCLUDED.title)
+		   OR (NOT 'year' = ANY(m.overrides) AND m.year <> EXCLUDED.year)
+		   OR (NOT 'description' = ANY(m.overrides) AND m.description <> EXCLUDED.description)
+		RETURNING `+movieColumns+`, (xmax = 0)`,
+		m.TMDBID,
+		m.Title,
+		m.Year,
+		m.Description,
+	).Scan(append(movieFields(&m), &inserted)...)
+
+	// The conflict's WHERE filtered the row out: it is already up to date.
+	if errors.Is(err, pgx.ErrNoRows) {
+		stored, err := r.GetByTMDBID(ctx, m.TMDBID)
+		return stored, model.UpsertUnchanged, err
+	}
 	if err != nil {
-		return []model.Movie{}
+		return model.Movie{}, 0, db.Classify(err)
 	}
-	defer rows.Close()
 
-	var movies []model.Movie
+	if inserted {
+		return m, model.UpsertCreated, nil
+	}
+	return m, model.UpsertUpdated, nil
+}
 
-	for rows.Next() {
-		var m model.Movie
-		if err := rows.Scan(
-			&m.ID,
-			&m.TMDBID,
-			&m.Title,
-			&m.Year,
-			&m.Description,
-			&m.Rating,
-		); err == nil {
-			movies = append(movies, m)
-		}
+func (r *MovieRepository) GetAll(ctx context.Context) ([]model.Movie, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	rows, err := r.db.Query(
+		ctx,
+		`SELECT `+movieColumns+` FROM movies`,
+	)
+	if err != nil {
+		return nil, db.Classify(err)
 	}
 
-	return movies
+	movies, err := collectMovies(rows)
+	return movies, db.Classify(err)
 }
 
-func (r *MovieRepository) GetByID(id int) (model.Movie, error) {
+func (r *MovieRepository) GetByID(ctx context.Context, id int) (model.Movie, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
 	var m model.Movie
 
-	err := db.DB.QueryRow(
-		context.Background(),
-		`SELECT id, tmdb_id, title, year, description, rating FROM movies WHERE id=$1`,
+	err := r.db.QueryRow(
+		ctx,
+		`SELECT `+movieColumns+` FROM movies WHERE id=$1`,
 		id,
-	).Scan(
-		&m.ID,
-		&m.TMDBID,
-		&m.Title,
-		&m.Year,
-		&m.Description,
-		&m.Rating,
-	)
+	).Scan(movieFields(&m)...)
 
-	if err != nil {
+	if errors.Is(err, pgx.ErrNoRows) {
 		return model.Movie{}, ErrMovieNotFound
 	}
+	if err != nil {
+		return model.Movie{}, db.Classify(err)
+	}
 
 	return m, nil
 }
 
-func (r *MovieRepository) GetByTMDBID(tmdbID int) (model.Movie, error) {
+func (r *MovieRepository) GetByTMDBID(ctx context.Context, tmdbID int) (model.Movie, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
 	var m model.Movie
 
-	err := db.DB.QueryRow(
-		context.Background(),
-		`SELECT id, tmdb_id, title, year, description, rating FROM movies WHERE tmdb_id=$1`,
+	err := r.db.QueryRow(
+		ctx,
+		`SELECT `+movieColumns+` FROM movies WHERE tmdb_id=$1`,
 		tmdbID,
-	).Scan(
-		&m.ID,
-		&m.TMDBID,
-		&m.Title,
-		&m.Year,
-		&m.Description,
-		&m.Rating,
-	)
+	).Scan(movieFields(&m)...)
 
-	if err != nil {
+	if errors.Is(err, pgx.ErrNoRows) {
 		return model.Movie{}, ErrMovieNotFound
 	}
+	if err != nil {
+		return model.Movie{}, db.Classify(err)
+	}
 
 	return m, nil
 }
 
-func (r *MovieRepository) ExistsByTMDBID(tmdbID int) bool {
-	var id int
-	err := db.DB.QueryRow(
-		context.Background(),
-		`SELECT id FROM movies WHERE tmdb_id=$1`,
+func (r *MovieRepository) ExistsByTMDBID(ctx context.Context, tmdbID int) (bool, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	var exists bool
+	err := r.db.QueryRow(
+		ctx,
+		`SELECT EXISTS (SELECT 1 FROM movies WHERE tmdb_id=$1)`,
 		tmdbID,
-	).Scan(&id)
+	).Scan(&exists)
 
-	return err == nil
+	return exists, db.Classify(err)
 }
 
-func (r *MovieRepository) Update(m model.Movie) (model.Movie, error) {
-	cmd, err := db.DB.Exec(
-		context.Background(),
-		`UPDATE movies SET title=$1, year=$2, description=$3, rating=$4 WHERE id=$5`,
+// Update leaves the rating as stored, so that writing back a movie read
+// earlier cannot undo a rating job that ran in between. The movie returned
+// carries the stored rating.
+func (r *MovieRepository) Update(ctx context.Context, m model.Movie) (model.Movie, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	err := r.db.QueryRow(
+		ctx,
+		`UPDATE movies
+		SET title=$1, year=$2, description=$3, overrides=COALESCE($5::text[], '{}')
+		WHERE id=$4
+		RETURNING rating`,
 		m.Title,
 		m.Year,
 		m.Description,
-		m.Rating,
 		m.ID,
-	)
-
-	if err != nil {
-		return model.Movie{}, err
-	}
+		m.Overrides,
+	).Scan(&m.Rating)
 
-	if cmd.RowsAffected() == 0 {
+	if errors.Is(err, pgx.ErrNoRows) {
 		return model.Movie{}, ErrMovieNotFound
 	}
+	if err != nil {
+		return model.Movie{}, db.Classify(err)
+	}
 
 	return m, nil
 }
 
-func (r *MovieRepository) Delete(id int) error {
-	cmd, err := db.DB.Exec(
-		context.Background(),
+func (r *MovieRepository) Delete(ctx context.Context, id int) error {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	cmd, err := r.db.Exec(
+		ctx,
 		`DELETE FROM movies WHERE id=$1`,
 		id,
 	)
 
 	if err != nil {
-		return err
+		return db.Classify(err)
 	}
 
 	if cmd.RowsAffected() == 0 {
@@ -174,40 +245,172 @@ func (r *MovieRepository) Delete(id int) error {
 	return nil
 }
 
-func (r *MovieRepository) Search(title string, year int) ([]model.Movie, error) {
-	query := `
-		SELECT id, tmdb_id, title, year, description, rating
+func (r *MovieRepository) List(ctx context.Context, f model.MovieFilter, opts model.ListOptions) ([]model.Movie, int, error) {
+	order, err := orderBy(movieSortColumns, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	limit, offset := pageLimit(opts)
+	where, args := movieFilterSQL(f, nil)
+
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpSearch)
+	defer cancel()
+
+	var total int
+	if err := r.db.QueryRow(
+		ctx,
+		`SELECT COUNT(*) FROM movies WHERE true`+where,
+		args...,
+	).Scan(&total); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	rows, err := r.db.Query(
+		ctx,
+		fmt.Sprintf(`SELECT `+movieColumns+`
 		FROM movies
-		WHERE ($1 = '' OR LOWER(title) LIKE '%' || LOWER($1) || '%')
-		  AND ($2 = 0 OR year = $2)
-	`
+		WHERE true`+where+`
+		ORDER BY `+order+`
+		LIMIT $%d OFFSET $%d`, len(args)+1, len(args)+2),
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, db.Classify(err)
+	}
 
-	rows, err := db.DB.Query(
-		context.Background(),
-		query,
-		title,
-		year,
+	movies, err := collectMovies(rows)
+	return movies, total, db.Classify(err)
+}
+
+// Search matches query against the full-text index over title and
+// description, falling back to trigram similarity on the title so that
+// misspelled queries still find something. With a query and no explicit
+// sort, results are ordered by relevance.
+func (r *MovieRepository) Search(ctx context.Context, query string, f model.MovieFilter, opts model.ListOptions) ([]model.MovieSearchHit, int, error) {
+	query = strings.TrimSpace(query)
+
+	order := "rank DESC, id ASC"
+	if opts.Sort != "relevance" && (opts.Sort != "" || query == "") {
+		var err error
+		order, err = orderBy(movieSortColumns, opts)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	limit, offset := pageLimit(opts)
+
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpSearch)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, 0, db.Classify(err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(
+		ctx,
+		`SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)`,
+		strconv.FormatFloat(searchSimilarityThreshold, 'f', -1, 64),
+	); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	where, args := movieFilterSQL(f, []any{query})
+	from := `
+		FROM movies, websearch_to_tsquery('english', $1) AS tsq
+		WHERE ($1 = '' OR search_vector @@ tsq OR $1 <% title)` + where
+
+	var total int
+	if err := tx.QueryRow(
+		ctx,
+		`SELECT COUNT(*)`+from,
+		args...,
+	).Scan(&total); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	rows, err := tx.Query(
+		ctx,
+		`SELECT `+movieColumns+`,
+			CASE WHEN $1 = '' THEN 0
+			     ELSE ts_rank_cd(search_vector, tsq) * 2 + word_similarity($1, title)
+			END AS rank,
+			CASE WHEN $1 = '' THEN ''
+			     ELSE ts_headline('english', `+htmlEscapeSQL(`coalesce(nullif(description, ''), title)`)+`, tsq,
+			                      'StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=10, MaxFragments=2')
+			END AS snippet`+from+`
+		ORDER BY `+order+
+			fmt.Sprintf(` LIMIT $%d OFFSET $%d`, len(args)+1, len(args)+2),
+		append(args, limit, offset)...,
 	)
 	if err != nil {
-		return nil, err
+		return nil, 0, db.Classify(err)
 	}
 	defer rows.Close()
 
-	var movies []model.Movie
+	hits := make([]model.MovieSearchHit, 0)
+	for rows.Next() {
+		var h model.MovieSearchHit
+		if err := rows.Scan(append(movieFields(&h.Movie), &h.Rank, &h.Snippet)...); err != nil {
+			return nil, 0, db.Classify(err)
+		}
+		hits = append(hits, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	return hits, total, db.Classify(tx.Commit(ctx))
+}
+
+// movieFilterSQL returns the conditions f puts on a query over movies, each
+// starting with AND, and args with their values appended as the next
+// placeholders.
+func movieFilterSQL(f model.MovieFilter, args []any) (string, []any) {
+	var b strings.Builder
+	add := func(cond string, v int) {
+		args = append(args, v)
+		fmt.Fprintf(&b, "\n\t\t  AND "+cond, len(args))
+	}
+
+	if f.Year != 0 {
+		add("movies.year = $%d", f.Year)
+	}
+	if f.YearFrom != 0 {
+		add("movies.year >= $%d", f.YearFrom)
+	}
+	if f.YearTo != 0 {
+		add("movies.year <= $%d", f.YearTo)
+	}
+	if f.GenreID != 0 {
+		add("EXISTS (SELECT 1 FROM movie_genres mg WHERE mg.movie_id = movies.id AND mg.genre_id = $%d)", f.GenreID)
+	}
+	return b.String(), args
+}
+
+func collectMovies(rows pgx.Rows) ([]model.Movie, error) {
+	defer rows.Close()
+
+	movies := make([]model.Movie, 0)
 	for rows.Next() {
 		var m model.Movie
-		if err := rows.Scan(
-			&m.ID,
-			&m.TMDBID,
-			&m.Title,
-			&m.Year,
-			&m.Description,
-			&m.Rating,
-		); err != nil {
+		if err := rows.Scan(movieFields(&m)...); err != nil {
 			return nil, err
 		}
 		movies = append(movies, m)
 	}
 
-	return movies, nil
+	return movies, rows.Err()
+}
+
+// htmlEscapeSQL wraps a text expression so it comes out HTML-escaped the way
+// html.EscapeString does it. The search snippet escapes the description
+// before ts_headline adds its <mark> tags, so that markup in a description
+// cannot reach a client rendering the snippet as HTML.
+func htmlEscapeSQL(expr string) string {
+	for _, r := range [][2]string{{"&", "&amp;"}, {"<", "&lt;"}, {">", "&gt;"}, {`"`, "&#34;"}, {"''", "&#39;"}} {
+		expr = "replace(" + expr + ", '" + r[0] + "', '" + r[1] + "')"
+	}
+	return expr
 }
@@ -0,0 +1,284 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/AlikhanF2006/Final_project/model"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+var ErrPersonNotFound = errors.New("person not found")
+
+const personColumns = `id, tmdb_id, name, profile_url`
+
+type PersonRepository struct {
+	db *db.DB
+}
+
+func NewPersonRepository(database *db.DB) *PersonRepository {
+	return &PersonRepository{db: database}
+}
+
+func (r *PersonRepository) GetByID(ctx context.Context, id int) (model.Person, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	var p model.Person
+	err := r.db.QueryRow(
+		ctx,
+		`SELECT `+personColumns+` FROM people WHERE id = $1`,
+		id,
+	).Scan(&p.ID, &p.TMDBID, &p.Name, &p.ProfileURL)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Person{}, ErrPersonNotFound
+	}
+	return p, db.Classify(err)
+}
+
+// Search finds people whose name contains query or is trigram-similar to
+// it, most similar first unless sort is given. An empty query lists
+// everyone.
+func (r *PersonRepository) Search(ctx context.Context, query string, opts model.ListOptions) ([]model.Person, int, error) {
+	query = strings.TrimSpace(query)
+
+	order := "word_similarity($1, name) DESC, name ASC, id ASC"
+	if opts.Sort != "relevance" && (opts.Sort != "" || query == "") {
+		var err error
+		order, err = orderBy(personSortColumns, opts)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	limit, offset := pageLimit(opts)
+
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpSearch)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, 0, db.Classify(err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(
+		ctx,
+		`SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)`,
+		strconv.FormatFloat(searchSimilarityThreshold, 'f', -1, 64),
+	); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	from := `
+		FROM people
+		WHERE $1 = '' OR name ILIKE '%' || $2 || '%' OR $1 <% name`
+	args := []any{query, escapeLike(query)}
+
+	var total int
+	if err := tx.QueryRow(ctx, `SELECT COUNT(*)`+from, args...).Scan(&total); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	rows, err := tx.Query(
+		ctx,
+		`SELECT `+personColumns+from+`
+		ORDER BY `+order+`
+		LIMIT $3 OFFSET $4`,
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, db.Classify(err)
+	}
+	defer rows.Close()
+
+	people := make([]model.Person, 0)
+	for rows.Next() {
+		var p model.Person
+		if err := rows.Scan(&p.ID, &p.TMDBID, &p.Name, &p.ProfileURL); err != nil {
+			return nil, 0, db.Classify(err)
+		}
+		people = append(people, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	return people, total, db.Classify(tx.Commit(ctx))
+}
+
+// Filmography returns the person's credits, newest movie first.
+func (r *PersonRepository) Filmography(ctx context.Context, personID int) ([]model.FilmographyEntry, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	rows, err := r.db.Query(
+		ctx,
+		`SELECT m.id, m.tmdb_id, m.title, m.year, c.role, c.character, c.job, c.department
+		FROM movie_credits c
+		JOIN movies m ON m.id = c.movie_id
+		WHERE c.person_id = $1
+		ORDER BY m.year DESC, m.title, m.id, c.role, c.credit_order, c.id`,
+		personID,
+	)
+	if err != nil {
+		return nil, db.Classify(err)
+	}
+	defer rows.Close()
+
+	entries := make([]model.FilmographyEntry, 0)
+	for rows.Next() {
+		var e model.FilmographyEntry
+		if err := rows.Scan(&e.MovieID, &e.TMDBID, &e.Title, &e.Year, &e.Role, &e.Character, &e.Job, &e.Department); err != nil {
+			return nil, db.Classify(err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, db.Classify(rows.Err())
+}
+
+// MovieCredits returns the movie's credits, cast first, in billing order.
+func (r *PersonRepository) MovieCredits(ctx context.Context, movieID int) ([]model.Credit, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	rows, err := r.db.Query(ctx, movieCreditsQuery, movieID)
+	if err != nil {
+		return nil, db.Classify(err)
+	}
+	return collectCredits(rows)
+}
+
+const movieCreditsQuery = `
+	SELECT p.id, p.tmdb_id, p.name, p.profile_url, c.role, c.character, c.job, c.department, c.credit_order
+	FROM movie_credits c
+	JOIN people p ON p.id = c.person_id
+	WHERE c.movie_id = $1
+	ORDER BY c.role, c.credit_order, c.id`
+
+// SetMovieCredits replaces the movie's credits, adding the people they name
+// and refreshing the names and photos of known ones. It reports whether the
+// credits changed; changes to the people alone do not count.
+func (r *PersonRepository) SetMovieCredits(ctx context.Context, movieID int, credits []model.Credit) (bool, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, db.Classify(err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `SELECT id FROM movies WHERE id = $1 FOR UPDATE`, movieID).Scan(&movieID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, ErrMovieNotFound
+	}
+	if err != nil {
+		return false, db.Classify(err)
+	}
+
+	// ON CONFLICT DO UPDATE cannot touch a row twice, so each person goes
+	// in once.
+	var (
+		tmdbIDs  []int
+		names    []string
+		profiles []string
+		seen     = make(map[int]bool, len(credits))
+	)
+	for _, c := range credits {
+		if seen[c.TMDBID] {
+			continue
+		}
+		seen[c.TMDBID] = true
+		tmdbIDs = append(tmdbIDs, c.TMDBID)
+		names = append(names, c.Name)
+		profiles = append(profiles, c.ProfileURL)
+	}
+	if _, err := tx.Exec(
+		ctx,
+		`INSERT INTO people (tmdb_id, name, profile_url)
+		SELECT * FROM unnest($1::int[], $2::text[], $3::text[])
+		ON CONFLICT (tmdb_id) DO UPDATE SET name = EXCLUDED.name, profile_url = EXCLUDED.profile_url
+		WHERE people.name <> EXCLUDED.name OR people.profile_url <> EXCLUDED.profile_url`,
+		tmdbIDs,
+		names,
+		profiles,
+	); err != nil {
+		return false, db.Classify(err)
+	}
+
+	rows, err := tx.Query(ctx, movieCreditsQuery, movieID)
+	if err != nil {
+		return false, db.Classify(err)
+	}
+	current, err := collectCredits(rows)
+	if err != nil {
+		return false, err
+	}
+
+	want := slices.SortedFunc(slices.Values(credits), model.CompareCredits)
+	slices.SortFunc(current, model.CompareCredits)
+	if slices.EqualFunc(current, want, model.Credit.SamePart) {
+		return false, db.Classify(tx.Commit(ctx))
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM movie_credits WHERE movie_id = $1`, movieID); err != nil {
+		return false, db.Classify(err)
+	}
+
+	var (
+		people      = make([]int, len(want))
+		roles       = make([]string, len(want))
+		characters  = make([]string, len(want))
+		jobs        = make([]string, len(want))
+		departments = make([]string, len(want))
+		orders      = make([]int, len(want))
+	)
+	for i, c := range want {
+		people[i], roles[i], characters[i] = c.TMDBID, c.Role, c.Character
+		jobs[i], departments[i], orders[i] = c.Job, c.Department, c.Order
+	}
+	if _, err := tx.Exec(
+		ctx,
+		`INSERT INTO movie_credits (movie_id, person_id, role, character, job, department, credit_order)
+		SELECT $1, p.id, c.role, c.character, c.job, c.department, c.credit_order
+		FROM unnest($2::int[], $3::text[], $4::text[], $5::text[], $6::text[], $7::int[])
+		     WITH ORDINALITY AS c(tmdb_id, role, character, job, department, credit_order, n)
+		JOIN people p ON p.tmdb_id = c.tmdb_id
+		ORDER BY c.n`,
+		movieID,
+		people,
+		roles,
+		characters,
+		jobs,
+		departments,
+		orders,
+	); err != nil {
+		return false, db.Classify(err)
+	}
+
+	return true, db.Classify(tx.Commit(ctx))
+}
+
+func collectCredits(rows pgx.Rows) ([]model.Credit, error) {
+	defer rows.Close()
+
+	credits := make([]model.Credit, 0)
+	for rows.Next() {
+		var c model.Credit
+		if err := rows.Scan(&c.PersonID, &c.TMDBID, &c.Name, &c.ProfileURL, &c.Role, &c.Character, &c.Job, &c.Department, &c.Order); err != nil {
+			return nil, db.Classify(err)
+		}
+		credits = append(credits, c)
+	}
+	return credits, db.Classify(rows.Err())
+}
+
+// escapeLike escapes the LIKE wildcards in s.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
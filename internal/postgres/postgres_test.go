@@ -0,0 +1,61 @@
+package postgres_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/AlikhanF2006/Final_project/configs"
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/internal/storagetest"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+// TestStorage runs against the database in MOVIE_TEST_DATABASE_URL and is
+// skipped without it. It migrates the database and then empties every table
+// the checks write to, so point it at a database of its own.
+func TestStorage(t *testing.T) {
+	url := os.Getenv("MOVIE_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("MOVIE_TEST_DATABASE_URL is not set")
+	}
+
+	ctx := context.Background()
+	database, err := db.Connect(ctx, configs.DatabaseConfig{URL: url, ConnectAttempts: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	migrator, err := db.NewMigrator(database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Exec(
+		ctx,
+		`TRUNCATE users, movies, genres, people, rating_jobs, tmdb_sync_runs, refresh_tokens, revoked_tokens
+		RESTART IDENTITY CASCADE`,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	err = storagetest.Run(ctx, storagetest.Repos{
+		Movies:     postgres.NewMovieRepository(database),
+		Reviews:    postgres.NewReviewRepository(database),
+		Users:      postgres.NewUserRepository(database),
+		Exports:    postgres.NewExportRepository(database),
+		Genres:     postgres.NewGenreRepository(database),
+		People:     postgres.NewPersonRepository(database),
+		Watchlists: postgres.NewWatchlistRepository(database),
+		Diary:      postgres.NewDiaryRepository(database),
+		Lists:      postgres.NewMovieListRepository(database),
+		Feed:       postgres.NewFeedRepository(database),
+		RatingJobs: postgres.NewRatingJobRepository(database),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
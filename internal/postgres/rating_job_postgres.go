@@ -0,0 +1,182 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+// ErrRatingJobFailed wraps the error of a job whose recomputation failed and
+// was put back for a retry. The queue itself is fine, so callers log it and
+// go on with the next job.
+var ErrRatingJobFailed = errors.New("rating job failed")
+
+// ratingJobRetryDelay is how long a job that failed waits before it is
+// claimed again.
+const ratingJobRetryDelay = 30 * time.Second
+
+type RatingJobRepository struct {
+	db *db.DB
+}
+
+func NewRatingJobRepository(database *db.DB) *RatingJobRepository {
+	return &RatingJobRepository{db: database}
+}
+
+// enqueueRating records that movieID needs its rating recomputed. It runs in
+// the caller's transaction so the job is stored if and only if the review
+// change is.
+func enqueueRating(ctx context.Context, tx pgx.Tx, movieID int) error {
+	_, err := tx.Exec(
+		ctx,
+		`INSERT INTO rating_jobs (movie_id) VALUES ($1)
+		ON CONFLICT (movie_id) DO UPDATE
+		SET enqueued_at = now(), next_attempt_at = now(), attempts = 0, last_error = ''`,
+		movieID,
+	)
+	return err
+}
+
+// withRatingJob runs fn in a transaction and, if fn succeeds, queues a
+// rating recomputation for the movie fn touched in the same transaction.
+// Review and diary writes go through it.
+func withRatingJob(ctx context.Context, database *db.DB, fn func(ctx context.Context, tx pgx.Tx) (int, error)) error {
+	ctx, cancel := database.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	tx, err := database.Begin(ctx)
+	if err != nil {
+		return db.Classify(err)
+	}
+	defer tx.Rollback(ctx)
+
+	movieID, err := fn(ctx, tx)
+	if err != nil {
+		return db.Classify(err)
+	}
+	if err := enqueueRating(ctx, tx, movieID); err != nil {
+		return db.Classify(err)
+	}
+	return db.Classify(tx.Commit(ctx))
+}
+
+func (r *RatingJobRepository) Enqueue(ctx context.Context, movieID int) error {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return db.Classify(err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := enqueueRating(ctx, tx, movieID); err != nil {
+		return db.Classify(err)
+	}
+	return db.Classify(tx.Commit(ctx))
+}
+
+// ProcessNext claims one due job, recomputes that movie's rating and deletes
+// the job, all in one transaction. The rating averages one score per user:
+// their latest scored diary entry, or else their review, so rewatches do not
+// add weight. It returns false when no job is due. Concurrent workers skip
+// each other's claimed rows. A job whose recomputation fails is put back for
+// a retry and reported as ErrRatingJobFailed.
+func (r *RatingJobRepository) ProcessNext(ctx context.Context) (bool, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, db.Classify(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var movieID int
+	err = tx.QueryRow(
+		ctx,
+		`SELECT movie_id FROM rating_jobs
+		WHERE next_attempt_at <= now()
+		ORDER BY enqueued_at
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`,
+	).Scan(&movieID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, db.Classify(err)
+	}
+
+	if _, err := tx.Exec(
+		ctx,
+		`UPDATE movies
+		SET rating = COALESCE((
+			SELECT AVG(score)::float8 FROM (
+				SELECT DISTINCT ON (user_id) score
+				FROM (
+					SELECT user_id, score, 1 AS source, watched_on, id
+					FROM diary_entries
+					WHERE movie_id = $1 AND score IS NOT NULL
+					UNION ALL
+					SELECT user_id, score, 0, NULL, id
+					FROM reviews
+					WHERE movie_id = $1
+				) scores
+				ORDER BY user_id, source DESC, watched_on DESC, id DESC
+			) latest
+		), 0)
+		WHERE id = $1`,
+		movieID,
+	); err != nil {
+		tx.Rollback(ctx)
+		return true, r.markFailed(ctx, movieID, err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM rating_jobs WHERE movie_id = $1`, movieID); err != nil {
+		return true, db.Classify(err)
+	}
+
+	return true, db.Classify(tx.Commit(ctx))
+}
+
+// markFailed counts a failed attempt and holds the job back for
+// ratingJobRetryDelay. The failure may be the job's own context running out,
+// so the bookkeeping gets a context of its own; only a caller that gave up
+// leaves the job as it was.
+func (r *RatingJobRepository) markFailed(ctx context.Context, movieID int, cause error) error {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return db.Classify(cause)
+	}
+	ctx, cancel := r.db.WithTimeout(context.WithoutCancel(ctx), db.OpWrite)
+	defer cancel()
+
+	_, err := r.db.Exec(
+		ctx,
+		`UPDATE rating_jobs
+		SET attempts = attempts + 1, last_error = $2, next_attempt_at = now() + make_interval(secs => $3)
+		WHERE movie_id = $1`,
+		movieID,
+		cause.Error(),
+		ratingJobRetryDelay.Seconds(),
+	)
+	if err != nil {
+		return errors.Join(db.Classify(cause), db.Classify(err))
+	}
+	return fmt.Errorf("%w: movie %d: %w", ErrRatingJobFailed, movieID, db.Classify(cause))
+}
+
+// Pending returns the number of queued jobs.
+func (r *RatingJobRepository) Pending(ctx context.Context) (int, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	var n int
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM rating_jobs`).Scan(&n)
+	return n, db.Classify(err)
+}
@@ -4,46 +4,71 @@ import (
 	"context"
 	"errors"
 
+	"github.com/jackc/pgx/v5"
+
 	"github.com/AlikhanF2006/Final_project/model"
 	"github.com/AlikhanF2006/Final_project/pkg/db"
 )
 
-type ReviewRepository struct{}
+var (
+	ErrReviewNotFound = errors.New("review not found")
+	ErrReviewExists   = errors.New("user has already reviewed this movie")
+)
+
+type ReviewRepository struct {
+	db *db.DB
+}
 
-func NewReviewRepository() *ReviewRepository {
-	return &ReviewRepository{}
+func NewReviewRepository(database *db.DB) *ReviewRepository {
+	return &ReviewRepository{db: database}
 }
 
-func (r *ReviewRepository) Add(movieID int, rev model.Review) (model.Review, error) {
+func (r *ReviewRepository) Add(ctx context.Context, movieID int, rev model.Review) (model.Review, error) {
 	query := `
 		INSERT INTO reviews (movie_id, user_id, score, text)
 		VALUES ($1, $2, $3, $4)
 		RETURNING id, created_at
 	`
 
-	err := db.DB.QueryRow(
-		context.Background(),
-		query,
-		movieID,
-		rev.UserID,
-		rev.Score,
-		rev.Text,
-	).Scan(&rev.ID, &rev.CreatedAt)
+	err := withRatingJob(ctx, r.db, func(ctx context.Context, tx pgx.Tx) (int, error) {
+		if err := tx.QueryRow(
+			ctx,
+			query,
+			movieID,
+			rev.UserID,
+			rev.Score,
+			rev.Text,
+		).Scan(&rev.ID, &rev.CreatedAt); err != nil {
+			return 0, err
+		}
+		return movieID, publishActivity(ctx, tx, model.ActivityReview, rev.UserID, model.ActivitySubject{ReviewID: rev.ID})
+	})
+	switch {
+	case violates(err, uniqueViolationCode, ""):
+		return model.Review{}, ErrReviewExists
+	case violates(err, foreignKeyViolationCode, "reviews_movie_id_fkey"):
+		return model.Review{}, ErrMovieNotFound
+	case err != nil:
+		return model.Review{}, err
+	}
 
 	rev.MovieID = movieID
-	return rev, err
+	return rev, nil
 }
 
-func (r *ReviewRepository) ListByMovieID(movieID int) ([]model.Review, error) {
+func (r *ReviewRepository) ListByMovieID(ctx context.Context, movieID int) ([]model.Review, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
 	query := `
 		SELECT id, movie_id, user_id, score, text, created_at
 		FROM reviews
 		WHERE movie_id = $1
 	`
 
-	rows, err := db.DB.Query(context.Background(), query, movieID)
+	rows, err := r.db.Query(ctx, query, movieID)
 	if err != nil {
-		return nil, err
+		return nil, db.Classify(err)
 	}
 	defer rows.Close()
 
@@ -58,58 +83,119 @@ func (r *ReviewRepository) ListByMovieID(movieID int) ([]model.Review, error) {
 			&rr.Text,
 			&rr.CreatedAt,
 		); err != nil {
-			return nil, err
+			return nil, db.Classify(err)
 		}
 		revs = append(revs, rr)
 	}
 
-	return revs, nil
+	return revs, db.Classify(rows.Err())
+}
+
+func (r *ReviewRepository) PageByMovieID(ctx context.Context, movieID int, opts model.ListOptions) ([]model.Review, int, error) {
+	order, err := orderBy(reviewSortColumns, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	limit, offset := pageLimit(opts)
+
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	var total int
+	if err := r.db.QueryRow(
+		ctx,
+		`SELECT COUNT(*) FROM reviews WHERE movie_id = $1`,
+		movieID,
+	).Scan(&total); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	query := `
+		SELECT id, movie_id, user_id, score, text, created_at
+		FROM reviews
+		WHERE movie_id = $1
+		ORDER BY ` + order + `
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := r.db.Query(ctx, query, movieID, limit, offset)
+	if err != nil {
+		return nil, 0, db.Classify(err)
+	}
+	defer rows.Close()
+
+	revs := make([]model.Review, 0)
+	for rows.Next() {
+		var rr model.Review
+		if err := rows.Scan(
+			&rr.ID,
+			&rr.MovieID,
+			&rr.UserID,
+			&rr.Score,
+			&rr.Text,
+			&rr.CreatedAt,
+		); err != nil {
+			return nil, 0, db.Classify(err)
+		}
+		revs = append(revs, rr)
+	}
+
+	return revs, total, db.Classify(rows.Err())
 }
 
 func (r *ReviewRepository) UpdateByMovieAndUser(
+	ctx context.Context,
 	movieID int,
 	userID int,
 	score int,
 ) error {
-	cmd, err := db.DB.Exec(
-		context.Background(),
-		`UPDATE reviews SET score=$1 WHERE movie_id=$2 AND user_id=$3`,
-		score,
-		movieID,
-		userID,
-	)
-	if err != nil {
-		return err
-	}
-	if cmd.RowsAffected() == 0 {
-		return errors.New("not found")
-	}
-	return nil
+	return withRatingJob(ctx, r.db, func(ctx context.Context, tx pgx.Tx) (int, error) {
+		cmd, err := tx.Exec(
+			ctx,
+			`UPDATE reviews SET score=$1 WHERE movie_id=$2 AND user_id=$3`,
+			score,
+			movieID,
+			userID,
+		)
+		if err != nil {
+			return 0, err
+		}
+		if cmd.RowsAffected() == 0 {
+			return 0, ErrReviewNotFound
+		}
+		return movieID, nil
+	})
 }
 
 func (r *ReviewRepository) DeleteByMovieAndUser(
+	ctx context.Context,
 	movieID int,
 	userID int,
 ) error {
-	cmd, err := db.DB.Exec(
-		context.Background(),
-		`DELETE FROM reviews WHERE movie_id=$1 AND user_id=$2`,
-		movieID,
-		userID,
-	)
-	if err != nil {
-		return err
-	}
-	if cmd.RowsAffected() == 0 {
-		return errors.New("not found")
-	}
-	return nil
+	return withRatingJob(ctx, r.db, func(ctx context.Context, tx pgx.Tx) (int, error) {
+		cmd, err := tx.Exec(
+			ctx,
+			`DELETE FROM reviews WHERE movie_id=$1 AND user_id=$2`,
+			movieID,
+			userID,
+		)
+		if err != nil {
+			return 0, err
+		}
+		if cmd.RowsAffected() == 0 {
+			return 0, ErrReviewNotFound
+		}
+		return movieID, nil
+	})
 }
 
-func (r *ReviewRepository) GetByID(id int) (model.Review, error) {
+func (r *ReviewRepository) GetByID(ctx context.Context, id int) (model.Review, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
 	var rev model.Review
 	query := `SELECT id, movie_id, user_id, score, text, created_at FROM reviews WHERE id=$1`
-	err := db.DB.QueryRow(context.Background(), query, id).Scan(
+	err := r.db.QueryRow(ctx, query, id).Scan(
 		&rev.ID,
 		&rev.MovieID,
 		&rev.UserID,
@@ -117,19 +203,22 @@ func (r *ReviewRepository) GetByID(id int) (model.Review, error) {
 		&rev.Text,
 		&rev.CreatedAt,
 	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Review{}, ErrReviewNotFound
+	}
 	if err != nil {
-		return model.Review{}, errors.New("not found")
+		return model.Review{}, db.Classify(err)
 	}
 	return rev, nil
 }
 
-func (r *ReviewRepository) DeleteByID(id int) error {
-	cmd, err := db.DB.Exec(context.Background(), `DELETE FROM reviews WHERE id=$1`, id)
-	if err != nil {
-		return err
-	}
-	if cmd.RowsAffected() == 0 {
-		return errors.New("not found")
-	}
-	return nil
+func (r *ReviewRepository) DeleteByID(ctx context.Context, id int) error {
+	return withRatingJob(ctx, r.db, func(ctx context.Context, tx pgx.Tx) (int, error) {
+		var movieID int
+		err := tx.QueryRow(ctx, `DELETE FROM reviews WHERE id=$1 RETURNING movie_id`, id).Scan(&movieID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrReviewNotFound
+		}
+		return movieID, err
+	})
 }
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+type RoleRepository struct {
+	db *db.DB
+}
+
+func NewRoleRepository(database *db.DB) *RoleRepository {
+	return &RoleRepository{db: database}
+}
+
+// LoadGrants returns every role with the permissions it grants. Roles with no
+// permissions are present with an empty slice.
+func (r *RoleRepository) LoadGrants(ctx context.Context) (map[string][]string, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	rows, err := r.db.Query(
+		ctx,
+		`SELECT r.name, rp.permission
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role = r.name`,
+	)
+	if err != nil {
+		return nil, db.Classify(err)
+	}
+	defer rows.Close()
+
+	grants := make(map[string][]string)
+	for rows.Next() {
+		var role string
+		var perm *string
+		if err := rows.Scan(&role, &perm); err != nil {
+			return nil, db.Classify(err)
+		}
+		if perm == nil {
+			if _, ok := grants[role]; !ok {
+				grants[role] = []string{}
+			}
+			continue
+		}
+		grants[role] = append(grants[role], *perm)
+	}
+
+	return grants, db.Classify(rows.Err())
+}
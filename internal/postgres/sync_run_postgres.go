@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/AlikhanF2006/Final_project/model"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+type SyncRunRepository struct {
+	db *db.DB
+}
+
+func NewSyncRunRepository(database *db.DB) *SyncRunRepository {
+	return &SyncRunRepository{db: database}
+}
+
+// Create records the start of a run.
+func (r *SyncRunRepository) Create(ctx context.Context, run model.SyncRun) (model.SyncRun, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	err := r.db.QueryRow(
+		ctx,
+		`INSERT INTO tmdb_sync_runs (triggered_by, status, lists)
+		VALUES ($1, $2, COALESCE($3::text[], '{}'))
+		RETURNING id, started_at`,
+		run.Trigger,
+		run.Status,
+		run.Lists,
+	).Scan(&run.ID, &run.StartedAt)
+
+	return run, db.Classify(err)
+}
+
+// Finish stores the run's outcome and counts and stamps finished_at.
+func (r *SyncRunRepository) Finish(ctx context.Context, run model.SyncRun) error {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	_, err := r.db.Exec(
+		ctx,
+		`UPDATE tmdb_sync_runs
+		SET status=$2, finished_at=now(), pages=$3, fetched=$4, created=$5,
+			updated=$6, unchanged=$7, failed=$8, errors=COALESCE($9::text[], '{}')
+		WHERE id=$1`,
+		run.ID,
+		run.Status,
+		run.Pages,
+		run.Fetched,
+		run.Created,
+		run.Updated,
+		run.Unchanged,
+		run.Failed,
+		run.Errors,
+	)
+	return db.Classify(err)
+}
+
+// List returns runs newest first.
+func (r *SyncRunRepository) List(ctx context.Context, opts model.ListOptions) ([]model.SyncRun, int, error) {
+	limit, offset := pageLimit(opts)
+
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM tmdb_sync_runs`).Scan(&total); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	rows, err := r.db.Query(
+		ctx,
+		`SELECT id, triggered_by, status, lists, started_at, finished_at,
+			pages, fetched, created, updated, unchanged, failed, errors
+		FROM tmdb_sync_runs
+		ORDER BY id DESC
+		LIMIT $1 OFFSET $2`,
+		limit,
+		offset,
+	)
+	if err != nil {
+		return nil, 0, db.Classify(err)
+	}
+	defer rows.Close()
+
+	runs := make([]model.SyncRun, 0)
+	for rows.Next() {
+		var run model.SyncRun
+		if err := rows.Scan(
+			&run.ID,
+			&run.Trigger,
+			&run.Status,
+			&run.Lists,
+			&run.StartedAt,
+			&run.FinishedAt,
+			&run.Pages,
+			&run.Fetched,
+			&run.Created,
+			&run.Updated,
+			&run.Unchanged,
+			&run.Failed,
+			&run.Errors,
+		); err != nil {
+			return nil, 0, db.Classify(err)
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, total, db.Classify(rows.Err())
+}
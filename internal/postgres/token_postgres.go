@@ -0,0 +1,181 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/AlikhanF2006/Final_project/model"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenReused   = errors.New("refresh token already used")
+)
+
+type TokenRepository struct {
+	db *db.DB
+}
+
+func NewTokenRepository(database *db.DB) *TokenRepository {
+	return &TokenRepository{db: database}
+}
+
+func (r *TokenRepository) CreateRefreshToken(ctx context.Context, t model.RefreshToken) (model.RefreshToken, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	err := r.db.QueryRow(
+		ctx,
+		`INSERT INTO refresh_tokens (user_id, token_hash, family_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`,
+		t.UserID,
+		t.TokenHash,
+		t.FamilyID,
+		t.ExpiresAt,
+	).Scan(&t.ID, &t.CreatedAt)
+
+	return t, db.Classify(err)
+}
+
+func (r *TokenRepository) GetRefreshToken(ctx context.Context, hash string) (model.RefreshToken, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	var t model.RefreshToken
+	err := r.db.QueryRow(
+		ctx,
+		`SELECT id, user_id, token_hash, family_id, expires_at, revoked_at, replaced_by, created_at
+		FROM refresh_tokens WHERE token_hash=$1`,
+		hash,
+	).Scan(
+		&t.ID,
+		&t.UserID,
+		&t.TokenHash,
+		&t.FamilyID,
+		&t.ExpiresAt,
+		&t.RevokedAt,
+		&t.ReplacedBy,
+		&t.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.RefreshToken{}, ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return model.RefreshToken{}, db.Classify(err)
+	}
+	return t, nil
+}
+
+// RotateRefreshToken revokes oldID and stores next in its place in one
+// transaction. If oldID was already revoked (the token is being replayed) it
+// returns ErrRefreshTokenReused and stores nothing.
+func (r *TokenRepository) RotateRefreshToken(ctx context.Context, oldID int, next model.RefreshToken) (model.RefreshToken, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return model.RefreshToken{}, db.Classify(err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := tx.QueryRow(
+		ctx,
+		`INSERT INTO refresh_tokens (user_id, token_hash, family_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`,
+		next.UserID,
+		next.TokenHash,
+		next.FamilyID,
+		next.ExpiresAt,
+	).Scan(&next.ID, &next.CreatedAt); err != nil {
+		return model.RefreshToken{}, db.Classify(err)
+	}
+
+	cmd, err := tx.Exec(
+		ctx,
+		`UPDATE refresh_tokens SET revoked_at=now(), replaced_by=$1
+		WHERE id=$2 AND revoked_at IS NULL`,
+		next.ID,
+		oldID,
+	)
+	if err != nil {
+		return model.RefreshToken{}, db.Classify(err)
+	}
+	if cmd.RowsAffected() == 0 {
+		return model.RefreshToken{}, ErrRefreshTokenReused
+	}
+
+	return next, db.Classify(tx.Commit(ctx))
+}
+
+func (r *TokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	_, err := r.db.Exec(
+		ctx,
+		`UPDATE refresh_tokens SET revoked_at=now() WHERE family_id=$1 AND revoked_at IS NULL`,
+		familyID,
+	)
+	return db.Classify(err)
+}
+
+func (r *TokenRepository) RevokeAllForUser(ctx context.Context, userID int) error {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	_, err := r.db.Exec(
+		ctx,
+		`UPDATE refresh_tokens SET revoked_at=now() WHERE user_id=$1 AND revoked_at IS NULL`,
+		userID,
+	)
+	return db.Classify(err)
+}
+
+// RevokeAccessToken denylists one access token until it expires. Expired
+// denylist entries are pruned on the way.
+func (r *TokenRepository) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	if _, err := r.db.Exec(ctx, `DELETE FROM revoked_tokens WHERE expires_at < now()`); err != nil {
+		return db.Classify(err)
+	}
+
+	_, err := r.db.Exec(
+		ctx,
+		`INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING`,
+		jti,
+		expiresAt,
+	)
+	return db.Classify(err)
+}
+
+// SessionState returns the user's current token version and whether jti has
+// been denylisted. It returns ErrUserNotFound once the user is deleted.
+func (r *TokenRepository) SessionState(ctx context.Context, userID int, jti string) (int, bool, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	var version int
+	var revoked bool
+	err := r.db.QueryRow(
+		ctx,
+		`SELECT u.token_version,
+			EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $2)
+		FROM users u WHERE u.id = $1`,
+		userID,
+		jti,
+	).Scan(&version, &revoked)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, ErrUserNotFound
+	}
+	return version, revoked, db.Classify(err)
+}
@@ -4,85 +4,190 @@ import (
 	"context"
 	"errors"
 
+	"github.com/jackc/pgx/v5"
+
 	"github.com/AlikhanF2006/Final_project/model"
 	"github.com/AlikhanF2006/Final_project/pkg/db"
 )
 
-var ErrUserNotFound = errors.New("user not found")
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrUserExists   = errors.New("username or email already taken")
+)
 
-type UserRepository struct{}
+type UserRepository struct {
+	db *db.DB
+}
 
-func NewUserRepository() *UserRepository {
-	return &UserRepository{}
+func NewUserRepository(database *db.DB) *UserRepository {
+	return &UserRepository{db: database}
 }
 
-func (r *UserRepository) Create(u model.User) (model.User, error) {
+func (r *UserRepository) Create(ctx context.Context, u model.User) (model.User, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
 	query := `
 		INSERT INTO users (username, email, password_hash, role)
 		VALUES ($1, $2, $3, $4)
 		RETURNING id, created_at
 	`
-	err := db.DB.QueryRow(
-		context.Background(),
+	err := r.db.QueryRow(
+		ctx,
 		query,
 		u.Username,
 		u.Email,
 		u.PasswordHash,
 		u.Role,
 	).Scan(&u.ID, &u.CreatedAt)
+	if violates(err, uniqueViolationCode, "") {
+		return model.User{}, ErrUserExists
+	}
 
-	return u, err
+	return u, db.Classify(err)
 }
 
-func (r *UserRepository) GetByEmail(email string) (model.User, error) {
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (model.User, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
 	var u model.User
 	query := `
-		SELECT id, username, email, password_hash, role, created_at
+		SELECT id, username, email, password_hash, role, token_version, created_at
 		FROM users WHERE email=$1
 	`
-	err := db.DB.QueryRow(context.Background(), query, email).
-		Scan(&u.ID, &u.Username, &u.Email, &u.PasswordHash, &u.Role, &u.CreatedAt)
-	if err != nil {
+	err := r.db.QueryRow(ctx, query, email).
+		Scan(&u.ID, &u.Username, &u.Email, &u.PasswordHash, &u.Role, &u.TokenVersion, &u.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
 		return model.User{}, ErrUserNotFound
 	}
+	if err != nil {
+		return model.User{}, db.Classify(err)
+	}
 	return u, nil
 }
 
-func (r *UserRepository) GetByID(id int) (model.User, error) {
+func (r *UserRepository) GetByID(ctx context.Context, id int) (model.User, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
 	var u model.User
 	query := `
-		SELECT id, username, email, password_hash, role, created_at
+		SELECT id, username, email, password_hash, role, token_version, created_at
 		FROM users WHERE id=$1
 	`
-	err := db.DB.QueryRow(context.Background(), query, id).
-		Scan(&u.ID, &u.Username, &u.Email, &u.PasswordHash, &u.Role, &u.CreatedAt)
-	if err != nil {
+	err := r.db.QueryRow(ctx, query, id).
+		Scan(&u.ID, &u.Username, &u.Email, &u.PasswordHash, &u.Role, &u.TokenVersion, &u.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
 		return model.User{}, ErrUserNotFound
 	}
+	if err != nil {
+		return model.User{}, db.Classify(err)
+	}
 	return u, nil
 }
 
-func (r *UserRepository) Update(u model.User) (model.User, error) {
-	_, err := db.DB.Exec(
-		context.Background(),
+func (r *UserRepository) Update(ctx context.Context, u model.User) (model.User, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	cmd, err := r.db.Exec(
+		ctx,
 		`UPDATE users SET username=$1, email=$2 WHERE id=$3`,
 		u.Username,
 		u.Email,
 		u.ID,
 	)
-	return u, err
+	if violates(err, uniqueViolationCode, "") {
+		return model.User{}, ErrUserExists
+	}
+	if err != nil {
+		return model.User{}, db.Classify(err)
+	}
+	if cmd.RowsAffected() == 0 {
+		return model.User{}, ErrUserNotFound
+	}
+	return u, nil
+}
+
+// UpdatePassword also bumps token_version, which invalidates every access
+// token issued before the change.
+func (r *UserRepository) UpdatePassword(ctx context.Context, id int, hash string) error {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	cmd, err := r.db.Exec(
+		ctx,
+		`UPDATE users SET password_hash=$1, token_version=token_version+1 WHERE id=$2`,
+		hash,
+		id,
+	)
+	if err != nil {
+		return db.Classify(err)
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *UserRepository) BumpTokenVersion(ctx context.Context, id int) error {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	_, err := r.db.Exec(ctx, `UPDATE users SET token_version=token_version+1 WHERE id=$1`, id)
+	return db.Classify(err)
 }
 
-func (r *UserRepository) UpdatePassword(id int, hash string) error {
-	_, err := db.DB.Exec(context.Background(), `UPDATE users SET password_hash=$1 WHERE id=$2`, hash, id)
-	return err
+func (r *UserRepository) UpdateRole(ctx context.Context, id int, role string) error {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	cmd, err := r.db.Exec(ctx, `UPDATE users SET role=$1, token_version=token_version+1 WHERE id=$2`, role, id)
+	if err != nil {
+		return db.Classify(err)
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
 }
 
-func (r *UserRepository) Delete(id int) error {
-	_, err := db.DB.Exec(
-		context.Background(),
-		`DELETE FROM users WHERE id=$1`,
+// Delete removes the user with everything that cascades from it, and
+// queues a rating recomputation for every movie the user's reviews and
+// diary entries scored, in the same transaction.
+func (r *UserRepository) Delete(ctx context.Context, id int) error {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return db.Classify(err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(
+		ctx,
+		`SELECT movie_id FROM reviews WHERE user_id=$1
+		UNION
+		SELECT movie_id FROM diary_entries WHERE user_id=$1`,
 		id,
 	)
-	return err
+	if err != nil {
+		return db.Classify(err)
+	}
+	movieIDs, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return db.Classify(err)
+	}
+	for _, movieID := range movieIDs {
+		if err := enqueueRating(ctx, tx, movieID); err != nil {
+			return db.Classify(err)
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM users WHERE id=$1`, id); err != nil {
+		return db.Classify(err)
+	}
+	return db.Classify(tx.Commit(ctx))
 }
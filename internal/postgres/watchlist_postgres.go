@@ -0,0 +1,225 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/AlikhanF2006/Final_project/model"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+var (
+	ErrWatchlistEntryNotFound = errors.New("movie is not on the watchlist")
+	ErrWatchlistEntryExists   = errors.New("movie is already on the watchlist")
+)
+
+const watchlistColumns = `w.id, w.position, w.note, coalesce(to_char(w.planned_for, 'YYYY-MM-DD'), ''), w.created_at,
+	movies.id, movies.tmdb_id, movies.title, movies.year, movies.description, movies.rating, movies.overrides`
+
+const watchlistFrom = `
+	FROM watchlist_entries w
+	JOIN movies ON movies.id = w.movie_id`
+
+// watchlistFields returns scan targets for watchlistColumns.
+func watchlistFields(e *model.WatchlistEntry) []any {
+	return append([]any{&e.ID, &e.Position, &e.Note, &e.PlannedFor, &e.AddedAt}, movieFields(&e.Movie)...)
+}
+
+type WatchlistRepository struct {
+	db *db.DB
+}
+
+func NewWatchlistRepository(database *db.DB) *WatchlistRepository {
+	return &WatchlistRepository{db: database}
+}
+
+// List returns the user's entries in f, by position unless sort is given.
+func (r *WatchlistRepository) List(ctx context.Context, userID int, f model.WatchlistFilter, opts model.ListOptions) ([]model.WatchlistEntry, int, error) {
+	order, err := qualifiedOrder(watchlistSortColumns, "position", "w.id", opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	limit, offset := pageLimit(opts)
+
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	where := ""
+	args := []any{userID}
+	if f.DueBy != "" {
+		args = append(args, f.DueBy)
+		where = fmt.Sprintf("\n\t\t  AND w.planned_for <= $%d::date", len(args))
+	}
+	filter, args := movieFilterSQL(f.MovieFilter, args)
+	from := watchlistFrom + `
+		WHERE w.user_id = $1` + where + filter
+
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*)`+from, args...).Scan(&total); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	rows, err := r.db.Query(
+		ctx,
+		fmt.Sprintf(`SELECT `+watchlistColumns+from+`
+		ORDER BY `+order+`
+		LIMIT $%d OFFSET $%d`, len(args)+1, len(args)+2),
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, db.Classify(err)
+	}
+	defer rows.Close()
+
+	entries := make([]model.WatchlistEntry, 0)
+	for rows.Next() {
+		var e model.WatchlistEntry
+		if err := rows.Scan(watchlistFields(&e)...); err != nil {
+			return nil, 0, db.Classify(err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, db.Classify(rows.Err())
+}
+
+func (r *WatchlistRepository) Get(ctx context.Context, userID, movieID int) (model.WatchlistEntry, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpRead)
+	defer cancel()
+
+	var e model.WatchlistEntry
+	err := r.db.QueryRow(
+		ctx,
+		`SELECT `+watchlistColumns+watchlistFrom+`
+		WHERE w.user_id = $1 AND w.movie_id = $2`,
+		userID,
+		movieID,
+	).Scan(watchlistFields(&e)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.WatchlistEntry{}, ErrWatchlistEntryNotFound
+	}
+	return e, db.Classify(err)
+}
+
+// Add puts e.Movie at the end of the user's watchlist.
+func (r *WatchlistRepository) Add(ctx context.Context, userID int, e model.WatchlistEntry) (model.WatchlistEntry, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	_, err := r.db.Exec(
+		ctx,
+		`INSERT INTO watchlist_entries (user_id, movie_id, position, note, planned_for)
+		SELECT $1, $2, coalesce(max(position), 0) + 1, $3, nullif($4, '')::date
+		FROM watchlist_entries
+		WHERE user_id = $1`,
+		userID,
+		e.Movie.ID,
+		e.Note,
+		e.PlannedFor,
+	)
+	switch {
+	case violates(err, uniqueViolationCode, "watchlist_entries_user_movie_key"):
+		return model.WatchlistEntry{}, ErrWatchlistEntryExists
+	case violates(err, foreignKeyViolationCode, "watchlist_entries_movie_id_fkey"):
+		return model.WatchlistEntry{}, ErrMovieNotFound
+	case err != nil:
+		return model.WatchlistEntry{}, db.Classify(err)
+	}
+
+	return r.Get(ctx, userID, e.Movie.ID)
+}
+
+// Update sets the note and planned date of the entry for e.Movie.
+func (r *WatchlistRepository) Update(ctx context.Context, userID int, e model.WatchlistEntry) (model.WatchlistEntry, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	tag, err := r.db.Exec(
+		ctx,
+		`UPDATE watchlist_entries SET note = $3, planned_for = nullif($4, '')::date
+		WHERE user_id = $1 AND movie_id = $2`,
+		userID,
+		e.Movie.ID,
+		e.Note,
+		e.PlannedFor,
+	)
+	if err != nil {
+		return model.WatchlistEntry{}, db.Classify(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return model.WatchlistEntry{}, ErrWatchlistEntryNotFound
+	}
+
+	return r.Get(ctx, userID, e.Movie.ID)
+}
+
+// Move puts the movie's entry at position (clamped to the list) and
+// renumbers the watchlist from 1.
+func (r *WatchlistRepository) Move(ctx context.Context, userID, movieID, position int) (model.WatchlistEntry, error) {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return model.WatchlistEntry{}, db.Classify(err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(
+		ctx,
+		`SELECT movie_id FROM watchlist_entries WHERE user_id = $1 ORDER BY position, id FOR UPDATE`,
+		userID,
+	)
+	if err != nil {
+		return model.WatchlistEntry{}, db.Classify(err)
+	}
+	order, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return model.WatchlistEntry{}, db.Classify(err)
+	}
+
+	i := slices.Index(order, movieID)
+	if i < 0 {
+		return model.WatchlistEntry{}, ErrWatchlistEntryNotFound
+	}
+	order = slices.Delete(order, i, i+1)
+	order = slices.Insert(order, min(max(position, 1), len(order)+1)-1, movieID)
+
+	if _, err := tx.Exec(
+		ctx,
+		`UPDATE watchlist_entries w SET position = o.n
+		FROM unnest($2::int[]) WITH ORDINALITY AS o(movie_id, n)
+		WHERE w.user_id = $1 AND w.movie_id = o.movie_id AND w.position <> o.n`,
+		userID,
+		order,
+	); err != nil {
+		return model.WatchlistEntry{}, db.Classify(err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return model.WatchlistEntry{}, db.Classify(err)
+	}
+
+	return r.Get(ctx, userID, movieID)
+}
+
+func (r *WatchlistRepository) Remove(ctx context.Context, userID, movieID int) error {
+	ctx, cancel := r.db.WithTimeout(ctx, db.OpWrite)
+	defer cancel()
+
+	tag, err := r.db.Exec(
+		ctx,
+		`DELETE FROM watchlist_entries WHERE user_id = $1 AND movie_id = $2`,
+		userID,
+		movieID,
+	)
+	if err != nil {
+		return db.Classify(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrWatchlistEntryNotFound
+	}
+	return nil
+}
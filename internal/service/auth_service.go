@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/AlikhanF2006/Final_project/internal/auth"
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/internal/postgres/dto"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+type AuthService struct {
+	users      postgres.UserRepo
+	tokens     postgres.TokenRepo
+	issuer     *auth.TokenService
+	refreshTTL time.Duration
+}
+
+func NewAuthService(
+	users postgres.UserRepo,
+	tokens postgres.TokenRepo,
+	issuer *auth.TokenService,
+	refreshTTL time.Duration,
+) *AuthService {
+	return &AuthService{
+		users:      users,
+		tokens:     tokens,
+		issuer:     issuer,
+		refreshTTL: refreshTTL,
+	}
+}
+
+func (s *AuthService) Login(ctx context.Context, req dto.LoginDTO) (dto.TokenResponse, error) {
+	user, err := s.users.GetByEmail(ctx, req.Email)
+	if errors.Is(err, postgres.ErrUserNotFound) {
+		return dto.TokenResponse{}, ErrBadCredentials
+	}
+	if err != nil {
+		return dto.TokenResponse{}, err
+	}
+
+	if bcrypt.CompareHashAndPassword(
+		[]byte(user.PasswordHash),
+		[]byte(req.Password),
+	) != nil {
+		return dto.TokenResponse{}, ErrBadCredentials
+	}
+
+	return s.IssueTokens(ctx, user)
+}
+
+// IssueTokens starts a new session for u: an access token and the first
+// refresh token of a new family.
+func (s *AuthService) IssueTokens(ctx context.Context, u model.User) (dto.TokenResponse, error) {
+	family, err := auth.NewTokenID()
+	if err != nil {
+		return dto.TokenResponse{}, err
+	}
+
+	plain, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		return dto.TokenResponse{}, err
+	}
+
+	if _, err := s.tokens.CreateRefreshToken(ctx, model.RefreshToken{
+		UserID:    u.ID,
+		TokenHash: hash,
+		FamilyID:  family,
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	}); err != nil {
+		return dto.TokenResponse{}, err
+	}
+
+	return s.tokenResponse(u, plain)
+}
+
+// Refresh exchanges a refresh token for a new access/refresh pair. The old
+// refresh token stops working; presenting it again revokes the whole family,
+// since that means it was copied.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (dto.TokenResponse, error) {
+	stored, err := s.tokens.GetRefreshToken(ctx, auth.HashRefreshToken(refreshToken))
+	if errors.Is(err, postgres.ErrRefreshTokenNotFound) {
+		return dto.TokenResponse{}, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return dto.TokenResponse{}, err
+	}
+
+	if stored.RevokedAt != nil {
+		_ = s.tokens.RevokeFamily(ctx, stored.FamilyID)
+		return dto.TokenResponse{}, ErrInvalidRefreshToken
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return dto.TokenResponse{}, ErrInvalidRefreshToken
+	}
+
+	user, err := s.users.GetByID(ctx, stored.UserID)
+	if errors.Is(err, postgres.ErrUserNotFound) {
+		return dto.TokenResponse{}, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return dto.TokenResponse{}, err
+	}
+
+	plain, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		return dto.TokenResponse{}, err
+	}
+
+	if _, err := s.tokens.RotateRefreshToken(ctx, stored.ID, model.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hash,
+		FamilyID:  stored.FamilyID,
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	}); err != nil {
+		if errors.Is(err, postgres.ErrRefreshTokenReused) {
+			_ = s.tokens.RevokeFamily(ctx, stored.FamilyID)
+			return dto.TokenResponse{}, ErrInvalidRefreshToken
+		}
+		return dto.TokenResponse{}, err
+	}
+
+	return s.tokenResponse(user, plain)
+}
+
+// Logout revokes the calling access token and, if given, the session's
+// refresh token family. With all set every session of the user is ended.
+func (s *AuthService) Logout(ctx context.Context, userID int, jti string, expiresAt time.Time, refreshToken string, all bool) error {
+	if err := s.tokens.RevokeAccessToken(ctx, jti, expiresAt); err != nil {
+		return err
+	}
+
+	if refreshToken != "" {
+		stored, err := s.tokens.GetRefreshToken(ctx, auth.HashRefreshToken(refreshToken))
+		if err == nil && stored.UserID == userID {
+			if err := s.tokens.RevokeFamily(ctx, stored.FamilyID); err != nil {
+				return err
+			}
+		}
+	}
+
+	if all {
+		return s.RevokeAll(ctx, userID)
+	}
+	return nil
+}
+
+// RevokeAll invalidates every access and refresh token of the user.
+func (s *AuthService) RevokeAll(ctx context.Context, userID int) error {
+	if err := s.users.BumpTokenVersion(ctx, userID); err != nil {
+		return err
+	}
+	return s.tokens.RevokeAllForUser(ctx, userID)
+}
+
+// CheckSession reports whether an access token with the given version and
+// jti is still honoured. It is called by AuthMiddleware on every request.
+func (s *AuthService) CheckSession(ctx context.Context, userID int, version int, jti string) error {
+	current, revoked, err := s.tokens.SessionState(ctx, userID, jti)
+	if err != nil {
+		if errors.Is(err, postgres.ErrUserNotFound) {
+			return auth.ErrSessionRevoked
+		}
+		return err
+	}
+	if revoked || current != version {
+		return auth.ErrSessionRevoked
+	}
+	return nil
+}
+
+func (s *AuthService) tokenResponse(u model.User, refreshToken string) (dto.TokenResponse, error) {
+	access, err := s.issuer.Issue(u.ID, u.Role, u.TokenVersion)
+	if err != nil {
+		return dto.TokenResponse{}, err
+	}
+
+	return dto.TokenResponse{
+		AccessToken:  access.Token,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(time.Until(access.ExpiresAt).Seconds()),
+	}, nil
+}
@@ -0,0 +1,375 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/auth"
+	"github.com/AlikhanF2006/Final_project/internal/memory"
+	"github.com/AlikhanF2006/Final_project/internal/postgres/dto"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+// authFixture is an AuthService over the memory backend with one user.
+type authFixture struct {
+	users  *memory.UserRepository
+	issuer *auth.TokenService
+	svc    *AuthService
+	user   model.User
+}
+
+func newAuthFixture(t *testing.T, refreshTTL time.Duration) *authFixture {
+	t.Helper()
+
+	issuer, err := auth.NewTokenService(auth.TokenConfig{
+		Issuer:       "movies-test",
+		Audience:     "movies-test",
+		TTL:          time.Minute,
+		SigningKeyID: "k1",
+		Keys:         []auth.Key{{ID: "k1", Algorithm: auth.AlgHS256, Secret: []byte("test-secret")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := memory.NewStore()
+	users := memory.NewUserRepository(store)
+	u, err := users.Create(context.Background(), model.User{Username: "ann", Email: "ann@example.com", PasswordHash: "x", Role: auth.RoleUser})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &authFixture{
+		users:  users,
+		issuer: issuer,
+		svc:    NewAuthService(users, memory.NewTokenRepository(store), issuer, refreshTTL),
+		user:   u,
+	}
+}
+
+// login starts a new session for the fixture's user.
+func (f *authFixture) login(t *testing.T) dto.TokenResponse {
+	t.Helper()
+	tok, err := f.svc.IssueTokens(context.Background(), f.user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+// check runs CheckSession the way AuthMiddleware does for the access token.
+func (f *authFixture) check(t *testing.T, tok dto.TokenResponse) error {
+	t.Helper()
+	claims := parseClaims(t, f, tok)
+	return f.svc.CheckSession(context.Background(), claims.UserID, claims.Version, claims.ID)
+}
+
+func (f *authFixture) logout(t *testing.T, tok dto.TokenResponse, refreshToken string, all bool) {
+	t.Helper()
+	claims := parseClaims(t, f, tok)
+	if err := f.svc.Logout(context.Background(), f.user.ID, claims.ID, claims.ExpiresAt.Time, refreshToken, all); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+}
+
+func TestAuthServiceRefresh(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		present func(t *testing.T, f *authFixture, first dto.TokenResponse) string
+		wantErr error
+	}{
+		{
+			name:    "fresh token",
+			ttl:     time.Hour,
+			present: func(t *testing.T, f *authFixture, first dto.TokenResponse) string { return first.RefreshToken },
+		},
+		{
+			name: "rotated token",
+			ttl:  time.Hour,
+			present: func(t *testing.T, f *authFixture, first dto.TokenResponse) string {
+				next, err := f.svc.Refresh(context.Background(), first.RefreshToken)
+				if err != nil {
+					t.Fatalf("first refresh: %v", err)
+				}
+				return next.RefreshToken
+			},
+		},
+		{
+			name:    "unknown token",
+			ttl:     time.Hour,
+			present: func(t *testing.T, f *authFixture, first dto.TokenResponse) string { return "not-a-token" },
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name:    "expired token",
+			ttl:     -time.Minute,
+			present: func(t *testing.T, f *authFixture, first dto.TokenResponse) string { return first.RefreshToken },
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name: "deleted user",
+			ttl:  time.Hour,
+			present: func(t *testing.T, f *authFixture, first dto.TokenResponse) string {
+				if err := f.users.Delete(context.Background(), f.user.ID); err != nil {
+					t.Fatal(err)
+				}
+				return first.RefreshToken
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newAuthFixture(t, tt.ttl)
+			first := f.login(t)
+
+			got, err := f.svc.Refresh(context.Background(), tt.present(t, f, first))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Refresh: got error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.RefreshToken == "" || got.RefreshToken == first.RefreshToken {
+				t.Errorf("Refresh did not rotate the refresh token")
+			}
+			if err := f.check(t, got); err != nil {
+				t.Errorf("CheckSession of refreshed access token: %v", err)
+			}
+		})
+	}
+}
+
+func TestAuthServiceRefreshReplayRevokesFamily(t *testing.T) {
+	f := newAuthFixture(t, time.Hour)
+	ctx := context.Background()
+
+	stolen := f.login(t)
+	other := f.login(t)
+
+	next, err := f.svc.Refresh(ctx, stolen.RefreshToken)
+	if err != nil {
+		t.Fatalf("first refresh: %v", err)
+	}
+
+	if _, err := f.svc.Refresh(ctx, stolen.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("replayed refresh: got error %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if _, err := f.svc.Refresh(ctx, next.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh with the rotated token after a replay: got error %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if _, err := f.svc.Refresh(ctx, other.RefreshToken); err != nil {
+		t.Errorf("refresh of another session after a replay: %v", err)
+	}
+}
+
+func TestAuthServiceLogout(t *testing.T) {
+	tests := []struct {
+		name        string
+		withRefresh bool
+		all         bool
+		// wantRefresh and wantOther say whether the session's refresh token
+		// and a second session survive the logout.
+		wantRefresh bool
+		wantOther   bool
+	}{
+		{name: "access token only", wantRefresh: true, wantOther: true},
+		{name: "with refresh token", withRefresh: true, wantOther: true},
+		{name: "all sessions", all: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newAuthFixture(t, time.Hour)
+			ctx := context.Background()
+			session := f.login(t)
+			other := f.login(t)
+
+			refresh := ""
+			if tt.withRefresh {
+				refresh = session.RefreshToken
+			}
+			f.logout(t, session, refresh, tt.all)
+
+			if err := f.check(t, session); !errors.Is(err, auth.ErrSessionRevoked) {
+				t.Errorf("CheckSession of logged out token: got error %v, want %v", err, auth.ErrSessionRevoked)
+			}
+
+			_, err := f.svc.Refresh(ctx, session.RefreshToken)
+			if tt.wantRefresh != (err == nil) {
+				t.Errorf("refresh of the logged out session: got error %v, want it to work: %v", err, tt.wantRefresh)
+			}
+
+			err = f.check(t, other)
+			if tt.wantOther != (err == nil) {
+				t.Errorf("CheckSession of another session: got error %v, want it to work: %v", err, tt.wantOther)
+			}
+			_, err = f.svc.Refresh(ctx, other.RefreshToken)
+			if tt.wantOther != (err == nil) {
+				t.Errorf("refresh of another session: got error %v, want it to work: %v", err, tt.wantOther)
+			}
+		})
+	}
+}
+
+func TestAuthServiceLogoutIgnoresForeignRefreshToken(t *testing.T) {
+	f := newAuthFixture(t, time.Hour)
+	ctx := context.Background()
+
+	mallory, err := f.users.Create(ctx, model.User{Username: "mallory", Email: "mallory@example.com", PasswordHash: "x", Role: auth.RoleUser})
+	if err != nil {
+		t.Fatal(err)
+	}
+	victim := f.login(t)
+	attacker, err := f.svc.IssueTokens(ctx, mallory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := parseClaims(t, f, attacker)
+	if err := f.svc.Logout(ctx, mallory.ID, claims.ID, claims.ExpiresAt.Time, victim.RefreshToken, false); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if _, err := f.svc.Refresh(ctx, victim.RefreshToken); err != nil {
+		t.Errorf("another user's logout revoked the refresh token: %v", err)
+	}
+}
+
+func TestAuthServiceRevokeAll(t *testing.T) {
+	f := newAuthFixture(t, time.Hour)
+	ctx := context.Background()
+	sessions := []dto.TokenResponse{f.login(t), f.login(t)}
+
+	if err := f.svc.RevokeAll(ctx, f.user.ID); err != nil {
+		t.Fatalf("RevokeAll: %v", err)
+	}
+
+	for i, s := range sessions {
+		if err := f.check(t, s); !errors.Is(err, auth.ErrSessionRevoked) {
+			t.Errorf("session %d: CheckSession got error %v, want %v", i, err, auth.ErrSessionRevoked)
+		}
+		if _, err := f.svc.Refresh(ctx, s.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("session %d: Refresh got error %v, want %v", i, err, ErrInvalidRefreshToken)
+		}
+	}
+
+	// Tokens issued afterwards carry the bumped version.
+	u, err := f.users.GetByID(ctx, f.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.user = u
+	if err := f.check(t, f.login(t)); err != nil {
+		t.Errorf("CheckSession of a session started after RevokeAll: %v", err)
+	}
+}
+
+func TestAuthServiceCheckSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T, f *authFixture, tok dto.TokenResponse) (context.Context, *auth.Claims)
+		wantErr error
+	}{
+		{
+			name: "valid",
+			prepare: func(t *testing.T, f *authFixture, tok dto.TokenResponse) (context.Context, *auth.Claims) {
+				return context.Background(), parseClaims(t, f, tok)
+			},
+		},
+		{
+			name: "stale token version",
+			prepare: func(t *testing.T, f *authFixture, tok dto.TokenResponse) (context.Context, *auth.Claims) {
+				if err := f.users.BumpTokenVersion(context.Background(), f.user.ID); err != nil {
+					t.Fatal(err)
+				}
+				return context.Background(), parseClaims(t, f, tok)
+			},
+			wantErr: auth.ErrSessionRevoked,
+		},
+		{
+			name: "denylisted jti",
+			prepare: func(t *testing.T, f *authFixture, tok dto.TokenResponse) (context.Context, *auth.Claims) {
+				f.logout(t, tok, "", false)
+				return context.Background(), parseClaims(t, f, tok)
+			},
+			wantErr: auth.ErrSessionRevoked,
+		},
+		{
+			name: "deleted user",
+			prepare: func(t *testing.T, f *authFixture, tok dto.TokenResponse) (context.Context, *auth.Claims) {
+				if err := f.users.Delete(context.Background(), f.user.ID); err != nil {
+					t.Fatal(err)
+				}
+				return context.Background(), parseClaims(t, f, tok)
+			},
+			wantErr: auth.ErrSessionRevoked,
+		},
+		{
+			name: "storage error is not a revocation",
+			prepare: func(t *testing.T, f *authFixture, tok dto.TokenResponse) (context.Context, *auth.Claims) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, parseClaims(t, f, tok)
+			},
+			wantErr: context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newAuthFixture(t, time.Hour)
+			ctx, claims := tt.prepare(t, f, f.login(t))
+
+			err := f.svc.CheckSession(ctx, claims.UserID, claims.Version, claims.ID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckSession: got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChangePasswordBumpsTokenVersion(t *testing.T) {
+	f := newAuthFixture(t, time.Hour)
+	ctx := context.Background()
+	users := NewUserService(f.users, nil, f.svc, nil)
+	old := f.login(t)
+
+	fresh, err := users.ChangePassword(ctx, f.user.ID, "new-password")
+	if err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+
+	u, err := f.users.GetByID(ctx, f.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.TokenVersion <= f.user.TokenVersion {
+		t.Errorf("token version: got %d, want more than %d", u.TokenVersion, f.user.TokenVersion)
+	}
+
+	if err := f.check(t, old); !errors.Is(err, auth.ErrSessionRevoked) {
+		t.Errorf("CheckSession of a token issued before the change: got error %v, want %v", err, auth.ErrSessionRevoked)
+	}
+	if _, err := f.svc.Refresh(ctx, old.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh of a session started before the change: got error %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if err := f.check(t, fresh); err != nil {
+		t.Errorf("CheckSession of the token returned by ChangePassword: %v", err)
+	}
+	if _, err := f.svc.Refresh(ctx, fresh.RefreshToken); err != nil {
+		t.Errorf("refresh of the session returned by ChangePassword: %v", err)
+	}
+}
+
+func parseClaims(t *testing.T, f *authFixture, tok dto.TokenResponse) *auth.Claims {
+	t.Helper()
+	claims, err := f.issuer.Parse(tok.AccessToken)
+	if err != nil {
+		t.Fatalf("parse access token: %v", err)
+	}
+	return claims
+}
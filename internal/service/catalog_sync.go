@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/internal/tmdb"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+var (
+	ErrSyncRunning    = errors.New("a tmdb sync is already running")
+	ErrSyncNotStarted = errors.New("tmdb sync is not started")
+)
+
+const (
+	defaultSyncInterval = 6 * time.Hour
+
+	// maxSyncRunErrors bounds the errors stored with a run; the rest are
+	// only counted.
+	maxSyncRunErrors = 20
+
+	// syncFinishTimeout is how long recording a run's outcome may take once
+	// the run itself was cancelled by shutdown.
+	syncFinishTimeout = 5 * time.Second
+)
+
+var defaultSyncLists = []tmdb.MovieList{
+	tmdb.ListPopular,
+	tmdb.ListNowPlaying,
+	tmdb.ListTopRated,
+	tmdb.ListUpcoming,
+}
+
+type CatalogSyncConfig struct {
+	// Scheduled runs a sync at Start and every Interval after it. Manual
+	// runs are available either way.
+	Scheduled bool
+	Interval  time.Duration
+	Lists     []tmdb.MovieList
+	// MaxPages limits the pages read from each list; 0 reads all of them
+	// (TMDB serves at most tmdb.MaxListPages).
+	MaxPages int
+}
+
+// CatalogSync copies TMDB's movie lists into the local catalog. Every movie
+// is upserted on its TMDB id, so new movies are added and known ones pick up
+// TMDB's current title, year and description, except for fields an admin
+// has overridden. Each run is recorded with its counts and errors. Only one
+// run goes at a time per process; across processes the upsert keeps
+// concurrent runs harmless.
+type CatalogSync struct {
+	client *tmdb.Client
+	movies postgres.MovieRepo
+	runs   postgres.SyncRunRepo
+
+	scheduled bool
+	interval  time.Duration
+	lists     []tmdb.MovieList
+	maxPages  int
+
+	running sync.Mutex
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewCatalogSync(
+	client *tmdb.Client,
+	movies postgres.MovieRepo,
+	runs postgres.SyncRunRepo,
+	cfg CatalogSyncConfig,
+) *CatalogSync {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultSyncInterval
+	}
+	if len(cfg.Lists) == 0 {
+		cfg.Lists = defaultSyncLists
+	}
+	if cfg.MaxPages <= 0 || cfg.MaxPages > tmdb.MaxListPages {
+		cfg.MaxPages = tmdb.MaxListPages
+	}
+
+	return &CatalogSync{
+		client:    client,
+		movies:    movies,
+		runs:      runs,
+		scheduled: cfg.Scheduled,
+		interval:  cfg.Interval,
+		lists:     cfg.Lists,
+		maxPages:  cfg.MaxPages,
+	}
+}
+
+// Start begins the schedule, if any, and makes Trigger available. Runs stop
+// when Stop is called or ctx is cancelled.
+func (s *CatalogSync) Start(ctx context.Context) {
+	s.ctx, s.cancel = context.WithCancel(ctx)
+
+	if !s.scheduled {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.loop(s.ctx)
+	}()
+}
+
+// Stop cancels the current run, which is recorded as failed, and waits for
+// it to finish or ctx to expire.
+func (s *CatalogSync) Stop(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Trigger starts a manual run in the background. It returns ErrSyncRunning
+// if a run is in progress.
+func (s *CatalogSync) Trigger() error {
+	if s.ctx == nil || s.ctx.Err() != nil {
+		return ErrSyncNotStarted
+	}
+	if !s.running.TryLock() {
+		return ErrSyncRunning
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.running.Unlock()
+		s.run(s.ctx, model.SyncTriggerManual)
+	}()
+	return nil
+}
+
+// Runs lists past runs, newest first.
+func (s *CatalogSync) Runs(ctx context.Context, opts model.ListOptions) ([]model.SyncRun, int, error) {
+	return s.runs.List(ctx, opts)
+}
+
+func (s *CatalogSync) loop(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if s.running.TryLock() {
+			s.run(ctx, model.SyncTriggerSchedule)
+			s.running.Unlock()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// run performs one sync; the caller holds s.running.
+func (s *CatalogSync) run(ctx context.Context, trigger string) {
+	lists := make([]string, len(s.lists))
+	for i, l := range s.lists {
+		lists[i] = string(l)
+	}
+
+	run, err := s.runs.Create(ctx, model.SyncRun{
+		Trigger: trigger,
+		Status:  model.SyncRunning,
+		Lists:   lists,
+	})
+	if err != nil {
+		log.Printf("tmdb sync: record start: %v", err)
+		return
+	}
+
+	st := &syncState{run: &run, seen: make(map[int]bool)}
+	for _, list := range s.lists {
+		if ctx.Err() != nil {
+			break
+		}
+		s.syncList(ctx, list, st)
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		st.addError(fmt.Errorf("run cancelled: %w", ctx.Err()))
+		run.Status = model.SyncFailed
+	case st.errCount > 0 && run.Fetched == 0:
+		run.Status = model.SyncFailed
+	case st.errCount > 0:
+		run.Status = model.SyncPartial
+	default:
+		run.Status = model.SyncSucceeded
+	}
+	if dropped := st.errCount - len(run.Errors); dropped > 0 {
+		run.Errors = append(run.Errors, fmt.Sprintf("and %d more errors", dropped))
+	}
+
+	finishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), syncFinishTimeout)
+	defer cancel()
+	if err := s.runs.Finish(finishCtx, run); err != nil {
+		log.Printf("tmdb sync: record run %d: %v", run.ID, err)
+	}
+
+	log.Printf(
+		"tmdb sync: run %d %s: %d pages, %d movies, %d created, %d updated, %d unchanged, %d failed",
+		run.ID, run.Status, run.Pages, run.Fetched, run.Created, run.Updated, run.Unchanged, run.Failed,
+	)
+}
+
+type syncState struct {
+	run *model.SyncRun
+	// seen skips movies already upserted by this run: the lists overlap.
+	seen     map[int]bool
+	errCount int
+}
+
+func (st *syncState) addError(err error) {
+	st.errCount++
+	if len(st.run.Errors) < maxSyncRunErrors {
+		st.run.Errors = append(st.run.Errors, err.Error())
+	}
+}
+
+// syncList reads list page by page until its last page or s.maxPages. A
+// page that cannot be fetched ends the list, since later pages would shift
+// under a retry anyway.
+func (s *CatalogSync) syncList(ctx context.Context, list tmdb.MovieList, st *syncState) {
+	last := s.maxPages
+	for page := 1; page <= last; page++ {
+		p, err := s.client.GetMovieList(ctx, list, page)
+		if err != nil {
+			if ctx.Err() == nil {
+				st.addError(fmt.Errorf("%s page %d: %w", list, page, err))
+			}
+			return
+		}
+		st.run.Pages++
+		last = min(last, p.TotalPages)
+
+		for _, m := range p.Results {
+			st.run.Fetched++
+			if st.seen[m.ID] {
+				continue
+			}
+			st.seen[m.ID] = true
+
+			if m.ID == 0 || m.Title == "" {
+				st.run.Failed++
+				st.addError(fmt.Errorf("%s page %d: movie %d has no id or title", list, page, m.ID))
+				continue
+			}
+
+			_, result, err := s.movies.UpsertTMDB(ctx, model.Movie{
+				TMDBID:      m.ID,
+				Title:       m.Title,
+				Year:        m.Year(),
+				Description: m.Overview,
+			})
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				st.run.Failed++
+				st.addError(fmt.Errorf("upsert tmdb movie %d: %w", m.ID, err))
+				continue
+			}
+
+			switch result {
+			case model.UpsertCreated:
+				st.run.Created++
+			case model.UpsertUpdated:
+				st.run.Updated++
+			default:
+				st.run.Unchanged++
+			}
+		}
+	}
+}
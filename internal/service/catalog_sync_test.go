@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/memory"
+	"github.com/AlikhanF2006/Final_project/internal/tmdb"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+// fakeLists serves TMDB's list endpoints from pages[list][page-1]. Lists it
+// does not know answer 500. hold, if set, delays every response until it is
+// closed or the request is cancelled.
+type fakeLists struct {
+	pages map[string][][]tmdb.MovieSummary
+	hold  chan struct{}
+}
+
+func (f *fakeLists) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.hold != nil {
+		select {
+		case <-f.hold:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	list := strings.TrimPrefix(r.URL.Path, "/3/movie/")
+	pages, ok := f.pages[list]
+	if !ok {
+		http.Error(w, `{"status_message":"boom"}`, http.StatusInternalServerError)
+		return
+	}
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 || page > len(pages) {
+		http.Error(w, `{"status_message":"bad page"}`, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(tmdb.MoviePage{
+		Page:       page,
+		TotalPages: len(pages),
+		Results:    pages[page-1],
+	})
+}
+
+type syncFixture struct {
+	movies *memory.MovieRepository
+	runs   *memory.SyncRunRepository
+	sync   *CatalogSync
+}
+
+func newSyncFixture(t *testing.T, lists *fakeLists, cfg CatalogSyncConfig) *syncFixture {
+	t.Helper()
+	srv := httptest.NewServer(lists)
+	t.Cleanup(srv.Close)
+
+	client := tmdb.NewClient(tmdb.Config{
+		Token:     "test-token",
+		BaseURL:   srv.URL,
+		RateLimit: 1000,
+		RateBurst: 1000,
+	})
+
+	store := memory.NewStore()
+	f := &syncFixture{
+		movies: memory.NewMovieRepository(store),
+		runs:   memory.NewSyncRunRepository(store),
+	}
+	f.sync = NewCatalogSync(client, f.movies, f.runs, cfg)
+	return f
+}
+
+// lastRun returns the newest recorded run.
+func (f *syncFixture) lastRun(t *testing.T) model.SyncRun {
+	t.Helper()
+	runs, _, err := f.sync.Runs(context.Background(), model.ListOptions{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) == 0 {
+		t.Fatal("no sync run recorded")
+	}
+	return runs[0]
+}
+
+func summary(id int, title, date, overview string) tmdb.MovieSummary {
+	return tmdb.MovieSummary{ID: id, Title: title, ReleaseDate: date, Overview: overview}
+}
+
+func TestCatalogSyncRun(t *testing.T) {
+	lists := &fakeLists{pages: map[string][][]tmdb.MovieSummary{
+		"popular": {
+			{
+				summary(1, "New Movie", "2024-05-01", "Brand new."),
+				summary(2, "Renamed", "1999-01-01", "Same."),
+				summary(3, "Current", "2001-01-01", "Same."),
+			},
+			{
+				summary(4, "TMDB Title", "2010-01-01", "TMDB's new description."),
+				summary(5, "TMDB Title Only", "2011-01-01", "Kept."),
+				summary(0, "", "", ""),
+			},
+		},
+		"top_rated": {
+			{
+				// Already upserted from popular; counted as fetched only.
+				summary(1, "New Movie", "2024-05-01", "Brand new."),
+				summary(6, "Classic", "1950-01-01", ""),
+			},
+		},
+	}}
+	f := newSyncFixture(t, lists, CatalogSyncConfig{
+		Lists: []tmdb.MovieList{tmdb.ListPopular, tmdb.ListTopRated, tmdb.ListUpcoming},
+	})
+	ctx := context.Background()
+
+	seed := func(m model.Movie) model.Movie {
+		t.Helper()
+		stored, _, err := f.movies.UpsertTMDB(ctx, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(m.Overrides) > 0 {
+			stored.Title, stored.Overrides = m.Title, m.Overrides
+			if stored, err = f.movies.Update(ctx, stored); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return stored
+	}
+	seed(model.Movie{TMDBID: 2, Title: "Old Name", Year: 1999, Description: "Same."})
+	seed(model.Movie{TMDBID: 3, Title: "Current", Year: 2001, Description: "Same."})
+	// An admin renamed 4 and 5; the sync must keep their titles.
+	seed(model.Movie{TMDBID: 4, Title: "Admin Title", Year: 2010, Description: "Old description.", Overrides: []string{model.FieldTitle}})
+	seed(model.Movie{TMDBID: 5, Title: "Admin Title Only", Year: 2011, Description: "Kept.", Overrides: []string{model.FieldTitle}})
+
+	f.sync.run(ctx, model.SyncTriggerManual)
+
+	run := f.lastRun(t)
+	if run.Status != model.SyncPartial || run.Trigger != model.SyncTriggerManual || run.FinishedAt == nil {
+		t.Errorf("run: got status %s, trigger %s, finished %v", run.Status, run.Trigger, run.FinishedAt)
+	}
+	if want := []string{"popular", "top_rated", "upcoming"}; strings.Join(run.Lists, ",") != strings.Join(want, ",") {
+		t.Errorf("lists: got %v, want %v", run.Lists, want)
+	}
+	// popular: 2 pages, 6 movies; top_rated: 1 page, 2 movies; upcoming fails.
+	if run.Pages != 3 || run.Fetched != 8 || run.Created != 2 || run.Updated != 2 || run.Unchanged != 2 || run.Failed != 1 {
+		t.Errorf("counts: got pages %d, fetched %d, created %d, updated %d, unchanged %d, failed %d; want 3, 8, 2, 2, 2, 1",
+			run.Pages, run.Fetched, run.Created, run.Updated, run.Unchanged, run.Failed)
+	}
+	if len(run.Errors) != 2 ||
+		!strings.Contains(run.Errors[0], "has no id or title") ||
+		!strings.Contains(run.Errors[1], "upcoming page 1") {
+		t.Errorf("errors: got %q", run.Errors)
+	}
+
+	tests := []struct {
+		tmdbID      int
+		title       string
+		description string
+		overrides   []string
+	}{
+		{tmdbID: 1, title: "New Movie", description: "Brand new."},
+		{tmdbID: 2, title: "Renamed", description: "Same."},
+		{tmdbID: 4, title: "Admin Title", description: "TMDB's new description.", overrides: []string{model.FieldTitle}},
+		{tmdbID: 5, title: "Admin Title Only", description: "Kept.", overrides: []string{model.FieldTitle}},
+		{tmdbID: 6, title: "Classic"},
+	}
+	for _, tt := range tests {
+		m, err := f.movies.GetByTMDBID(ctx, tt.tmdbID)
+		if err != nil {
+			t.Errorf("tmdb movie %d: %v", tt.tmdbID, err)
+			continue
+		}
+		if m.Title != tt.title || m.Description != tt.description || strings.Join(m.Overrides, ",") != strings.Join(tt.overrides, ",") {
+			t.Errorf("tmdb movie %d: got title %q, description %q, overrides %v; want %q, %q, %v",
+				tt.tmdbID, m.Title, m.Description, m.Overrides, tt.title, tt.description, tt.overrides)
+		}
+	}
+
+	// A second run finds nothing to change.
+	f.sync.run(ctx, model.SyncTriggerSchedule)
+	again := f.lastRun(t)
+	if again.ID == run.ID || again.Created != 0 || again.Updated != 0 || again.Unchanged != 6 {
+		t.Errorf("second run: got %+v", again)
+	}
+}
+
+func TestCatalogSyncStatus(t *testing.T) {
+	one := [][]tmdb.MovieSummary{{summary(1, "One", "2020-01-01", "")}}
+	three := [][]tmdb.MovieSummary{
+		{summary(1, "One", "2020-01-01", "")},
+		{summary(2, "Two", "2020-01-01", "")},
+		{summary(3, "Three", "2020-01-01", "")},
+	}
+
+	tests := []struct {
+		name     string
+		pages    map[string][][]tmdb.MovieSummary
+		maxPages int
+		status   string
+		wantRead int
+	}{
+		{name: "every list read", pages: map[string][][]tmdb.MovieSummary{"popular": three, "upcoming": one}, status: model.SyncSucceeded, wantRead: 4},
+		{name: "one list fails", pages: map[string][][]tmdb.MovieSummary{"popular": three}, status: model.SyncPartial, wantRead: 3},
+		{name: "every list fails", pages: map[string][][]tmdb.MovieSummary{}, status: model.SyncFailed},
+		{name: "max pages", pages: map[string][][]tmdb.MovieSummary{"popular": three, "upcoming": one}, maxPages: 2, status: model.SyncSucceeded, wantRead: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newSyncFixture(t, &fakeLists{pages: tt.pages}, CatalogSyncConfig{
+				Lists:    []tmdb.MovieList{tmdb.ListPopular, tmdb.ListUpcoming},
+				MaxPages: tt.maxPages,
+			})
+			f.sync.run(context.Background(), model.SyncTriggerManual)
+
+			run := f.lastRun(t)
+			if run.Status != tt.status || run.Pages != tt.wantRead {
+				t.Errorf("got status %s after %d pages, want %s after %d (errors %q)", run.Status, run.Pages, tt.status, tt.wantRead, run.Errors)
+			}
+		})
+	}
+}
+
+func TestCatalogSyncTriggerAndStop(t *testing.T) {
+	lists := &fakeLists{
+		pages: map[string][][]tmdb.MovieSummary{"popular": {{summary(1, "One", "2020-01-01", "")}}},
+		hold:  make(chan struct{}),
+	}
+	f := newSyncFixture(t, lists, CatalogSyncConfig{Lists: []tmdb.MovieList{tmdb.ListPopular}})
+
+	if err := f.sync.Trigger(); err != ErrSyncNotStarted {
+		t.Errorf("Trigger before Start: got %v, want %v", err, ErrSyncNotStarted)
+	}
+
+	f.sync.Start(context.Background())
+	if err := f.sync.Trigger(); err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+	if err := f.sync.Trigger(); err != ErrSyncRunning {
+		t.Errorf("Trigger during a run: got %v, want %v", err, ErrSyncRunning)
+	}
+
+	// Stop once the run is recorded and waiting on TMDB.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		runs, _, err := f.sync.Runs(context.Background(), model.ListOptions{Limit: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(runs) > 0 && runs[0].Status == model.SyncRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("triggered run was never recorded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := f.sync.Stop(stopCtx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	run := f.lastRun(t)
+	if run.Status != model.SyncFailed || run.FinishedAt == nil {
+		t.Errorf("stopped run: got status %s, finished %v", run.Status, run.FinishedAt)
+	}
+	if len(run.Errors) != 1 || !strings.Contains(run.Errors[0], "run cancelled") {
+		t.Errorf("stopped run errors: got %q", run.Errors)
+	}
+	if err := f.sync.Trigger(); err != ErrSyncNotStarted {
+		t.Errorf("Trigger after Stop: got %v, want %v", err, ErrSyncNotStarted)
+	}
+}
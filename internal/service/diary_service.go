@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+var ErrBadDiaryData = errors.New("invalid diary data")
+
+const (
+	maxDiaryNote = 1000
+
+	// maxCalendarYears bounds a calendar request, which lists every month
+	// in its range.
+	maxCalendarYears = 10
+)
+
+type DiaryService struct {
+	diary   postgres.DiaryRepo
+	genres  postgres.GenreRepo
+	ratings *RatingWorker
+}
+
+// NewDiaryService takes the rating worker only to wake it, as
+// NewReviewService does.
+func NewDiaryService(diary postgres.DiaryRepo, genres postgres.GenreRepo, ratings *RatingWorker) *DiaryService {
+	return &DiaryService{diary: diary, genres: genres, ratings: ratings}
+}
+
+// DiaryUpdate changes the fields that are set; a Score of 0 clears the
+// score.
+type DiaryUpdate struct {
+	WatchedOn *string
+	Rewatch   *bool
+	Score     *int
+	Note      *string
+}
+
+// ListDiary returns the user's diary entries in f.
+func (s *DiaryService) ListDiary(ctx context.Context, userID int, f model.DiaryFilter, opts model.ListOptions) ([]model.DiaryEntry, int, error) {
+	for _, d := range []*string{&f.From, &f.To} {
+		if *d == "" {
+			continue
+		}
+		t, err := time.Parse(time.DateOnly, *d)
+		if err != nil {
+			return nil, 0, ErrBadDiaryData
+		}
+		*d = t.Format(time.DateOnly)
+	}
+
+	entries, total, err := s.diary.List(ctx, userID, f, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, s.finish(ctx, entries)
+}
+
+// LogWatch adds a watch of e.Movie to the user's diary. An empty WatchedOn
+// means today. Without rewatch, the entry counts as a rewatch when the user
+// has logged the movie on or before that date.
+func (s *DiaryService) LogWatch(ctx context.Context, userID int, e model.DiaryEntry, rewatch *bool) (model.DiaryEntry, error) {
+	if e.Movie.ID <= 0 {
+		return model.DiaryEntry{}, ErrBadDiaryData
+	}
+	if e.WatchedOn == "" {
+		e.WatchedOn = today()
+	}
+	if err := diaryFields(&e); err != nil {
+		return model.DiaryEntry{}, err
+	}
+
+	if rewatch != nil {
+		e.Rewatch = *rewatch
+	} else {
+		_, seen, err := s.diary.List(ctx, userID, model.DiaryFilter{MovieID: e.Movie.ID, To: e.WatchedOn}, model.ListOptions{Limit: 1})
+		if err != nil {
+			return model.DiaryEntry{}, err
+		}
+		e.Rewatch = seen > 0
+	}
+
+	added, err := s.diary.Add(ctx, userID, e)
+	if err != nil {
+		return model.DiaryEntry{}, err
+	}
+
+	s.ratings.Notify()
+	return s.finishOne(ctx, added)
+}
+
+// UpdateDiaryEntry applies upd to the user's entry id.
+func (s *DiaryService) UpdateDiaryEntry(ctx context.Context, userID, id int, upd DiaryUpdate) (model.DiaryEntry, error) {
+	e, err := s.diary.Get(ctx, userID, id)
+	if err != nil {
+		return model.DiaryEntry{}, err
+	}
+
+	if upd.WatchedOn != nil {
+		e.WatchedOn = *upd.WatchedOn
+	}
+	if upd.Rewatch != nil {
+		e.Rewatch = *upd.Rewatch
+	}
+	if upd.Score != nil {
+		e.Score = upd.Score
+		if *upd.Score == 0 {
+			e.Score = nil
+		}
+	}
+	if upd.Note != nil {
+		e.Note = *upd.Note
+	}
+	if err := diaryFields(&e); err != nil {
+		return model.DiaryEntry{}, err
+	}
+
+	if e, err = s.diary.Update(ctx, userID, e); err != nil {
+		return model.DiaryEntry{}, err
+	}
+
+	s.ratings.Notify()
+	return s.finishOne(ctx, e)
+}
+
+func (s *DiaryService) DeleteDiaryEntry(ctx context.Context, userID, id int) error {
+	if err := s.diary.Delete(ctx, userID, id); err != nil {
+		return err
+	}
+
+	s.ratings.Notify()
+	return nil
+}
+
+// Calendar counts the user's watches per month and day from from to to,
+// both YYYY-MM-DD. Without from the calendar starts on January 1 of the
+// current year, and without to it ends on December 31 of from's year.
+func (s *DiaryService) Calendar(ctx context.Context, userID int, from, to string) (model.DiaryCalendar, error) {
+	if from == "" {
+		from = time.Now().UTC().Format("2006") + "-01-01"
+	}
+	start, err := time.Parse(time.DateOnly, from)
+	if err != nil {
+		return model.DiaryCalendar{}, ErrBadDiaryData
+	}
+	if to == "" {
+		to = start.Format("2006") + "-12-31"
+	}
+	end, err := time.Parse(time.DateOnly, to)
+	if err != nil || end.Before(start) || end.After(start.AddDate(maxCalendarYears, 0, 0)) {
+		return model.DiaryCalendar{}, ErrBadDiaryData
+	}
+
+	cal := model.DiaryCalendar{
+		From:   start.Format(time.DateOnly),
+		To:     end.Format(time.DateOnly),
+		Months: make([]model.DiaryMonth, 0),
+	}
+	days, err := s.diary.Days(ctx, userID, cal.From, cal.To)
+	if err != nil {
+		return model.DiaryCalendar{}, err
+	}
+
+	index := make(map[string]int)
+	for m := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC); !m.After(end); m = m.AddDate(0, 1, 0) {
+		month := m.Format("2006-01")
+		index[month] = len(cal.Months)
+		cal.Months = append(cal.Months, model.DiaryMonth{Month: month, Days: make([]model.DiaryDay, 0)})
+	}
+	for _, d := range days {
+		m := &cal.Months[index[d.Date[:len("2006-01")]]]
+		m.Days = append(m.Days, d)
+		m.Watches += d.Watches
+		cal.Watches += d.Watches
+	}
+	return cal, nil
+}
+
+// finish fills in the movies' genres.
+func (s *DiaryService) finish(ctx context.Context, entries []model.DiaryEntry) error {
+	movies := make([]model.Movie, len(entries))
+	for i, e := range entries {
+		movies[i] = e.Movie
+	}
+	if err := attachGenres(ctx, s.genres, movies); err != nil {
+		return err
+	}
+	for i := range entries {
+		entries[i].Movie = movies[i]
+	}
+	return nil
+}
+
+func (s *DiaryService) finishOne(ctx context.Context, e model.DiaryEntry) (model.DiaryEntry, error) {
+	entries := []model.DiaryEntry{e}
+	if err := s.finish(ctx, entries); err != nil {
+		return model.DiaryEntry{}, err
+	}
+	return entries[0], nil
+}
+
+// diaryFields checks an entry's date, score and note and puts the date in
+// YYYY-MM-DD form. Watches cannot be logged ahead of time, though the date
+// may be a day past today's in UTC for users east of it.
+func diaryFields(e *model.DiaryEntry) error {
+	if len(e.Note) > maxDiaryNote {
+		return ErrBadDiaryData
+	}
+	if e.Score != nil && (*e.Score < 1 || *e.Score > 5) {
+		return ErrBadDiaryData
+	}
+	d, err := time.Parse(time.DateOnly, e.WatchedOn)
+	if err != nil || d.After(time.Now().UTC().AddDate(0, 0, 1)) {
+		return ErrBadDiaryData
+	}
+	e.WatchedOn = d.Format(time.DateOnly)
+	return nil
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+// exportGenreBatch is how many exported movies get their genres loaded in
+// one query.
+const exportGenreBatch = 500
+
+// ExportService streams the catalog out for analysis. Rows reach fn one at a
+// time, so an export never holds the whole result.
+type ExportService struct {
+	exports postgres.ExportRepo
+	genres  postgres.GenreRepo
+}
+
+func NewExportService(exports postgres.ExportRepo, genres postgres.GenreRepo) *ExportService {
+	return &ExportService{exports: exports, genres: genres}
+}
+
+// Movies exports movies with their genres, which are loaded a batch of
+// movies at a time.
+func (s *ExportService) Movies(ctx context.Context, f model.ExportFilter, fn func(model.Movie) error) error {
+	batch := make([]model.Movie, 0, exportGenreBatch)
+	flush := func() error {
+		if err := attachGenres(ctx, s.genres, batch); err != nil {
+			return err
+		}
+		for _, m := range batch {
+			if err := fn(m); err != nil {
+				return err
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	err := s.exports.ExportMovies(ctx, f, func(m model.Movie) error {
+		batch = append(batch, m)
+		if len(batch) < exportGenreBatch {
+			return nil
+		}
+		return flush()
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+func (s *ExportService) Reviews(ctx context.Context, f model.ExportFilter, fn func(model.ExportedReview) error) error {
+	return s.exports.ExportReviews(ctx, f, fn)
+}
+
+// Ratings exports every movie's stored rating with the count, average and
+// range of its review scores.
+func (s *ExportService) Ratings(ctx context.Context, f model.ExportFilter, fn func(model.MovieRating) error) error {
+	return s.exports.ExportRatings(ctx, f, fn)
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+var ErrFollowSelf = errors.New("cannot follow yourself")
+
+// FeedService manages who follows whom and reads their feeds. Activities
+// are published by the review, diary and list repositories, in the same
+// transaction as the write they report.
+type FeedService struct {
+	feed   postgres.FeedRepo
+	users  postgres.UserRepo
+	genres postgres.GenreRepo
+}
+
+func NewFeedService(feed postgres.FeedRepo, users postgres.UserRepo, genres postgres.GenreRepo) *FeedService {
+	return &FeedService{feed: feed, users: users, genres: genres}
+}
+
+// Follow makes the user follow targetID; their recent activities show up
+// in the user's feed right away.
+func (s *FeedService) Follow(ctx context.Context, userID, targetID int) error {
+	if userID == targetID {
+		return ErrFollowSelf
+	}
+	return s.feed.Follow(ctx, userID, targetID)
+}
+
+func (s *FeedService) Unfollow(ctx context.Context, userID, targetID int) error {
+	return s.feed.Unfollow(ctx, userID, targetID)
+}
+
+func (s *FeedService) Followers(ctx context.Context, userID int, opts model.ListOptions) ([]model.Follow, int, error) {
+	if _, err := s.users.GetByID(ctx, userID); err != nil {
+		return nil, 0, err
+	}
+	return s.feed.Followers(ctx, userID, opts)
+}
+
+func (s *FeedService) Following(ctx context.Context, userID int, opts model.ListOptions) ([]model.Follow, int, error) {
+	if _, err := s.users.GetByID(ctx, userID); err != nil {
+		return nil, 0, err
+	}
+	return s.feed.Following(ctx, userID, opts)
+}
+
+// Feed returns up to limit activities of the users the user follows,
+// newest first, starting after activity before (0 for the newest).
+func (s *FeedService) Feed(ctx context.Context, userID, before, limit int) ([]model.Activity, error) {
+	limit = min(max(limit, 1), model.MaxPageLimit)
+	activities, err := s.feed.Feed(ctx, userID, before, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var movies []model.Movie
+	for _, a := range activities {
+		if a.Movie != nil {
+			movies = append(movies, *a.Movie)
+		}
+	}
+	if err := attachGenres(ctx, s.genres, movies); err != nil {
+		return nil, err
+	}
+	i := 0
+	for _, a := range activities {
+		if a.Movie != nil {
+			*a.Movie = movies[i]
+			i++
+		}
+	}
+	return activities, nil
+}
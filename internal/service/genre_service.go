@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/internal/tmdb"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+var ErrBadGenreData = errors.New("invalid genre data")
+
+// maxGenreName bounds a genre's name; TMDB's longest is "Science Fiction".
+const maxGenreName = 50
+
+type GenreService struct {
+	genres postgres.GenreRepo
+}
+
+func NewGenreService(genres postgres.GenreRepo) *GenreService {
+	return &GenreService{genres: genres}
+}
+
+// ListGenres returns every genre by name with its number of movies.
+func (s *GenreService) ListGenres(ctx context.Context) ([]model.Genre, error) {
+	return s.genres.List(ctx)
+}
+
+func (s *GenreService) CreateGenre(ctx context.Context, name string) (model.Genre, error) {
+	name, err := genreName(name)
+	if err != nil {
+		return model.Genre{}, err
+	}
+	return s.genres.Create(ctx, model.Genre{Name: name})
+}
+
+// RenameGenre changes a genre's name. Genres linked to TMDB keep the link,
+// and later imports leave the new name alone.
+func (s *GenreService) RenameGenre(ctx context.Context, id int, name string) (model.Genre, error) {
+	name, err := genreName(name)
+	if err != nil {
+		return model.Genre{}, err
+	}
+	return s.genres.Update(ctx, model.Genre{ID: id, Name: name})
+}
+
+func (s *GenreService) DeleteGenre(ctx context.Context, id int) error {
+	return s.genres.Delete(ctx, id)
+}
+
+// ResolveGenre finds a genre by id or, failing that, by name.
+func (s *GenreService) ResolveGenre(ctx context.Context, idOrName string) (model.Genre, error) {
+	if id, err := strconv.Atoi(idOrName); err == nil {
+		return s.genres.GetByID(ctx, id)
+	}
+	return s.genres.GetByName(ctx, strings.TrimSpace(idOrName))
+}
+
+func genreName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxGenreName {
+		return "", ErrBadGenreData
+	}
+	return name, nil
+}
+
+// attachGenres loads the genres of movies into them. Every movie gets a
+// non-nil slice, so responses show an empty list rather than null.
+func attachGenres(ctx context.Context, genres postgres.GenreRepo, movies []model.Movie) error {
+	ids := make([]int, len(movies))
+	for i, m := range movies {
+		ids[i] = m.ID
+	}
+	byMovie, err := genres.ForMovies(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range movies {
+		movies[i].Genres = byMovie[movies[i].ID]
+		if movies[i].Genres == nil {
+			movies[i].Genres = []model.Genre{}
+		}
+	}
+	return nil
+}
+
+// setTMDBGenres gives a movie imported from TMDB the local genres matching
+// TMDB's, creating any that are missing, unless an admin has set its genres
+// by hand. It reports whether the movie's genres changed.
+func setTMDBGenres(ctx context.Context, genres postgres.GenreRepo, m model.Movie, from []tmdb.Genre) (bool, error) {
+	if m.Overridden(model.FieldGenres) {
+		return false, nil
+	}
+
+	ids := make([]int, 0, len(from))
+	for _, g := range from {
+		name := strings.TrimSpace(g.Name)
+		if g.ID == 0 || name == "" {
+			continue
+		}
+		local, err := genres.EnsureTMDB(ctx, model.Genre{TMDBID: g.ID, Name: name})
+		if err != nil {
+			return false, err
+		}
+		ids = append(ids, local.ID)
+	}
+	return genres.SetMovieGenres(ctx, m.ID, ids)
+}
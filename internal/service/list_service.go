@@ -0,0 +1,335 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+var ErrBadListData = errors.New("invalid list data")
+
+const (
+	maxListTitle       = 200
+	maxListDescription = 2000
+	maxListComment     = 1000
+)
+
+// MovieListService enforces who may do what with a list: anyone may read a
+// public or unlisted list, the owner and collaborators may change its
+// entries, and only the owner may change the list itself or its
+// collaborators. A private list reads as missing to everyone else. The
+// repository publishes new lists and entries to feeds, which only show them
+// while the list is public.
+type MovieListService struct {
+	lists  postgres.MovieListRepo
+	genres postgres.GenreRepo
+}
+
+func NewMovieListService(lists postgres.MovieListRepo, genres postgres.GenreRepo) *MovieListService {
+	return &MovieListService{lists: lists, genres: genres}
+}
+
+// ListUpdate changes the fields that are set.
+type ListUpdate struct {
+	Title       *string
+	Description *string
+	Visibility  *string
+}
+
+// ListEntryUpdate changes the fields that are set.
+type ListEntryUpdate struct {
+	Comment  *string
+	Position *int
+}
+
+// MyLists returns the lists the user owns or collaborates on.
+func (s *MovieListService) MyLists(ctx context.Context, userID int, opts model.ListOptions) ([]model.MovieList, int, error) {
+	lists, total, err := s.lists.List(ctx, model.ListFilter{MemberID: userID}, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range lists {
+		lists[i].Role = model.ListRoleCollaborator
+		if lists[i].OwnerID == userID {
+			lists[i].Role = model.ListRoleOwner
+		}
+	}
+	return lists, total, nil
+}
+
+// ListsWithMovie returns the public lists the movie is on.
+func (s *MovieListService) ListsWithMovie(ctx context.Context, movieID int, opts model.ListOptions) ([]model.MovieList, int, error) {
+	return s.lists.List(ctx, model.ListFilter{MovieID: movieID, PublicOnly: true}, opts)
+}
+
+func (s *MovieListService) GetList(ctx context.Context, userID, id int) (model.MovieList, error) {
+	return s.view(ctx, userID, id)
+}
+
+// CreateList creates l for the user; an empty visibility means private.
+func (s *MovieListService) CreateList(ctx context.Context, userID int, l model.MovieList) (model.MovieList, error) {
+	l.OwnerID = userID
+	if err := listFields(&l); err != nil {
+		return model.MovieList{}, err
+	}
+
+	created, err := s.lists.Create(ctx, l)
+	if err != nil {
+		return model.MovieList{}, err
+	}
+	created.Role = model.ListRoleOwner
+	return created, nil
+}
+
+func (s *MovieListService) UpdateList(ctx context.Context, userID, id int, upd ListUpdate) (model.MovieList, error) {
+	l, err := s.own(ctx, userID, id)
+	if err != nil {
+		return model.MovieList{}, err
+	}
+
+	if upd.Title != nil {
+		l.Title = *upd.Title
+	}
+	if upd.Description != nil {
+		l.Description = *upd.Description
+	}
+	if upd.Visibility != nil {
+		if *upd.Visibility == "" {
+			return model.MovieList{}, ErrBadListData
+		}
+		l.Visibility = *upd.Visibility
+	}
+	if err := listFields(&l); err != nil {
+		return model.MovieList{}, err
+	}
+
+	if l, err = s.lists.Update(ctx, l); err != nil {
+		return model.MovieList{}, err
+	}
+	l.Role = model.ListRoleOwner
+	return l, nil
+}
+
+func (s *MovieListService) DeleteList(ctx context.Context, userID, id int) error {
+	if _, err := s.own(ctx, userID, id); err != nil {
+		return err
+	}
+	return s.lists.Delete(ctx, id)
+}
+
+// CloneList copies a list the user can read into a new list of theirs.
+// Fields of l left empty are taken from the source, except the visibility,
+// which defaults to private.
+func (s *MovieListService) CloneList(ctx context.Context, userID, srcID int, l model.MovieList) (model.MovieList, error) {
+	src, err := s.view(ctx, userID, srcID)
+	if err != nil {
+		return model.MovieList{}, err
+	}
+
+	l.OwnerID = userID
+	if strings.TrimSpace(l.Title) == "" {
+		l.Title = src.Title
+	}
+	if l.Description == "" {
+		l.Description = src.Description
+	}
+	if err := listFields(&l); err != nil {
+		return model.MovieList{}, err
+	}
+
+	cloned, err := s.lists.Clone(ctx, srcID, l)
+	if err != nil {
+		return model.MovieList{}, err
+	}
+	cloned.Role = model.ListRoleOwner
+	return cloned, nil
+}
+
+// AddCollaborator lets collaboratorID edit the entries of the user's list.
+func (s *MovieListService) AddCollaborator(ctx context.Context, userID, listID, collaboratorID int) (model.MovieList, error) {
+	l, err := s.own(ctx, userID, listID)
+	if err != nil {
+		return model.MovieList{}, err
+	}
+	if collaboratorID == l.OwnerID {
+		return model.MovieList{}, ErrBadListData
+	}
+
+	if err := s.lists.AddCollaborator(ctx, listID, collaboratorID); err != nil {
+		return model.MovieList{}, err
+	}
+	return s.GetList(ctx, userID, listID)
+}
+
+// RemoveCollaborator is for the owner, or for a collaborator leaving the
+// list.
+func (s *MovieListService) RemoveCollaborator(ctx context.Context, userID, listID, collaboratorID int) error {
+	if userID != collaboratorID {
+		if _, err := s.own(ctx, userID, listID); err != nil {
+			return err
+		}
+	}
+	return s.lists.RemoveCollaborator(ctx, listID, collaboratorID)
+}
+
+func (s *MovieListService) ListEntries(ctx context.Context, userID, listID int, opts model.ListOptions) ([]model.ListEntry, int, error) {
+	if _, err := s.view(ctx, userID, listID); err != nil {
+		return nil, 0, err
+	}
+
+	entries, total, err := s.lists.Entries(ctx, listID, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, s.finish(ctx, entries)
+}
+
+// AddEntry puts e.Movie at the end of the list.
+func (s *MovieListService) AddEntry(ctx context.Context, userID, listID int, e model.ListEntry) (model.ListEntry, error) {
+	if e.Movie.ID <= 0 || len(e.Comment) > maxListComment {
+		return model.ListEntry{}, ErrBadListData
+	}
+	if _, err := s.edit(ctx, userID, listID); err != nil {
+		return model.ListEntry{}, err
+	}
+
+	e.AddedBy = userID
+	added, err := s.lists.AddEntry(ctx, listID, e)
+	if err != nil {
+		return model.ListEntry{}, err
+	}
+	return s.finishOne(ctx, added)
+}
+
+func (s *MovieListService) UpdateEntry(ctx context.Context, userID, listID, movieID int, upd ListEntryUpdate) (model.ListEntry, error) {
+	if upd.Comment != nil && len(*upd.Comment) > maxListComment {
+		return model.ListEntry{}, ErrBadListData
+	}
+	if upd.Position != nil && *upd.Position < 1 {
+		return model.ListEntry{}, ErrBadListData
+	}
+	if _, err := s.edit(ctx, userID, listID); err != nil {
+		return model.ListEntry{}, err
+	}
+
+	e, err := s.lists.GetEntry(ctx, listID, movieID)
+	if err != nil {
+		return model.ListEntry{}, err
+	}
+	if upd.Comment != nil {
+		e.Comment = *upd.Comment
+		if e, err = s.lists.UpdateEntry(ctx, listID, e); err != nil {
+			return model.ListEntry{}, err
+		}
+	}
+	if upd.Position != nil {
+		if e, err = s.lists.MoveEntry(ctx, listID, movieID, *upd.Position); err != nil {
+			return model.ListEntry{}, err
+		}
+	}
+	return s.finishOne(ctx, e)
+}
+
+func (s *MovieListService) RemoveEntry(ctx context.Context, userID, listID, movieID int) error {
+	if _, err := s.edit(ctx, userID, listID); err != nil {
+		return err
+	}
+	return s.lists.RemoveEntry(ctx, listID, movieID)
+}
+
+// view loads a list the user may read, with their role on it.
+func (s *MovieListService) view(ctx context.Context, userID, id int) (model.MovieList, error) {
+	l, err := s.lists.Get(ctx, id)
+	if err != nil {
+		return model.MovieList{}, err
+	}
+
+	switch {
+	case l.OwnerID == userID:
+		l.Role = model.ListRoleOwner
+	case isCollaborator(l, userID):
+		l.Role = model.ListRoleCollaborator
+	case l.Visibility == model.ListPrivate:
+		return model.MovieList{}, postgres.ErrListNotFound
+	}
+	return l, nil
+}
+
+// edit loads a list whose entries the user may change.
+func (s *MovieListService) edit(ctx context.Context, userID, id int) (model.MovieList, error) {
+	l, err := s.view(ctx, userID, id)
+	if err != nil {
+		return model.MovieList{}, err
+	}
+	if l.Role == "" {
+		return model.MovieList{}, ErrForbidden
+	}
+	return l, nil
+}
+
+// own loads a list the user owns.
+func (s *MovieListService) own(ctx context.Context, userID, id int) (model.MovieList, error) {
+	l, err := s.view(ctx, userID, id)
+	if err != nil {
+		return model.MovieList{}, err
+	}
+	if l.Role != model.ListRoleOwner {
+		return model.MovieList{}, ErrForbidden
+	}
+	return l, nil
+}
+
+func isCollaborator(l model.MovieList, userID int) bool {
+	for _, c := range l.Collaborators {
+		if c.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// finish fills in the movies' genres.
+func (s *MovieListService) finish(ctx context.Context, entries []model.ListEntry) error {
+	movies := make([]model.Movie, len(entries))
+	for i, e := range entries {
+		movies[i] = e.Movie
+	}
+	if err := attachGenres(ctx, s.genres, movies); err != nil {
+		return err
+	}
+	for i := range entries {
+		entries[i].Movie = movies[i]
+	}
+	return nil
+}
+
+func (s *MovieListService) finishOne(ctx context.Context, e model.ListEntry) (model.ListEntry, error) {
+	entries := []model.ListEntry{e}
+	if err := s.finish(ctx, entries); err != nil {
+		return model.ListEntry{}, err
+	}
+	return entries[0], nil
+}
+
+// listFields trims and checks a list's title and description, and its
+// visibility, defaulting to private.
+func listFields(l *model.MovieList) error {
+	l.Title = strings.TrimSpace(l.Title)
+	l.Description = strings.TrimSpace(l.Description)
+	if l.Visibility == "" {
+		l.Visibility = model.ListPrivate
+	}
+
+	switch {
+	case l.Title == "" || len(l.Title) > maxListTitle:
+		return ErrBadListData
+	case len(l.Description) > maxListDescription:
+		return ErrBadListData
+	case l.Visibility != model.ListPublic && l.Visibility != model.ListUnlisted && l.Visibility != model.ListPrivate:
+		return ErrBadListData
+	}
+	return nil
+}
@@ -0,0 +1,446 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/importfile"
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/internal/tmdb"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+var (
+	ErrImportNotFound = errors.New("import not found")
+	ErrImportsStopped = errors.New("imports are shutting down")
+)
+
+const (
+	// importWorkers is how many rows of one import are fetched from TMDB at
+	// a time; the client's rate limit paces them further.
+	importWorkers = 4
+
+	// maxImportJobs is how many imports are kept for GET. Running imports
+	// are never dropped.
+	maxImportJobs = 50
+)
+
+type ImportOptions struct {
+	Format importfile.Format
+	// DryRun fetches every movie from TMDB and reports what the import
+	// would do, without writing anything.
+	DryRun bool
+}
+
+// MovieImporter adds movies by TMDB id in bulk. Each movie is fetched from
+// TMDB and upserted on its TMDB id like the catalog sync does, so importing
+// the same file twice creates nothing new. The movie's TMDB genres come with
+// it, unless an admin has set its genres by hand, and so do its main cast
+// and crew. Jobs started over HTTP run in the background and live in memory
+// only; their rows say what happened to each entry.
+type MovieImporter struct {
+	client tmdb.API
+	movies postgres.MovieRepo
+	genres postgres.GenreRepo
+	people postgres.PersonRepo
+
+	mu     sync.Mutex
+	jobs   map[int]*importJob
+	order  []int // job ids, oldest first
+	lastID int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type importJob struct {
+	mu  sync.Mutex
+	job model.ImportJob
+}
+
+func NewMovieImporter(
+	client tmdb.API,
+	movies postgres.MovieRepo,
+	genres postgres.GenreRepo,
+	people postgres.PersonRepo,
+) *MovieImporter {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &MovieImporter{
+		client: client,
+		movies: movies,
+		genres: genres,
+		people: people,
+		jobs:   make(map[int]*importJob),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start begins importing entries in the background and returns the new job.
+func (imp *MovieImporter) Start(entries []importfile.Entry, opts ImportOptions) (model.ImportJob, error) {
+	if imp.ctx.Err() != nil {
+		return model.ImportJob{}, ErrImportsStopped
+	}
+
+	j := newImportJob(entries, opts)
+
+	imp.mu.Lock()
+	imp.lastID++
+	j.job.ID = imp.lastID
+	imp.jobs[j.job.ID] = j
+	imp.order = append(imp.order, j.job.ID)
+	imp.evictLocked()
+	imp.mu.Unlock()
+
+	imp.wg.Add(1)
+	go func() {
+		defer imp.wg.Done()
+		imp.execute(imp.ctx, j, nil)
+	}()
+
+	return j.snapshot(false), nil
+}
+
+// Run imports entries and returns when done. onRow, if set, is called once
+// per finished row, one call at a time.
+func (imp *MovieImporter) Run(ctx context.Context, entries []importfile.Entry, opts ImportOptions, onRow func(model.ImportRow)) model.ImportJob {
+	j := newImportJob(entries, opts)
+	if onRow != nil {
+		for _, row := range j.job.Rows {
+			if row.Status != model.ImportRowPending {
+				onRow(row)
+			}
+		}
+	}
+	imp.execute(ctx, j, onRow)
+	return j.snapshot(true)
+}
+
+// Get returns an import with its rows.
+func (imp *MovieImporter) Get(id int) (model.ImportJob, error) {
+	imp.mu.Lock()
+	j, ok := imp.jobs[id]
+	imp.mu.Unlock()
+	if !ok {
+		return model.ImportJob{}, ErrImportNotFound
+	}
+	return j.snapshot(true), nil
+}
+
+// List returns imports newest first, without their rows.
+func (imp *MovieImporter) List(opts model.ListOptions) ([]model.ImportJob, int) {
+	imp.mu.Lock()
+	jobs := make([]*importJob, 0, len(imp.order))
+	for _, id := range slices.Backward(imp.order) {
+		jobs = append(jobs, imp.jobs[id])
+	}
+	imp.mu.Unlock()
+
+	total := len(jobs)
+	start := min(opts.Offset, total)
+	end := total
+	if opts.Limit > 0 {
+		end = min(start+opts.Limit, total)
+	}
+
+	page := make([]model.ImportJob, 0, end-start)
+	for _, j := range jobs[start:end] {
+		page = append(page, j.snapshot(false))
+	}
+	return page, total
+}
+
+// Stop cancels running imports, which finish as failed, and waits for them
+// or for ctx to expire.
+func (imp *MovieImporter) Stop(ctx context.Context) error {
+	imp.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		imp.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// evictLocked drops the oldest finished jobs beyond maxImportJobs.
+func (imp *MovieImporter) evictLocked() {
+	for i := 0; len(imp.order) > maxImportJobs && i < len(imp.order); {
+		id := imp.order[i]
+		if imp.jobs[id].running() {
+			i++
+			continue
+		}
+		delete(imp.jobs, id)
+		imp.order = slices.Delete(imp.order, i, i+1)
+	}
+}
+
+// newImportJob sets up the rows: entries without a valid id fail and
+// repeated ids are skipped up front, the rest wait for a worker.
+func newImportJob(entries []importfile.Entry, opts ImportOptions) *importJob {
+	job := model.ImportJob{
+		Format:    string(opts.Format),
+		DryRun:    opts.DryRun,
+		Status:    model.ImportRunning,
+		StartedAt: time.Now().UTC(),
+		Total:     len(entries),
+		Rows:      make([]model.ImportRow, len(entries)),
+	}
+
+	seen := make(map[int]int, len(entries))
+	for i, e := range entries {
+		row := model.ImportRow{Line: e.Line, TMDBID: e.TMDBID, Status: model.ImportRowPending}
+		switch first, dup := seen[e.TMDBID]; {
+		case e.Err != nil:
+			row.Status = model.ImportRowFailed
+			row.Error = e.Err.Error()
+		case dup:
+			row.Status = model.ImportRowDuplicate
+			row.Error = fmt.Sprintf("same tmdb id as line %d", first)
+		default:
+			seen[e.TMDBID] = e.Line
+		}
+		job.Rows[i] = row
+		countImportRow(&job, row)
+	}
+
+	return &importJob{job: job}
+}
+
+func (imp *MovieImporter) execute(ctx context.Context, j *importJob, onRow func(model.ImportRow)) {
+	var todo []int
+	for i, row := range j.job.Rows {
+		if row.Status == model.ImportRowPending {
+			todo = append(todo, i)
+		}
+	}
+
+	pending := make(chan int)
+	go func() {
+		defer close(pending)
+		for _, i := range todo {
+			select {
+			case pending <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range importWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range pending {
+				row := imp.importRow(ctx, j.row(i), j.job.DryRun)
+				j.finishRow(i, row, onRow)
+			}
+		}()
+	}
+	wg.Wait()
+
+	j.finish(ctx.Err(), onRow)
+}
+
+// ImportMovie fetches one movie from TMDB and stores it as an import row
+// would, for callers that need a TMDB movie in the catalog.
+func (imp *MovieImporter) ImportMovie(ctx context.Context, tmdbID int) (model.Movie, error) {
+	d, fresh, err := imp.fetch(ctx, tmdbID)
+	if err != nil {
+		return model.Movie{}, err
+	}
+	stored, _, err := imp.store(ctx, d, fresh)
+	return stored, err
+}
+
+// importRow fetches one movie from TMDB and upserts it, or in a dry run
+// works out what the upsert would do.
+func (imp *MovieImporter) importRow(ctx context.Context, row model.ImportRow, dryRun bool) model.ImportRow {
+	fail := func(err error) model.ImportRow {
+		row.Status = model.ImportRowFailed
+		row.Error = err.Error()
+		return row
+	}
+
+	d, fresh, err := imp.fetch(ctx, row.TMDBID)
+	if err != nil {
+		return fail(err)
+	}
+
+	if dryRun {
+		existing, err := imp.movies.GetByTMDBID(ctx, row.TMDBID)
+		if errors.Is(err, postgres.ErrMovieNotFound) {
+			row.Status = model.ImportRowCreated
+			row.Title = fresh.Title
+			return row
+		}
+		if err != nil {
+			return fail(err)
+		}
+
+		merged, changed := existing.MergeTMDB(fresh)
+		row.Status = model.ImportRowUnchanged
+		if changed {
+			row.Status = model.ImportRowUpdated
+		}
+		row.MovieID = existing.ID
+		row.Title = merged.Title
+		return row
+	}
+
+	stored, result, err := imp.store(ctx, d, fresh)
+	if err != nil {
+		return fail(err)
+	}
+	switch result {
+	case model.UpsertCreated:
+		row.Status = model.ImportRowCreated
+	case model.UpsertUpdated:
+		row.Status = model.ImportRowUpdated
+	default:
+		row.Status = model.ImportRowUnchanged
+	}
+	row.MovieID = stored.ID
+	row.Title = stored.Title
+	return row
+}
+
+// fetch gets a movie from TMDB, returning it as TMDB sent it and as a local
+// movie.
+func (imp *MovieImporter) fetch(ctx context.Context, tmdbID int) (tmdb.MovieDetails, model.Movie, error) {
+	d, err := imp.client.GetMovie(ctx, tmdbID)
+	if err != nil {
+		return tmdb.MovieDetails{}, model.Movie{}, err
+	}
+	if d.Title == "" {
+		return tmdb.MovieDetails{}, model.Movie{}, errors.New("tmdb returned a movie without a title")
+	}
+	return d, model.Movie{
+		TMDBID:      tmdbID,
+		Title:       d.Title,
+		Year:        d.Year(),
+		Description: d.Overview,
+	}, nil
+}
+
+// store upserts a fetched movie with its genres and credits. A movie whose
+// genres or credits changed counts as updated even if its row did not.
+func (imp *MovieImporter) store(ctx context.Context, d tmdb.MovieDetails, fresh model.Movie) (model.Movie, model.UpsertResult, error) {
+	stored, result, err := imp.movies.UpsertTMDB(ctx, fresh)
+	if err != nil {
+		return model.Movie{}, 0, err
+	}
+	genresChanged, err := setTMDBGenres(ctx, imp.genres, stored, d.Genres)
+	if err != nil {
+		return model.Movie{}, 0, fmt.Errorf("set genres: %w", err)
+	}
+	creditsChanged, err := imp.people.SetMovieCredits(ctx, stored.ID, tmdbCredits(d))
+	if err != nil {
+		return model.Movie{}, 0, fmt.Errorf("set credits: %w", err)
+	}
+	if (genresChanged || creditsChanged) && result == model.UpsertUnchanged {
+		result = model.UpsertUpdated
+	}
+	return stored, result, nil
+}
+
+func (j *importJob) running() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.job.Status == model.ImportRunning
+}
+
+func (j *importJob) row(i int) model.ImportRow {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.job.Rows[i]
+}
+
+func (j *importJob) finishRow(i int, row model.ImportRow, onRow func(model.ImportRow)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.job.Rows[i] = row
+	countImportRow(&j.job, row)
+	if onRow != nil {
+		onRow(row)
+	}
+}
+
+// finish fails the rows a cancelled import never got to and sets the
+// job's final status.
+func (j *importJob) finish(cancelErr error, onRow func(model.ImportRow)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for i, row := range j.job.Rows {
+		if row.Status != model.ImportRowPending {
+			continue
+		}
+		row.Status = model.ImportRowFailed
+		row.Error = fmt.Sprintf("import cancelled: %v", cancelErr)
+		j.job.Rows[i] = row
+		countImportRow(&j.job, row)
+		if onRow != nil {
+			onRow(row)
+		}
+	}
+
+	stored := j.job.Created + j.job.Updated + j.job.Unchanged
+	switch {
+	case cancelErr != nil:
+		j.job.Status = model.ImportFailed
+	case j.job.Failed > 0 && stored == 0:
+		j.job.Status = model.ImportFailed
+	case j.job.Failed > 0:
+		j.job.Status = model.ImportPartial
+	default:
+		j.job.Status = model.ImportSucceeded
+	}
+	now := time.Now().UTC()
+	j.job.FinishedAt = &now
+}
+
+// countImportRow adds a finished row to the job's counters.
+func countImportRow(job *model.ImportJob, row model.ImportRow) {
+	switch row.Status {
+	case model.ImportRowPending:
+		return
+	case model.ImportRowCreated:
+		job.Created++
+	case model.ImportRowUpdated:
+		job.Updated++
+	case model.ImportRowUnchanged:
+		job.Unchanged++
+	case model.ImportRowDuplicate:
+		job.Duplicates++
+	case model.ImportRowFailed:
+		job.Failed++
+	}
+	job.Processed++
+}
+
+func (j *importJob) snapshot(withRows bool) model.ImportJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job := j.job
+	job.Rows = nil
+	if withRows {
+		job.Rows = slices.Clone(j.job.Rows)
+	}
+	return job
+}
@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/importfile"
+	"github.com/AlikhanF2006/Final_project/internal/memory"
+	"github.com/AlikhanF2006/Final_project/internal/tmdb"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+// fakeTMDB serves movies from a map; ids it does not know fail as TMDB's
+// 404 would. block, if set, holds every GetMovie until it is closed.
+type fakeTMDB struct {
+	mu     sync.Mutex
+	movies map[int]tmdb.MovieDetails
+	block  chan struct{}
+}
+
+func (f *fakeTMDB) GetPopularMovies(ctx context.Context) ([]tmdb.MovieSummary, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeTMDB) GetMovie(ctx context.Context, tmdbID int) (tmdb.MovieDetails, error) {
+	if f.block != nil {
+		select {
+		case <-f.block:
+		case <-ctx.Done():
+			return tmdb.MovieDetails{}, ctx.Err()
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d, ok := f.movies[tmdbID]
+	if !ok {
+		return tmdb.MovieDetails{}, fmt.Errorf("tmdb movie %d: not found", tmdbID)
+	}
+	return d, nil
+}
+
+func newFakeTMDB() *fakeTMDB {
+	return &fakeTMDB{movies: map[int]tmdb.MovieDetails{
+		550: {
+			ID: 550, Title: "Fight Club", ReleaseDate: "1999-10-15", Overview: "An insomniac office worker...",
+			Genres:  []tmdb.Genre{{ID: 18, Name: "Drama"}},
+			Credits: tmdb.Credits{Cast: []tmdb.CastMember{{ID: 819, Name: "Edward Norton", Character: "Narrator"}}},
+		},
+		603: {ID: 603, Title: "The Matrix", ReleaseDate: "1999-03-31", Genres: []tmdb.Genre{{ID: 878, Name: "Science Fiction"}}},
+		680: {ID: 680, Title: "Pulp Fiction", ReleaseDate: "1994-09-10"},
+		// TMDB answers, but without a title.
+		13: {ID: 13},
+	}}
+}
+
+type importFixture struct {
+	tmdb   *fakeTMDB
+	movies *memory.MovieRepository
+	imp    *MovieImporter
+}
+
+func newImportFixture(t *testing.T) *importFixture {
+	t.Helper()
+	store := memory.NewStore()
+	f := &importFixture{
+		tmdb:   newFakeTMDB(),
+		movies: memory.NewMovieRepository(store),
+	}
+	f.imp = NewMovieImporter(f.tmdb, f.movies, memory.NewGenreRepository(store), memory.NewPersonRepository(store))
+	t.Cleanup(func() {
+		if err := f.imp.Stop(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+	return f
+}
+
+func entries(ids ...int) []importfile.Entry {
+	out := make([]importfile.Entry, len(ids))
+	for i, id := range ids {
+		out[i] = importfile.Entry{Line: i + 1, TMDBID: id}
+	}
+	return out
+}
+
+func TestMovieImporterRows(t *testing.T) {
+	f := newImportFixture(t)
+	ctx := context.Background()
+
+	// 680 is already in the catalog with an outdated title; 603 is current.
+	if _, _, err := f.movies.UpsertTMDB(ctx, model.Movie{TMDBID: 680, Title: "Pulp", Year: 1994}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.imp.ImportMovie(ctx, 603); err != nil {
+		t.Fatal(err)
+	}
+
+	in := []importfile.Entry{
+		{Line: 1, TMDBID: 550},
+		{Line: 2, Err: errors.New(`invalid tmdb id "abc"`)},
+		{Line: 3, TMDBID: 550},
+		{Line: 4, TMDBID: 603},
+		{Line: 5, TMDBID: 680},
+		{Line: 6, TMDBID: 999},
+		{Line: 7, TMDBID: 13},
+	}
+
+	var (
+		mu       sync.Mutex
+		reported = map[int]int{}
+	)
+	job := f.imp.Run(ctx, in, ImportOptions{Format: importfile.FormatIDs}, func(row model.ImportRow) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported[row.Line]++
+	})
+
+	want := []struct {
+		status  string
+		title   string
+		errPart string
+	}{
+		{status: model.ImportRowCreated, title: "Fight Club"},
+		{status: model.ImportRowFailed, errPart: "abc"},
+		{status: model.ImportRowDuplicate, errPart: "line 1"},
+		{status: model.ImportRowUnchanged, title: "The Matrix"},
+		{status: model.ImportRowUpdated, title: "Pulp Fiction"},
+		{status: model.ImportRowFailed, errPart: "not found"},
+		{status: model.ImportRowFailed, errPart: "without a title"},
+	}
+	if len(job.Rows) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(job.Rows), len(want))
+	}
+	for i, w := range want {
+		row := job.Rows[i]
+		if row.Status != w.status || row.Title != w.title || (w.errPart == "") != (row.Error == "") {
+			t.Errorf("row %d: got %+v, want status %s, title %q, error %q", i+1, row, w.status, w.title, w.errPart)
+		}
+		if w.errPart != "" && !strings.Contains(row.Error, w.errPart) {
+			t.Errorf("row %d: error %q does not mention %q", i+1, row.Error, w.errPart)
+		}
+		if stored := row.Status == model.ImportRowCreated || row.Status == model.ImportRowUpdated || row.Status == model.ImportRowUnchanged; stored && row.MovieID == 0 {
+			t.Errorf("row %d: stored without a movie id", i+1)
+		}
+		if reported[row.Line] != 1 {
+			t.Errorf("row %d: reported to onRow %d times, want once", i+1, reported[row.Line])
+		}
+	}
+
+	if job.Status != model.ImportPartial || job.FinishedAt == nil {
+		t.Errorf("job: got status %s, finished at %v, want %s and a finish time", job.Status, job.FinishedAt, model.ImportPartial)
+	}
+	if job.Total != 7 || job.Processed != 7 || job.Created != 1 || job.Updated != 1 || job.Unchanged != 1 || job.Duplicates != 1 || job.Failed != 3 {
+		t.Errorf("job counters: got %+v", job)
+	}
+
+	m, err := f.movies.GetByTMDBID(ctx, 550)
+	if err != nil {
+		t.Fatalf("imported movie: %v", err)
+	}
+	if m.Title != "Fight Club" || m.Year != 1999 {
+		t.Errorf("imported movie: got %+v", m)
+	}
+	if _, err := f.movies.GetByTMDBID(ctx, 999); err == nil {
+		t.Error("a failed row stored a movie")
+	}
+
+	// Importing the same file again changes nothing.
+	again := f.imp.Run(ctx, entries(550, 603, 680), ImportOptions{Format: importfile.FormatIDs}, nil)
+	if again.Status != model.ImportSucceeded || again.Unchanged != 3 {
+		t.Errorf("second import: got status %s with %d unchanged, want %s with 3", again.Status, again.Unchanged, model.ImportSucceeded)
+	}
+}
+
+func TestMovieImporterJobStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		ids  []int
+		want string
+	}{
+		{name: "all stored", ids: []int{550, 603}, want: model.ImportSucceeded},
+		{name: "some failed", ids: []int{550, 999}, want: model.ImportPartial},
+		{name: "all failed", ids: []int{999, 13}, want: model.ImportFailed},
+		{name: "only duplicates besides stored rows", ids: []int{550, 550}, want: model.ImportSucceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newImportFixture(t)
+			job := f.imp.Run(context.Background(), entries(tt.ids...), ImportOptions{}, nil)
+			if job.Status != tt.want {
+				t.Errorf("status: got %s, want %s (rows %+v)", job.Status, tt.want, job.Rows)
+			}
+		})
+	}
+}
+
+func TestMovieImporterDryRun(t *testing.T) {
+	f := newImportFixture(t)
+	ctx := context.Background()
+	if _, _, err := f.movies.UpsertTMDB(ctx, model.Movie{TMDBID: 680, Title: "Pulp", Year: 1994}); err != nil {
+		t.Fatal(err)
+	}
+
+	job := f.imp.Run(ctx, entries(550, 680), ImportOptions{DryRun: true}, nil)
+	if !job.DryRun || job.Status != model.ImportSucceeded || job.Created != 1 || job.Updated != 1 {
+		t.Errorf("dry run: got %+v", job)
+	}
+	if _, err := f.movies.GetByTMDBID(ctx, 550); err == nil {
+		t.Error("dry run created a movie")
+	}
+	if m, _ := f.movies.GetByTMDBID(ctx, 680); m.Title != "Pulp" {
+		t.Errorf("dry run updated a movie: title %q", m.Title)
+	}
+}
+
+func TestMovieImporterBackgroundJob(t *testing.T) {
+	f := newImportFixture(t)
+	f.tmdb.block = make(chan struct{})
+
+	started, err := f.imp.Start(entries(550, 603), ImportOptions{Format: importfile.FormatCSV})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if started.Status != model.ImportRunning || started.Processed != 0 || started.Rows != nil {
+		t.Errorf("started job: got %+v", started)
+	}
+
+	running, err := f.imp.Get(started.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if running.Status != model.ImportRunning || len(running.Rows) != 2 {
+		t.Errorf("running job: got %+v", running)
+	}
+
+	close(f.tmdb.block)
+	done := waitForImport(t, f.imp, started.ID)
+	if done.Status != model.ImportSucceeded || done.Created != 2 || done.FinishedAt == nil {
+		t.Errorf("finished job: got %+v", done)
+	}
+
+	list, total := f.imp.List(model.ListOptions{})
+	if total != 1 || len(list) != 1 || list[0].ID != started.ID || list[0].Rows != nil {
+		t.Errorf("List: got %d jobs %+v", total, list)
+	}
+	if _, err := f.imp.Get(started.ID + 1); !errors.Is(err, ErrImportNotFound) {
+		t.Errorf("Get of unknown job: got error %v, want %v", err, ErrImportNotFound)
+	}
+}
+
+func TestMovieImporterStopFailsRunningJob(t *testing.T) {
+	f := newImportFixture(t)
+	f.tmdb.block = make(chan struct{})
+
+	started, err := f.imp.Start(entries(550, 603, 680, 13, 999, 11, 12), ImportOptions{})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := f.imp.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	job, err := f.imp.Get(started.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != model.ImportFailed || job.Failed != job.Total || job.Processed != job.Total {
+		t.Errorf("stopped job: got %+v", job)
+	}
+	for _, row := range job.Rows {
+		if row.Status != model.ImportRowFailed || row.Error == "" {
+			t.Errorf("row %d of a stopped job: got %+v", row.Line, row)
+		}
+	}
+
+	if _, err := f.imp.Start(entries(550), ImportOptions{}); !errors.Is(err, ErrImportsStopped) {
+		t.Errorf("Start after Stop: got error %v, want %v", err, ErrImportsStopped)
+	}
+}
+
+func waitForImport(t *testing.T, imp *MovieImporter, id int) model.ImportJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := imp.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status != model.ImportRunning {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("import %d still running: %+v", id, job)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -1,8 +1,9 @@
 package service
 
 import (
+	"context"
 	"errors"
-	"strconv"
+	"slices"
 	"strings"
 
 	"github.com/AlikhanF2006/Final_project/internal/postgres"
@@ -13,140 +14,303 @@ import (
 var ErrBadMovieData = errors.New("invalid movie data")
 
 type MovieService struct {
-	movieRepo  *postgres.MovieRepository
-	tmdbClient *tmdb.Client
+	movieRepo  postgres.MovieRepo
+	genreRepo  postgres.GenreRepo
+	personRepo postgres.PersonRepo
+	tmdbClient tmdb.API
 }
 
 func NewMovieService(
-	movieRepo *postgres.MovieRepository,
-	tmdbClient *tmdb.Client,
+	movieRepo postgres.MovieRepo,
+	genreRepo postgres.GenreRepo,
+	personRepo postgres.PersonRepo,
+	tmdbClient tmdb.API,
 ) *MovieService {
 	return &MovieService{
 		movieRepo:  movieRepo,
+		genreRepo:  genreRepo,
+		personRepo: personRepo,
 		tmdbClient: tmdbClient,
 	}
 }
 
-func (s *MovieService) CreateMovie(m model.Movie) (model.Movie, error) {
+func (s *MovieService) CreateMovie(ctx context.Context, m model.Movie) (model.Movie, error) {
 	m.Title = strings.TrimSpace(m.Title)
 	if m.Title == "" || m.Year <= 0 {
 		return model.Movie{}, ErrBadMovieData
 	}
-	return s.movieRepo.Create(m)
+	created, err := s.movieRepo.Create(ctx, m)
+	if err != nil {
+		return model.Movie{}, err
+	}
+	return s.withGenres(ctx, created)
 }
 
-func (s *MovieService) ListMovies() []model.Movie {
-	return s.movieRepo.GetAll()
+func (s *MovieService) ListMovies(ctx context.Context, f model.MovieFilter, opts model.ListOptions) ([]model.Movie, int, error) {
+	movies, total, err := s.movieRepo.List(ctx, f, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return movies, total, attachGenres(ctx, s.genreRepo, movies)
 }
 
-func (s *MovieService) GetMovie(id int) (model.Movie, error) {
-	return s.movieRepo.GetByID(id)
+func (s *MovieService) GetMovie(ctx context.Context, id int) (model.Movie, error) {
+	m, err := s.movieRepo.GetByID(ctx, id)
+	if err != nil {
+		return model.Movie{}, err
+	}
+	return s.withGenres(ctx, m)
 }
 
-func (s *MovieService) UpdateMovie(id int, upd model.Movie) (model.Movie, error) {
-	existing, err := s.movieRepo.GetByID(id)
+// GetMovieDetail returns the movie with its top-billed cast and directors.
+func (s *MovieService) GetMovieDetail(ctx context.Context, id int) (model.MovieDetail, error) {
+	m, err := s.GetMovie(ctx, id)
+	if err != nil {
+		return model.MovieDetail{}, err
+	}
+	return movieDetail(ctx, s.personRepo, m)
+}
+
+// UpdateMovie applies the non-empty fields of upd. On movies imported from
+// TMDB those fields become overrides, which the TMDB sync leaves alone.
+func (s *MovieService) UpdateMovie(ctx context.Context, id int, upd model.Movie) (model.Movie, error) {
+	existing, err := s.movieRepo.GetByID(ctx, id)
 	if err != nil {
 		return model.Movie{}, err
 	}
 
+	var changed []string
 	if strings.TrimSpace(upd.Title) != "" {
 		existing.Title = strings.TrimSpace(upd.Title)
+		changed = append(changed, model.FieldTitle)
 	}
 	if upd.Year > 0 {
 		existing.Year = upd.Year
+		changed = append(changed, model.FieldYear)
 	}
 	if upd.Description != "" {
 		existing.Description = upd.Description
+		changed = append(changed, model.FieldDescription)
 	}
 
-	return s.movieRepo.Update(existing)
-}
+	if existing.TMDBID != 0 {
+		for _, field := range changed {
+			if !existing.Overridden(field) {
+				existing.Overrides = append(existing.Overrides, field)
+			}
+		}
+	}
 
-func (s *MovieService) DeleteMovie(id int) error {
-	return s.movieRepo.Delete(id)
+	return s.update(ctx, existing)
 }
 
-func (s *MovieService) Search(title string, year int) []model.Movie {
-	all := s.movieRepo.GetAll()
-	result := make([]model.Movie, 0)
+// SetGenres replaces the movie's genres. On movies imported from TMDB the
+// genres become an override, which later imports leave alone.
+func (s *MovieService) SetGenres(ctx context.Context, id int, genreIDs []int) (model.Movie, error) {
+	existing, err := s.movieRepo.GetByID(ctx, id)
+	if err != nil {
+		return model.Movie{}, err
+	}
+
+	if _, err := s.genreRepo.SetMovieGenres(ctx, id, genreIDs); err != nil {
+		return model.Movie{}, err
+	}
+	if existing.TMDBID == 0 || existing.Overridden(model.FieldGenres) {
+		return s.withGenres(ctx, existing)
+	}
 
-	title = strings.ToLower(strings.TrimSpace(title))
+	existing.Overrides = append(existing.Overrides, model.FieldGenres)
+	return s.update(ctx, existing)
+}
 
-	for _, m := range all {
-		if title != "" && !strings.Contains(strings.ToLower(m.Title), title) {
-			continue
-		}
-		if year > 0 && m.Year != year {
-			continue
-		}
-		result = append(result, m)
+// ClearOverrides hands every field of the movie back to the TMDB sync.
+func (s *MovieService) ClearOverrides(ctx context.Context, id int) (model.Movie, error) {
+	existing, err := s.movieRepo.GetByID(ctx, id)
+	if err != nil {
+		return model.Movie{}, err
 	}
 
-	return result
+	existing.Overrides = nil
+	return s.update(ctx, existing)
 }
 
-func (s *MovieService) GetPopularFromTMDB() ([]model.Movie, error) {
-	moviesDTO, err := s.tmdbClient.GetPopularMovies()
+func (s *MovieService) update(ctx context.Context, m model.Movie) (model.Movie, error) {
+	updated, err := s.movieRepo.Update(ctx, m)
 	if err != nil {
-		return nil, err
+		return model.Movie{}, err
 	}
+	return s.withGenres(ctx, updated)
+}
 
-	var result []model.Movie
+func (s *MovieService) withGenres(ctx context.Context, m model.Movie) (model.Movie, error) {
+	movies := []model.Movie{m}
+	if err := attachGenres(ctx, s.genreRepo, movies); err != nil {
+		return model.Movie{}, err
+	}
+	return movies[0], nil
+}
 
-	for _, m := range moviesDTO {
-		year := 0
-		if len(m.ReleaseDate) >= 4 {
-			if y, err := strconv.Atoi(m.ReleaseDate[:4]); err == nil {
-				year = y
-			}
-		}
+func (s *MovieService) DeleteMovie(ctx context.Context, id int) error {
+	return s.movieRepo.Delete(ctx, id)
+}
 
-		if s.movieRepo.ExistsByTMDBID(m.ID) {
-			existing, err := s.movieRepo.GetByTMDBID(m.ID)
-			if err == nil {
-				result = append(result, existing)
-			}
-			continue
-		}
+// GetPopularFromTMDB returns TMDB's current popular list as local movies,
+// importing the ones not in the catalog yet and refreshing the others.
+func (s *MovieService) GetPopularFromTMDB(ctx context.Context) ([]model.Movie, error) {
+	popular, err := s.tmdbClient.GetPopularMovies(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-		created, err := s.movieRepo.Create(model.Movie{
+	result := make([]model.Movie, 0, len(popular))
+	for _, m := range popular {
+		movie, _, err := s.movieRepo.UpsertTMDB(ctx, model.Movie{
 			TMDBID:      m.ID,
 			Title:       m.Title,
 			Description: m.Overview,
-			Year:        year,
-			Rating:      0,
+			Year:        m.Year(),
 		})
-		if err == nil {
-			result = append(result, created)
+		if err != nil {
+			return nil, err
 		}
+		result = append(result, movie)
 	}
 
-	return result, nil
+	return result, attachGenres(ctx, s.genreRepo, result)
 }
 
-func (s *MovieService) GetMovieWithTrailer(tmdbID int) (map[string]any, error) {
-	movie, err := s.tmdbClient.GetMovie(tmdbID)
+// GetMovieWithTrailer returns the movie's TMDB metadata, credits and videos,
+// with TrailerURL pointing at its YouTube trailer when it has one.
+func (s *MovieService) GetMovieWithTrailer(ctx context.Context, tmdbID int) (model.TMDBMovie, error) {
+	details, err := s.tmdbClient.GetMovie(ctx, tmdbID)
 	if err != nil {
-		return nil, err
+		return model.TMDBMovie{}, err
 	}
 
-	trailerKey, _ := s.tmdbClient.GetTrailerKey(tmdbID)
+	return tmdbMovie(details), nil
+}
 
-	result := map[string]any{
-		"id":           movie.ID,
-		"title":        movie.Title,
-		"description":  movie.Overview,
-		"release_date": movie.ReleaseDate,
-		"trailer_url":  "",
+func (s *MovieService) SearchMovies(ctx context.Context, query string, f model.MovieFilter, opts model.ListOptions) ([]model.MovieSearchHit, int, error) {
+	hits, total, err := s.movieRepo.Search(ctx, query, f, opts)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	if trailerKey != "" {
-		result["trailer_url"] = "https://www.youtube.com/watch?v=" + trailerKey
+	movies := make([]model.Movie, len(hits))
+	for i, h := range hits {
+		movies[i] = h.Movie
 	}
+	if err := attachGenres(ctx, s.genreRepo, movies); err != nil {
+		return nil, 0, err
+	}
+	for i := range hits {
+		hits[i].Genres = movies[i].Genres
+	}
+	return hits, total, nil
+}
+
+const (
+	tmdbCastLimit = 20
+	posterSize    = "w500"
+	backdropSize  = "w1280"
+	profileSize   = "w185"
+)
 
-	return result, nil
+// tmdbCrewJobs are the crew credits worth showing on a movie page; TMDB lists
+// everyone down to the caterers.
+var tmdbCrewJobs = map[string]bool{
+	"Director":                true,
+	"Screenplay":              true,
+	"Writer":                  true,
+	"Novel":                   true,
+	"Story":                   true,
+	"Producer":                true,
+	"Original Music Composer": true,
+	"Director of Photography": true,
+	"Editor":                  true,
 }
 
-func (s *MovieService) SearchMovies(title string, year int) ([]model.Movie, error) {
-	return s.movieRepo.Search(title, year)
+var videoURLs = map[string]string{
+	"YouTube": "https://www.youtube.com/watch?v=",
+	"Vimeo":   "https://vimeo.com/",
+}
+
+func tmdbMovie(d tmdb.MovieDetails) model.TMDBMovie {
+	m := model.TMDBMovie{
+		ID:            d.ID,
+		IMDbID:        d.IMDbID,
+		Title:         d.Title,
+		OriginalTitle: d.OriginalTitle,
+		Tagline:       d.Tagline,
+		Description:   d.Overview,
+		ReleaseDate:   d.ReleaseDate,
+		Year:          d.Year(),
+		Runtime:       d.Runtime,
+		Genres:        make([]model.TMDBGenre, 0, len(d.Genres)),
+		PosterURL:     tmdb.ImageURL(d.PosterPath, posterSize),
+		BackdropURL:   tmdb.ImageURL(d.BackdropPath, backdropSize),
+		VoteAverage:   d.VoteAverage,
+		VoteCount:     d.VoteCount,
+		Cast:          make([]model.CastCredit, 0, min(len(d.Credits.Cast), tmdbCastLimit)),
+		Crew:          make([]model.CrewCredit, 0),
+		Videos:        make([]model.Video, 0, len(d.Videos.Results)),
+		ReleaseDates:  make([]model.ReleaseDate, 0),
+	}
+
+	for _, g := range d.Genres {
+		m.Genres = append(m.Genres, model.TMDBGenre{ID: g.ID, Name: g.Name})
+	}
+
+	cast := slices.Clone(d.Credits.Cast)
+	slices.SortStableFunc(cast, func(a, b tmdb.CastMember) int { return a.Order - b.Order })
+	for _, c := range cast[:min(len(cast), tmdbCastLimit)] {
+		m.Cast = append(m.Cast, model.CastCredit{
+			PersonID:   c.ID,
+			Name:       c.Name,
+			Character:  c.Character,
+			Order:      c.Order,
+			ProfileURL: tmdb.ImageURL(c.ProfilePath, profileSize),
+		})
+	}
+	for _, c := range d.Credits.Crew {
+		if !tmdbCrewJobs[c.Job] {
+			continue
+		}
+		m.Crew = append(m.Crew, model.CrewCredit{
+			PersonID:   c.ID,
+			Name:       c.Name,
+			Job:        c.Job,
+			Department: c.Department,
+			ProfileURL: tmdb.ImageURL(c.ProfilePath, profileSize),
+		})
+	}
+
+	for _, v := range d.Videos.Results {
+		base, ok := videoURLs[v.Site]
+		if !ok {
+			continue
+		}
+		m.Videos = append(m.Videos, model.Video{
+			Name:     v.Name,
+			Type:     v.Type,
+			Official: v.Official,
+			URL:      base + v.Key,
+		})
+	}
+	if key := d.Videos.TrailerKey(); key != "" {
+		m.TrailerURL = videoURLs["YouTube"] + key
+	}
+
+	for _, country := range d.ReleaseDates.Results {
+		for _, r := range country.ReleaseDates {
+			m.ReleaseDates = append(m.ReleaseDates, model.ReleaseDate{
+				Country:       country.Country,
+				Date:          r.ReleaseDate,
+				Type:          r.Type.String(),
+				Certification: r.Certification,
+			})
+		}
+	}
+
+	return m
 }
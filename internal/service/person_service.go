@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/internal/tmdb"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+// detailCastLimit is how much of the cast a movie's detail response shows.
+const detailCastLimit = 10
+
+type PersonService struct {
+	people postgres.PersonRepo
+}
+
+func NewPersonService(people postgres.PersonRepo) *PersonService {
+	return &PersonService{people: people}
+}
+
+// GetPerson returns a person with their filmography in the local catalog.
+func (s *PersonService) GetPerson(ctx context.Context, id int) (model.PersonDetail, error) {
+	p, err := s.people.GetByID(ctx, id)
+	if err != nil {
+		return model.PersonDetail{}, err
+	}
+	films, err := s.people.Filmography(ctx, id)
+	if err != nil {
+		return model.PersonDetail{}, err
+	}
+	return model.PersonDetail{Person: p, Filmography: films}, nil
+}
+
+func (s *PersonService) SearchPeople(ctx context.Context, query string, opts model.ListOptions) ([]model.Person, int, error) {
+	return s.people.Search(ctx, query, opts)
+}
+
+// movieDetail adds the top-billed cast and the directors to m.
+func movieDetail(ctx context.Context, people postgres.PersonRepo, m model.Movie) (model.MovieDetail, error) {
+	credits, err := people.MovieCredits(ctx, m.ID)
+	if err != nil {
+		return model.MovieDetail{}, err
+	}
+
+	d := model.MovieDetail{Movie: m, Cast: []model.Credit{}, Directors: []model.Credit{}}
+	for _, c := range credits {
+		switch {
+		case c.Role == model.RoleCast && len(d.Cast) < detailCastLimit:
+			d.Cast = append(d.Cast, c)
+		case c.Role == model.RoleCrew && c.Job == "Director":
+			d.Directors = append(d.Directors, c)
+		}
+	}
+	return d, nil
+}
+
+// tmdbCredits picks the credits worth keeping from a TMDB movie: the
+// top-billed cast and the crew in tmdbCrewJobs, the same ones tmdbMovie
+// shows. Crew credits are ordered as TMDB lists them.
+func tmdbCredits(d tmdb.MovieDetails) []model.Credit {
+	cast := slices.Clone(d.Credits.Cast)
+	slices.SortStableFunc(cast, func(a, b tmdb.CastMember) int { return a.Order - b.Order })
+
+	credits := make([]model.Credit, 0, min(len(cast), tmdbCastLimit))
+	for _, c := range cast {
+		if len(credits) == tmdbCastLimit {
+			break
+		}
+		if c.ID == 0 || strings.TrimSpace(c.Name) == "" {
+			continue
+		}
+		credits = append(credits, model.Credit{
+			TMDBID:     c.ID,
+			Name:       strings.TrimSpace(c.Name),
+			ProfileURL: tmdb.ImageURL(c.ProfilePath, profileSize),
+			Role:       model.RoleCast,
+			Character:  c.Character,
+			Order:      c.Order,
+		})
+	}
+
+	order := 0
+	for _, c := range d.Credits.Crew {
+		if !tmdbCrewJobs[c.Job] || c.ID == 0 || strings.TrimSpace(c.Name) == "" {
+			continue
+		}
+		credits = append(credits, model.Credit{
+			TMDBID:     c.ID,
+			Name:       strings.TrimSpace(c.Name),
+			ProfileURL: tmdb.ImageURL(c.ProfilePath, profileSize),
+			Role:       model.RoleCrew,
+			Job:        c.Job,
+			Department: c.Department,
+			Order:      order,
+		})
+		order++
+	}
+	return credits
+}
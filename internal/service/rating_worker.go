@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+)
+
+const (
+	defaultRatingWorkers      = 2
+	defaultRatingPollInterval = 5 * time.Second
+)
+
+type RatingWorkerConfig struct {
+	Workers      int
+	PollInterval time.Duration
+}
+
+// RatingWorker recomputes movie ratings from the rating_jobs outbox. Review
+// and diary writes enqueue jobs in the same transaction, so no recomputation
+// is lost across restarts; Notify only wakes the workers early.
+type RatingWorker struct {
+	jobs         postgres.RatingJobRepo
+	workers      int
+	pollInterval time.Duration
+
+	wake   chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewRatingWorker(jobs postgres.RatingJobRepo, cfg RatingWorkerConfig) *RatingWorker {
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultRatingWorkers
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultRatingPollInterval
+	}
+
+	return &RatingWorker{
+		jobs:         jobs,
+		workers:      cfg.Workers,
+		pollInterval: cfg.PollInterval,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Notify wakes an idle worker. It never blocks.
+func (w *RatingWorker) Notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the worker pool until Stop is called or ctx is cancelled.
+func (w *RatingWorker) Start(ctx context.Context) {
+	ctx, w.cancel = context.WithCancel(ctx)
+	w.done = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx)
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(w.done)
+	}()
+}
+
+// Stop stops taking new wake-ups and drains the jobs that are already due,
+// until the queue is empty or ctx expires. A job that fails is logged and
+// left for a retry; the drain goes on with the others. Whatever is left
+// stays in the outbox for the next start.
+func (w *RatingWorker) Stop(ctx context.Context) error {
+	if w.cancel == nil {
+		return nil
+	}
+	w.cancel()
+
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return w.drain(ctx)
+}
+
+func (w *RatingWorker) loop(ctx context.Context) {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.drain(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("rating worker: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain processes due jobs until none are left. Failed jobs are logged and
+// skipped, as ProcessNext has put them back for a retry; any other error
+// stops the drain and is returned, as is ctx's once it is done.
+func (w *RatingWorker) drain(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		processed, err := w.jobs.ProcessNext(ctx)
+		if errors.Is(err, postgres.ErrRatingJobFailed) {
+			log.Printf("rating worker: %v", err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !processed {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+)
+
+// fakeRatingJobs hands out results in order, then reports an empty queue.
+type fakeRatingJobs struct {
+	mu      sync.Mutex
+	results []error
+	calls   int
+}
+
+func (f *fakeRatingJobs) ProcessNext(ctx context.Context) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.calls >= len(f.results) {
+		return false, nil
+	}
+	err := f.results[f.calls]
+	f.calls++
+	return true, err
+}
+
+func (f *fakeRatingJobs) Pending(ctx context.Context) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.results) - f.calls, nil
+}
+
+func TestRatingWorkerStopDrainsPastFailedJobs(t *testing.T) {
+	failed := fmt.Errorf("%w: movie 1: boom", postgres.ErrRatingJobFailed)
+	jobs := &fakeRatingJobs{results: []error{failed, nil, failed, nil}}
+	w := NewRatingWorker(jobs, RatingWorkerConfig{})
+	w.Start(context.Background())
+
+	if err := w.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if n, _ := jobs.Pending(context.Background()); n != 0 {
+		t.Errorf("%d jobs left undrained", n)
+	}
+}
+
+func TestRatingWorkerDrainStopsOnBrokenQueue(t *testing.T) {
+	broken := errors.New("connection refused")
+	jobs := &fakeRatingJobs{results: []error{broken, nil}}
+	w := NewRatingWorker(jobs, RatingWorkerConfig{})
+
+	if err := w.drain(context.Background()); !errors.Is(err, broken) {
+		t.Errorf("drain: got error %v, want %v", err, broken)
+	}
+	if n, _ := jobs.Pending(context.Background()); n != 1 {
+		t.Errorf("%d jobs left, want the one after the error", n)
+	}
+}
+
+func TestRatingWorkerDrainHonoursContext(t *testing.T) {
+	jobs := &fakeRatingJobs{results: []error{nil, nil}}
+	w := NewRatingWorker(jobs, RatingWorkerConfig{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := w.drain(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("drain: got error %v, want context.Canceled", err)
+	}
+}
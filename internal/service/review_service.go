@@ -1,8 +1,8 @@
 package service
 
 import (
+	"context"
 	"errors"
-	"fmt"
 
 	"github.com/AlikhanF2006/Final_project/internal/postgres"
 	"github.com/AlikhanF2006/Final_project/model"
@@ -14,32 +14,28 @@ var (
 )
 
 type ReviewService struct {
-	reviewRepo *postgres.ReviewRepository
-	movieRepo  *postgres.MovieRepository
-	ratingCh   chan int
+	reviewRepo postgres.ReviewRepo
+	movieRepo  postgres.MovieRepo
+	ratings    *RatingWorker
 }
 
+// NewReviewService takes the rating worker only to wake it: the repository
+// queues the recomputation, and publishes new reviews to the reviewer's
+// followers, together with each review change.
 func NewReviewService(
-	reviewRepo *postgres.ReviewRepository,
-	movieRepo *postgres.MovieRepository,
+	reviewRepo postgres.ReviewRepo,
+	movieRepo postgres.MovieRepo,
+	ratings *RatingWorker,
 ) *ReviewService {
 	return &ReviewService{
 		reviewRepo: reviewRepo,
 		movieRepo:  movieRepo,
-		ratingCh:   make(chan int, 10),
+		ratings:    ratings,
 	}
 }
 
-func (s *ReviewService) StartRatingWorker() {
-	go func() {
-		for movieID := range s.ratingCh {
-			s.recalculateRating(movieID)
-		}
-	}()
-}
-
-func (s *ReviewService) AddReview(movieID int, r model.Review) (model.Review, error) {
-	if _, err := s.movieRepo.GetByID(movieID); err != nil {
+func (s *ReviewService) AddReview(ctx context.Context, movieID int, r model.Review) (model.Review, error) {
+	if _, err := s.movieRepo.GetByID(ctx, movieID); err != nil {
 		return model.Review{}, err
 	}
 
@@ -47,23 +43,24 @@ func (s *ReviewService) AddReview(movieID int, r model.Review) (model.Review, er
 		return model.Review{}, ErrBadReviewData
 	}
 
-	created, err := s.reviewRepo.Add(movieID, r)
+	created, err := s.reviewRepo.Add(ctx, movieID, r)
 	if err != nil {
 		return model.Review{}, err
 	}
 
-	s.ratingCh <- movieID
+	s.ratings.Notify()
 	return created, nil
 }
 
-func (s *ReviewService) ListReviews(movieID int) ([]model.Review, error) {
-	if _, err := s.movieRepo.GetByID(movieID); err != nil {
-		return nil, err
+func (s *ReviewService) ListReviews(ctx context.Context, movieID int, opts model.ListOptions) ([]model.Review, int, error) {
+	if _, err := s.movieRepo.GetByID(ctx, movieID); err != nil {
+		return nil, 0, err
 	}
-	return s.reviewRepo.ListByMovieID(movieID)
+	return s.reviewRepo.PageByMovieID(ctx, movieID, opts)
 }
 
 func (s *ReviewService) UpdateReview(
+	ctx context.Context,
 	movieID int,
 	userID int,
 	newScore int,
@@ -73,55 +70,45 @@ func (s *ReviewService) UpdateReview(
 	}
 
 	if err := s.reviewRepo.UpdateByMovieAndUser(
+		ctx,
 		movieID,
 		userID,
 		newScore,
 	); err != nil {
-		return ErrForbidden
+		if errors.Is(err, postgres.ErrReviewNotFound) {
+			return ErrForbidden
+		}
+		return err
 	}
 
-	s.ratingCh <- movieID
+	s.ratings.Notify()
 	return nil
 }
 
 func (s *ReviewService) DeleteReview(
+	ctx context.Context,
 	movieID int,
 	userID int,
 ) error {
 	if err := s.reviewRepo.DeleteByMovieAndUser(
+		ctx,
 		movieID,
 		userID,
 	); err != nil {
-		return ErrForbidden
+		if errors.Is(err, postgres.ErrReviewNotFound) {
+			return ErrForbidden
+		}
+		return err
 	}
 
-	s.ratingCh <- movieID
+	s.ratings.Notify()
 	return nil
 }
 
-func (s *ReviewService) DeleteReviewByID(reviewID int) error {
-	rev, err := s.reviewRepo.GetByID(reviewID)
-	if err != nil {
-		return fmt.Errorf("review not found")
+func (s *ReviewService) DeleteReviewByID(ctx context.Context, reviewID int) error {
+	if err := s.reviewRepo.DeleteByID(ctx, reviewID); err != nil {
+		return err
 	}
-	if err := s.reviewRepo.DeleteByID(reviewID); err != nil {
-		return fmt.Errorf("cannot delete review")
-	}
-	s.ratingCh <- rev.MovieID
+	s.ratings.Notify()
 	return nil
 }
-
-func (s *ReviewService) recalculateRating(movieID int) {
-	revs, err := s.reviewRepo.ListByMovieID(movieID)
-	if err != nil || len(revs) == 0 {
-		_ = s.movieRepo.SetRating(movieID, 0)
-		return
-	}
-
-	sum := 0
-	for _, r := range revs {
-		sum += r.Score
-	}
-	avg := float64(sum) / float64(len(revs))
-	_ = s.movieRepo.SetRating(movieID, avg)
-}
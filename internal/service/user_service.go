@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"time"
 
@@ -11,27 +12,35 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-var ErrBadCredentials = errors.New("invalid credentials")
+var (
+	ErrBadCredentials = errors.New("invalid credentials")
+	ErrUnknownRole    = errors.New("unknown role")
+)
 
 type UserService struct {
-	repo *postgres.UserRepository
+	repo    postgres.UserRepo
+	policy  *auth.Policy
+	authSvc *AuthService
+	ratings *RatingWorker
 }
 
-func NewUserService(r *postgres.UserRepository) *UserService {
-	return &UserService{repo: r}
+// NewUserService takes the rating worker only to wake it: deleting a user
+// queues a recomputation for every movie they scored.
+func NewUserService(r postgres.UserRepo, policy *auth.Policy, authSvc *AuthService, ratings *RatingWorker) *UserService {
+	return &UserService{repo: r, policy: policy, authSvc: authSvc, ratings: ratings}
 }
 
-func (s *UserService) Register(req dto.RegisterDTO) (dto.UserDTO, error) {
+func (s *UserService) Register(ctx context.Context, req dto.RegisterDTO) (dto.UserDTO, error) {
 	hash, _ := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 
 	user := model.User{
 		Username:     req.Username,
 		Email:        req.Email,
 		PasswordHash: string(hash),
-		Role:         "user",
+		Role:         auth.RoleUser,
 	}
 
-	created, err := s.repo.Create(user)
+	created, err := s.repo.Create(ctx, user)
 	if err != nil {
 		return dto.UserDTO{}, err
 	}
@@ -39,32 +48,16 @@ func (s *UserService) Register(req dto.RegisterDTO) (dto.UserDTO, error) {
 	return toUserDTO(created), nil
 }
 
-func (s *UserService) Login(req dto.LoginDTO) (string, error) {
-	user, err := s.repo.GetByEmail(req.Email)
-	if err != nil {
-		return "", ErrBadCredentials
-	}
-
-	if bcrypt.CompareHashAndPassword(
-		[]byte(user.PasswordHash),
-		[]byte(req.Password),
-	) != nil {
-		return "", ErrBadCredentials
-	}
-
-	return auth.GenerateToken(user.ID)
-}
-
-func (s *UserService) GetProfile(id int) (dto.UserDTO, error) {
-	u, err := s.repo.GetByID(id)
+func (s *UserService) GetProfile(ctx context.Context, id int) (dto.UserDTO, error) {
+	u, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return dto.UserDTO{}, err
 	}
 	return toUserDTO(u), nil
 }
 
-func (s *UserService) UpdateProfile(id int, req dto.UpdateProfileDTO) (dto.UserDTO, error) {
-	u, err := s.repo.GetByID(id)
+func (s *UserService) UpdateProfile(ctx context.Context, id int, req dto.UpdateProfileDTO) (dto.UserDTO, error) {
+	u, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return dto.UserDTO{}, err
 	}
@@ -76,7 +69,7 @@ func (s *UserService) UpdateProfile(id int, req dto.UpdateProfileDTO) (dto.UserD
 		u.Email = req.Email
 	}
 
-	updated, err := s.repo.Update(u)
+	updated, err := s.repo.Update(ctx, u)
 	if err != nil {
 		return dto.UserDTO{}, err
 	}
@@ -84,20 +77,51 @@ func (s *UserService) UpdateProfile(id int, req dto.UpdateProfileDTO) (dto.UserD
 	return toUserDTO(updated), nil
 }
 
-func (s *UserService) ChangePassword(id int, newPassword string) error {
+// ChangePassword ends every existing session of the user and returns a
+// fresh token pair for the caller.
+func (s *UserService) ChangePassword(ctx context.Context, id int, newPassword string) (dto.TokenResponse, error) {
 	if len(newPassword) < 6 {
-		return errors.New("password too short")
+		return dto.TokenResponse{}, errors.New("password too short")
 	}
 	hash, _ := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
-	return s.repo.UpdatePassword(id, string(hash))
+	if err := s.repo.UpdatePassword(ctx, id, string(hash)); err != nil {
+		return dto.TokenResponse{}, err
+	}
+	if err := s.authSvc.RevokeAll(ctx, id); err != nil {
+		return dto.TokenResponse{}, err
+	}
+
+	u, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return dto.TokenResponse{}, err
+	}
+	return s.authSvc.IssueTokens(ctx, u)
+}
+
+func (s *UserService) DeleteAccount(ctx context.Context, id int) error {
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.ratings.Notify()
+	return nil
 }
 
-func (s *UserService) DeleteAccount(id int) error {
-	return s.repo.Delete(id)
+func (s *UserService) AdminDeleteUser(ctx context.Context, id int) error {
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.ratings.Notify()
+	return nil
 }
 
-func (s *UserService) AdminDeleteUser(id int) error {
-	return s.repo.Delete(id)
+func (s *UserService) SetRole(ctx context.Context, id int, role string) (dto.UserDTO, error) {
+	if !s.policy.HasRole(role) {
+		return dto.UserDTO{}, ErrUnknownRole
+	}
+	if err := s.repo.UpdateRole(ctx, id, role); err != nil {
+		return dto.UserDTO{}, err
+	}
+	return s.GetProfile(ctx, id)
 }
 
 func toUserDTO(u model.User) dto.UserDTO {
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+var ErrBadWatchlistData = errors.New("invalid watchlist data")
+
+const maxWatchlistNote = 1000
+
+type WatchlistService struct {
+	watchlist postgres.WatchlistRepo
+	movies    postgres.MovieRepo
+	genres    postgres.GenreRepo
+	importer  *MovieImporter
+}
+
+func NewWatchlistService(
+	watchlist postgres.WatchlistRepo,
+	movies postgres.MovieRepo,
+	genres postgres.GenreRepo,
+	importer *MovieImporter,
+) *WatchlistService {
+	return &WatchlistService{
+		watchlist: watchlist,
+		movies:    movies,
+		genres:    genres,
+		importer:  importer,
+	}
+}
+
+// WatchlistUpdate changes the fields that are set; an empty PlannedFor
+// clears the date.
+type WatchlistUpdate struct {
+	Note       *string
+	PlannedFor *string
+	Position   *int
+}
+
+// ListWatchlist returns the user's watchlist. With dueOnly it keeps the
+// entries whose planned date has come.
+func (s *WatchlistService) ListWatchlist(ctx context.Context, userID int, f model.MovieFilter, dueOnly bool, opts model.ListOptions) ([]model.WatchlistEntry, int, error) {
+	wf := model.WatchlistFilter{MovieFilter: f}
+	if dueOnly {
+		wf.DueBy = today()
+	}
+	entries, total, err := s.watchlist.List(ctx, userID, wf, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, s.finish(ctx, entries)
+}
+
+// AddToWatchlist adds e.Movie, given by id or by TMDB id, to the end of the
+// user's watchlist. A TMDB movie not in the catalog yet is imported first.
+func (s *WatchlistService) AddToWatchlist(ctx context.Context, userID int, e model.WatchlistEntry) (model.WatchlistEntry, error) {
+	if (e.Movie.ID == 0) == (e.Movie.TMDBID == 0) {
+		return model.WatchlistEntry{}, ErrBadWatchlistData
+	}
+	var err error
+	if e.Note, e.PlannedFor, err = watchlistFields(e.Note, e.PlannedFor); err != nil {
+		return model.WatchlistEntry{}, err
+	}
+
+	if e.Movie.ID == 0 {
+		m, err := s.movies.GetByTMDBID(ctx, e.Movie.TMDBID)
+		if errors.Is(err, postgres.ErrMovieNotFound) {
+			m, err = s.importer.ImportMovie(ctx, e.Movie.TMDBID)
+		}
+		if err != nil {
+			return model.WatchlistEntry{}, err
+		}
+		e.Movie = m
+	}
+
+	added, err := s.watchlist.Add(ctx, userID, e)
+	if err != nil {
+		return model.WatchlistEntry{}, err
+	}
+	return s.finishOne(ctx, added)
+}
+
+// UpdateWatchlistEntry applies upd to the user's entry for movieID.
+func (s *WatchlistService) UpdateWatchlistEntry(ctx context.Context, userID, movieID int, upd WatchlistUpdate) (model.WatchlistEntry, error) {
+	e, err := s.watchlist.Get(ctx, userID, movieID)
+	if err != nil {
+		return model.WatchlistEntry{}, err
+	}
+
+	if upd.Note != nil || upd.PlannedFor != nil {
+		if upd.Note != nil {
+			e.Note = *upd.Note
+		}
+		if upd.PlannedFor != nil {
+			e.PlannedFor = *upd.PlannedFor
+		}
+		if e.Note, e.PlannedFor, err = watchlistFields(e.Note, e.PlannedFor); err != nil {
+			return model.WatchlistEntry{}, err
+		}
+		if e, err = s.watchlist.Update(ctx, userID, e); err != nil {
+			return model.WatchlistEntry{}, err
+		}
+	}
+	if upd.Position != nil {
+		if *upd.Position < 1 {
+			return model.WatchlistEntry{}, ErrBadWatchlistData
+		}
+		if e, err = s.watchlist.Move(ctx, userID, movieID, *upd.Position); err != nil {
+			return model.WatchlistEntry{}, err
+		}
+	}
+	return s.finishOne(ctx, e)
+}
+
+func (s *WatchlistService) RemoveFromWatchlist(ctx context.Context, userID, movieID int) error {
+	return s.watchlist.Remove(ctx, userID, movieID)
+}
+
+// finish fills in the movies' genres and the due flags.
+func (s *WatchlistService) finish(ctx context.Context, entries []model.WatchlistEntry) error {
+	movies := make([]model.Movie, len(entries))
+	for i, e := range entries {
+		movies[i] = e.Movie
+	}
+	if err := attachGenres(ctx, s.genres, movies); err != nil {
+		return err
+	}
+
+	now := today()
+	for i := range entries {
+		entries[i].Movie = movies[i]
+		entries[i].Due = entries[i].PlannedFor != "" && entries[i].PlannedFor <= now
+	}
+	return nil
+}
+
+func (s *WatchlistService) finishOne(ctx context.Context, e model.WatchlistEntry) (model.WatchlistEntry, error) {
+	entries := []model.WatchlistEntry{e}
+	if err := s.finish(ctx, entries); err != nil {
+		return model.WatchlistEntry{}, err
+	}
+	return entries[0], nil
+}
+
+// watchlistFields checks an entry's note and planned date, returning the
+// date in YYYY-MM-DD form.
+func watchlistFields(note, plannedFor string) (string, string, error) {
+	if len(note) > maxWatchlistNote {
+		return "", "", ErrBadWatchlistData
+	}
+	if plannedFor == "" {
+		return note, "", nil
+	}
+	d, err := time.Parse(time.DateOnly, plannedFor)
+	if err != nil {
+		return "", "", ErrBadWatchlistData
+	}
+	return note, d.Format(time.DateOnly), nil
+}
+
+// today is the current date in UTC, which planned dates are compared to.
+func today() string {
+	return time.Now().UTC().Format(time.DateOnly)
+}
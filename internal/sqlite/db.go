@@ -0,0 +1,127 @@
+// Package sqlite is a storage backend on an embedded SQLite database, using
+// the pure-Go modernc.org/sqlite driver. It implements the repository
+// interfaces from internal/postgres with the same errors, uniqueness rules,
+// cascading deletes and search behaviour, for running the server without a
+// Postgres instance.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/AlikhanF2006/Final_project/configs"
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/internal/textsearch"
+)
+
+var ErrEmptyPath = errors.New("sqlite path is empty")
+
+const defaultBusyTimeout = 5 * time.Second
+
+func init() {
+	// word_similarity(query, title) stands in for the pg_trgm function of the
+	// same name in the search query.
+	sqlite.MustRegisterDeterministicScalarFunction("word_similarity", 2,
+		func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			query, _ := args[0].(string)
+			title, _ := args[1].(string)
+			return textsearch.WordSimilarity(query, title), nil
+		},
+	)
+}
+
+// DB is the database handle shared by the repositories.
+type DB struct {
+	*sql.DB
+}
+
+// Open opens (creating if needed) the database file at cfg.Path and applies
+// the pending migrations. Every connection enforces foreign keys, so deletes
+// cascade as they do in Postgres.
+func Open(ctx context.Context, cfg configs.SQLiteConfig) (*DB, error) {
+	if cfg.Path == "" {
+		return nil, ErrEmptyPath
+	}
+	if dir := filepath.Dir(cfg.Path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("create sqlite directory: %w", err)
+		}
+	}
+
+	busy := cfg.BusyTimeout
+	if busy <= 0 {
+		busy = defaultBusyTimeout
+	}
+
+	q := url.Values{}
+	q.Add("_pragma", "foreign_keys(1)")
+	q.Add("_pragma", "journal_mode(WAL)")
+	q.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busy.Milliseconds()))
+	q.Set("_time_format", "sqlite")
+	q.Set("_txlock", "immediate")
+
+	sqlDB, err := sql.Open("sqlite", "file:"+cfg.Path+"?"+q.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+
+	d := &DB{DB: sqlDB}
+	if err := d.migrate(ctx); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("migrate sqlite: %w", err)
+	}
+	return d, nil
+}
+
+// Ping checks that the database file is usable.
+func (d *DB) Ping(ctx context.Context) error {
+	return d.DB.PingContext(ctx)
+}
+
+func (d *DB) Close() {
+	if d == nil || d.DB == nil {
+		return
+	}
+	if err := d.DB.Close(); err != nil {
+		log.Printf("sqlite: close: %v", err)
+	}
+}
+
+// violates reports whether err is an SQLite constraint failure with the given
+// extended result code.
+func violates(err error, code int) bool {
+	var e *sqlite.Error
+	return errors.As(err, &e) && e.Code() == code
+}
+
+func isUniqueViolation(err error) bool {
+	return violates(err, sqlite3.SQLITE_CONSTRAINT_UNIQUE)
+}
+
+var (
+	_ postgres.MovieRepo     = (*MovieRepository)(nil)
+	_ postgres.ReviewRepo    = (*ReviewRepository)(nil)
+	_ postgres.UserRepo      = (*UserRepository)(nil)
+	_ postgres.RoleRepo      = (*RoleRepository)(nil)
+	_ postgres.TokenRepo     = (*TokenRepository)(nil)
+	_ postgres.RatingJobRepo = (*RatingJobRepository)(nil)
+	_ postgres.SyncRunRepo   = (*SyncRunRepository)(nil)
+	_ postgres.ExportRepo    = (*ExportRepository)(nil)
+	_ postgres.GenreRepo     = (*GenreRepository)(nil)
+	_ postgres.PersonRepo    = (*PersonRepository)(nil)
+	_ postgres.WatchlistRepo = (*WatchlistRepository)(nil)
+	_ postgres.DiaryRepo     = (*DiaryRepository)(nil)
+	_ postgres.MovieListRepo = (*MovieListRepository)(nil)
+	_ postgres.FeedRepo      = (*FeedRepository)(nil)
+)
@@ -0,0 +1,213 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+const diaryColumns = `d.id, d.watched_on, d.rewatch, d.score, d.note, d.created_at,
+	movies.id, movies.tmdb_id, movies.title, movies.year, movies.description, movies.rating, movies.overrides`
+
+const diaryFrom = `
+	FROM diary_entries d
+	JOIN movies ON movies.id = d.movie_id`
+
+// diaryFields returns scan targets for diaryColumns.
+func diaryFields(e *model.DiaryEntry) []any {
+	return append([]any{&e.ID, &e.WatchedOn, &e.Rewatch, &e.Score, &e.Note, &e.CreatedAt}, movieFields(&e.Movie)...)
+}
+
+type DiaryRepository struct {
+	db *DB
+}
+
+func NewDiaryRepository(database *DB) *DiaryRepository {
+	return &DiaryRepository{db: database}
+}
+
+func (r *DiaryRepository) List(ctx context.Context, userID int, f model.DiaryFilter, opts model.ListOptions) ([]model.DiaryEntry, int, error) {
+	order, err := qualifiedOrder(diarySortColumns, "watched", "d.id", opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	limit, offset := pageLimit(opts)
+
+	where := ""
+	args := []any{userID}
+	if f.MovieID != 0 {
+		args = append(args, f.MovieID)
+		where += fmt.Sprintf("\n\t\t  AND d.movie_id = ?%d", len(args))
+	}
+	if f.From != "" {
+		args = append(args, f.From)
+		where += fmt.Sprintf("\n\t\t  AND d.watched_on >= ?%d", len(args))
+	}
+	if f.To != "" {
+		args = append(args, f.To)
+		where += fmt.Sprintf("\n\t\t  AND d.watched_on <= ?%d", len(args))
+	}
+	from := diaryFrom + `
+		WHERE d.user_id = ?1` + where
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*)`+from, args...).Scan(&total); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT `+diaryColumns+from+`
+		ORDER BY `+order+
+			fmt.Sprintf(` LIMIT ?%d OFFSET ?%d`, len(args)+1, len(args)+2),
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, db.Classify(err)
+	}
+	defer rows.Close()
+
+	entries := make([]model.DiaryEntry, 0)
+	for rows.Next() {
+		var e model.DiaryEntry
+		if err := rows.Scan(diaryFields(&e)...); err != nil {
+			return nil, 0, db.Classify(err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, db.Classify(rows.Err())
+}
+
+func (r *DiaryRepository) Get(ctx context.Context, userID, id int) (model.DiaryEntry, error) {
+	var e model.DiaryEntry
+	err := r.db.QueryRowContext(
+		ctx,
+		`SELECT `+diaryColumns+diaryFrom+`
+		WHERE d.user_id = ? AND d.id = ?`,
+		userID,
+		id,
+	).Scan(diaryFields(&e)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.DiaryEntry{}, postgres.ErrDiaryEntryNotFound
+	}
+	return e, db.Classify(err)
+}
+
+// Add checks for the movie up front: SQLite reports foreign key failures
+// without naming the constraint.
+func (r *DiaryRepository) Add(ctx context.Context, userID int, e model.DiaryEntry) (model.DiaryEntry, error) {
+	var id int
+	err := withRatingJob(ctx, r.db, func(ctx context.Context, tx *sql.Tx) (int, error) {
+		var exists bool
+		if err := tx.QueryRowContext(
+			ctx,
+			`SELECT EXISTS (SELECT 1 FROM movies WHERE id = ?)`,
+			e.Movie.ID,
+		).Scan(&exists); err != nil {
+			return 0, err
+		}
+		if !exists {
+			return 0, postgres.ErrMovieNotFound
+		}
+
+		if err := tx.QueryRowContext(
+			ctx,
+			`INSERT INTO diary_entries (user_id, movie_id, watched_on, rewatch, score, note, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			RETURNING id`,
+			userID,
+			e.Movie.ID,
+			e.WatchedOn,
+			e.Rewatch,
+			e.Score,
+			e.Note,
+			time.Now().UTC(),
+		).Scan(&id); err != nil {
+			return 0, err
+		}
+		return e.Movie.ID, publishActivity(ctx, tx, model.ActivityDiary, userID, model.ActivitySubject{DiaryEntryID: id})
+	})
+	if err != nil {
+		return model.DiaryEntry{}, err
+	}
+
+	return r.Get(ctx, userID, id)
+}
+
+func (r *DiaryRepository) Update(ctx context.Context, userID int, e model.DiaryEntry) (model.DiaryEntry, error) {
+	err := withRatingJob(ctx, r.db, func(ctx context.Context, tx *sql.Tx) (int, error) {
+		var movieID int
+		err := tx.QueryRowContext(
+			ctx,
+			`UPDATE diary_entries SET watched_on = ?, rewatch = ?, score = ?, note = ?
+			WHERE user_id = ? AND id = ?
+			RETURNING movie_id`,
+			e.WatchedOn,
+			e.Rewatch,
+			e.Score,
+			e.Note,
+			userID,
+			e.ID,
+		).Scan(&movieID)
+		return movieID, err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.DiaryEntry{}, postgres.ErrDiaryEntryNotFound
+	}
+	if err != nil {
+		return model.DiaryEntry{}, err
+	}
+
+	return r.Get(ctx, userID, e.ID)
+}
+
+func (r *DiaryRepository) Delete(ctx context.Context, userID, id int) error {
+	err := withRatingJob(ctx, r.db, func(ctx context.Context, tx *sql.Tx) (int, error) {
+		var movieID int
+		err := tx.QueryRowContext(
+			ctx,
+			`DELETE FROM diary_entries WHERE user_id = ? AND id = ? RETURNING movie_id`,
+			userID,
+			id,
+		).Scan(&movieID)
+		return movieID, err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return postgres.ErrDiaryEntryNotFound
+	}
+	return err
+}
+
+func (r *DiaryRepository) Days(ctx context.Context, userID int, from, to string) ([]model.DiaryDay, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT watched_on, COUNT(*)
+		FROM diary_entries
+		WHERE user_id = ? AND watched_on BETWEEN ? AND ?
+		GROUP BY watched_on
+		ORDER BY watched_on`,
+		userID,
+		from,
+		to,
+	)
+	if err != nil {
+		return nil, db.Classify(err)
+	}
+	defer rows.Close()
+
+	days := make([]model.DiaryDay, 0)
+	for rows.Next() {
+		var d model.DiaryDay
+		if err := rows.Scan(&d.Date, &d.Watches); err != nil {
+			return nil, db.Classify(err)
+		}
+		days = append(days, d)
+	}
+	return days, db.Classify(rows.Err())
+}
@@ -0,0 +1,144 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/AlikhanF2006/Final_project/internal/textsearch"
+	"github.com/AlikhanF2006/Final_project/model"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+// ExportRepository streams export rows straight from the statement. In WAL
+// mode an open read does not block writers, so unlike Postgres there is no
+// need for a cursor.
+type ExportRepository struct {
+	db *DB
+}
+
+func NewExportRepository(database *DB) *ExportRepository {
+	return &ExportRepository{db: database}
+}
+
+func (r *ExportRepository) ExportMovies(ctx context.Context, f model.ExportFilter, fn func(model.Movie) error) error {
+	order, err := orderBy(movieSortColumns, model.ListOptions{Sort: f.Sort, Desc: f.Desc})
+	if err != nil {
+		return err
+	}
+	ids, args := exportMovieIDs(f)
+
+	return r.stream(ctx,
+		`SELECT `+movieColumns+`
+		FROM movies
+		WHERE id IN (`+ids+`)
+		ORDER BY `+order,
+		args,
+		func(rows *sql.Rows) error {
+			var m model.Movie
+			if err := rows.Scan(movieFields(&m)...); err != nil {
+				return db.Classify(err)
+			}
+			return fn(m)
+		},
+	)
+}
+
+func (r *ExportRepository) ExportReviews(ctx context.Context, f model.ExportFilter, fn func(model.ExportedReview) error) error {
+	order, err := orderBy(reviewSortColumns, model.ListOptions{Sort: f.Sort, Desc: f.Desc})
+	if err != nil {
+		return err
+	}
+	ids, args := exportMovieIDs(f, f.UserID)
+
+	// ORDER BY names resolve to the result columns first, so the aliases
+	// keep id and created_at from being ambiguous across the join.
+	return r.stream(ctx,
+		`SELECT r.id AS id, r.movie_id, m.tmdb_id, m.title, r.user_id, u.username,
+		        r.score AS score, r.text, r.created_at AS created_at
+		FROM reviews r
+		JOIN movies m ON m.id = r.movie_id
+		JOIN users u ON u.id = r.user_id
+		WHERE r.movie_id IN (`+ids+`)
+		  AND (?4 = 0 OR r.user_id = ?4)
+		ORDER BY `+order,
+		args,
+		func(rows *sql.Rows) error {
+			var e model.ExportedReview
+			if err := rows.Scan(&e.ID, &e.MovieID, &e.TMDBID, &e.MovieTitle, &e.UserID, &e.Username, &e.Score, &e.Text, &e.CreatedAt); err != nil {
+				return db.Classify(err)
+			}
+			return fn(e)
+		},
+	)
+}
+
+func (r *ExportRepository) ExportRatings(ctx context.Context, f model.ExportFilter, fn func(model.MovieRating) error) error {
+	order, err := orderBy(movieSortColumns, model.ListOptions{Sort: f.Sort, Desc: f.Desc})
+	if err != nil {
+		return err
+	}
+	ids, args := exportMovieIDs(f)
+
+	return r.stream(ctx,
+		`SELECT id, tmdb_id, title, year, rating,
+		        coalesce(s.reviews, 0), coalesce(s.average, 0),
+		        coalesce(s.min_score, 0), coalesce(s.max_score, 0)
+		FROM movies
+		LEFT JOIN (
+			SELECT movie_id, COUNT(*) AS reviews, AVG(score) AS average,
+			       MIN(score) AS min_score, MAX(score) AS max_score
+			FROM reviews
+			GROUP BY movie_id
+		) AS s ON s.movie_id = movies.id
+		WHERE id IN (`+ids+`)
+		ORDER BY `+order,
+		args,
+		func(rows *sql.Rows) error {
+			var mr model.MovieRating
+			if err := rows.Scan(&mr.MovieID, &mr.TMDBID, &mr.Title, &mr.Year, &mr.Rating,
+				&mr.Reviews, &mr.AverageScore, &mr.MinScore, &mr.MaxScore); err != nil {
+				return db.Classify(err)
+			}
+			return fn(mr)
+		},
+	)
+}
+
+// exportMovieIDs returns a subquery of the ids of the movies f selects, with
+// the movie search's semantics, and its arguments: ?1 to ?3 for the search,
+// then args, then the movie filter's values.
+func exportMovieIDs(f model.ExportFilter, args ...any) (string, []any) {
+	query := strings.TrimSpace(f.Query)
+	fts, ftsQuery := ftsMatch(textsearch.Terms(query))
+
+	args = append([]any{query, ftsQuery, textsearch.SimilarityThreshold}, args...)
+	args = append(args, f.MovieID)
+	movieID := len(args)
+	where, args := movieFilterSQL(f.MovieFilter, args)
+
+	return `SELECT movies.id
+		FROM movies
+		LEFT JOIN (` + fts + `) AS fts ON fts.id = movies.id
+		WHERE (?1 = '' OR fts.id IS NOT NULL OR word_similarity(?1, movies.title) >= ?3)
+		  AND (?` + strconv.Itoa(movieID) + ` = 0 OR movies.id = ?` + strconv.Itoa(movieID) + `)` + where,
+		args
+}
+
+// stream runs query and calls scan for each row; scan's errors are returned
+// as they are.
+func (r *ExportRepository) stream(ctx context.Context, query string, args []any, scan func(*sql.Rows) error) error {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return db.Classify(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return db.Classify(rows.Err())
+}
@@ -0,0 +1,279 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+type FeedRepository struct {
+	db *DB
+}
+
+func NewFeedRepository(database *DB) *FeedRepository {
+	return &FeedRepository{db: database}
+}
+
+// Follow is idempotent; following a user again does not backfill twice.
+// It checks for both users up front: SQLite reports foreign key failures
+// without naming the constraint.
+func (r *FeedRepository) Follow(ctx context.Context, followerID, followeeID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return db.Classify(err)
+	}
+	defer tx.Rollback()
+
+	for _, id := range []int{followerID, followeeID} {
+		if err := mustExist(ctx, tx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = ?)`, id, postgres.ErrUserNotFound); err != nil {
+			return err
+		}
+	}
+
+	res, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO follows (follower_id, followee_id, created_at) VALUES (?, ?, ?)
+		ON CONFLICT DO NOTHING`,
+		followerID,
+		followeeID,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return db.Classify(err)
+	}
+
+	if n, _ := res.RowsAffected(); n > 0 {
+		if _, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO feed_items (user_id, activity_id)
+			SELECT ?, id FROM activities
+			WHERE actor_id = ?
+			ORDER BY id DESC
+			LIMIT ?
+			ON CONFLICT DO NOTHING`,
+			followerID,
+			followeeID,
+			model.FeedBackfill,
+		); err != nil {
+			return db.Classify(err)
+		}
+	}
+	return db.Classify(tx.Commit())
+}
+
+func (r *FeedRepository) Unfollow(ctx context.Context, followerID, followeeID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return db.Classify(err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(
+		ctx,
+		`DELETE FROM follows WHERE follower_id = ? AND followee_id = ?`,
+		followerID,
+		followeeID,
+	)
+	if err != nil {
+		return db.Classify(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return postgres.ErrNotFollowing
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`DELETE FROM feed_items
+		WHERE user_id = ? AND activity_id IN (SELECT id FROM activities WHERE actor_id = ?)`,
+		followerID,
+		followeeID,
+	); err != nil {
+		return db.Classify(err)
+	}
+	return db.Classify(tx.Commit())
+}
+
+func (r *FeedRepository) Followers(ctx context.Context, userID int, opts model.ListOptions) ([]model.Follow, int, error) {
+	return r.follows(ctx, "f.follower_id", "f.followee_id", userID, opts)
+}
+
+func (r *FeedRepository) Following(ctx context.Context, userID int, opts model.ListOptions) ([]model.Follow, int, error) {
+	return r.follows(ctx, "f.followee_id", "f.follower_id", userID, opts)
+}
+
+// follows lists the users in column other of the follows where column self
+// is userID.
+func (r *FeedRepository) follows(ctx context.Context, other, self string, userID int, opts model.ListOptions) ([]model.Follow, int, error) {
+	order, err := qualifiedOrder(followSortColumns, "followed", "u.id", opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	limit, offset := pageLimit(opts)
+
+	from := `
+		FROM follows f
+		JOIN users u ON u.id = ` + other + `
+		WHERE ` + self + ` = ?`
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*)`+from, userID).Scan(&total); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT u.id, u.username, f.created_at`+from+`
+		ORDER BY `+order+`
+		LIMIT ? OFFSET ?`,
+		userID,
+		limit,
+		offset,
+	)
+	if err != nil {
+		return nil, 0, db.Classify(err)
+	}
+	defer rows.Close()
+
+	follows := make([]model.Follow, 0)
+	for rows.Next() {
+		var f model.Follow
+		if err := rows.Scan(&f.UserID, &f.Username, &f.Since); err != nil {
+			return nil, 0, db.Classify(err)
+		}
+		follows = append(follows, f)
+	}
+	return follows, total, db.Classify(rows.Err())
+}
+
+// publishActivity records an activity and copies it into the feed of
+// everyone following the actor. Like enqueueRating, it runs in the caller's
+// transaction, so the activity is stored if and only if the write it
+// reports is.
+func publishActivity(ctx context.Context, tx *sql.Tx, kind string, userID int, s model.ActivitySubject) error {
+	var id int
+	if err := tx.QueryRowContext(
+		ctx,
+		`INSERT INTO activities (actor_id, kind, review_id, diary_entry_id, list_id, list_entry_id, created_at)
+		VALUES (?, ?, nullif(?, 0), nullif(?, 0), nullif(?, 0), nullif(?, 0), ?)
+		RETURNING id`,
+		userID,
+		kind,
+		s.ReviewID,
+		s.DiaryEntryID,
+		s.ListID,
+		s.ListEntryID,
+		time.Now().UTC(),
+	).Scan(&id); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO feed_items (user_id, activity_id)
+		SELECT follower_id, ?2 FROM follows WHERE followee_id = ?1`,
+		userID,
+		id,
+	)
+	return err
+}
+
+// Feed returns up to limit activities from userID's feed older than before,
+// newest first. Activities on lists that are not public are left out.
+func (r *FeedRepository) Feed(ctx context.Context, userID, before, limit int) ([]model.Activity, error) {
+	if before <= 0 {
+		before = math.MaxInt64
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT a.id, a.kind, a.actor_id, u.username, a.created_at,
+			coalesce(rev.movie_id, d.movie_id, e.movie_id, 0),
+			coalesce(rev.score, d.score), coalesce(rev.text, ''),
+			coalesce(d.watched_on, ''), coalesce(d.rewatch, 0),
+			coalesce(l.id, 0), coalesce(l.title, '')
+		FROM feed_items f
+		JOIN activities a ON a.id = f.activity_id
+		JOIN users u ON u.id = a.actor_id
+		LEFT JOIN reviews rev ON rev.id = a.review_id
+		LEFT JOIN diary_entries d ON d.id = a.diary_entry_id
+		LEFT JOIN movie_list_entries e ON e.id = a.list_entry_id
+		LEFT JOIN movie_lists l ON l.id = a.list_id
+		WHERE f.user_id = ? AND f.activity_id < ?
+		  AND (a.list_id IS NULL OR l.visibility = ?)
+		ORDER BY f.activity_id DESC
+		LIMIT ?`,
+		userID,
+		before,
+		model.ListPublic,
+		limit,
+	)
+	if err != nil {
+		return nil, db.Classify(err)
+	}
+	defer rows.Close()
+
+	activities := make([]model.Activity, 0)
+	var args []any
+	for rows.Next() {
+		var (
+			a       model.Activity
+			movieID int
+			list    model.ListRef
+		)
+		if err := rows.Scan(
+			&a.ID, &a.Kind, &a.UserID, &a.Username, &a.CreatedAt,
+			&movieID, &a.Score, &a.Text, &a.WatchedOn, &a.Rewatch, &list.ID, &list.Title,
+		); err != nil {
+			return nil, db.Classify(err)
+		}
+		if movieID != 0 {
+			a.Movie = &model.Movie{ID: movieID}
+			args = append(args, movieID)
+		}
+		if list.ID != 0 {
+			a.List = &list
+		}
+		activities = append(activities, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, db.Classify(err)
+	}
+	rows.Close()
+	if len(args) == 0 {
+		return activities, nil
+	}
+
+	rows, err = r.db.QueryContext(
+		ctx,
+		`SELECT `+movieColumns+` FROM movies WHERE id IN (?`+strings.Repeat(", ?", len(args)-1)+`)`,
+		args...,
+	)
+	if err != nil {
+		return nil, db.Classify(err)
+	}
+	defer rows.Close()
+
+	byID := make(map[int]model.Movie, len(args))
+	for rows.Next() {
+		var m model.Movie
+		if err := rows.Scan(movieFields(&m)...); err != nil {
+			return nil, db.Classify(err)
+		}
+		byID[m.ID] = m
+	}
+	if err := rows.Err(); err != nil {
+		return nil, db.Classify(err)
+	}
+	for _, a := range activities {
+		if a.Movie != nil {
+			*a.Movie = byID[a.Movie.ID]
+		}
+	}
+	return activities, nil
+}
@@ -0,0 +1,250 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+type GenreRepository struct {
+	db *DB
+}
+
+func NewGenreRepository(database *DB) *GenreRepository {
+	return &GenreRepository{db: database}
+}
+
+func (r *GenreRepository) List(ctx context.Context) ([]model.Genre, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT g.id, coalesce(g.tmdb_id, 0), g.name, COUNT(mg.movie_id)
+		FROM genres g
+		LEFT JOIN movie_genres mg ON mg.genre_id = g.id
+		GROUP BY g.id
+		ORDER BY lower(g.name), g.id`,
+	)
+	if err != nil {
+		return nil, db.Classify(err)
+	}
+	defer rows.Close()
+
+	genres := make([]model.Genre, 0)
+	for rows.Next() {
+		var g model.Genre
+		if err := rows.Scan(&g.ID, &g.TMDBID, &g.Name, &g.Movies); err != nil {
+			return nil, db.Classify(err)
+		}
+		genres = append(genres, g)
+	}
+	return genres, db.Classify(rows.Err())
+}
+
+func (r *GenreRepository) GetByID(ctx context.Context, id int) (model.Genre, error) {
+	return r.getOne(ctx, r.db, `WHERE id = ?`, id)
+}
+
+// GetByName finds a genre by name, ignoring case.
+func (r *GenreRepository) GetByName(ctx context.Context, name string) (model.Genre, error) {
+	return r.getOne(ctx, r.db, `WHERE lower(name) = lower(?)`, name)
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (r *GenreRepository) getOne(ctx context.Context, q queryRower, where string, arg any) (model.Genre, error) {
+	var g model.Genre
+	err := q.QueryRowContext(
+		ctx,
+		`SELECT id, coalesce(tmdb_id, 0), name FROM genres `+where,
+		arg,
+	).Scan(&g.ID, &g.TMDBID, &g.Name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Genre{}, postgres.ErrGenreNotFound
+	}
+	return g, db.Classify(err)
+}
+
+func (r *GenreRepository) Create(ctx context.Context, g model.Genre) (model.Genre, error) {
+	err := r.db.QueryRowContext(
+		ctx,
+		`INSERT INTO genres (tmdb_id, name, created_at) VALUES (nullif(?, 0), ?, ?) RETURNING id`,
+		g.TMDBID,
+		g.Name,
+		time.Now().UTC(),
+	).Scan(&g.ID)
+	if isUniqueViolation(err) {
+		return model.Genre{}, postgres.ErrGenreExists
+	}
+	return g, db.Classify(err)
+}
+
+// Update renames a genre. Its TMDB link stays.
+func (r *GenreRepository) Update(ctx context.Context, g model.Genre) (model.Genre, error) {
+	err := r.db.QueryRowContext(
+		ctx,
+		`UPDATE genres SET name = ? WHERE id = ? RETURNING coalesce(tmdb_id, 0)`,
+		g.Name,
+		g.ID,
+	).Scan(&g.TMDBID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return model.Genre{}, postgres.ErrGenreNotFound
+	case isUniqueViolation(err):
+		return model.Genre{}, postgres.ErrGenreExists
+	}
+	return g, db.Classify(err)
+}
+
+func (r *GenreRepository) Delete(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM genres WHERE id = ?`, id)
+	if err != nil {
+		return db.Classify(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return postgres.ErrGenreNotFound
+	}
+	return nil
+}
+
+// EnsureTMDB returns the local genre linked to TMDB genre g, linking a
+// same-named genre created by hand or creating a new one. The transaction
+// holds the write lock, so looking first is race free.
+func (r *GenreRepository) EnsureTMDB(ctx context.Context, g model.Genre) (model.Genre, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Genre{}, db.Classify(err)
+	}
+	defer tx.Rollback()
+
+	existing, err := r.getOne(ctx, tx, `WHERE tmdb_id = ?`, g.TMDBID)
+	if errors.Is(err, postgres.ErrGenreNotFound) {
+		existing, err = r.getOne(ctx, tx, `WHERE lower(name) = lower(?)`, g.Name)
+		switch {
+		case errors.Is(err, postgres.ErrGenreNotFound):
+			existing = g
+			err = tx.QueryRowContext(
+				ctx,
+				`INSERT INTO genres (tmdb_id, name, created_at) VALUES (?, ?, ?) RETURNING id`,
+				g.TMDBID,
+				g.Name,
+				time.Now().UTC(),
+			).Scan(&existing.ID)
+		case err == nil && existing.TMDBID == 0:
+			existing.TMDBID = g.TMDBID
+			_, err = tx.ExecContext(ctx, `UPDATE genres SET tmdb_id = ? WHERE id = ?`, g.TMDBID, existing.ID)
+		}
+	}
+	if err != nil {
+		return model.Genre{}, db.Classify(err)
+	}
+
+	return existing, db.Classify(tx.Commit())
+}
+
+func (r *GenreRepository) ForMovies(ctx context.Context, movieIDs []int) (map[int][]model.Genre, error) {
+	out := make(map[int][]model.Genre, len(movieIDs))
+	if len(movieIDs) == 0 {
+		return out, nil
+	}
+
+	args := make([]any, len(movieIDs))
+	for i, id := range movieIDs {
+		args[i] = id
+	}
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT mg.movie_id, g.id, coalesce(g.tmdb_id, 0), g.name
+		FROM movie_genres mg
+		JOIN genres g ON g.id = mg.genre_id
+		WHERE mg.movie_id IN (?`+strings.Repeat(", ?", len(movieIDs)-1)+`)
+		ORDER BY lower(g.name), g.id`,
+		args...,
+	)
+	if err != nil {
+		return nil, db.Classify(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			movieID int
+			g       model.Genre
+		)
+		if err := rows.Scan(&movieID, &g.ID, &g.TMDBID, &g.Name); err != nil {
+			return nil, db.Classify(err)
+		}
+		out[movieID] = append(out[movieID], g)
+	}
+	return out, db.Classify(rows.Err())
+}
+
+// SetMovieGenres checks for the movie up front: SQLite reports foreign key
+// failures without naming the constraint, so any that remains is a missing
+// genre.
+func (r *GenreRepository) SetMovieGenres(ctx context.Context, movieID int, genreIDs []int) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, db.Classify(err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(
+		ctx,
+		`SELECT EXISTS (SELECT 1 FROM movies WHERE id = ?)`,
+		movieID,
+	).Scan(&exists); err != nil {
+		return false, db.Classify(err)
+	}
+	if !exists {
+		return false, postgres.ErrMovieNotFound
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT genre_id FROM movie_genres WHERE movie_id = ? ORDER BY genre_id`, movieID)
+	if err != nil {
+		return false, db.Classify(err)
+	}
+	var current []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return false, db.Classify(err)
+		}
+		current = append(current, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, db.Classify(err)
+	}
+
+	want := slices.Compact(slices.Sorted(slices.Values(genreIDs)))
+	if slices.Equal(current, want) {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM movie_genres WHERE movie_id = ?`, movieID); err != nil {
+		return false, db.Classify(err)
+	}
+	for _, id := range want {
+		_, err := tx.ExecContext(ctx, `INSERT INTO movie_genres (movie_id, genre_id) VALUES (?, ?)`, movieID, id)
+		if violates(err, sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY) {
+			return false, postgres.ErrGenreNotFound
+		}
+		if err != nil {
+			return false, db.Classify(err)
+		}
+	}
+
+	return true, db.Classify(tx.Commit())
+}
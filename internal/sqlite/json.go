@@ -0,0 +1,46 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// jsonStrings stores a []string in a TEXT column as a JSON array, where
+// Postgres has a text[] column. It is both a scan target and an argument:
+//
+//	rows.Scan(jsonStrings{&m.Overrides})
+//	db.Exec(`UPDATE ... SET overrides = ?`, jsonStrings{&m.Overrides})
+type jsonStrings struct {
+	p *[]string
+}
+
+func (j jsonStrings) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*j.p = []string{}
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("scan %T into string list", src)
+	}
+
+	list := []string{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*j.p = list
+	return nil
+}
+
+func (j jsonStrings) Value() (driver.Value, error) {
+	if j.p == nil || *j.p == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(*j.p)
+	return string(data), err
+}
@@ -0,0 +1,524 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+const movieListColumns = `l.id, l.owner_id, u.username, l.title, l.description, l.visibility, l.cloned_from,
+	(SELECT COUNT(*) FROM movie_list_entries e WHERE e.list_id = l.id), l.created_at, l.updated_at`
+
+const movieListFrom = `
+	FROM movie_lists l
+	JOIN users u ON u.id = l.owner_id`
+
+// movieListFields returns scan targets for movieListColumns.
+func movieListFields(l *model.MovieList) []any {
+	return []any{&l.ID, &l.OwnerID, &l.Owner, &l.Title, &l.Description, &l.Visibility, &l.ClonedFrom, &l.Entries, &l.CreatedAt, &l.UpdatedAt}
+}
+
+const listEntryColumns = `e.id, e.position, e.comment, coalesce(e.added_by, 0), e.created_at,
+	movies.id, movies.tmdb_id, movies.title, movies.year, movies.description, movies.rating, movies.overrides`
+
+const listEntryFrom = `
+	FROM movie_list_entries e
+	JOIN movies ON movies.id = e.movie_id`
+
+// listEntryFields returns scan targets for listEntryColumns.
+func listEntryFields(e *model.ListEntry) []any {
+	return append([]any{&e.ID, &e.Position, &e.Comment, &e.AddedBy, &e.AddedAt}, movieFields(&e.Movie)...)
+}
+
+type MovieListRepository struct {
+	db *DB
+}
+
+func NewMovieListRepository(database *DB) *MovieListRepository {
+	return &MovieListRepository{db: database}
+}
+
+func (r *MovieListRepository) List(ctx context.Context, f model.ListFilter, opts model.ListOptions) ([]model.MovieList, int, error) {
+	order, err := qualifiedOrder(movieListSortColumns, "updated", "l.id", opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	limit, offset := pageLimit(opts)
+
+	where := ""
+	var args []any
+	if f.MemberID != 0 {
+		args = append(args, f.MemberID)
+		where += fmt.Sprintf(`
+		  AND (l.owner_id = ?%[1]d OR EXISTS (
+		      SELECT 1 FROM movie_list_collaborators c WHERE c.list_id = l.id AND c.user_id = ?%[1]d))`, len(args))
+	}
+	if f.MovieID != 0 {
+		args = append(args, f.MovieID)
+		where += fmt.Sprintf(`
+		  AND EXISTS (SELECT 1 FROM movie_list_entries e WHERE e.list_id = l.id AND e.movie_id = ?%d)`, len(args))
+	}
+	if f.PublicOnly {
+		args = append(args, model.ListPublic)
+		where += fmt.Sprintf("\n\t\t  AND l.visibility = ?%d", len(args))
+	}
+	from := movieListFrom + `
+		WHERE true` + where
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*)`+from, args...).Scan(&total); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT `+movieListColumns+from+`
+		ORDER BY `+order+
+			fmt.Sprintf(` LIMIT ?%d OFFSET ?%d`, len(args)+1, len(args)+2),
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, db.Classify(err)
+	}
+	defer rows.Close()
+
+	lists := make([]model.MovieList, 0)
+	for rows.Next() {
+		var l model.MovieList
+		if err := rows.Scan(movieListFields(&l)...); err != nil {
+			return nil, 0, db.Classify(err)
+		}
+		lists = append(lists, l)
+	}
+	return lists, total, db.Classify(rows.Err())
+}
+
+func (r *MovieListRepository) Get(ctx context.Context, id int) (model.MovieList, error) {
+	var l model.MovieList
+	err := r.db.QueryRowContext(
+		ctx,
+		`SELECT `+movieListColumns+movieListFrom+`
+		WHERE l.id = ?`,
+		id,
+	).Scan(movieListFields(&l)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.MovieList{}, postgres.ErrListNotFound
+	}
+	if err != nil {
+		return model.MovieList{}, db.Classify(err)
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT c.user_id, u.username, c.added_at
+		FROM movie_list_collaborators c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.list_id = ?
+		ORDER BY c.added_at, c.user_id`,
+		id,
+	)
+	if err != nil {
+		return model.MovieList{}, db.Classify(err)
+	}
+	defer rows.Close()
+
+	l.Collaborators = make([]model.ListCollaborator, 0)
+	for rows.Next() {
+		var c model.ListCollaborator
+		if err := rows.Scan(&c.UserID, &c.Username, &c.AddedAt); err != nil {
+			return model.MovieList{}, db.Classify(err)
+		}
+		l.Collaborators = append(l.Collaborators, c)
+	}
+	return l, db.Classify(rows.Err())
+}
+
+// Create checks for the owner up front: SQLite reports foreign key failures
+// without naming the constraint.
+func (r *MovieListRepository) Create(ctx context.Context, l model.MovieList) (model.MovieList, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.MovieList{}, db.Classify(err)
+	}
+	defer tx.Rollback()
+
+	if err := mustExist(ctx, tx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = ?)`, l.OwnerID, postgres.ErrUserNotFound); err != nil {
+		return model.MovieList{}, err
+	}
+	if l.ID, err = insertList(ctx, tx, l, nil); err != nil {
+		return model.MovieList{}, err
+	}
+	if err := publishActivity(ctx, tx, model.ActivityListCreated, l.OwnerID, model.ActivitySubject{ListID: l.ID}); err != nil {
+		return model.MovieList{}, db.Classify(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return model.MovieList{}, db.Classify(err)
+	}
+
+	return r.Get(ctx, l.ID)
+}
+
+func (r *MovieListRepository) Update(ctx context.Context, l model.MovieList) (model.MovieList, error) {
+	res, err := r.db.ExecContext(
+		ctx,
+		`UPDATE movie_lists SET title = ?, description = ?, visibility = ?, updated_at = ?
+		WHERE id = ?`,
+		l.Title,
+		l.Description,
+		l.Visibility,
+		time.Now().UTC(),
+		l.ID,
+	)
+	if err != nil {
+		return model.MovieList{}, db.Classify(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return model.MovieList{}, postgres.ErrListNotFound
+	}
+
+	return r.Get(ctx, l.ID)
+}
+
+func (r *MovieListRepository) Delete(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM movie_lists WHERE id = ?`, id)
+	if err != nil {
+		return db.Classify(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return postgres.ErrListNotFound
+	}
+	return nil
+}
+
+// Clone creates l as a copy of list srcID's entries, in the same order and
+// with the same comments, all added by l's owner.
+func (r *MovieListRepository) Clone(ctx context.Context, srcID int, l model.MovieList) (model.MovieList, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.MovieList{}, db.Classify(err)
+	}
+	defer tx.Rollback()
+
+	if err := mustExist(ctx, tx, `SELECT EXISTS (SELECT 1 FROM movie_lists WHERE id = ?)`, srcID, postgres.ErrListNotFound); err != nil {
+		return model.MovieList{}, err
+	}
+	if err := mustExist(ctx, tx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = ?)`, l.OwnerID, postgres.ErrUserNotFound); err != nil {
+		return model.MovieList{}, err
+	}
+	if l.ID, err = insertList(ctx, tx, l, &srcID); err != nil {
+		return model.MovieList{}, err
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO movie_list_entries (list_id, movie_id, position, comment, added_by, created_at)
+		SELECT ?1, movie_id, row_number() OVER (ORDER BY position, id), comment, ?2, ?3
+		FROM movie_list_entries
+		WHERE list_id = ?4`,
+		l.ID,
+		l.OwnerID,
+		time.Now().UTC(),
+		srcID,
+	); err != nil {
+		return model.MovieList{}, db.Classify(err)
+	}
+	if err := publishActivity(ctx, tx, model.ActivityListCreated, l.OwnerID, model.ActivitySubject{ListID: l.ID}); err != nil {
+		return model.MovieList{}, db.Classify(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return model.MovieList{}, db.Classify(err)
+	}
+
+	return r.Get(ctx, l.ID)
+}
+
+func insertList(ctx context.Context, tx *sql.Tx, l model.MovieList, clonedFrom *int) (int, error) {
+	now := time.Now().UTC()
+	var id int
+	err := tx.QueryRowContext(
+		ctx,
+		`INSERT INTO movie_lists (owner_id, title, description, visibility, cloned_from, created_at, updated_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?6)
+		RETURNING id`,
+		l.OwnerID,
+		l.Title,
+		l.Description,
+		l.Visibility,
+		clonedFrom,
+		now,
+	).Scan(&id)
+	return id, db.Classify(err)
+}
+
+// mustExist runs an EXISTS query and returns notFound when it is false.
+func mustExist(ctx context.Context, tx *sql.Tx, query string, id int, notFound error) error {
+	var exists bool
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&exists); err != nil {
+		return db.Classify(err)
+	}
+	if !exists {
+		return notFound
+	}
+	return nil
+}
+
+// AddCollaborator is idempotent: adding a collaborator twice keeps the
+// first date.
+func (r *MovieListRepository) AddCollaborator(ctx context.Context, listID, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return db.Classify(err)
+	}
+	defer tx.Rollback()
+
+	if err := mustExist(ctx, tx, `SELECT EXISTS (SELECT 1 FROM movie_lists WHERE id = ?)`, listID, postgres.ErrListNotFound); err != nil {
+		return err
+	}
+	if err := mustExist(ctx, tx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = ?)`, userID, postgres.ErrUserNotFound); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO movie_list_collaborators (list_id, user_id, added_at) VALUES (?, ?, ?)
+		ON CONFLICT DO NOTHING`,
+		listID,
+		userID,
+		time.Now().UTC(),
+	); err != nil {
+		return db.Classify(err)
+	}
+	return db.Classify(tx.Commit())
+}
+
+func (r *MovieListRepository) RemoveCollaborator(ctx context.Context, listID, userID int) error {
+	res, err := r.db.ExecContext(
+		ctx,
+		`DELETE FROM movie_list_collaborators WHERE list_id = ? AND user_id = ?`,
+		listID,
+		userID,
+	)
+	if err != nil {
+		return db.Classify(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return postgres.ErrCollaboratorNotFound
+	}
+	return nil
+}
+
+func (r *MovieListRepository) Entries(ctx context.Context, listID int, opts model.ListOptions) ([]model.ListEntry, int, error) {
+	order, err := qualifiedOrder(listEntrySortColumns, "position", "e.id", opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	limit, offset := pageLimit(opts)
+
+	var total int
+	if err := r.db.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*) FROM movie_list_entries WHERE list_id = ?`,
+		listID,
+	).Scan(&total); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT `+listEntryColumns+listEntryFrom+`
+		WHERE e.list_id = ?
+		ORDER BY `+order+`
+		LIMIT ? OFFSET ?`,
+		listID,
+		limit,
+		offset,
+	)
+	if err != nil {
+		return nil, 0, db.Classify(err)
+	}
+	defer rows.Close()
+
+	entries := make([]model.ListEntry, 0)
+	for rows.Next() {
+		var e model.ListEntry
+		if err := rows.Scan(listEntryFields(&e)...); err != nil {
+			return nil, 0, db.Classify(err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, db.Classify(rows.Err())
+}
+
+func (r *MovieListRepository) GetEntry(ctx context.Context, listID, movieID int) (model.ListEntry, error) {
+	var e model.ListEntry
+	err := r.db.QueryRowContext(
+		ctx,
+		`SELECT `+listEntryColumns+listEntryFrom+`
+		WHERE e.list_id = ? AND e.movie_id = ?`,
+		listID,
+		movieID,
+	).Scan(listEntryFields(&e)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ListEntry{}, postgres.ErrListEntryNotFound
+	}
+	return e, db.Classify(err)
+}
+
+func (r *MovieListRepository) AddEntry(ctx context.Context, listID int, e model.ListEntry) (model.ListEntry, error) {
+	err := r.withTouch(ctx, listID, func(ctx context.Context, tx *sql.Tx) error {
+		if err := mustExist(ctx, tx, `SELECT EXISTS (SELECT 1 FROM movies WHERE id = ?)`, e.Movie.ID, postgres.ErrMovieNotFound); err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO movie_list_entries (list_id, movie_id, position, comment, added_by, created_at)
+			SELECT ?1, ?2, coalesce(max(position), 0) + 1, ?3, ?4, ?5
+			FROM movie_list_entries
+			WHERE list_id = ?1`,
+			listID,
+			e.Movie.ID,
+			e.Comment,
+			e.AddedBy,
+			time.Now().UTC(),
+		)
+		if isUniqueViolation(err) {
+			return postgres.ErrListEntryExists
+		}
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		return publishActivity(ctx, tx, model.ActivityListEntry, e.AddedBy, model.ActivitySubject{ListID: listID, ListEntryID: int(id)})
+	})
+	if err != nil {
+		return model.ListEntry{}, err
+	}
+
+	return r.GetEntry(ctx, listID, e.Movie.ID)
+}
+
+func (r *MovieListRepository) UpdateEntry(ctx context.Context, listID int, e model.ListEntry) (model.ListEntry, error) {
+	err := r.withTouch(ctx, listID, func(ctx context.Context, tx *sql.Tx) error {
+		res, err := tx.ExecContext(
+			ctx,
+			`UPDATE movie_list_entries SET comment = ? WHERE list_id = ? AND movie_id = ?`,
+			e.Comment,
+			listID,
+			e.Movie.ID,
+		)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return postgres.ErrListEntryNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return model.ListEntry{}, err
+	}
+
+	return r.GetEntry(ctx, listID, e.Movie.ID)
+}
+
+// MoveEntry puts the movie's entry at position (clamped to the list) and
+// renumbers the list from 1, as WatchlistRepository.Move does.
+func (r *MovieListRepository) MoveEntry(ctx context.Context, listID, movieID, position int) (model.ListEntry, error) {
+	err := r.withTouch(ctx, listID, func(ctx context.Context, tx *sql.Tx) error {
+		rows, err := tx.QueryContext(
+			ctx,
+			`SELECT movie_id FROM movie_list_entries WHERE list_id = ? ORDER BY position, id`,
+			listID,
+		)
+		if err != nil {
+			return err
+		}
+		var order []int
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			order = append(order, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		i := slices.Index(order, movieID)
+		if i < 0 {
+			return postgres.ErrListEntryNotFound
+		}
+		order = slices.Delete(order, i, i+1)
+		order = slices.Insert(order, min(max(position, 1), len(order)+1)-1, movieID)
+
+		for n, id := range order {
+			if _, err := tx.ExecContext(
+				ctx,
+				`UPDATE movie_list_entries SET position = ?1 WHERE list_id = ?2 AND movie_id = ?3 AND position <> ?1`,
+				n+1,
+				listID,
+				id,
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return model.ListEntry{}, err
+	}
+
+	return r.GetEntry(ctx, listID, movieID)
+}
+
+func (r *MovieListRepository) RemoveEntry(ctx context.Context, listID, movieID int) error {
+	return r.withTouch(ctx, listID, func(ctx context.Context, tx *sql.Tx) error {
+		res, err := tx.ExecContext(
+			ctx,
+			`DELETE FROM movie_list_entries WHERE list_id = ? AND movie_id = ?`,
+			listID,
+			movieID,
+		)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return postgres.ErrListEntryNotFound
+		}
+		return nil
+	})
+}
+
+// withTouch runs fn in a transaction after bumping the list's updated_at.
+func (r *MovieListRepository) withTouch(ctx context.Context, listID int, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return db.Classify(err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE movie_lists SET updated_at = ? WHERE id = ?`, time.Now().UTC(), listID)
+	if err != nil {
+		return db.Classify(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return postgres.ErrListNotFound
+	}
+
+	if err := fn(ctx, tx); err != nil {
+		return db.Classify(err)
+	}
+	return db.Classify(tx.Commit())
+}
@@ -0,0 +1,114 @@
+-- The schema of Postgres migrations 0001-0007 in SQLite terms.
+
+CREATE TABLE roles (
+    name TEXT PRIMARY KEY
+);
+
+INSERT INTO roles (name) VALUES ('user'), ('moderator'), ('admin');
+
+CREATE TABLE role_permissions (
+    role       TEXT NOT NULL REFERENCES roles (name) ON DELETE CASCADE,
+    permission TEXT NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('user',      'review:write'),
+    ('moderator', 'review:write'),
+    ('moderator', 'review:moderate'),
+    ('moderator', 'movie:write'),
+    ('admin',     'review:write'),
+    ('admin',     'review:moderate'),
+    ('admin',     'movie:write'),
+    ('admin',     'user:admin');
+
+CREATE TABLE users (
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    username      TEXT NOT NULL UNIQUE,
+    email         TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    role          TEXT NOT NULL DEFAULT 'user' REFERENCES roles (name),
+    token_version INTEGER NOT NULL DEFAULT 0,
+    created_at    DATETIME NOT NULL
+);
+
+CREATE TABLE movies (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    tmdb_id     INTEGER NOT NULL DEFAULT 0,
+    title       TEXT NOT NULL,
+    year        INTEGER NOT NULL DEFAULT 0,
+    description TEXT NOT NULL DEFAULT '',
+    rating      REAL NOT NULL DEFAULT 0,
+    created_at  DATETIME NOT NULL
+);
+
+-- Movies created by hand have tmdb_id = 0, so only real TMDB ids are unique.
+CREATE UNIQUE INDEX movies_tmdb_id_key ON movies (tmdb_id) WHERE tmdb_id <> 0;
+
+-- Full-text index over title and description, kept in sync by triggers. The
+-- porter tokenizer stems english words like the Postgres 'english' config.
+CREATE VIRTUAL TABLE movies_fts USING fts5 (
+    title,
+    description,
+    content = 'movies',
+    content_rowid = 'id',
+    tokenize = 'porter unicode61'
+);
+
+CREATE TRIGGER movies_fts_insert AFTER INSERT ON movies BEGIN
+    INSERT INTO movies_fts (rowid, title, description)
+    VALUES (new.id, new.title, new.description);
+END;
+
+CREATE TRIGGER movies_fts_delete AFTER DELETE ON movies BEGIN
+    INSERT INTO movies_fts (movies_fts, rowid, title, description)
+    VALUES ('delete', old.id, old.title, old.description);
+END;
+
+CREATE TRIGGER movies_fts_update AFTER UPDATE OF title, description ON movies BEGIN
+    INSERT INTO movies_fts (movies_fts, rowid, title, description)
+    VALUES ('delete', old.id, old.title, old.description);
+    INSERT INTO movies_fts (rowid, title, description)
+    VALUES (new.id, new.title, new.description);
+END;
+
+CREATE TABLE reviews (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    movie_id   INTEGER NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    score      INTEGER NOT NULL CHECK (score >= 1 AND score <= 5),
+    text       TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    UNIQUE (movie_id, user_id)
+);
+
+CREATE INDEX reviews_user_id_idx ON reviews (user_id);
+
+CREATE TABLE refresh_tokens (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id     INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash  TEXT NOT NULL UNIQUE,
+    family_id   TEXT NOT NULL,
+    expires_at  DATETIME NOT NULL,
+    revoked_at  DATETIME,
+    replaced_by INTEGER REFERENCES refresh_tokens (id) ON DELETE SET NULL,
+    created_at  DATETIME NOT NULL
+);
+
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens (user_id);
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+CREATE TABLE revoked_tokens (
+    jti        TEXT PRIMARY KEY,
+    expires_at DATETIME NOT NULL
+);
+
+CREATE TABLE rating_jobs (
+    movie_id        INTEGER PRIMARY KEY REFERENCES movies (id) ON DELETE CASCADE,
+    enqueued_at     DATETIME NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME NOT NULL,
+    last_error      TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX rating_jobs_next_attempt_idx ON rating_jobs (next_attempt_at);
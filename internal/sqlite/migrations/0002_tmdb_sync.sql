@@ -0,0 +1,22 @@
+-- Postgres migration 0008. overrides and the run's lists and errors are JSON
+-- arrays of strings.
+
+ALTER TABLE movies ADD COLUMN overrides TEXT NOT NULL DEFAULT '[]';
+
+CREATE TABLE tmdb_sync_runs (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    triggered_by TEXT NOT NULL,
+    status      TEXT NOT NULL,
+    lists       TEXT NOT NULL DEFAULT '[]',
+    started_at  DATETIME NOT NULL,
+    finished_at DATETIME,
+    pages       INTEGER NOT NULL DEFAULT 0,
+    fetched     INTEGER NOT NULL DEFAULT 0,
+    created     INTEGER NOT NULL DEFAULT 0,
+    updated     INTEGER NOT NULL DEFAULT 0,
+    unchanged   INTEGER NOT NULL DEFAULT 0,
+    failed      INTEGER NOT NULL DEFAULT 0,
+    errors      TEXT NOT NULL DEFAULT '[]'
+);
+
+CREATE INDEX tmdb_sync_runs_started_at_idx ON tmdb_sync_runs (started_at);
@@ -0,0 +1,19 @@
+-- Postgres migration 0009.
+
+CREATE TABLE genres (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    tmdb_id    INTEGER UNIQUE,
+    name       TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+);
+
+CREATE UNIQUE INDEX genres_name_key ON genres (lower(name));
+
+CREATE TABLE movie_genres (
+    movie_id INTEGER NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    genre_id INTEGER NOT NULL REFERENCES genres (id) ON DELETE CASCADE,
+    PRIMARY KEY (movie_id, genre_id)
+);
+
+CREATE INDEX movie_genres_genre_id_idx ON movie_genres (genre_id);
+CREATE INDEX movies_year_idx ON movies (year);
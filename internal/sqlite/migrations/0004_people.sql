@@ -0,0 +1,24 @@
+-- Postgres migration 0010. People are searched with word_similarity and
+-- LIKE, so there is no trigram index.
+
+CREATE TABLE people (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    tmdb_id     INTEGER NOT NULL UNIQUE,
+    name        TEXT NOT NULL,
+    profile_url TEXT NOT NULL DEFAULT '',
+    created_at  DATETIME NOT NULL
+);
+
+CREATE TABLE movie_credits (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    movie_id     INTEGER NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    person_id    INTEGER NOT NULL REFERENCES people (id) ON DELETE CASCADE,
+    role         TEXT NOT NULL CHECK (role IN ('cast', 'crew')),
+    character    TEXT NOT NULL DEFAULT '',
+    job          TEXT NOT NULL DEFAULT '',
+    department   TEXT NOT NULL DEFAULT '',
+    credit_order INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX movie_credits_movie_id_idx ON movie_credits (movie_id, role, credit_order);
+CREATE INDEX movie_credits_person_id_idx ON movie_credits (person_id);
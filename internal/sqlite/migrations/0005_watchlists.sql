@@ -0,0 +1,15 @@
+-- Postgres migration 0011. planned_for holds a YYYY-MM-DD date.
+
+CREATE TABLE watchlist_entries (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id     INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    movie_id    INTEGER NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    position    INTEGER NOT NULL,
+    note        TEXT NOT NULL DEFAULT '',
+    planned_for TEXT,
+    created_at  DATETIME NOT NULL,
+    UNIQUE (user_id, movie_id)
+);
+
+CREATE INDEX watchlist_entries_user_position_idx ON watchlist_entries (user_id, position);
+CREATE INDEX watchlist_entries_movie_id_idx ON watchlist_entries (movie_id);
@@ -0,0 +1,15 @@
+-- Postgres migration 0012. watched_on holds a YYYY-MM-DD date.
+
+CREATE TABLE diary_entries (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    movie_id   INTEGER NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    watched_on TEXT NOT NULL,
+    rewatch    INTEGER NOT NULL DEFAULT 0,
+    score      INTEGER CHECK (score BETWEEN 1 AND 5),
+    note       TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX diary_entries_user_watched_idx ON diary_entries (user_id, watched_on);
+CREATE INDEX diary_entries_movie_user_idx ON diary_entries (movie_id, user_id);
@@ -0,0 +1,37 @@
+-- Postgres migration 0013.
+
+CREATE TABLE movie_lists (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    title       TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    visibility  TEXT NOT NULL DEFAULT 'private' CHECK (visibility IN ('public', 'unlisted', 'private')),
+    cloned_from INTEGER REFERENCES movie_lists (id) ON DELETE SET NULL,
+    created_at  DATETIME NOT NULL,
+    updated_at  DATETIME NOT NULL
+);
+
+CREATE INDEX movie_lists_owner_id_idx ON movie_lists (owner_id);
+
+CREATE TABLE movie_list_entries (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    list_id    INTEGER NOT NULL REFERENCES movie_lists (id) ON DELETE CASCADE,
+    movie_id   INTEGER NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    position   INTEGER NOT NULL,
+    comment    TEXT NOT NULL DEFAULT '',
+    added_by   INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at DATETIME NOT NULL,
+    UNIQUE (list_id, movie_id)
+);
+
+CREATE INDEX movie_list_entries_list_position_idx ON movie_list_entries (list_id, position);
+CREATE INDEX movie_list_entries_movie_id_idx ON movie_list_entries (movie_id);
+
+CREATE TABLE movie_list_collaborators (
+    list_id  INTEGER NOT NULL REFERENCES movie_lists (id) ON DELETE CASCADE,
+    user_id  INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    added_at DATETIME NOT NULL,
+    PRIMARY KEY (list_id, user_id)
+);
+
+CREATE INDEX movie_list_collaborators_user_id_idx ON movie_list_collaborators (user_id);
@@ -0,0 +1,36 @@
+-- Postgres migration 0014.
+
+CREATE TABLE follows (
+    follower_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    followee_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at  DATETIME NOT NULL,
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX follows_followee_id_idx ON follows (followee_id);
+
+CREATE TABLE activities (
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    actor_id       INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    kind           TEXT NOT NULL CHECK (kind IN ('review', 'diary', 'list_created', 'list_entry')),
+    review_id      INTEGER REFERENCES reviews (id) ON DELETE CASCADE,
+    diary_entry_id INTEGER REFERENCES diary_entries (id) ON DELETE CASCADE,
+    list_id        INTEGER REFERENCES movie_lists (id) ON DELETE CASCADE,
+    list_entry_id  INTEGER REFERENCES movie_list_entries (id) ON DELETE CASCADE,
+    created_at     DATETIME NOT NULL
+);
+
+CREATE INDEX activities_actor_id_idx ON activities (actor_id, id);
+CREATE INDEX activities_review_id_idx ON activities (review_id) WHERE review_id IS NOT NULL;
+CREATE INDEX activities_diary_entry_id_idx ON activities (diary_entry_id) WHERE diary_entry_id IS NOT NULL;
+CREATE INDEX activities_list_id_idx ON activities (list_id) WHERE list_id IS NOT NULL;
+CREATE INDEX activities_list_entry_id_idx ON activities (list_entry_id) WHERE list_entry_id IS NOT NULL;
+
+CREATE TABLE feed_items (
+    user_id     INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    activity_id INTEGER NOT NULL REFERENCES activities (id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, activity_id)
+);
+
+CREATE INDEX feed_items_activity_id_idx ON feed_items (activity_id);
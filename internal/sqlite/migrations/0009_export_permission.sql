@@ -0,0 +1,8 @@
+-- Postgres migration 0015.
+
+INSERT INTO roles (name) VALUES ('analyst');
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('analyst', 'review:write'),
+    ('analyst', 'data:export'),
+    ('admin',   'data:export');
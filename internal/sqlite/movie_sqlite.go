@@ -0,0 +1,351 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/internal/textsearch"
+	"github.com/AlikhanF2006/Final_project/model"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+const movieColumns = `id, tmdb_id, title, year, description, rating, overrides`
+
+// movieFields returns scan targets for movieColumns.
+func movieFields(m *model.Movie) []any {
+	return []any{&m.ID, &m.TMDBID, &m.Title, &m.Year, &m.Description, &m.Rating, jsonStrings{&m.Overrides}}
+}
+
+type MovieRepository struct {
+	db *DB
+}
+
+func NewMovieRepository(database *DB) *MovieRepository {
+	return &MovieRepository{db: database}
+}
+
+func (r *MovieRepository) SetRating(ctx context.Context, movieID int, rating float64) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE movies SET rating = ? WHERE id = ?`, rating, movieID)
+	return db.Classify(err)
+}
+
+// Create and Update leave the rating to SetRating, as the Postgres
+// repository does.
+func (r *MovieRepository) Create(ctx context.Context, m model.Movie) (model.Movie, error) {
+	err := r.db.QueryRowContext(
+		ctx,
+		`INSERT INTO movies (tmdb_id, title, year, description, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id`,
+		m.TMDBID,
+		m.Title,
+		m.Year,
+		m.Description,
+		time.Now().UTC(),
+	).Scan(&m.ID)
+	if isUniqueViolation(err) {
+		return model.Movie{}, postgres.ErrMovieExists
+	}
+
+	m.Rating = 0
+	return m, db.Classify(err)
+}
+
+// UpsertTMDB inserts a movie fetched from TMDB or refreshes the row with the
+// same tmdb_id. Fields listed in the row's overrides keep their local value,
+// and rating is never touched: it comes from local reviews. A row that would
+// not change is not written at all.
+func (r *MovieRepository) UpsertTMDB(ctx context.Context, m model.Movie) (model.Movie, model.UpsertResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Movie{}, 0, db.Classify(err)
+	}
+	defer tx.Rollback()
+
+	// SQLite has no xmax to tell an insert from an update afterwards; the
+	// transaction holds the write lock, so looking first is race free.
+	var existed bool
+	if err := tx.QueryRowContext(
+		ctx,
+		`SELECT EXISTS (SELECT 1 FROM movies WHERE tmdb_id = ?)`,
+		m.TMDBID,
+	).Scan(&existed); err != nil {
+		return model.Movie{}, 0, db.Classify(err)
+	}
+
+	err = tx.QueryRowContext(
+		ctx,
+		`INSERT INTO movies (tmdb_id, title, year, description, created_at)
+		VALUES (?1, ?2, ?3, ?4, ?5)
+		ON CONFLICT (tmdb_id) WHERE tmdb_id <> 0 DO UPDATE SET
+			title = CASE WHEN instr(movies.overrides, '"title"') THEN movies.title ELSE excluded.title END,
+			year = CASE WHEN instr(movies.overrides, '"year"') THEN movies.year ELSE excluded.year END,
+			description = CASE WHEN instr(movies.overrides, '"description"') THEN movies.description ELSE excluded.description END
+		WHERE (NOT instr(movies.overrides, '"title"') AND movies.title <> excluded.title)
+		   OR (NOT instr(movies.overrides, '"year"') AND movies.year <> excluded.year)
+		   OR (NOT instr(movies.overrides, '"description"') AND movies.description <> excluded.description)
+		RETURNING `+movieColumns,
+		m.TMDBID,
+		m.Title,
+		m.Year,
+		m.Description,
+		time.Now().UTC(),
+	).Scan(movieFields(&m)...)
+
+	result := model.UpsertCreated
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// The conflict's WHERE filtered the row out: it is already up to date.
+		err = tx.QueryRowContext(
+			ctx,
+			`SELECT `+movieColumns+` FROM movies WHERE tmdb_id = ?`,
+			m.TMDBID,
+		).Scan(movieFields(&m)...)
+		result = model.UpsertUnchanged
+	case existed:
+		result = model.UpsertUpdated
+	}
+	if err != nil {
+		return model.Movie{}, 0, db.Classify(err)
+	}
+
+	return m, result, db.Classify(tx.Commit())
+}
+
+func (r *MovieRepository) GetAll(ctx context.Context) ([]model.Movie, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT `+movieColumns+` FROM movies ORDER BY id`,
+	)
+	if err != nil {
+		return nil, db.Classify(err)
+	}
+
+	movies, err := collectMovies(rows)
+	return movies, db.Classify(err)
+}
+
+func (r *MovieRepository) GetByID(ctx context.Context, id int) (model.Movie, error) {
+	return r.getOne(ctx, `WHERE id = ?`, id)
+}
+
+func (r *MovieRepository) GetByTMDBID(ctx context.Context, tmdbID int) (model.Movie, error) {
+	return r.getOne(ctx, `WHERE tmdb_id = ?`, tmdbID)
+}
+
+func (r *MovieRepository) ExistsByTMDBID(ctx context.Context, tmdbID int) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(
+		ctx,
+		`SELECT EXISTS (SELECT 1 FROM movies WHERE tmdb_id = ?)`,
+		tmdbID,
+	).Scan(&exists)
+
+	return exists, db.Classify(err)
+}
+
+func (r *MovieRepository) Update(ctx context.Context, m model.Movie) (model.Movie, error) {
+	err := r.db.QueryRowContext(
+		ctx,
+		`UPDATE movies SET title = ?, year = ?, description = ?, overrides = ? WHERE id = ?
+		RETURNING rating`,
+		m.Title,
+		m.Year,
+		m.Description,
+		jsonStrings{&m.Overrides},
+		m.ID,
+	).Scan(&m.Rating)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Movie{}, postgres.ErrMovieNotFound
+	}
+	if err != nil {
+		return model.Movie{}, db.Classify(err)
+	}
+
+	return m, nil
+}
+
+func (r *MovieRepository) Delete(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM movies WHERE id = ?`, id)
+	if err != nil {
+		return db.Classify(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return postgres.ErrMovieNotFound
+	}
+
+	return nil
+}
+
+func (r *MovieRepository) List(ctx context.Context, f model.MovieFilter, opts model.ListOptions) ([]model.Movie, int, error) {
+	order, err := orderBy(movieSortColumns, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	limit, offset := pageLimit(opts)
+	where, args := movieFilterSQL(f, nil)
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM movies WHERE true`+where, args...).Scan(&total); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT `+movieColumns+`
+		FROM movies
+		WHERE true`+where+`
+		ORDER BY `+order+
+			fmt.Sprintf(` LIMIT ?%d OFFSET ?%d`, len(args)+1, len(args)+2),
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	movies, err := collectMovies(rows)
+	return movies, total, db.Classify(err)
+}
+
+// Search matches query against the FTS5 index over title and description,
+// falling back to trigram word similarity on the title (word_similarity is
+// registered in db.go) so that misspelled queries still find something.
+// With a query and no explicit sort, results are ordered by relevance.
+func (r *MovieRepository) Search(ctx context.Context, query string, f model.MovieFilter, opts model.ListOptions) ([]model.MovieSearchHit, int, error) {
+	query = strings.TrimSpace(query)
+
+	order := "rank DESC, id ASC"
+	if opts.Sort != "relevance" && (opts.Sort != "" || query == "") {
+		var err error
+		order, err = orderBy(movieSortColumns, opts)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	limit, offset := pageLimit(opts)
+
+	terms := textsearch.Terms(query)
+	fts, ftsQuery := ftsMatch(terms)
+
+	where, args := movieFilterSQL(f, []any{query, ftsQuery, textsearch.SimilarityThreshold})
+	from := `
+		FROM movies
+		LEFT JOIN (` + fts + `) AS fts USING (id)
+		WHERE (?1 = '' OR fts.id IS NOT NULL OR word_similarity(?1, title) >= ?3)` + where
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*)`+from, args...).Scan(&total); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT `+movieColumns+`,
+			CASE WHEN ?1 = '' THEN 0
+			     ELSE coalesce(fts.score, 0) * 2 + word_similarity(?1, title)
+			END AS rank`+from+`
+		ORDER BY `+order+
+			fmt.Sprintf(` LIMIT ?%d OFFSET ?%d`, len(args)+1, len(args)+2),
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, db.Classify(err)
+	}
+	defer rows.Close()
+
+	hits := make([]model.MovieSearchHit, 0)
+	for rows.Next() {
+		var h model.MovieSearchHit
+		if err := rows.Scan(append(movieFields(&h.Movie), &h.Rank)...); err != nil {
+			return nil, 0, db.Classify(err)
+		}
+		if query != "" {
+			h.Snippet = textsearch.Snippet(terms, h.Title, h.Description)
+		}
+		hits = append(hits, h)
+	}
+
+	return hits, total, db.Classify(rows.Err())
+}
+
+// ftsMatch returns a subquery of the movies matching terms, with their bm25
+// score, and the FTS5 query to bind to it as ?2. An FTS5 query of quoted
+// terms is an AND of stemmed words, which is what websearch_to_tsquery makes
+// of plain input. Without terms (an empty or stop-word-only query) only the
+// trigram fallback can match, and since FTS5 rejects an empty MATCH the
+// index is left out altogether.
+func ftsMatch(terms []string) (subquery, ftsQuery string) {
+	if len(terms) == 0 {
+		return `SELECT NULL AS id, 0 AS score WHERE ?2 <> ''`, ""
+	}
+
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = `"` + t + `"`
+	}
+	return `SELECT rowid AS id, -bm25(movies_fts, 2.0, 1.0) AS score
+		FROM movies_fts WHERE movies_fts MATCH ?2`, strings.Join(quoted, " ")
+}
+
+// movieFilterSQL returns the conditions f puts on a query over movies, each
+// starting with AND, and args with their values appended as the next
+// numbered parameters.
+func movieFilterSQL(f model.MovieFilter, args []any) (string, []any) {
+	var b strings.Builder
+	add := func(cond string, v int) {
+		args = append(args, v)
+		fmt.Fprintf(&b, "\n\t\t  AND "+cond, len(args))
+	}
+
+	if f.Year != 0 {
+		add("movies.year = ?%d", f.Year)
+	}
+	if f.YearFrom != 0 {
+		add("movies.year >= ?%d", f.YearFrom)
+	}
+	if f.YearTo != 0 {
+		add("movies.year <= ?%d", f.YearTo)
+	}
+	if f.GenreID != 0 {
+		add("EXISTS (SELECT 1 FROM movie_genres mg WHERE mg.movie_id = movies.id AND mg.genre_id = ?%d)", f.GenreID)
+	}
+	return b.String(), args
+}
+
+func (r *MovieRepository) getOne(ctx context.Context, where string, arg any) (model.Movie, error) {
+	var m model.Movie
+
+	err := r.db.QueryRowContext(
+		ctx,
+		`SELECT `+movieColumns+` FROM movies `+where,
+		arg,
+	).Scan(movieFields(&m)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Movie{}, postgres.ErrMovieNotFound
+	}
+	if err != nil {
+		return model.Movie{}, db.Classify(err)
+	}
+
+	return m, nil
+}
+
+func collectMovies(rows *sql.Rows) ([]model.Movie, error) {
+	defer rows.Close()
+
+	movies := make([]model.Movie, 0)
+	for rows.Next() {
+		var m model.Movie
+		if err := rows.Scan(movieFields(&m)...); err != nil {
+			return nil, err
+		}
+		movies = append(movies, m)
+	}
+
+	return movies, rows.Err()
+}
@@ -0,0 +1,115 @@
+package sqlite
+
+import (
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+var movieSortColumns = map[string]string{
+	"rating":  "rating",
+	"year":    "year",
+	"title":   "title",
+	"created": "created_at",
+}
+
+var personSortColumns = map[string]string{
+	"name": "name",
+}
+
+// watchlistSortColumns are qualified: the watchlist query joins movies.
+// Entries without a date sort after the others, as NULLs do in Postgres.
+var watchlistSortColumns = map[string]string{
+	"position": "w.position",
+	"added":    "w.created_at",
+	"planned":  "coalesce(w.planned_for, '9999-12-31')",
+	"title":    "movies.title",
+	"year":     "movies.year",
+}
+
+// diarySortColumns are qualified like watchlistSortColumns. Unrated entries
+// sort as the lowest scores.
+var diarySortColumns = map[string]string{
+	"watched": "d.watched_on",
+	"added":   "d.created_at",
+	"score":   "coalesce(d.score, 0)",
+	"title":   "movies.title",
+	"year":    "movies.year",
+}
+
+// movieListSortColumns and listEntrySortColumns are qualified like
+// watchlistSortColumns.
+var movieListSortColumns = map[string]string{
+	"updated": "l.updated_at",
+	"created": "l.created_at",
+	"title":   "l.title",
+}
+
+var listEntrySortColumns = map[string]string{
+	"position": "e.position",
+	"added":    "e.created_at",
+	"title":    "movies.title",
+	"year":     "movies.year",
+}
+
+// followSortColumns are qualified like watchlistSortColumns: follow lists
+// join users.
+var followSortColumns = map[string]string{
+	"followed": "f.created_at",
+	"name":     "u.username",
+}
+
+var reviewSortColumns = map[string]string{
+	"created": "created_at",
+	"score":   "score",
+}
+
+// orderBy turns opts.Sort into an ORDER BY clause using only whitelisted
+// columns. The id tie-breaker keeps pages stable when sort values repeat.
+func orderBy(columns map[string]string, opts model.ListOptions) (string, error) {
+	dir := " ASC"
+	if opts.Desc {
+		dir = " DESC"
+	}
+
+	if opts.Sort == "" {
+		return "id" + dir, nil
+	}
+
+	col, ok := columns[opts.Sort]
+	if !ok {
+		return "", postgres.ErrBadSort
+	}
+	return col + dir + ", id" + dir, nil
+}
+
+// qualifiedOrder is orderBy for joined queries, where a bare id would be
+// ambiguous: it falls back to the def sort key and breaks ties on id.
+func qualifiedOrder(columns map[string]string, def, id string, opts model.ListOptions) (string, error) {
+	if opts.Sort == "" {
+		opts.Sort = def
+	}
+	col, ok := columns[opts.Sort]
+	if !ok {
+		return "", postgres.ErrBadSort
+	}
+	dir := " ASC"
+	if opts.Desc {
+		dir = " DESC"
+	}
+	return col + dir + ", " + id + dir, nil
+}
+
+func pageLimit(opts model.ListOptions) (int, int) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = model.DefaultPageLimit
+	}
+	if limit > model.MaxPageLimit {
+		limit = model.MaxPageLimit
+	}
+	offset := opts.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
@@ -0,0 +1,225 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/internal/textsearch"
+	"github.com/AlikhanF2006/Final_project/model"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+const personColumns = `id, tmdb_id, name, profile_url`
+
+type PersonRepository struct {
+	db *DB
+}
+
+func NewPersonRepository(database *DB) *PersonRepository {
+	return &PersonRepository{db: database}
+}
+
+func (r *PersonRepository) GetByID(ctx context.Context, id int) (model.Person, error) {
+	var p model.Person
+	err := r.db.QueryRowContext(
+		ctx,
+		`SELECT `+personColumns+` FROM people WHERE id = ?`,
+		id,
+	).Scan(&p.ID, &p.TMDBID, &p.Name, &p.ProfileURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Person{}, postgres.ErrPersonNotFound
+	}
+	return p, db.Classify(err)
+}
+
+// Search matches names containing query, ignoring case, or trigram-similar
+// to it. instr stands in for ILIKE so that wildcards in query need no
+// escaping.
+func (r *PersonRepository) Search(ctx context.Context, query string, opts model.ListOptions) ([]model.Person, int, error) {
+	query = strings.TrimSpace(query)
+
+	order := "word_similarity(?1, name) DESC, name ASC, id ASC"
+	if opts.Sort != "relevance" && (opts.Sort != "" || query == "") {
+		var err error
+		order, err = orderBy(personSortColumns, opts)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	limit, offset := pageLimit(opts)
+
+	from := `
+		FROM people
+		WHERE ?1 = '' OR instr(lower(name), lower(?1)) > 0 OR word_similarity(?1, name) >= ?2`
+	args := []any{query, textsearch.SimilarityThreshold}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*)`+from, args...).Scan(&total); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT `+personColumns+from+`
+		ORDER BY `+order+
+			fmt.Sprintf(` LIMIT ?%d OFFSET ?%d`, len(args)+1, len(args)+2),
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, db.Classify(err)
+	}
+	defer rows.Close()
+
+	people := make([]model.Person, 0)
+	for rows.Next() {
+		var p model.Person
+		if err := rows.Scan(&p.ID, &p.TMDBID, &p.Name, &p.ProfileURL); err != nil {
+			return nil, 0, db.Classify(err)
+		}
+		people = append(people, p)
+	}
+	return people, total, db.Classify(rows.Err())
+}
+
+func (r *PersonRepository) Filmography(ctx context.Context, personID int) ([]model.FilmographyEntry, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT m.id, m.tmdb_id, m.title, m.year, c.role, c.character, c.job, c.department
+		FROM movie_credits c
+		JOIN movies m ON m.id = c.movie_id
+		WHERE c.person_id = ?
+		ORDER BY m.year DESC, m.title, m.id, c.role, c.credit_order, c.id`,
+		personID,
+	)
+	if err != nil {
+		return nil, db.Classify(err)
+	}
+	defer rows.Close()
+
+	entries := make([]model.FilmographyEntry, 0)
+	for rows.Next() {
+		var e model.FilmographyEntry
+		if err := rows.Scan(&e.MovieID, &e.TMDBID, &e.Title, &e.Year, &e.Role, &e.Character, &e.Job, &e.Department); err != nil {
+			return nil, db.Classify(err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, db.Classify(rows.Err())
+}
+
+func (r *PersonRepository) MovieCredits(ctx context.Context, movieID int) ([]model.Credit, error) {
+	return movieCredits(ctx, r.db, movieID)
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func movieCredits(ctx context.Context, q queryer, movieID int) ([]model.Credit, error) {
+	rows, err := q.QueryContext(
+		ctx,
+		`SELECT p.id, p.tmdb_id, p.name, p.profile_url, c.role, c.character, c.job, c.department, c.credit_order
+		FROM movie_credits c
+		JOIN people p ON p.id = c.person_id
+		WHERE c.movie_id = ?
+		ORDER BY c.role, c.credit_order, c.id`,
+		movieID,
+	)
+	if err != nil {
+		return nil, db.Classify(err)
+	}
+	defer rows.Close()
+
+	credits := make([]model.Credit, 0)
+	for rows.Next() {
+		var c model.Credit
+		if err := rows.Scan(&c.PersonID, &c.TMDBID, &c.Name, &c.ProfileURL, &c.Role, &c.Character, &c.Job, &c.Department, &c.Order); err != nil {
+			return nil, db.Classify(err)
+		}
+		credits = append(credits, c)
+	}
+	return credits, db.Classify(rows.Err())
+}
+
+// SetMovieCredits checks for the movie up front, as SetMovieGenres does, and
+// then upserts the people one by one.
+func (r *PersonRepository) SetMovieCredits(ctx context.Context, movieID int, credits []model.Credit) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, db.Classify(err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(
+		ctx,
+		`SELECT EXISTS (SELECT 1 FROM movies WHERE id = ?)`,
+		movieID,
+	).Scan(&exists); err != nil {
+		return false, db.Classify(err)
+	}
+	if !exists {
+		return false, postgres.ErrMovieNotFound
+	}
+
+	now := time.Now().UTC()
+	ids := make(map[int]int, len(credits))
+	for _, c := range credits {
+		if _, ok := ids[c.TMDBID]; ok {
+			continue
+		}
+		var id int
+		if err := tx.QueryRowContext(
+			ctx,
+			`INSERT INTO people (tmdb_id, name, profile_url, created_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT (tmdb_id) DO UPDATE SET name = excluded.name, profile_url = excluded.profile_url
+			RETURNING id`,
+			c.TMDBID,
+			c.Name,
+			c.ProfileURL,
+			now,
+		).Scan(&id); err != nil {
+			return false, db.Classify(err)
+		}
+		ids[c.TMDBID] = id
+	}
+
+	current, err := movieCredits(ctx, tx, movieID)
+	if err != nil {
+		return false, err
+	}
+
+	want := slices.SortedFunc(slices.Values(credits), model.CompareCredits)
+	slices.SortFunc(current, model.CompareCredits)
+	if slices.EqualFunc(current, want, model.Credit.SamePart) {
+		return false, db.Classify(tx.Commit())
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM movie_credits WHERE movie_id = ?`, movieID); err != nil {
+		return false, db.Classify(err)
+	}
+	for _, c := range want {
+		if _, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO movie_credits (movie_id, person_id, role, character, job, department, credit_order)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			movieID,
+			ids[c.TMDBID],
+			c.Role,
+			c.Character,
+			c.Job,
+			c.Department,
+			c.Order,
+		); err != nil {
+			return false, db.Classify(err)
+		}
+	}
+
+	return true, db.Classify(tx.Commit())
+}
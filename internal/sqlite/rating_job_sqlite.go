@@ -0,0 +1,155 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+// ratingJobRetryDelay is how long a job that failed waits before it is
+// claimed again.
+const ratingJobRetryDelay = 30 * time.Second
+
+type RatingJobRepository struct {
+	db *DB
+}
+
+func NewRatingJobRepository(database *DB) *RatingJobRepository {
+	return &RatingJobRepository{db: database}
+}
+
+// enqueueRating records that movieID needs its rating recomputed. It runs in
+// the caller's transaction so the job is stored if and only if the review
+// change is.
+func enqueueRating(ctx context.Context, tx *sql.Tx, movieID int) error {
+	now := time.Now().UTC()
+	_, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO rating_jobs (movie_id, enqueued_at, next_attempt_at) VALUES (?1, ?2, ?2)
+		ON CONFLICT (movie_id) DO UPDATE
+		SET enqueued_at = ?2, next_attempt_at = ?2, attempts = 0, last_error = ''`,
+		movieID,
+		now,
+	)
+	return err
+}
+
+// withRatingJob runs fn in a transaction and, if fn succeeds, queues a
+// rating recomputation for the movie fn touched in the same transaction.
+// Review and diary writes go through it.
+func withRatingJob(ctx context.Context, database *DB, fn func(ctx context.Context, tx *sql.Tx) (int, error)) error {
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return db.Classify(err)
+	}
+	defer tx.Rollback()
+
+	movieID, err := fn(ctx, tx)
+	if err != nil {
+		return db.Classify(err)
+	}
+	if err := enqueueRating(ctx, tx, movieID); err != nil {
+		return db.Classify(err)
+	}
+	return db.Classify(tx.Commit())
+}
+
+// ProcessNext claims one due job, recomputes that movie's rating and deletes
+// the job, all in one transaction. The rating averages one score per user:
+// their latest scored diary entry, or else their review. It returns false
+// when no job is due. Transactions take the write lock up front
+// (_txlock=immediate), so concurrent workers queue behind each other instead
+// of double-claiming. A job whose recomputation fails is put back for a
+// retry and reported as postgres.ErrRatingJobFailed.
+func (r *RatingJobRepository) ProcessNext(ctx context.Context) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, db.Classify(err)
+	}
+	defer tx.Rollback()
+
+	var movieID int
+	err = tx.QueryRowContext(
+		ctx,
+		`SELECT movie_id FROM rating_jobs
+		WHERE next_attempt_at <= ?
+		ORDER BY enqueued_at
+		LIMIT 1`,
+		time.Now().UTC(),
+	).Scan(&movieID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, db.Classify(err)
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`UPDATE movies
+		SET rating = COALESCE((
+			SELECT AVG(score) FROM (
+				SELECT score, row_number() OVER (
+					PARTITION BY user_id ORDER BY source DESC, watched_on DESC, id DESC
+				) AS n
+				FROM (
+					SELECT user_id, score, 1 AS source, watched_on, id
+					FROM diary_entries
+					WHERE movie_id = ?1 AND score IS NOT NULL
+					UNION ALL
+					SELECT user_id, score, 0, NULL, id
+					FROM reviews
+					WHERE movie_id = ?1
+				)
+			)
+			WHERE n = 1
+		), 0)
+		WHERE id = ?1`,
+		movieID,
+	); err != nil {
+		tx.Rollback()
+		return true, r.markFailed(ctx, movieID, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM rating_jobs WHERE movie_id = ?`, movieID); err != nil {
+		return true, db.Classify(err)
+	}
+
+	return true, db.Classify(tx.Commit())
+}
+
+// markFailed counts a failed attempt and holds the job back for
+// ratingJobRetryDelay. As in the Postgres backend, the bookkeeping does not
+// share the failed attempt's deadline; only a caller that gave up leaves the
+// job as it was.
+func (r *RatingJobRepository) markFailed(ctx context.Context, movieID int, cause error) error {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return db.Classify(cause)
+	}
+
+	_, err := r.db.ExecContext(
+		context.WithoutCancel(ctx),
+		`UPDATE rating_jobs
+		SET attempts = attempts + 1, last_error = ?, next_attempt_at = ?
+		WHERE movie_id = ?`,
+		cause.Error(),
+		time.Now().UTC().Add(ratingJobRetryDelay),
+		movieID,
+	)
+	if err != nil {
+		return errors.Join(db.Classify(cause), db.Classify(err))
+	}
+	return fmt.Errorf("%w: movie %d: %w", postgres.ErrRatingJobFailed, movieID, db.Classify(cause))
+}
+
+// Pending returns the number of queued jobs.
+func (r *RatingJobRepository) Pending(ctx context.Context) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM rating_jobs`).Scan(&n)
+	return n, db.Classify(err)
+}
@@ -0,0 +1,219 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+type ReviewRepository struct {
+	db *DB
+}
+
+func NewReviewRepository(database *DB) *ReviewRepository {
+	return &ReviewRepository{db: database}
+}
+
+// Add checks for the movie up front: SQLite reports foreign key failures
+// without naming the constraint, so a missing movie could not be told apart
+// from a missing user afterwards.
+func (r *ReviewRepository) Add(ctx context.Context, movieID int, rev model.Review) (model.Review, error) {
+	rev.CreatedAt = time.Now().UTC()
+
+	err := withRatingJob(ctx, r.db, func(ctx context.Context, tx *sql.Tx) (int, error) {
+		var exists bool
+		if err := tx.QueryRowContext(
+			ctx,
+			`SELECT EXISTS (SELECT 1 FROM movies WHERE id = ?)`,
+			movieID,
+		).Scan(&exists); err != nil {
+			return 0, err
+		}
+		if !exists {
+			return 0, postgres.ErrMovieNotFound
+		}
+
+		if err := tx.QueryRowContext(
+			ctx,
+			`INSERT INTO reviews (movie_id, user_id, score, text, created_at)
+			VALUES (?, ?, ?, ?, ?)
+			RETURNING id`,
+			movieID,
+			rev.UserID,
+			rev.Score,
+			rev.Text,
+			rev.CreatedAt,
+		).Scan(&rev.ID); err != nil {
+			return 0, err
+		}
+		return movieID, publishActivity(ctx, tx, model.ActivityReview, rev.UserID, model.ActivitySubject{ReviewID: rev.ID})
+	})
+	switch {
+	case isUniqueViolation(err):
+		return model.Review{}, postgres.ErrReviewExists
+	case err != nil:
+		return model.Review{}, err
+	}
+
+	rev.MovieID = movieID
+	return rev, nil
+}
+
+func (r *ReviewRepository) ListByMovieID(ctx context.Context, movieID int) ([]model.Review, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT id, movie_id, user_id, score, text, created_at
+		FROM reviews
+		WHERE movie_id = ?
+		ORDER BY id`,
+		movieID,
+	)
+	if err != nil {
+		return nil, db.Classify(err)
+	}
+
+	revs, err := collectReviews(rows)
+	return revs, db.Classify(err)
+}
+
+func (r *ReviewRepository) PageByMovieID(ctx context.Context, movieID int, opts model.ListOptions) ([]model.Review, int, error) {
+	order, err := orderBy(reviewSortColumns, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	limit, offset := pageLimit(opts)
+
+	var total int
+	if err := r.db.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*) FROM reviews WHERE movie_id = ?`,
+		movieID,
+	).Scan(&total); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT id, movie_id, user_id, score, text, created_at
+		FROM reviews
+		WHERE movie_id = ?
+		ORDER BY `+order+`
+		LIMIT ? OFFSET ?`,
+		movieID,
+		limit,
+		offset,
+	)
+	if err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	revs, err := collectReviews(rows)
+	return revs, total, db.Classify(err)
+}
+
+func (r *ReviewRepository) UpdateByMovieAndUser(
+	ctx context.Context,
+	movieID int,
+	userID int,
+	score int,
+) error {
+	return withRatingJob(ctx, r.db, func(ctx context.Context, tx *sql.Tx) (int, error) {
+		res, err := tx.ExecContext(
+			ctx,
+			`UPDATE reviews SET score = ? WHERE movie_id = ? AND user_id = ?`,
+			score,
+			movieID,
+			userID,
+		)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return 0, postgres.ErrReviewNotFound
+		}
+		return movieID, nil
+	})
+}
+
+func (r *ReviewRepository) DeleteByMovieAndUser(
+	ctx context.Context,
+	movieID int,
+	userID int,
+) error {
+	return withRatingJob(ctx, r.db, func(ctx context.Context, tx *sql.Tx) (int, error) {
+		res, err := tx.ExecContext(
+			ctx,
+			`DELETE FROM reviews WHERE movie_id = ? AND user_id = ?`,
+			movieID,
+			userID,
+		)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return 0, postgres.ErrReviewNotFound
+		}
+		return movieID, nil
+	})
+}
+
+func (r *ReviewRepository) GetByID(ctx context.Context, id int) (model.Review, error) {
+	var rev model.Review
+	err := r.db.QueryRowContext(
+		ctx,
+		`SELECT id, movie_id, user_id, score, text, created_at FROM reviews WHERE id = ?`,
+		id,
+	).Scan(
+		&rev.ID,
+		&rev.MovieID,
+		&rev.UserID,
+		&rev.Score,
+		&rev.Text,
+		&rev.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Review{}, postgres.ErrReviewNotFound
+	}
+	if err != nil {
+		return model.Review{}, db.Classify(err)
+	}
+	return rev, nil
+}
+
+func (r *ReviewRepository) DeleteByID(ctx context.Context, id int) error {
+	return withRatingJob(ctx, r.db, func(ctx context.Context, tx *sql.Tx) (int, error) {
+		var movieID int
+		err := tx.QueryRowContext(ctx, `DELETE FROM reviews WHERE id = ? RETURNING movie_id`, id).Scan(&movieID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, postgres.ErrReviewNotFound
+		}
+		return movieID, err
+	})
+}
+
+func collectReviews(rows *sql.Rows) ([]model.Review, error) {
+	defer rows.Close()
+
+	revs := make([]model.Review, 0)
+	for rows.Next() {
+		var rr model.Review
+		if err := rows.Scan(
+			&rr.ID,
+			&rr.MovieID,
+			&rr.UserID,
+			&rr.Score,
+			&rr.Text,
+			&rr.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		revs = append(revs, rr)
+	}
+
+	return revs, rows.Err()
+}
@@ -0,0 +1,37 @@
+package sqlite_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlikhanF2006/Final_project/configs"
+	"github.com/AlikhanF2006/Final_project/internal/sqlite"
+	"github.com/AlikhanF2006/Final_project/internal/storagetest"
+)
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	database, err := sqlite.Open(ctx, configs.SQLiteConfig{Path: filepath.Join(t.TempDir(), "movies.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	err = storagetest.Run(ctx, storagetest.Repos{
+		Movies:     sqlite.NewMovieRepository(database),
+		Reviews:    sqlite.NewReviewRepository(database),
+		Users:      sqlite.NewUserRepository(database),
+		Exports:    sqlite.NewExportRepository(database),
+		Genres:     sqlite.NewGenreRepository(database),
+		People:     sqlite.NewPersonRepository(database),
+		Watchlists: sqlite.NewWatchlistRepository(database),
+		Diary:      sqlite.NewDiaryRepository(database),
+		Lists:      sqlite.NewMovieListRepository(database),
+		Feed:       sqlite.NewFeedRepository(database),
+		RatingJobs: sqlite.NewRatingJobRepository(database),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,108 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/model"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+type SyncRunRepository struct {
+	db *DB
+}
+
+func NewSyncRunRepository(database *DB) *SyncRunRepository {
+	return &SyncRunRepository{db: database}
+}
+
+// Create records the start of a run.
+func (r *SyncRunRepository) Create(ctx context.Context, run model.SyncRun) (model.SyncRun, error) {
+	run.StartedAt = time.Now().UTC()
+	run.Errors = []string{}
+
+	err := r.db.QueryRowContext(
+		ctx,
+		`INSERT INTO tmdb_sync_runs (triggered_by, status, lists, started_at)
+		VALUES (?, ?, ?, ?)
+		RETURNING id`,
+		run.Trigger,
+		run.Status,
+		jsonStrings{&run.Lists},
+		run.StartedAt,
+	).Scan(&run.ID)
+
+	return run, db.Classify(err)
+}
+
+// Finish stores the run's outcome and counts and stamps finished_at.
+func (r *SyncRunRepository) Finish(ctx context.Context, run model.SyncRun) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		`UPDATE tmdb_sync_runs
+		SET status = ?, finished_at = ?, pages = ?, fetched = ?, created = ?,
+			updated = ?, unchanged = ?, failed = ?, errors = ?
+		WHERE id = ?`,
+		run.Status,
+		time.Now().UTC(),
+		run.Pages,
+		run.Fetched,
+		run.Created,
+		run.Updated,
+		run.Unchanged,
+		run.Failed,
+		jsonStrings{&run.Errors},
+		run.ID,
+	)
+	return db.Classify(err)
+}
+
+// List returns runs newest first.
+func (r *SyncRunRepository) List(ctx context.Context, opts model.ListOptions) ([]model.SyncRun, int, error) {
+	limit, offset := pageLimit(opts)
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM tmdb_sync_runs`).Scan(&total); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT id, triggered_by, status, lists, started_at, finished_at,
+			pages, fetched, created, updated, unchanged, failed, errors
+		FROM tmdb_sync_runs
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?`,
+		limit,
+		offset,
+	)
+	if err != nil {
+		return nil, 0, db.Classify(err)
+	}
+	defer rows.Close()
+
+	runs := make([]model.SyncRun, 0)
+	for rows.Next() {
+		var run model.SyncRun
+		if err := rows.Scan(
+			&run.ID,
+			&run.Trigger,
+			&run.Status,
+			jsonStrings{&run.Lists},
+			&run.StartedAt,
+			&run.FinishedAt,
+			&run.Pages,
+			&run.Fetched,
+			&run.Created,
+			&run.Updated,
+			&run.Unchanged,
+			&run.Failed,
+			jsonStrings{&run.Errors},
+		); err != nil {
+			return nil, 0, db.Classify(err)
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, total, db.Classify(rows.Err())
+}
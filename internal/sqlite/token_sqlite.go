@@ -0,0 +1,169 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+type TokenRepository struct {
+	db *DB
+}
+
+func NewTokenRepository(database *DB) *TokenRepository {
+	return &TokenRepository{db: database}
+}
+
+const insertRefreshToken = `
+	INSERT INTO refresh_tokens (user_id, token_hash, family_id, expires_at, created_at)
+	VALUES (?, ?, ?, ?, ?)
+	RETURNING id`
+
+func (r *TokenRepository) CreateRefreshToken(ctx context.Context, t model.RefreshToken) (model.RefreshToken, error) {
+	t.CreatedAt = time.Now().UTC()
+
+	err := r.db.QueryRowContext(
+		ctx,
+		insertRefreshToken,
+		t.UserID,
+		t.TokenHash,
+		t.FamilyID,
+		t.ExpiresAt.UTC(),
+		t.CreatedAt,
+	).Scan(&t.ID)
+
+	return t, db.Classify(err)
+}
+
+func (r *TokenRepository) GetRefreshToken(ctx context.Context, hash string) (model.RefreshToken, error) {
+	var t model.RefreshToken
+	err := r.db.QueryRowContext(
+		ctx,
+		`SELECT id, user_id, token_hash, family_id, expires_at, revoked_at, replaced_by, created_at
+		FROM refresh_tokens WHERE token_hash = ?`,
+		hash,
+	).Scan(
+		&t.ID,
+		&t.UserID,
+		&t.TokenHash,
+		&t.FamilyID,
+		&t.ExpiresAt,
+		&t.RevokedAt,
+		&t.ReplacedBy,
+		&t.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.RefreshToken{}, postgres.ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return model.RefreshToken{}, db.Classify(err)
+	}
+	return t, nil
+}
+
+// RotateRefreshToken revokes oldID and stores next in its place in one
+// transaction. If oldID was already revoked (the token is being replayed) it
+// returns ErrRefreshTokenReused and stores nothing.
+func (r *TokenRepository) RotateRefreshToken(ctx context.Context, oldID int, next model.RefreshToken) (model.RefreshToken, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.RefreshToken{}, db.Classify(err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	next.CreatedAt = now
+	if err := tx.QueryRowContext(
+		ctx,
+		insertRefreshToken,
+		next.UserID,
+		next.TokenHash,
+		next.FamilyID,
+		next.ExpiresAt.UTC(),
+		next.CreatedAt,
+	).Scan(&next.ID); err != nil {
+		return model.RefreshToken{}, db.Classify(err)
+	}
+
+	res, err := tx.ExecContext(
+		ctx,
+		`UPDATE refresh_tokens SET revoked_at = ?, replaced_by = ?
+		WHERE id = ? AND revoked_at IS NULL`,
+		now,
+		next.ID,
+		oldID,
+	)
+	if err != nil {
+		return model.RefreshToken{}, db.Classify(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return model.RefreshToken{}, postgres.ErrRefreshTokenReused
+	}
+
+	return next, db.Classify(tx.Commit())
+}
+
+func (r *TokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		`UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL`,
+		time.Now().UTC(),
+		familyID,
+	)
+	return db.Classify(err)
+}
+
+func (r *TokenRepository) RevokeAllForUser(ctx context.Context, userID int) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		`UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL`,
+		time.Now().UTC(),
+		userID,
+	)
+	return db.Classify(err)
+}
+
+// RevokeAccessToken denylists one access token until it expires. Expired
+// denylist entries are pruned on the way.
+func (r *TokenRepository) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	if _, err := r.db.ExecContext(
+		ctx,
+		`DELETE FROM revoked_tokens WHERE expires_at < ?`,
+		time.Now().UTC(),
+	); err != nil {
+		return db.Classify(err)
+	}
+
+	_, err := r.db.ExecContext(
+		ctx,
+		`INSERT INTO revoked_tokens (jti, expires_at) VALUES (?, ?)
+		ON CONFLICT (jti) DO NOTHING`,
+		jti,
+		expiresAt.UTC(),
+	)
+	return db.Classify(err)
+}
+
+// SessionState returns the user's current token version and whether jti has
+// been denylisted. It returns ErrUserNotFound once the user is deleted.
+func (r *TokenRepository) SessionState(ctx context.Context, userID int, jti string) (int, bool, error) {
+	var version int
+	var revoked bool
+	err := r.db.QueryRowContext(
+		ctx,
+		`SELECT u.token_version,
+			EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = ?2)
+		FROM users u WHERE u.id = ?1`,
+		userID,
+		jti,
+	).Scan(&version, &revoked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, postgres.ErrUserNotFound
+	}
+	return version, revoked, db.Classify(err)
+}
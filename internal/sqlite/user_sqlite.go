@@ -0,0 +1,170 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+type UserRepository struct {
+	db *DB
+}
+
+func NewUserRepository(database *DB) *UserRepository {
+	return &UserRepository{db: database}
+}
+
+func (r *UserRepository) Create(ctx context.Context, u model.User) (model.User, error) {
+	u.CreatedAt = time.Now().UTC()
+
+	err := r.db.QueryRowContext(
+		ctx,
+		`INSERT INTO users (username, email, password_hash, role, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id`,
+		u.Username,
+		u.Email,
+		u.PasswordHash,
+		u.Role,
+		u.CreatedAt,
+	).Scan(&u.ID)
+	if isUniqueViolation(err) {
+		return model.User{}, postgres.ErrUserExists
+	}
+
+	return u, db.Classify(err)
+}
+
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (model.User, error) {
+	return r.getOne(ctx, `WHERE email = ?`, email)
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id int) (model.User, error) {
+	return r.getOne(ctx, `WHERE id = ?`, id)
+}
+
+func (r *UserRepository) Update(ctx context.Context, u model.User) (model.User, error) {
+	res, err := r.db.ExecContext(
+		ctx,
+		`UPDATE users SET username = ?, email = ? WHERE id = ?`,
+		u.Username,
+		u.Email,
+		u.ID,
+	)
+	if isUniqueViolation(err) {
+		return model.User{}, postgres.ErrUserExists
+	}
+	if err != nil {
+		return model.User{}, db.Classify(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return model.User{}, postgres.ErrUserNotFound
+	}
+	return u, nil
+}
+
+// UpdatePassword also bumps token_version, which invalidates every access
+// token issued before the change.
+func (r *UserRepository) UpdatePassword(ctx context.Context, id int, hash string) error {
+	res, err := r.db.ExecContext(
+		ctx,
+		`UPDATE users SET password_hash = ?, token_version = token_version + 1 WHERE id = ?`,
+		hash,
+		id,
+	)
+	if err != nil {
+		return db.Classify(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return postgres.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *UserRepository) BumpTokenVersion(ctx context.Context, id int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE users SET token_version = token_version + 1 WHERE id = ?`, id)
+	return db.Classify(err)
+}
+
+func (r *UserRepository) UpdateRole(ctx context.Context, id int, role string) error {
+	res, err := r.db.ExecContext(
+		ctx,
+		`UPDATE users SET role = ?, token_version = token_version + 1 WHERE id = ?`,
+		role,
+		id,
+	)
+	if err != nil {
+		return db.Classify(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return postgres.ErrUserNotFound
+	}
+	return nil
+}
+
+// Delete removes the user with everything that cascades from it, and
+// queues a rating recomputation for every movie the user's reviews and
+// diary entries scored, in the same transaction.
+func (r *UserRepository) Delete(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return db.Classify(err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT movie_id FROM reviews WHERE user_id = ?1
+		UNION
+		SELECT movie_id FROM diary_entries WHERE user_id = ?1`,
+		id,
+	)
+	if err != nil {
+		return db.Classify(err)
+	}
+	var movieIDs []int
+	for rows.Next() {
+		var movieID int
+		if err := rows.Scan(&movieID); err != nil {
+			rows.Close()
+			return db.Classify(err)
+		}
+		movieIDs = append(movieIDs, movieID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return db.Classify(err)
+	}
+	for _, movieID := range movieIDs {
+		if err := enqueueRating(ctx, tx, movieID); err != nil {
+			return db.Classify(err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id); err != nil {
+		return db.Classify(err)
+	}
+	return db.Classify(tx.Commit())
+}
+
+func (r *UserRepository) getOne(ctx context.Context, where string, arg any) (model.User, error) {
+	var u model.User
+	err := r.db.QueryRowContext(
+		ctx,
+		`SELECT id, username, email, password_hash, role, token_version, created_at
+		FROM users `+where,
+		arg,
+	).Scan(&u.ID, &u.Username, &u.Email, &u.PasswordHash, &u.Role, &u.TokenVersion, &u.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.User{}, postgres.ErrUserNotFound
+	}
+	if err != nil {
+		return model.User{}, db.Classify(err)
+	}
+	return u, nil
+}
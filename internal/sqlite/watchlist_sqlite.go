@@ -0,0 +1,232 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+	"github.com/AlikhanF2006/Final_project/pkg/db"
+)
+
+const watchlistColumns = `w.id, w.position, w.note, coalesce(w.planned_for, ''), w.created_at,
+	movies.id, movies.tmdb_id, movies.title, movies.year, movies.description, movies.rating, movies.overrides`
+
+const watchlistFrom = `
+	FROM watchlist_entries w
+	JOIN movies ON movies.id = w.movie_id`
+
+// watchlistFields returns scan targets for watchlistColumns.
+func watchlistFields(e *model.WatchlistEntry) []any {
+	return append([]any{&e.ID, &e.Position, &e.Note, &e.PlannedFor, &e.AddedAt}, movieFields(&e.Movie)...)
+}
+
+type WatchlistRepository struct {
+	db *DB
+}
+
+func NewWatchlistRepository(database *DB) *WatchlistRepository {
+	return &WatchlistRepository{db: database}
+}
+
+func (r *WatchlistRepository) List(ctx context.Context, userID int, f model.WatchlistFilter, opts model.ListOptions) ([]model.WatchlistEntry, int, error) {
+	order, err := qualifiedOrder(watchlistSortColumns, "position", "w.id", opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	limit, offset := pageLimit(opts)
+
+	where := ""
+	args := []any{userID}
+	if f.DueBy != "" {
+		args = append(args, f.DueBy)
+		where = fmt.Sprintf("\n\t\t  AND w.planned_for <= ?%d", len(args))
+	}
+	filter, args := movieFilterSQL(f.MovieFilter, args)
+	from := watchlistFrom + `
+		WHERE w.user_id = ?1` + where + filter
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*)`+from, args...).Scan(&total); err != nil {
+		return nil, 0, db.Classify(err)
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT `+watchlistColumns+from+`
+		ORDER BY `+order+
+			fmt.Sprintf(` LIMIT ?%d OFFSET ?%d`, len(args)+1, len(args)+2),
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, db.Classify(err)
+	}
+	defer rows.Close()
+
+	entries := make([]model.WatchlistEntry, 0)
+	for rows.Next() {
+		var e model.WatchlistEntry
+		if err := rows.Scan(watchlistFields(&e)...); err != nil {
+			return nil, 0, db.Classify(err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, db.Classify(rows.Err())
+}
+
+func (r *WatchlistRepository) Get(ctx context.Context, userID, movieID int) (model.WatchlistEntry, error) {
+	var e model.WatchlistEntry
+	err := r.db.QueryRowContext(
+		ctx,
+		`SELECT `+watchlistColumns+watchlistFrom+`
+		WHERE w.user_id = ? AND w.movie_id = ?`,
+		userID,
+		movieID,
+	).Scan(watchlistFields(&e)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.WatchlistEntry{}, postgres.ErrWatchlistEntryNotFound
+	}
+	return e, db.Classify(err)
+}
+
+// Add checks for the movie up front: SQLite reports foreign key failures
+// without naming the constraint.
+func (r *WatchlistRepository) Add(ctx context.Context, userID int, e model.WatchlistEntry) (model.WatchlistEntry, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.WatchlistEntry{}, db.Classify(err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(
+		ctx,
+		`SELECT EXISTS (SELECT 1 FROM movies WHERE id = ?)`,
+		e.Movie.ID,
+	).Scan(&exists); err != nil {
+		return model.WatchlistEntry{}, db.Classify(err)
+	}
+	if !exists {
+		return model.WatchlistEntry{}, postgres.ErrMovieNotFound
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO watchlist_entries (user_id, movie_id, position, note, planned_for, created_at)
+		SELECT ?1, ?2, coalesce(max(position), 0) + 1, ?3, nullif(?4, ''), ?5
+		FROM watchlist_entries
+		WHERE user_id = ?1`,
+		userID,
+		e.Movie.ID,
+		e.Note,
+		e.PlannedFor,
+		time.Now().UTC(),
+	)
+	if isUniqueViolation(err) {
+		return model.WatchlistEntry{}, postgres.ErrWatchlistEntryExists
+	}
+	if err != nil {
+		return model.WatchlistEntry{}, db.Classify(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return model.WatchlistEntry{}, db.Classify(err)
+	}
+
+	return r.Get(ctx, userID, e.Movie.ID)
+}
+
+func (r *WatchlistRepository) Update(ctx context.Context, userID int, e model.WatchlistEntry) (model.WatchlistEntry, error) {
+	res, err := r.db.ExecContext(
+		ctx,
+		`UPDATE watchlist_entries SET note = ?, planned_for = nullif(?, '')
+		WHERE user_id = ? AND movie_id = ?`,
+		e.Note,
+		e.PlannedFor,
+		userID,
+		e.Movie.ID,
+	)
+	if err != nil {
+		return model.WatchlistEntry{}, db.Classify(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return model.WatchlistEntry{}, postgres.ErrWatchlistEntryNotFound
+	}
+
+	return r.Get(ctx, userID, e.Movie.ID)
+}
+
+// Move puts the movie's entry at position (clamped to the list) and
+// renumbers the watchlist from 1.
+func (r *WatchlistRepository) Move(ctx context.Context, userID, movieID, position int) (model.WatchlistEntry, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.WatchlistEntry{}, db.Classify(err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT movie_id FROM watchlist_entries WHERE user_id = ? ORDER BY position, id`,
+		userID,
+	)
+	if err != nil {
+		return model.WatchlistEntry{}, db.Classify(err)
+	}
+	var order []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return model.WatchlistEntry{}, db.Classify(err)
+		}
+		order = append(order, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return model.WatchlistEntry{}, db.Classify(err)
+	}
+
+	i := slices.Index(order, movieID)
+	if i < 0 {
+		return model.WatchlistEntry{}, postgres.ErrWatchlistEntryNotFound
+	}
+	order = slices.Delete(order, i, i+1)
+	order = slices.Insert(order, min(max(position, 1), len(order)+1)-1, movieID)
+
+	for n, id := range order {
+		if _, err := tx.ExecContext(
+			ctx,
+			`UPDATE watchlist_entries SET position = ?1 WHERE user_id = ?2 AND movie_id = ?3 AND position <> ?1`,
+			n+1,
+			userID,
+			id,
+		); err != nil {
+			return model.WatchlistEntry{}, db.Classify(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return model.WatchlistEntry{}, db.Classify(err)
+	}
+
+	return r.Get(ctx, userID, movieID)
+}
+
+func (r *WatchlistRepository) Remove(ctx context.Context, userID, movieID int) error {
+	res, err := r.db.ExecContext(
+		ctx,
+		`DELETE FROM watchlist_entries WHERE user_id = ? AND movie_id = ?`,
+		userID,
+		movieID,
+	)
+	if err != nil {
+		return db.Classify(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return postgres.ErrWatchlistEntryNotFound
+	}
+	return nil
+}
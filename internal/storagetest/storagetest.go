@@ -0,0 +1,1276 @@
+// Package storagetest checks that a storage backend behaves like the Postgres
+// one. Like testing/fstest it does not depend on package testing: Run
+// exercises the repositories and returns an error listing every mismatch, so
+// a test for any backend is a single call:
+//
+//	if err := storagetest.Run(ctx, repos); err != nil {
+//		t.Fatal(err)
+//	}
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/AlikhanF2006/Final_project/internal/postgres"
+	"github.com/AlikhanF2006/Final_project/model"
+)
+
+// Repos is the backend under test. The repositories must share one empty
+// store: Run creates its own users, movies and reviews and expects to see
+// only those.
+type Repos struct {
+	Movies  postgres.MovieRepo
+	Reviews postgres.ReviewRepo
+	Users   postgres.UserRepo
+	// Exports, Genres, People, Watchlists, Diary, Lists and Feed are checked
+	// when set; with RatingJobs, so are the ratings diary entries lead to.
+	Exports    postgres.ExportRepo
+	Genres     postgres.GenreRepo
+	People     postgres.PersonRepo
+	Watchlists postgres.WatchlistRepo
+	Diary      postgres.DiaryRepo
+	Lists      postgres.MovieListRepo
+	Feed       postgres.FeedRepo
+	RatingJobs postgres.RatingJobRepo
+}
+
+type checker struct {
+	ctx    context.Context
+	r      Repos
+	errs   []error
+	prefix string
+}
+
+func (c *checker) errorf(format string, args ...any) {
+	c.errs = append(c.errs, fmt.Errorf(c.prefix+": "+format, args...))
+}
+
+// must records err and reports whether the check can go on.
+func (c *checker) must(what string, err error) bool {
+	if err != nil {
+		c.errorf("%s: %v", what, err)
+		return false
+	}
+	return true
+}
+
+func (c *checker) wantErr(what string, got, want error) {
+	if !errors.Is(got, want) {
+		c.errorf("%s: got error %v, want %v", what, got, want)
+	}
+}
+
+// wantRating works through the queued rating jobs, then checks the stored
+// rating of movie id. It does nothing without RatingJobs.
+func (c *checker) wantRating(what string, id int, want float64) {
+	if c.r.RatingJobs == nil {
+		return
+	}
+	for {
+		more, err := c.r.RatingJobs.ProcessNext(c.ctx)
+		if !c.must(what, err) || !more {
+			break
+		}
+	}
+	if got, err := c.r.Movies.GetByID(c.ctx, id); c.must(what, err) && got.Rating != want {
+		c.errorf("%s: got rating %v, want %v", what, got.Rating, want)
+	}
+}
+
+// Run checks movies, reviews and users in turn, including the uniqueness
+// rules and cascading deletes the Postgres schema enforces.
+func Run(ctx context.Context, r Repos) error {
+	c := &checker{ctx: ctx, r: r}
+
+	for _, step := range []struct {
+		name string
+		fn   func(*checker)
+	}{
+		{"users", checkUsers},
+		{"movies", checkMovies},
+		{"upsert", checkUpsert},
+		{"search", checkSearch},
+		{"reviews", checkReviews},
+		{"cascade", checkCascade},
+		{"export", checkExport},
+		{"genres", checkGenres},
+		{"people", checkPeople},
+		{"watchlist", checkWatchlist},
+		{"diary", checkDiary},
+		{"lists", checkLists},
+		{"feed", checkFeed},
+	} {
+		c.prefix = step.name
+		step.fn(c)
+	}
+
+	return errors.Join(c.errs...)
+}
+
+func checkUsers(c *checker) {
+	u, err := c.r.Users.Create(c.ctx, model.User{Username: "ann", Email: "ann@example.com", PasswordHash: "x", Role: "user"})
+	if !c.must("create", err) {
+		return
+	}
+	if u.ID == 0 || u.CreatedAt.IsZero() {
+		c.errorf("create: id and created_at not set: %+v", u)
+	}
+
+	_, err = c.r.Users.Create(c.ctx, model.User{Username: "ann", Email: "other@example.com", PasswordHash: "x", Role: "user"})
+	c.wantErr("duplicate username", err, postgres.ErrUserExists)
+	_, err = c.r.Users.Create(c.ctx, model.User{Username: "other", Email: "ann@example.com", PasswordHash: "x", Role: "user"})
+	c.wantErr("duplicate email", err, postgres.ErrUserExists)
+
+	got, err := c.r.Users.GetByEmail(c.ctx, "ann@example.com")
+	if c.must("get by email", err) && got.ID != u.ID {
+		c.errorf("get by email: got id %d, want %d", got.ID, u.ID)
+	}
+	_, err = c.r.Users.GetByEmail(c.ctx, "nobody@example.com")
+	c.wantErr("get by unknown email", err, postgres.ErrUserNotFound)
+	_, err = c.r.Users.GetByID(c.ctx, u.ID+1000)
+	c.wantErr("get by unknown id", err, postgres.ErrUserNotFound)
+
+	bob, err := c.r.Users.Create(c.ctx, model.User{Username: "bob", Email: "bob@example.com", PasswordHash: "x", Role: "user"})
+	if c.must("create second", err) {
+		bob.Email = "ann@example.com"
+		_, err = c.r.Users.Update(c.ctx, bob)
+		c.wantErr("update to taken email", err, postgres.ErrUserExists)
+	}
+
+	c.must("update password", c.r.Users.UpdatePassword(c.ctx, u.ID, "y"))
+	c.must("update role", c.r.Users.UpdateRole(c.ctx, u.ID, "moderator"))
+	got, err = c.r.Users.GetByID(c.ctx, u.ID)
+	if c.must("get by id", err) {
+		if got.PasswordHash != "y" || got.Role != "moderator" {
+			c.errorf("get by id: password or role not updated: %+v", got)
+		}
+		if got.TokenVersion != u.TokenVersion+2 {
+			c.errorf("token version: got %d, want %d", got.TokenVersion, u.TokenVersion+2)
+		}
+	}
+	c.wantErr("update role of unknown user", c.r.Users.UpdateRole(c.ctx, u.ID+1000, "user"), postgres.ErrUserNotFound)
+	c.wantErr("update password of unknown user", c.r.Users.UpdatePassword(c.ctx, u.ID+1000, "y"), postgres.ErrUserNotFound)
+	_, err = c.r.Users.Update(c.ctx, model.User{ID: u.ID + 1000, Username: "ghost", Email: "ghost@example.com"})
+	c.wantErr("update unknown user", err, postgres.ErrUserNotFound)
+
+	c.must("delete", c.r.Users.Delete(c.ctx, u.ID))
+	_, err = c.r.Users.GetByID(c.ctx, u.ID)
+	c.wantErr("get deleted", err, postgres.ErrUserNotFound)
+	if bob.ID != 0 {
+		c.must("delete second", c.r.Users.Delete(c.ctx, bob.ID))
+	}
+}
+
+func checkMovies(c *checker) {
+	var ids []int
+	for i, m := range []model.Movie{
+		{TMDBID: 101, Title: "Beta", Year: 2001, Rating: 3},
+		{TMDBID: 102, Title: "Alpha", Year: 2003, Rating: 5},
+		{Title: "Gamma", Year: 2002, Rating: 4},
+		{Title: "Delta", Year: 2002, Rating: 1},
+	} {
+		created, err := c.r.Movies.Create(c.ctx, m)
+		if !c.must(fmt.Sprintf("create #%d", i), err) {
+			return
+		}
+		// Only the rating workers write ratings.
+		if created.Rating != 0 {
+			c.errorf("create #%d: got rating %v, want 0", i, created.Rating)
+		}
+		ids = append(ids, created.ID)
+	}
+	defer func() {
+		for _, id := range ids {
+			c.must("cleanup", c.r.Movies.Delete(c.ctx, id))
+		}
+	}()
+
+	_, err := c.r.Movies.Create(c.ctx, model.Movie{TMDBID: 101, Title: "Copy", Year: 2001})
+	c.wantErr("duplicate tmdb id", err, postgres.ErrMovieExists)
+
+	exists, err := c.r.Movies.ExistsByTMDBID(c.ctx, 102)
+	if c.must("exists", err) && !exists {
+		c.errorf("exists: tmdb id 102 not found")
+	}
+	if m, err := c.r.Movies.GetByTMDBID(c.ctx, 102); c.must("get by tmdb id", err) && m.ID != ids[1] {
+		c.errorf("get by tmdb id: got id %d, want %d", m.ID, ids[1])
+	}
+	_, err = c.r.Movies.GetByID(c.ctx, ids[len(ids)-1]+1000)
+	c.wantErr("get unknown", err, postgres.ErrMovieNotFound)
+
+	page, total, err := c.r.Movies.List(c.ctx, model.MovieFilter{}, model.ListOptions{Sort: "year", Desc: true, Limit: 2, Offset: 1})
+	if c.must("list", err) {
+		if total != 4 {
+			c.errorf("list: got total %d, want 4", total)
+		}
+		// Years 2003, 2002, 2002, 2001; ties on year go by id, descending.
+		if want := []int{ids[3], ids[2]}; !sameIDs(page, want) {
+			c.errorf("list: got %v, want ids %v", movieIDs(page), want)
+		}
+	}
+	_, _, err = c.r.Movies.List(c.ctx, model.MovieFilter{}, model.ListOptions{Sort: "nope"})
+	c.wantErr("list with unknown sort", err, postgres.ErrBadSort)
+
+	c.must("set rating", c.r.Movies.SetRating(c.ctx, ids[0], 3))
+	upd := model.Movie{ID: ids[0], Title: "Beta 2", Year: 2004, Description: "new", Rating: 2}
+	if updated, err := c.r.Movies.Update(c.ctx, upd); c.must("update", err) {
+		got, err := c.r.Movies.GetByID(c.ctx, ids[0])
+		if c.must("get updated", err) && (got.Title != upd.Title || got.Year != upd.Year || got.TMDBID != 101 || got.Rating != 3) {
+			c.errorf("update: got %+v", got)
+		}
+		if updated.Rating != 3 {
+			c.errorf("update: returned rating %v, want the stored 3", updated.Rating)
+		}
+	}
+	_, err = c.r.Movies.Update(c.ctx, model.Movie{ID: ids[len(ids)-1] + 1000, Title: "x", Year: 1})
+	c.wantErr("update unknown", err, postgres.ErrMovieNotFound)
+	c.wantErr("delete unknown", c.r.Movies.Delete(c.ctx, ids[len(ids)-1]+1000), postgres.ErrMovieNotFound)
+}
+
+func checkUpsert(c *checker) {
+	m, res, err := c.r.Movies.UpsertTMDB(c.ctx, model.Movie{TMDBID: 201, Title: "Up", Year: 2010, Description: "first"})
+	if !c.must("insert", err) {
+		return
+	}
+	defer func() { c.must("cleanup", c.r.Movies.Delete(c.ctx, m.ID)) }()
+	if res != model.UpsertCreated || m.ID == 0 {
+		c.errorf("insert: got %+v, result %d", m, res)
+	}
+
+	_, res, err = c.r.Movies.UpsertTMDB(c.ctx, model.Movie{TMDBID: 201, Title: "Up", Year: 2010, Description: "first"})
+	if c.must("same data", err) && res != model.UpsertUnchanged {
+		c.errorf("same data: got result %d, want unchanged", res)
+	}
+
+	c.must("set rating", c.r.Movies.SetRating(c.ctx, m.ID, 4))
+	m.Title = "Local title"
+	m.Overrides = []string{model.FieldTitle}
+	if _, err := c.r.Movies.Update(c.ctx, m); !c.must("override", err) {
+		return
+	}
+
+	got, res, err := c.r.Movies.UpsertTMDB(c.ctx, model.Movie{TMDBID: 201, Title: "Up (2010)", Year: 2010, Description: "second"})
+	if c.must("refresh", err) {
+		if res != model.UpsertUpdated {
+			c.errorf("refresh: got result %d, want updated", res)
+		}
+		if got.ID != m.ID || got.Title != "Local title" || got.Description != "second" || got.Rating != 4 {
+			c.errorf("refresh: got %+v", got)
+		}
+		if !got.Overridden(model.FieldTitle) {
+			c.errorf("refresh: overrides lost: %v", got.Overrides)
+		}
+	}
+
+	_, res, err = c.r.Movies.UpsertTMDB(c.ctx, model.Movie{TMDBID: 201, Title: "Other title", Year: 2010, Description: "second"})
+	if c.must("overridden field only", err) && res != model.UpsertUnchanged {
+		c.errorf("overridden field only: got result %d, want unchanged", res)
+	}
+}
+
+func checkSearch(c *checker) {
+	var ids []int
+	for _, m := range []model.Movie{
+		{Title: "The Matrix", Year: 1999, Description: "A hacker learns the truth about reality."},
+		{Title: "Spirited Away", Year: 2001, Description: "A girl wanders into the world of spirits."},
+		{Title: "Heat", Year: 1995, Description: "A detective hunts a crew of bank robbers."},
+	} {
+		created, err := c.r.Movies.Create(c.ctx, m)
+		if !c.must("create", err) {
+			return
+		}
+		ids = append(ids, created.ID)
+	}
+	defer func() {
+		for _, id := range ids {
+			c.must("cleanup", c.r.Movies.Delete(c.ctx, id))
+		}
+	}()
+
+	for _, tc := range []struct {
+		query string
+		year  int
+		want  []int
+	}{
+		{"matrix", 0, []int{ids[0]}},
+		{"bank robbers", 0, []int{ids[2]}},
+		{"spirted away", 0, []int{ids[1]}},
+		{"", 2001, []int{ids[1]}},
+		{"matrix", 2001, nil},
+		{"submarine", 0, nil},
+	} {
+		hits, total, err := c.r.Movies.Search(c.ctx, tc.query, model.MovieFilter{Year: tc.year}, model.ListOptions{})
+		if !c.must(fmt.Sprintf("search %q", tc.query), err) {
+			continue
+		}
+		got := make([]int, len(hits))
+		for i, h := range hits {
+			got[i] = h.ID
+		}
+		if total != len(tc.want) || !slices.Equal(got, tc.want) {
+			c.errorf("search %q year %d: got %v (total %d), want %v", tc.query, tc.year, got, total, tc.want)
+		}
+	}
+
+	_, _, err := c.r.Movies.Search(c.ctx, "", model.MovieFilter{}, model.ListOptions{Sort: "nope"})
+	c.wantErr("search with unknown sort", err, postgres.ErrBadSort)
+}
+
+func checkReviews(c *checker) {
+	u, err := c.r.Users.Create(c.ctx, model.User{Username: "rita", Email: "rita@example.com", PasswordHash: "x", Role: "user"})
+	if !c.must("create user", err) {
+		return
+	}
+	defer func() { c.must("cleanup user", c.r.Users.Delete(c.ctx, u.ID)) }()
+
+	m, err := c.r.Movies.Create(c.ctx, model.Movie{Title: "Reviewed", Year: 2000})
+	if !c.must("create movie", err) {
+		return
+	}
+	defer func() { c.must("cleanup movie", c.r.Movies.Delete(c.ctx, m.ID)) }()
+
+	rev, err := c.r.Reviews.Add(c.ctx, m.ID, model.Review{UserID: u.ID, Score: 4, Text: "good"})
+	if !c.must("add", err) {
+		return
+	}
+	if rev.ID == 0 || rev.MovieID != m.ID || rev.CreatedAt.IsZero() {
+		c.errorf("add: got %+v", rev)
+	}
+
+	_, err = c.r.Reviews.Add(c.ctx, m.ID, model.Review{UserID: u.ID, Score: 2})
+	c.wantErr("second review by the same user", err, postgres.ErrReviewExists)
+	_, err = c.r.Reviews.Add(c.ctx, m.ID+1000, model.Review{UserID: u.ID, Score: 2})
+	c.wantErr("review of unknown movie", err, postgres.ErrMovieNotFound)
+
+	c.must("update", c.r.Reviews.UpdateByMovieAndUser(c.ctx, m.ID, u.ID, 5))
+	if got, err := c.r.Reviews.GetByID(c.ctx, rev.ID); c.must("get", err) && got.Score != 5 {
+		c.errorf("update: got score %d, want 5", got.Score)
+	}
+	c.wantErr("update missing", c.r.Reviews.UpdateByMovieAndUser(c.ctx, m.ID, u.ID+1000, 5), postgres.ErrReviewNotFound)
+
+	page, total, err := c.r.Reviews.PageByMovieID(c.ctx, m.ID, model.ListOptions{Sort: "score"})
+	if c.must("page", err) && (total != 1 || len(page) != 1 || page[0].ID != rev.ID) {
+		c.errorf("page: got %+v (total %d)", page, total)
+	}
+	_, _, err = c.r.Reviews.PageByMovieID(c.ctx, m.ID, model.ListOptions{Sort: "nope"})
+	c.wantErr("page with unknown sort", err, postgres.ErrBadSort)
+
+	c.must("delete", c.r.Reviews.DeleteByMovieAndUser(c.ctx, m.ID, u.ID))
+	c.wantErr("delete again", c.r.Reviews.DeleteByMovieAndUser(c.ctx, m.ID, u.ID), postgres.ErrReviewNotFound)
+	_, err = c.r.Reviews.GetByID(c.ctx, rev.ID)
+	c.wantErr("get deleted", err, postgres.ErrReviewNotFound)
+	c.wantErr("delete deleted by id", c.r.Reviews.DeleteByID(c.ctx, rev.ID), postgres.ErrReviewNotFound)
+}
+
+func checkCascade(c *checker) {
+	u, err := c.r.Users.Create(c.ctx, model.User{Username: "cas", Email: "cas@example.com", PasswordHash: "x", Role: "user"})
+	if !c.must("create user", err) {
+		return
+	}
+	m1, err := c.r.Movies.Create(c.ctx, model.Movie{Title: "One", Year: 2000})
+	if !c.must("create movie", err) {
+		return
+	}
+	m2, err := c.r.Movies.Create(c.ctx, model.Movie{Title: "Two", Year: 2000})
+	if !c.must("create movie", err) {
+		return
+	}
+	defer func() { c.must("cleanup movie", c.r.Movies.Delete(c.ctx, m2.ID)) }()
+
+	r1, err := c.r.Reviews.Add(c.ctx, m1.ID, model.Review{UserID: u.ID, Score: 3})
+	if !c.must("add", err) {
+		return
+	}
+	r2, err := c.r.Reviews.Add(c.ctx, m2.ID, model.Review{UserID: u.ID, Score: 3})
+	if !c.must("add", err) {
+		return
+	}
+
+	c.wantRating("rating", m2.ID, 3)
+
+	c.must("delete movie", c.r.Movies.Delete(c.ctx, m1.ID))
+	_, err = c.r.Reviews.GetByID(c.ctx, r1.ID)
+	c.wantErr("review of deleted movie", err, postgres.ErrReviewNotFound)
+
+	// Deleting the only reviewer queues the movie for a recomputation.
+	c.must("delete user", c.r.Users.Delete(c.ctx, u.ID))
+	_, err = c.r.Reviews.GetByID(c.ctx, r2.ID)
+	c.wantErr("review of deleted user", err, postgres.ErrReviewNotFound)
+	c.wantRating("rating after deleting the reviewer", m2.ID, 0)
+}
+
+func checkExport(c *checker) {
+	if c.r.Exports == nil {
+		return
+	}
+
+	u, err := c.r.Users.Create(c.ctx, model.User{Username: "exp", Email: "exp@example.com", PasswordHash: "x", Role: "user"})
+	if !c.must("create user", err) {
+		return
+	}
+	defer func() { c.must("cleanup user", c.r.Users.Delete(c.ctx, u.ID)) }()
+
+	var ids []int
+	for _, m := range []model.Movie{
+		{Title: "The Matrix", Year: 1999, Description: "A hacker learns the truth about reality."},
+		{Title: "Heat", Year: 1995, Description: "A detective hunts a crew of bank robbers."},
+		{Title: "Ronin", Year: 1998},
+	} {
+		created, err := c.r.Movies.Create(c.ctx, m)
+		if !c.must("create movie", err) {
+			return
+		}
+		ids = append(ids, created.ID)
+	}
+	defer func() {
+		for _, id := range ids {
+			c.must("cleanup movie", c.r.Movies.Delete(c.ctx, id))
+		}
+	}()
+
+	for _, s := range []int{2, 5} {
+		other, err := c.r.Users.Create(c.ctx, model.User{Username: fmt.Sprintf("exp%d", s), Email: fmt.Sprintf("exp%d@example.com", s), PasswordHash: "x", Role: "user"})
+		if !c.must("create user", err) {
+			return
+		}
+		defer func() { c.must("cleanup user", c.r.Users.Delete(c.ctx, other.ID)) }()
+		if _, err := c.r.Reviews.Add(c.ctx, ids[1], model.Review{UserID: other.ID, Score: s}); !c.must("add review", err) {
+			return
+		}
+	}
+	if _, err := c.r.Reviews.Add(c.ctx, ids[0], model.Review{UserID: u.ID, Score: 4, Text: "good"}); !c.must("add review", err) {
+		return
+	}
+
+	var movies []model.Movie
+	err = c.r.Exports.ExportMovies(c.ctx, model.ExportFilter{Sort: "year", Desc: true}, func(m model.Movie) error {
+		movies = append(movies, m)
+		return nil
+	})
+	if c.must("movies", err) && !sameIDs(movies, []int{ids[0], ids[2], ids[1]}) {
+		c.errorf("movies: got %v", movieIDs(movies))
+	}
+
+	movies = nil
+	err = c.r.Exports.ExportMovies(c.ctx, model.ExportFilter{Query: "bank robbers"}, func(m model.Movie) error {
+		movies = append(movies, m)
+		return nil
+	})
+	if c.must("movies by query", err) && !sameIDs(movies, []int{ids[1]}) {
+		c.errorf("movies by query: got %v", movieIDs(movies))
+	}
+
+	var reviews []model.ExportedReview
+	err = c.r.Exports.ExportReviews(c.ctx, model.ExportFilter{UserID: u.ID}, func(r model.ExportedReview) error {
+		reviews = append(reviews, r)
+		return nil
+	})
+	if c.must("reviews by user", err) {
+		if len(reviews) != 1 || reviews[0].MovieTitle != "The Matrix" || reviews[0].Username != "exp" || reviews[0].Score != 4 {
+			c.errorf("reviews by user: got %+v", reviews)
+		}
+	}
+
+	var ratings []model.MovieRating
+	err = c.r.Exports.ExportRatings(c.ctx, model.ExportFilter{MovieFilter: model.MovieFilter{Year: 1995}}, func(r model.MovieRating) error {
+		ratings = append(ratings, r)
+		return nil
+	})
+	if c.must("ratings", err) {
+		want := model.MovieRating{MovieID: ids[1], Title: "Heat", Year: 1995, Reviews: 2, AverageScore: 3.5, MinScore: 2, MaxScore: 5}
+		if len(ratings) != 1 || ratings[0] != want {
+			c.errorf("ratings: got %+v, want %+v", ratings, want)
+		}
+	}
+
+	stop := errors.New("stop")
+	n := 0
+	err = c.r.Exports.ExportMovies(c.ctx, model.ExportFilter{}, func(model.Movie) error {
+		n++
+		return stop
+	})
+	if !errors.Is(err, stop) || n != 1 {
+		c.errorf("stop early: got %v after %d rows", err, n)
+	}
+
+	err = c.r.Exports.ExportReviews(c.ctx, model.ExportFilter{Sort: "nope"}, func(model.ExportedReview) error { return nil })
+	c.wantErr("unknown sort", err, postgres.ErrBadSort)
+}
+
+func checkGenres(c *checker) {
+	if c.r.Genres == nil {
+		return
+	}
+
+	horror, err := c.r.Genres.Create(c.ctx, model.Genre{Name: "Horror"})
+	if !c.must("create", err) {
+		return
+	}
+	_, err = c.r.Genres.Create(c.ctx, model.Genre{Name: "horror"})
+	c.wantErr("duplicate name", err, postgres.ErrGenreExists)
+
+	// A TMDB genre of the same name is linked to the one made by hand.
+	linked, err := c.r.Genres.EnsureTMDB(c.ctx, model.Genre{TMDBID: 27, Name: "Horror"})
+	if c.must("ensure by name", err) && (linked.ID != horror.ID || linked.TMDBID != 27) {
+		c.errorf("ensure by name: got %+v, want genre %d linked to 27", linked, horror.ID)
+	}
+	drama, err := c.r.Genres.EnsureTMDB(c.ctx, model.Genre{TMDBID: 18, Name: "Drama"})
+	if !c.must("ensure new", err) {
+		return
+	}
+	again, err := c.r.Genres.EnsureTMDB(c.ctx, model.Genre{TMDBID: 18, Name: "Drama (renamed on tmdb)"})
+	if c.must("ensure existing", err) && (again.ID != drama.ID || again.Name != "Drama") {
+		c.errorf("ensure existing: got %+v, want %+v", again, drama)
+	}
+
+	got, err := c.r.Genres.GetByName(c.ctx, "DRAMA")
+	if c.must("get by name", err) && got.ID != drama.ID {
+		c.errorf("get by name: got id %d, want %d", got.ID, drama.ID)
+	}
+	_, err = c.r.Genres.Update(c.ctx, model.Genre{ID: drama.ID, Name: "HORROR"})
+	c.wantErr("rename to taken name", err, postgres.ErrGenreExists)
+	_, err = c.r.Genres.Update(c.ctx, model.Genre{ID: drama.ID + 1000, Name: "x"})
+	c.wantErr("rename unknown", err, postgres.ErrGenreNotFound)
+
+	var ids []int
+	for _, m := range []model.Movie{
+		{Title: "Halloween", Year: 1978},
+		{Title: "The Thing", Year: 1982},
+		{Title: "Amadeus", Year: 1984},
+	} {
+		created, err := c.r.Movies.Create(c.ctx, m)
+		if !c.must("create movie", err) {
+			return
+		}
+		ids = append(ids, created.ID)
+	}
+	defer func() {
+		for _, id := range ids[1:] {
+			c.must("cleanup", c.r.Movies.Delete(c.ctx, id))
+		}
+		c.must("cleanup genre", c.r.Genres.Delete(c.ctx, horror.ID))
+	}()
+
+	for i, set := range [][]int{{horror.ID, drama.ID, horror.ID}, {horror.ID}, {drama.ID}} {
+		changed, err := c.r.Genres.SetMovieGenres(c.ctx, ids[i], set)
+		if c.must("set", err) && !changed {
+			c.errorf("set movie %d: not reported as changed", ids[i])
+		}
+	}
+	changed, err := c.r.Genres.SetMovieGenres(c.ctx, ids[1], []int{horror.ID})
+	if c.must("set same", err) && changed {
+		c.errorf("set same genres: reported as changed")
+	}
+	_, err = c.r.Genres.SetMovieGenres(c.ctx, ids[0], []int{drama.ID + 1000})
+	c.wantErr("set unknown genre", err, postgres.ErrGenreNotFound)
+	_, err = c.r.Genres.SetMovieGenres(c.ctx, ids[2]+1000, []int{drama.ID})
+	c.wantErr("set on unknown movie", err, postgres.ErrMovieNotFound)
+
+	byMovie, err := c.r.Genres.ForMovies(c.ctx, ids)
+	if c.must("for movies", err) {
+		names := func(gs []model.Genre) []string {
+			out := make([]string, len(gs))
+			for i, g := range gs {
+				out[i] = g.Name
+			}
+			return out
+		}
+		if got := names(byMovie[ids[0]]); !slices.Equal(got, []string{"Drama", "Horror"}) {
+			c.errorf("for movies: movie %d has %v, want [Drama Horror]", ids[0], got)
+		}
+	}
+
+	for _, tc := range []struct {
+		f    model.MovieFilter
+		want []int
+	}{
+		{model.MovieFilter{GenreID: horror.ID}, ids[:2]},
+		{model.MovieFilter{GenreID: horror.ID, YearFrom: 1980, YearTo: 1990}, ids[1:2]},
+		{model.MovieFilter{YearFrom: 1980}, ids[1:]},
+		{model.MovieFilter{GenreID: drama.ID, YearTo: 1980}, ids[:1]},
+	} {
+		movies, total, err := c.r.Movies.List(c.ctx, tc.f, model.ListOptions{})
+		if c.must("list", err) && (total != len(tc.want) || !sameIDs(movies, tc.want)) {
+			c.errorf("list %+v: got %v (total %d), want %v", tc.f, movieIDs(movies), total, tc.want)
+		}
+		hits, total, err := c.r.Movies.Search(c.ctx, "", tc.f, model.ListOptions{})
+		if c.must("search", err) {
+			got := make([]model.Movie, len(hits))
+			for i, h := range hits {
+				got[i] = h.Movie
+			}
+			if total != len(tc.want) || !sameIDs(got, tc.want) {
+				c.errorf("search %+v: got %v (total %d), want %v", tc.f, movieIDs(got), total, tc.want)
+			}
+		}
+	}
+
+	list, err := c.r.Genres.List(c.ctx)
+	if c.must("list genres", err) {
+		want := []model.Genre{
+			{ID: drama.ID, TMDBID: 18, Name: "Drama", Movies: 2},
+			{ID: horror.ID, TMDBID: 27, Name: "Horror", Movies: 2},
+		}
+		if !slices.Equal(list, want) {
+			c.errorf("list genres: got %+v, want %+v", list, want)
+		}
+	}
+
+	// Deleting a movie or a genre takes the links with it.
+	c.must("delete movie", c.r.Movies.Delete(c.ctx, ids[0]))
+	c.must("delete genre", c.r.Genres.Delete(c.ctx, drama.ID))
+	c.wantErr("delete deleted genre", c.r.Genres.Delete(c.ctx, drama.ID), postgres.ErrGenreNotFound)
+	byMovie, err = c.r.Genres.ForMovies(c.ctx, ids)
+	if c.must("for movies after delete", err) && (len(byMovie[ids[0]]) != 0 || len(byMovie[ids[2]]) != 0 || len(byMovie[ids[1]]) != 1) {
+		c.errorf("for movies after delete: got %+v", byMovie)
+	}
+}
+
+func checkPeople(c *checker) {
+	if c.r.People == nil {
+		return
+	}
+
+	older, err := c.r.Movies.Create(c.ctx, model.Movie{Title: "Alien", Year: 1979})
+	if !c.must("create movie", err) {
+		return
+	}
+	newer, err := c.r.Movies.Create(c.ctx, model.Movie{Title: "Aliens", Year: 1986})
+	if !c.must("create movie", err) {
+		return
+	}
+	defer func() {
+		c.must("cleanup", c.r.Movies.Delete(c.ctx, older.ID))
+	}()
+
+	credits := []model.Credit{
+		{TMDBID: 3, Name: "Ridley Scott", Role: model.RoleCrew, Job: "Director", Department: "Directing"},
+		{TMDBID: 2, Name: "Tom Skerritt", Role: model.RoleCast, Character: "Dallas", Order: 1},
+		{TMDBID: 1, Name: "Sigourney Weaver", Role: model.RoleCast, Character: "Ripley", Order: 0},
+	}
+	changed, err := c.r.People.SetMovieCredits(c.ctx, older.ID, credits)
+	if c.must("set credits", err) && !changed {
+		c.errorf("set credits: not reported as changed")
+	}
+	// The same credits in another order, with a new photo, change nothing.
+	again := slices.Clone(credits)
+	slices.Reverse(again)
+	again[0].ProfileURL = "https://example.com/weaver.jpg"
+	changed, err = c.r.People.SetMovieCredits(c.ctx, older.ID, again)
+	if c.must("set same credits", err) && changed {
+		c.errorf("set same credits: reported as changed")
+	}
+	_, err = c.r.People.SetMovieCredits(c.ctx, newer.ID+1000, credits)
+	c.wantErr("set on unknown movie", err, postgres.ErrMovieNotFound)
+
+	// A person credited twice is stored once.
+	changed, err = c.r.People.SetMovieCredits(c.ctx, newer.ID, []model.Credit{
+		{TMDBID: 1, Name: "Sigourney Weaver", ProfileURL: again[0].ProfileURL, Role: model.RoleCast, Character: "Ellen Ripley"},
+		{TMDBID: 4, Name: "James Cameron", Role: model.RoleCrew, Job: "Director", Department: "Directing", Order: 0},
+		{TMDBID: 4, Name: "James Cameron", Role: model.RoleCrew, Job: "Screenplay", Department: "Writing", Order: 1},
+	})
+	if c.must("set other credits", err) && !changed {
+		c.errorf("set other credits: not reported as changed")
+	}
+
+	got, err := c.r.People.MovieCredits(c.ctx, older.ID)
+	if c.must("movie credits", err) {
+		var parts []string
+		for _, cr := range got {
+			parts = append(parts, cr.Name+"/"+cr.Character+cr.Job)
+		}
+		want := []string{"Sigourney Weaver/Ripley", "Tom Skerritt/Dallas", "Ridley Scott/Director"}
+		if !slices.Equal(parts, want) {
+			c.errorf("movie credits: got %v, want %v", parts, want)
+		}
+		if len(got) > 0 && (got[0].TMDBID != 1 || got[0].ProfileURL != "https://example.com/weaver.jpg") {
+			c.errorf("movie credits: got %+v, want tmdb id 1 with the new photo", got[0])
+		}
+	}
+
+	people, total, err := c.r.People.Search(c.ctx, "", model.ListOptions{Sort: "name"})
+	if c.must("list people", err) {
+		var names []string
+		for _, p := range people {
+			names = append(names, p.Name)
+		}
+		want := []string{"James Cameron", "Ridley Scott", "Sigourney Weaver", "Tom Skerritt"}
+		if total != len(want) || !slices.Equal(names, want) {
+			c.errorf("list people: got %v (total %d), want %v", names, total, want)
+		}
+	}
+	weaver := model.Person{}
+	for _, q := range []string{"weav", "Sigorney"} {
+		people, total, err := c.r.People.Search(c.ctx, q, model.ListOptions{})
+		if c.must("search people", err) && (total != 1 || len(people) != 1 || people[0].TMDBID != 1) {
+			c.errorf("search %q: got %+v (total %d), want Sigourney Weaver", q, people, total)
+		} else if len(people) == 1 {
+			weaver = people[0]
+		}
+	}
+	_, _, err = c.r.People.Search(c.ctx, "", model.ListOptions{Sort: "nope"})
+	c.wantErr("unknown sort", err, postgres.ErrBadSort)
+
+	p, err := c.r.People.GetByID(c.ctx, weaver.ID)
+	if c.must("get person", err) && p != weaver {
+		c.errorf("get person: got %+v, want %+v", p, weaver)
+	}
+	_, err = c.r.People.GetByID(c.ctx, weaver.ID+1000)
+	c.wantErr("get unknown person", err, postgres.ErrPersonNotFound)
+
+	films, err := c.r.People.Filmography(c.ctx, weaver.ID)
+	if c.must("filmography", err) {
+		want := []model.FilmographyEntry{
+			{MovieID: newer.ID, Title: "Aliens", Year: 1986, Role: model.RoleCast, Character: "Ellen Ripley"},
+			{MovieID: older.ID, Title: "Alien", Year: 1979, Role: model.RoleCast, Character: "Ripley"},
+		}
+		if !slices.Equal(films, want) {
+			c.errorf("filmography: got %+v, want %+v", films, want)
+		}
+	}
+
+	// Deleting a movie takes its credits but not its people.
+	c.must("delete movie", c.r.Movies.Delete(c.ctx, newer.ID))
+	films, err = c.r.People.Filmography(c.ctx, weaver.ID)
+	if c.must("filmography after delete", err) && len(films) != 1 {
+		c.errorf("filmography after delete: got %+v, want only Alien", films)
+	}
+	_, err = c.r.People.GetByID(c.ctx, weaver.ID)
+	c.must("get person after delete", err)
+}
+
+func checkWatchlist(c *checker) {
+	if c.r.Watchlists == nil {
+		return
+	}
+	w := c.r.Watchlists
+
+	u, err := c.r.Users.Create(c.ctx, model.User{Username: "wes", Email: "wes@example.com", PasswordHash: "x", Role: "user"})
+	if !c.must("create user", err) {
+		return
+	}
+	other, err := c.r.Users.Create(c.ctx, model.User{Username: "val", Email: "val@example.com", PasswordHash: "x", Role: "user"})
+	if !c.must("create user", err) {
+		return
+	}
+	var ids []int
+	for _, m := range []model.Movie{
+		{Title: "Stalker", Year: 1979},
+		{Title: "Solaris", Year: 1972},
+		{Title: "Mirror", Year: 1975},
+		{Title: "Nostalghia", Year: 1983},
+	} {
+		created, err := c.r.Movies.Create(c.ctx, m)
+		if !c.must("create movie", err) {
+			return
+		}
+		ids = append(ids, created.ID)
+	}
+	defer func() {
+		for _, id := range ids[1:] {
+			c.must("cleanup", c.r.Movies.Delete(c.ctx, id))
+		}
+		c.must("cleanup", c.r.Users.Delete(c.ctx, u.ID))
+	}()
+
+	planned := []string{"2030-01-02", "", "2020-05-06", "2025-01-01"}
+	for i, id := range ids {
+		e, err := w.Add(c.ctx, u.ID, model.WatchlistEntry{Movie: model.Movie{ID: id}, Note: "n", PlannedFor: planned[i]})
+		if c.must("add", err) && (e.Movie.ID != id || e.Position != i+1 || e.PlannedFor != planned[i] || e.AddedAt.IsZero()) {
+			c.errorf("add: got %+v, want movie %d at %d planned for %q", e, id, i+1, planned[i])
+		}
+	}
+	_, err = w.Add(c.ctx, u.ID, model.WatchlistEntry{Movie: model.Movie{ID: ids[0]}})
+	c.wantErr("add twice", err, postgres.ErrWatchlistEntryExists)
+	_, err = w.Add(c.ctx, u.ID, model.WatchlistEntry{Movie: model.Movie{ID: ids[3] + 1000}})
+	c.wantErr("add unknown movie", err, postgres.ErrMovieNotFound)
+	_, err = w.Add(c.ctx, other.ID, model.WatchlistEntry{Movie: model.Movie{ID: ids[1]}})
+	c.must("add for other user", err)
+
+	list := func(what string, f model.WatchlistFilter, opts model.ListOptions, want []int) {
+		entries, total, err := w.List(c.ctx, u.ID, f, opts)
+		if !c.must(what, err) {
+			return
+		}
+		got := make([]int, len(entries))
+		for i, e := range entries {
+			got[i] = e.Movie.ID
+		}
+		if total != len(want) || !slices.Equal(got, want) {
+			c.errorf("%s: got %v (total %d), want %v", what, got, total, want)
+		}
+	}
+	list("list", model.WatchlistFilter{}, model.ListOptions{}, ids)
+	list("list by year", model.WatchlistFilter{}, model.ListOptions{Sort: "year"}, []int{ids[1], ids[2], ids[0], ids[3]})
+	list("list by planned date", model.WatchlistFilter{}, model.ListOptions{Sort: "planned"}, []int{ids[2], ids[3], ids[0], ids[1]})
+	list("list due", model.WatchlistFilter{DueBy: "2025-01-01"}, model.ListOptions{}, []int{ids[2], ids[3]})
+	list("list filtered", model.WatchlistFilter{MovieFilter: model.MovieFilter{YearFrom: 1975, YearTo: 1980}}, model.ListOptions{}, []int{ids[0], ids[2]})
+	_, _, err = w.List(c.ctx, u.ID, model.WatchlistFilter{}, model.ListOptions{Sort: "nope"})
+	c.wantErr("unknown sort", err, postgres.ErrBadSort)
+
+	e, err := w.Update(c.ctx, u.ID, model.WatchlistEntry{Movie: model.Movie{ID: ids[0]}, Note: "with subtitles"})
+	if c.must("update", err) && (e.Note != "with subtitles" || e.PlannedFor != "" || e.Position != 1) {
+		c.errorf("update: got %+v", e)
+	}
+	_, err = w.Update(c.ctx, other.ID, model.WatchlistEntry{Movie: model.Movie{ID: ids[0]}})
+	c.wantErr("update other user's entry", err, postgres.ErrWatchlistEntryNotFound)
+
+	e, err = w.Move(c.ctx, u.ID, ids[3], 1)
+	if c.must("move up", err) && e.Position != 1 {
+		c.errorf("move up: got position %d, want 1", e.Position)
+	}
+	list("list after move up", model.WatchlistFilter{}, model.ListOptions{}, []int{ids[3], ids[0], ids[1], ids[2]})
+	_, err = w.Move(c.ctx, u.ID, ids[3], 99)
+	c.must("move past the end", err)
+	list("list after move down", model.WatchlistFilter{}, model.ListOptions{}, []int{ids[0], ids[1], ids[2], ids[3]})
+	_, err = w.Move(c.ctx, other.ID, ids[0], 1)
+	c.wantErr("move other user's entry", err, postgres.ErrWatchlistEntryNotFound)
+
+	// Removing entries, directly or through their movie, leaves the rest in
+	// order, and a move numbers them from 1 again.
+	c.must("remove", w.Remove(c.ctx, u.ID, ids[1]))
+	c.wantErr("remove twice", w.Remove(c.ctx, u.ID, ids[1]), postgres.ErrWatchlistEntryNotFound)
+	c.must("delete movie", c.r.Movies.Delete(c.ctx, ids[0]))
+	e, err = w.Move(c.ctx, u.ID, ids[3], 2)
+	if c.must("move after removals", err) && e.Position != 2 {
+		c.errorf("move after removals: got position %d, want 2", e.Position)
+	}
+	entries, _, err := w.List(c.ctx, u.ID, model.WatchlistFilter{}, model.ListOptions{})
+	if c.must("list after removals", err) {
+		var got [][2]int
+		for _, e := range entries {
+			got = append(got, [2]int{e.Movie.ID, e.Position})
+		}
+		if want := [][2]int{{ids[2], 1}, {ids[3], 2}}; !slices.Equal(got, want) {
+			c.errorf("list after removals: got %v, want %v", got, want)
+		}
+	}
+
+	// The other user's watchlist goes with them.
+	c.must("delete other user", c.r.Users.Delete(c.ctx, other.ID))
+	_, err = w.Get(c.ctx, other.ID, ids[1])
+	c.wantErr("entry of deleted user", err, postgres.ErrWatchlistEntryNotFound)
+}
+
+func checkDiary(c *checker) {
+	if c.r.Diary == nil {
+		return
+	}
+	d := c.r.Diary
+
+	u, err := c.r.Users.Create(c.ctx, model.User{Username: "dee", Email: "dee@example.com", PasswordHash: "x", Role: "user"})
+	if !c.must("create user", err) {
+		return
+	}
+	defer func() { c.must("cleanup user", c.r.Users.Delete(c.ctx, u.ID)) }()
+	other, err := c.r.Users.Create(c.ctx, model.User{Username: "otto", Email: "otto@example.com", PasswordHash: "x", Role: "user"})
+	if !c.must("create user", err) {
+		return
+	}
+	m, err := c.r.Movies.Create(c.ctx, model.Movie{Title: "Rewatched", Year: 1999})
+	if !c.must("create movie", err) {
+		return
+	}
+	defer func() { c.must("cleanup movie", c.r.Movies.Delete(c.ctx, m.ID)) }()
+
+	score := func(n int) *int { return &n }
+	var ids []int
+	for _, e := range []model.DiaryEntry{
+		{WatchedOn: "2024-01-01", Score: score(5), Note: "first"},
+		{WatchedOn: "2024-06-01", Score: score(3), Rewatch: true},
+		{WatchedOn: "2024-03-01", Rewatch: true},
+	} {
+		e.Movie.ID = m.ID
+		added, err := d.Add(c.ctx, u.ID, e)
+		if !c.must("add", err) {
+			return
+		}
+		if added.ID == 0 || added.Movie.ID != m.ID || added.WatchedOn != e.WatchedOn || added.Rewatch != e.Rewatch ||
+			(added.Score == nil) != (e.Score == nil) || added.CreatedAt.IsZero() {
+			c.errorf("add: got %+v, want %+v", added, e)
+		}
+		ids = append(ids, added.ID)
+	}
+	_, err = d.Add(c.ctx, u.ID, model.DiaryEntry{Movie: model.Movie{ID: m.ID + 1000}, WatchedOn: "2024-01-01"})
+	c.wantErr("add unknown movie", err, postgres.ErrMovieNotFound)
+	theirs, err := d.Add(c.ctx, other.ID, model.DiaryEntry{Movie: model.Movie{ID: m.ID}, WatchedOn: "2024-03-01"})
+	if !c.must("add for other user", err) {
+		return
+	}
+
+	list := func(what string, f model.DiaryFilter, opts model.ListOptions, want []int) {
+		entries, total, err := d.List(c.ctx, u.ID, f, opts)
+		if !c.must(what, err) {
+			return
+		}
+		got := make([]int, len(entries))
+		for i, e := range entries {
+			got[i] = e.ID
+		}
+		if total != len(want) || !slices.Equal(got, want) {
+			c.errorf("%s: got %v (total %d), want %v", what, got, total, want)
+		}
+	}
+	list("list", model.DiaryFilter{}, model.ListOptions{}, []int{ids[0], ids[2], ids[1]})
+	list("list newest first", model.DiaryFilter{}, model.ListOptions{Sort: "watched", Desc: true}, []int{ids[1], ids[2], ids[0]})
+	list("list by score", model.DiaryFilter{}, model.ListOptions{Sort: "score"}, []int{ids[2], ids[1], ids[0]})
+	list("list by dates", model.DiaryFilter{From: "2024-02-01", To: "2024-06-01"}, model.ListOptions{}, []int{ids[2], ids[1]})
+	list("list other movie", model.DiaryFilter{MovieID: m.ID + 1000}, model.ListOptions{}, []int{})
+	_, _, err = d.List(c.ctx, u.ID, model.DiaryFilter{}, model.ListOptions{Sort: "nope"})
+	c.wantErr("unknown sort", err, postgres.ErrBadSort)
+
+	days, err := d.Days(c.ctx, u.ID, "2024-01-01", "2024-03-01")
+	if want := []model.DiaryDay{{Date: "2024-01-01", Watches: 1}, {Date: "2024-03-01", Watches: 1}}; c.must("days", err) && !slices.Equal(days, want) {
+		c.errorf("days: got %v, want %v", days, want)
+	}
+
+	_, err = d.Get(c.ctx, u.ID, theirs.ID)
+	c.wantErr("get other user's entry", err, postgres.ErrDiaryEntryNotFound)
+	_, err = d.Update(c.ctx, u.ID, theirs)
+	c.wantErr("update other user's entry", err, postgres.ErrDiaryEntryNotFound)
+	c.wantErr("delete other user's entry", d.Delete(c.ctx, u.ID, theirs.ID), postgres.ErrDiaryEntryNotFound)
+
+	// Each user's rating is their latest scored entry, or else their review.
+	_, err = c.r.Reviews.Add(c.ctx, m.ID, model.Review{UserID: u.ID, Score: 1})
+	c.must("add review", err)
+	_, err = c.r.Reviews.Add(c.ctx, m.ID, model.Review{UserID: other.ID, Score: 4})
+	c.must("add review", err)
+	rating := func(what string, want float64) { c.wantRating(what, m.ID, want) }
+	rating("rating", 3.5)
+
+	e, err := d.Get(c.ctx, u.ID, ids[1])
+	if c.must("get", err) {
+		e.Score, e.Note = nil, "fell asleep"
+		e, err = d.Update(c.ctx, u.ID, e)
+		if c.must("update", err) && (e.Score != nil || e.Note != "fell asleep" || e.WatchedOn != "2024-06-01") {
+			c.errorf("update: got %+v", e)
+		}
+	}
+	rating("rating after clearing the latest score", 4.5)
+	c.must("delete", d.Delete(c.ctx, u.ID, ids[0]))
+	c.wantErr("delete twice", d.Delete(c.ctx, u.ID, ids[0]), postgres.ErrDiaryEntryNotFound)
+	rating("rating after deleting the scored entry", 2.5)
+
+	// The other user's diary goes with them, and so does their score.
+	c.must("delete other user", c.r.Users.Delete(c.ctx, other.ID))
+	_, err = d.Get(c.ctx, other.ID, theirs.ID)
+	c.wantErr("entry of deleted user", err, postgres.ErrDiaryEntryNotFound)
+	rating("rating after deleting the other user", 1)
+}
+
+func checkLists(c *checker) {
+	if c.r.Lists == nil {
+		return
+	}
+	l := c.r.Lists
+
+	var users []model.User
+	for _, name := range []string{"lena", "lou", "lee"} {
+		u, err := c.r.Users.Create(c.ctx, model.User{Username: name, Email: name + "@example.com", PasswordHash: "x", Role: "user"})
+		if !c.must("create user", err) {
+			return
+		}
+		users = append(users, u)
+	}
+	owner, collab, cloner := users[0], users[1], users[2]
+	defer func() { c.must("cleanup user", c.r.Users.Delete(c.ctx, cloner.ID)) }()
+	var ids []int
+	for _, m := range []model.Movie{
+		{Title: "Persona", Year: 1966},
+		{Title: "Cries and Whispers", Year: 1972},
+		{Title: "Wild Strawberries", Year: 1957},
+	} {
+		created, err := c.r.Movies.Create(c.ctx, m)
+		if !c.must("create movie", err) {
+			return
+		}
+		ids = append(ids, created.ID)
+	}
+	defer func() { c.must("cleanup movie", c.r.Movies.Delete(c.ctx, ids[0])) }()
+
+	best, err := l.Create(c.ctx, model.MovieList{OwnerID: owner.ID, Title: "Best of Bergman", Visibility: model.ListPublic})
+	if !c.must("create", err) {
+		return
+	}
+	if best.ID == 0 || best.Owner != "lena" || best.Entries != 0 || best.CreatedAt.IsZero() || best.Collaborators == nil {
+		c.errorf("create: got %+v", best)
+	}
+	queue, err := l.Create(c.ctx, model.MovieList{OwnerID: collab.ID, Title: "Queue", Visibility: model.ListPrivate})
+	if !c.must("create", err) {
+		return
+	}
+	_, err = l.Create(c.ctx, model.MovieList{OwnerID: cloner.ID + 1000, Title: "x", Visibility: model.ListPrivate})
+	c.wantErr("create for unknown user", err, postgres.ErrUserNotFound)
+
+	for i, id := range ids {
+		e, err := l.AddEntry(c.ctx, best.ID, model.ListEntry{Movie: model.Movie{ID: id}, Comment: "c", AddedBy: owner.ID})
+		if c.must("add entry", err) && (e.Movie.ID != id || e.Position != i+1 || e.AddedBy != owner.ID || e.AddedAt.IsZero()) {
+			c.errorf("add entry: got %+v, want movie %d at %d", e, id, i+1)
+		}
+	}
+	_, err = l.AddEntry(c.ctx, best.ID, model.ListEntry{Movie: model.Movie{ID: ids[0]}})
+	c.wantErr("add entry twice", err, postgres.ErrListEntryExists)
+	_, err = l.AddEntry(c.ctx, best.ID, model.ListEntry{Movie: model.Movie{ID: ids[2] + 1000}})
+	c.wantErr("add unknown movie", err, postgres.ErrMovieNotFound)
+	_, err = l.AddEntry(c.ctx, queue.ID+1000, model.ListEntry{Movie: model.Movie{ID: ids[0]}})
+	c.wantErr("add to unknown list", err, postgres.ErrListNotFound)
+	_, err = l.AddEntry(c.ctx, queue.ID, model.ListEntry{Movie: model.Movie{ID: ids[1]}, AddedBy: owner.ID})
+	c.must("add to other list", err)
+
+	c.must("add collaborator", l.AddCollaborator(c.ctx, best.ID, collab.ID))
+	c.must("add collaborator twice", l.AddCollaborator(c.ctx, best.ID, collab.ID))
+	c.wantErr("add unknown collaborator", l.AddCollaborator(c.ctx, best.ID, cloner.ID+1000), postgres.ErrUserNotFound)
+	c.wantErr("collaborate on unknown list", l.AddCollaborator(c.ctx, queue.ID+1000, collab.ID), postgres.ErrListNotFound)
+	got, err := l.Get(c.ctx, best.ID)
+	if c.must("get", err) {
+		if got.Entries != 3 || len(got.Collaborators) != 1 || got.Collaborators[0].Username != "lou" || !got.UpdatedAt.After(best.UpdatedAt) {
+			c.errorf("get: got %+v", got)
+		}
+	}
+
+	lists := func(what string, f model.ListFilter, want []int) {
+		found, total, err := l.List(c.ctx, f, model.ListOptions{Sort: "title"})
+		if !c.must(what, err) {
+			return
+		}
+		got := make([]int, len(found))
+		for i, fl := range found {
+			got[i] = fl.ID
+		}
+		if total != len(want) || !slices.Equal(got, want) {
+			c.errorf("%s: got %v (total %d), want %v", what, got, total, want)
+		}
+	}
+	lists("lists of a member", model.ListFilter{MemberID: collab.ID}, []int{best.ID, queue.ID})
+	lists("lists of the owner", model.ListFilter{MemberID: owner.ID}, []int{best.ID})
+	lists("lists with a movie", model.ListFilter{MovieID: ids[1]}, []int{best.ID, queue.ID})
+	lists("public lists with a movie", model.ListFilter{MovieID: ids[1], PublicOnly: true}, []int{best.ID})
+	_, _, err = l.List(c.ctx, model.ListFilter{}, model.ListOptions{Sort: "nope"})
+	c.wantErr("unknown sort", err, postgres.ErrBadSort)
+
+	entries := func(what string, listID int, want [][2]int) {
+		found, total, err := l.Entries(c.ctx, listID, model.ListOptions{})
+		if !c.must(what, err) {
+			return
+		}
+		var got [][2]int
+		for _, e := range found {
+			got = append(got, [2]int{e.Movie.ID, e.Position})
+		}
+		if total != len(want) || !slices.Equal(got, want) {
+			c.errorf("%s: got %v (total %d), want %v", what, got, total, want)
+		}
+	}
+	e, err := l.MoveEntry(c.ctx, best.ID, ids[2], 1)
+	if c.must("move entry", err) && e.Position != 1 {
+		c.errorf("move entry: got position %d, want 1", e.Position)
+	}
+	entries("entries after move", best.ID, [][2]int{{ids[2], 1}, {ids[0], 2}, {ids[1], 3}})
+	_, err = l.MoveEntry(c.ctx, queue.ID, ids[0], 1)
+	c.wantErr("move entry not on the list", err, postgres.ErrListEntryNotFound)
+	e, err = l.UpdateEntry(c.ctx, best.ID, model.ListEntry{Movie: model.Movie{ID: ids[2]}, Comment: "the one with the strawberries"})
+	if c.must("update entry", err) && (e.Comment != "the one with the strawberries" || e.Position != 1) {
+		c.errorf("update entry: got %+v", e)
+	}
+
+	// A clone copies the entries in order, as added by the new owner.
+	clone, err := l.Clone(c.ctx, best.ID, model.MovieList{OwnerID: cloner.ID, Title: "Mine now", Visibility: model.ListPrivate})
+	if c.must("clone", err) && (clone.ClonedFrom == nil || *clone.ClonedFrom != best.ID || clone.Entries != 3 || clone.Owner != "lee") {
+		c.errorf("clone: got %+v", clone)
+	}
+	entries("entries of clone", clone.ID, [][2]int{{ids[2], 1}, {ids[0], 2}, {ids[1], 3}})
+	if e, err := l.GetEntry(c.ctx, clone.ID, ids[2]); c.must("get cloned entry", err) && (e.AddedBy != cloner.ID || e.Comment != "the one with the strawberries") {
+		c.errorf("get cloned entry: got %+v", e)
+	}
+	_, err = l.Clone(c.ctx, queue.ID+1000, model.MovieList{OwnerID: cloner.ID, Title: "x", Visibility: model.ListPrivate})
+	c.wantErr("clone unknown list", err, postgres.ErrListNotFound)
+
+	// Removing entries, directly or through their movie, leaves the rest in
+	// order.
+	c.must("remove entry", l.RemoveEntry(c.ctx, best.ID, ids[0]))
+	c.wantErr("remove entry twice", l.RemoveEntry(c.ctx, best.ID, ids[0]), postgres.ErrListEntryNotFound)
+	c.must("delete movie", c.r.Movies.Delete(c.ctx, ids[2]))
+	entries("entries after removals", best.ID, [][2]int{{ids[1], 3}})
+	entries("entries of clone after removals", clone.ID, [][2]int{{ids[0], 2}, {ids[1], 3}})
+
+	c.must("remove collaborator", l.RemoveCollaborator(c.ctx, best.ID, collab.ID))
+	c.wantErr("remove collaborator twice", l.RemoveCollaborator(c.ctx, best.ID, collab.ID), postgres.ErrCollaboratorNotFound)
+
+	// Deleting a list keeps its clones; deleting a user takes their lists
+	// and keeps the entries they added to others'.
+	c.must("delete", l.Delete(c.ctx, best.ID))
+	c.wantErr("delete twice", l.Delete(c.ctx, best.ID), postgres.ErrListNotFound)
+	if got, err := l.Get(c.ctx, clone.ID); c.must("get clone", err) && got.ClonedFrom != nil {
+		c.errorf("get clone: cloned_from is %d after deleting the source", *got.ClonedFrom)
+	}
+	c.must("delete owner", c.r.Users.Delete(c.ctx, owner.ID))
+	if e, err := l.GetEntry(c.ctx, queue.ID, ids[1]); c.must("entry added by deleted user", err) && e.AddedBy != 0 {
+		c.errorf("entry added by deleted user: got added_by %d", e.AddedBy)
+	}
+	c.must("delete movie", c.r.Movies.Delete(c.ctx, ids[1]))
+	c.must("delete collaborator", c.r.Users.Delete(c.ctx, collab.ID))
+	_, err = l.Get(c.ctx, queue.ID)
+	c.wantErr("list of deleted user", err, postgres.ErrListNotFound)
+}
+
+func checkFeed(c *checker) {
+	if c.r.Feed == nil || c.r.Lists == nil || c.r.Diary == nil {
+		return
+	}
+	f := c.r.Feed
+
+	var users []model.User
+	for _, name := range []string{"fay", "fred", "finn"} {
+		u, err := c.r.Users.Create(c.ctx, model.User{Username: name, Email: name + "@example.com", PasswordHash: "x", Role: "user"})
+		if !c.must("create user", err) {
+			return
+		}
+		users = append(users, u)
+	}
+	fay, fred, finn := users[0], users[1], users[2]
+	defer func() {
+		c.must("cleanup user", c.r.Users.Delete(c.ctx, fay.ID))
+		c.must("cleanup user", c.r.Users.Delete(c.ctx, finn.ID))
+	}()
+	m, err := c.r.Movies.Create(c.ctx, model.Movie{Title: "Stalker", Year: 1979})
+	if !c.must("create movie", err) {
+		return
+	}
+	defer func() { c.must("cleanup movie", c.r.Movies.Delete(c.ctx, m.ID)) }()
+
+	feed := func(what string, userID, before, limit int, want []string) []model.Activity {
+		activities, err := f.Feed(c.ctx, userID, before, limit)
+		if !c.must(what, err) {
+			return nil
+		}
+		got := make([]string, len(activities))
+		for i, a := range activities {
+			got[i] = a.Kind
+			if a.UserID != fred.ID || a.Username != "fred" || a.CreatedAt.IsZero() {
+				c.errorf("%s: got %+v", what, a)
+			}
+			if i > 0 && a.ID >= activities[i-1].ID {
+				c.errorf("%s: activity %d comes after %d", what, a.ID, activities[i-1].ID)
+			}
+		}
+		if !slices.Equal(got, want) {
+			c.errorf("%s: got %v, want %v", what, got, want)
+		}
+		return activities
+	}
+
+	// Writes publish themselves, and following backfills what the user did
+	// before.
+	rev, err := c.r.Reviews.Add(c.ctx, m.ID, model.Review{UserID: fred.ID, Score: 4, Text: "slow"})
+	if !c.must("add review", err) {
+		return
+	}
+	c.must("follow", f.Follow(c.ctx, fay.ID, fred.ID))
+	c.must("follow twice", f.Follow(c.ctx, fay.ID, fred.ID))
+	c.must("follow", f.Follow(c.ctx, finn.ID, fred.ID))
+	c.must("follow back", f.Follow(c.ctx, fred.ID, fay.ID))
+	c.wantErr("follow unknown user", f.Follow(c.ctx, fay.ID, finn.ID+1000), postgres.ErrUserNotFound)
+	if got := feed("feed after follow", fay.ID, 0, 10, []string{model.ActivityReview}); len(got) == 1 {
+		if a := got[0]; a.Movie == nil || a.Movie.ID != m.ID || a.Movie.Title != "Stalker" || a.Score == nil || *a.Score != 4 || a.Text != "slow" {
+			c.errorf("review activity: got %+v", a)
+		}
+	}
+
+	follows := func(what string, list func(context.Context, int, model.ListOptions) ([]model.Follow, int, error), userID int, want []int) {
+		found, total, err := list(c.ctx, userID, model.ListOptions{Sort: "name"})
+		if !c.must(what, err) {
+			return
+		}
+		got := make([]int, len(found))
+		for i, fl := range found {
+			got[i] = fl.UserID
+			if fl.Since.IsZero() {
+				c.errorf("%s: got %+v", what, fl)
+			}
+		}
+		if total != len(want) || !slices.Equal(got, want) {
+			c.errorf("%s: got %v (total %d), want %v", what, got, total, want)
+		}
+	}
+	follows("followers", f.Followers, fred.ID, []int{fay.ID, finn.ID})
+	follows("following", f.Following, fay.ID, []int{fred.ID})
+	follows("followers of the unfollowed", f.Followers, finn.ID, []int{})
+	_, _, err = f.Followers(c.ctx, fred.ID, model.ListOptions{Sort: "nope"})
+	c.wantErr("unknown sort", err, postgres.ErrBadSort)
+
+	// New activities fan out to every follower; those on lists that are not
+	// public stay out of feeds.
+	public, err := c.r.Lists.Create(c.ctx, model.MovieList{OwnerID: fred.ID, Title: "Zone", Visibility: model.ListPublic})
+	if !c.must("create list", err) {
+		return
+	}
+	if _, err := c.r.Lists.Create(c.ctx, model.MovieList{OwnerID: fred.ID, Title: "Secret", Visibility: model.ListPrivate}); !c.must("create list", err) {
+		return
+	}
+	if _, err := c.r.Lists.AddEntry(c.ctx, public.ID, model.ListEntry{Movie: model.Movie{ID: m.ID}, AddedBy: fred.ID}); !c.must("add entry", err) {
+		return
+	}
+	d, err := c.r.Diary.Add(c.ctx, fred.ID, model.DiaryEntry{Movie: model.Movie{ID: m.ID}, WatchedOn: "2024-03-01", Note: "private"})
+	if !c.must("add diary entry", err) {
+		return
+	}
+
+	// A write that fails publishes nothing, and users do not see their own
+	// activities.
+	_, err = c.r.Lists.AddEntry(c.ctx, public.ID, model.ListEntry{Movie: model.Movie{ID: m.ID}, AddedBy: fred.ID})
+	c.wantErr("add entry twice", err, postgres.ErrListEntryExists)
+	_, err = c.r.Reviews.Add(c.ctx, m.ID, model.Review{UserID: fred.ID, Score: 1})
+	c.wantErr("add review twice", err, postgres.ErrReviewExists)
+	_, err = c.r.Reviews.Add(c.ctx, m.ID, model.Review{UserID: fay.ID, Score: 2})
+	c.must("add own review", err)
+
+	all := []string{model.ActivityDiary, model.ActivityListEntry, model.ActivityListCreated, model.ActivityReview}
+	if got := feed("feed", fay.ID, 0, 10, all); len(got) == 4 {
+		if a := got[0]; a.Movie == nil || a.Movie.ID != m.ID || a.WatchedOn != "2024-03-01" || a.Text != "" {
+			c.errorf("diary activity: got %+v", a)
+		}
+		if a := got[1]; a.Movie == nil || a.Movie.ID != m.ID || a.List == nil || a.List.ID != public.ID || a.List.Title != "Zone" {
+			c.errorf("entry activity: got %+v", a)
+		}
+		if a := got[2]; a.Movie != nil || a.List == nil || a.List.ID != public.ID {
+			c.errorf("list activity: got %+v", a)
+		}
+	}
+	feed("feed of another follower", finn.ID, 0, 10, all)
+	if first := feed("first page", fay.ID, 0, 3, all[:3]); len(first) == 3 {
+		feed("next page", fay.ID, first[2].ID, 3, all[3:])
+	}
+
+	// Activities go with what they are about.
+	c.must("delete review", c.r.Reviews.DeleteByID(c.ctx, rev.ID))
+	feed("feed after deleting the review", fay.ID, 0, 10, all[:3])
+	c.must("delete diary entry", c.r.Diary.Delete(c.ctx, fred.ID, d.ID))
+	feed("feed after deleting the diary entry", fay.ID, 0, 10, all[1:3])
+	c.must("remove entry", c.r.Lists.RemoveEntry(c.ctx, public.ID, m.ID))
+	feed("feed after removing the entry", fay.ID, 0, 10, all[2:3])
+
+	// Unfollowing clears the feed of the user's activities, and deleting a
+	// user takes their follows and activities.
+	c.must("unfollow", f.Unfollow(c.ctx, fay.ID, fred.ID))
+	c.wantErr("unfollow twice", f.Unfollow(c.ctx, fay.ID, fred.ID), postgres.ErrNotFollowing)
+	feed("feed after unfollow", fay.ID, 0, 10, []string{})
+	c.must("delete followed user", c.r.Users.Delete(c.ctx, fred.ID))
+	feed("feed of deleted user's follower", finn.ID, 0, 10, []string{})
+	follows("following deleted user", f.Following, finn.ID, []int{})
+	follows("followers of deleted user's follow", f.Followers, fay.ID, []int{})
+}
+
+func movieIDs(movies []model.Movie) []int {
+	ids := make([]int, len(movies))
+	for i, m := range movies {
+		ids[i] = m.ID
+	}
+	return ids
+}
+
+func sameIDs(movies []model.Movie, want []int) bool {
+	return slices.Equal(movieIDs(movies), want)
+}
@@ -0,0 +1,155 @@
+// Package textsearch holds the pieces of the Postgres movie search that the
+// other storage backends rebuild in Go: english stop words, pg_trgm style
+// word similarity and <mark> snippets.
+package textsearch
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// SimilarityThreshold matches the pg_trgm word similarity the Postgres search
+// uses for its typo-tolerant fallback.
+const SimilarityThreshold = 0.4
+
+const snippetWords = 30
+
+// stopWords are dropped from queries, as the english text search
+// configuration does.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "in": true, "is": true,
+	"it": true, "of": true, "on": true, "or": true, "the": true, "to": true,
+	"with": true,
+}
+
+// Words lower-cases s and splits it into letter and digit runs.
+func Words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Terms returns the words of query without stop words.
+func Terms(query string) []string {
+	var terms []string
+	for _, w := range Words(query) {
+		if !stopWords[w] {
+			terms = append(terms, w)
+		}
+	}
+	return terms
+}
+
+// matchesTerm treats term as a prefix, a rough stand-in for stemming.
+func matchesTerm(word, term string) bool {
+	return strings.HasPrefix(word, term)
+}
+
+// TextScore reports whether every term occurs in the title or description
+// and scores the match, title hits counting more than description hits.
+func TextScore(terms []string, title, description string) (float64, bool) {
+	if len(terms) == 0 {
+		return 0, false
+	}
+
+	titleWords, descWords := Words(title), Words(description)
+	var score float64
+	for _, term := range terms {
+		found := false
+		for _, w := range titleWords {
+			if matchesTerm(w, term) {
+				score += 1
+				found = true
+			}
+		}
+		for _, w := range descWords {
+			if matchesTerm(w, term) {
+				score += 0.2
+				found = true
+			}
+		}
+		if !found {
+			return 0, false
+		}
+	}
+	return score / float64(len(terms)) / 10, true
+}
+
+// trigrams splits s the way pg_trgm does: lower-cased words padded with two
+// spaces in front and one behind.
+func trigrams(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range Words(s) {
+		padded := []rune("  " + w + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
+
+func similarity(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for t := range a {
+		if b[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// WordSimilarity approximates pg_trgm's word_similarity: the best similarity
+// between the query and any run of consecutive title words.
+func WordSimilarity(query, title string) float64 {
+	q := trigrams(query)
+	titleWords := Words(title)
+
+	best := 0.0
+	for i := range titleWords {
+		for j := i + 1; j <= len(titleWords); j++ {
+			if s := similarity(q, trigrams(strings.Join(titleWords[i:j], " "))); s > best {
+				best = s
+			}
+		}
+	}
+	return best
+}
+
+// Snippet marks the matched terms in the description (or the title when
+// there is no description), like ts_headline with StartSel=<mark>. The text
+// is HTML-escaped, so the result is safe to render as HTML.
+func Snippet(terms []string, title, description string) string {
+	text := description
+	if strings.TrimSpace(text) == "" {
+		text = title
+	}
+
+	fields := strings.Fields(text)
+	if len(fields) > snippetWords {
+		fields = fields[:snippetWords]
+	}
+	for i, f := range fields {
+		fields[i] = html.EscapeString(f)
+		for _, w := range Words(f) {
+			if matchesAnyTerm(terms, w) {
+				fields[i] = "<mark>" + fields[i] + "</mark>"
+				break
+			}
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+func matchesAnyTerm(terms []string, word string) bool {
+	for _, term := range terms {
+		if matchesTerm(word, term) {
+			return true
+		}
+	}
+	return false
+}
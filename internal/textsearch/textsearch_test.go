@@ -0,0 +1,28 @@
+package textsearch
+
+import "testing"
+
+func TestSnippetEscapesHTML(t *testing.T) {
+	for _, tc := range []struct {
+		title, description, want string
+	}{
+		{
+			title:       "T",
+			description: `<img src=x onerror=alert(1)> a heist film`,
+			want:        `&lt;img src=x onerror=alert(1)&gt; a <mark>heist</mark> film`,
+		},
+		{
+			title:       "T",
+			description: `"Heists" & <b>capers</b>`,
+			want:        `<mark>&#34;Heists&#34;</mark> &amp; &lt;b&gt;capers&lt;/b&gt;`,
+		},
+		{
+			title: `<script>heist</script>`,
+			want:  `<mark>&lt;script&gt;heist&lt;/script&gt;</mark>`,
+		},
+	} {
+		if got := Snippet([]string{"heist"}, tc.title, tc.description); got != tc.want {
+			t.Errorf("Snippet(%q, %q) = %q, want %q", tc.title, tc.description, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package tmdb
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultCacheEntries = 1000
+	defaultPopularTTL   = 10 * time.Minute
+	defaultMovieTTL     = 24 * time.Hour
+	defaultStaleTTL     = 24 * time.Hour
+
+	// revalidateTimeout bounds an upstream fetch that no request waits on
+	// any more, so a stuck refresh cannot pin the key forever.
+	revalidateTimeout = time.Minute
+)
+
+// API is the part of TMDB the services use. Both *Client and *Cache
+// implement it.
+type API interface {
+	GetPopularMovies(ctx context.Context) ([]MovieSummary, error)
+	GetMovie(ctx context.Context, tmdbID int) (MovieDetails, error)
+}
+
+var (
+	_ API = (*Client)(nil)
+	_ API = (*Cache)(nil)
+)
+
+type CacheConfig struct {
+	// MaxEntries bounds the cache; the least recently used entry goes first.
+	MaxEntries int
+	PopularTTL time.Duration
+	MovieTTL   time.Duration
+	// StaleTTL is how long past its TTL an entry is still served right away
+	// while it is refreshed in the background. When TMDB cannot be reached,
+	// an entry of any age is served instead of the error until it is evicted.
+	StaleTTL time.Duration
+}
+
+// Cache is an in-process LRU in front of a Client. Concurrent misses for the
+// same key share one upstream request.
+type Cache struct {
+	client   *Client
+	popular  time.Duration
+	movie    time.Duration
+	staleTTL time.Duration
+	max      int
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List // of *cacheEntry, most recently used first
+	inflight map[string]*fetch
+
+	hits, staleHits, misses, coalesced, failures, evictions atomic.Int64
+}
+
+type cacheEntry struct {
+	key       string
+	value     any
+	expiresAt time.Time
+}
+
+// fetch is one upstream request that every caller missing the same key
+// waits on.
+type fetch struct {
+	done  chan struct{}
+	value any
+	err   error
+}
+
+// CacheStats counts lookups since start. StaleHits are served past their
+// TTL, Coalesced waited on another request's fetch, Errors are failed
+// fetches (including ones covered by stale data).
+type CacheStats struct {
+	Entries   int   `json:"entries"`
+	Hits      int64 `json:"hits"`
+	StaleHits int64 `json:"stale_hits"`
+	Misses    int64 `json:"misses"`
+	Coalesced int64 `json:"coalesced"`
+	Errors    int64 `json:"errors"`
+	Evictions int64 `json:"evictions"`
+}
+
+func NewCache(client *Client, cfg CacheConfig) *Cache {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = defaultCacheEntries
+	}
+	if cfg.PopularTTL <= 0 {
+		cfg.PopularTTL = defaultPopularTTL
+	}
+	if cfg.MovieTTL <= 0 {
+		cfg.MovieTTL = defaultMovieTTL
+	}
+	if cfg.StaleTTL < 0 {
+		cfg.StaleTTL = 0
+	}
+
+	return &Cache{
+		client:   client,
+		popular:  cfg.PopularTTL,
+		movie:    cfg.MovieTTL,
+		staleTTL: cfg.StaleTTL,
+		max:      cfg.MaxEntries,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		inflight: make(map[string]*fetch),
+	}
+}
+
+func (c *Cache) GetPopularMovies(ctx context.Context) ([]MovieSummary, error) {
+	v, err := c.get(ctx, "popular", c.popular, func(ctx context.Context) (any, error) {
+		return c.client.GetPopularMovies(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]MovieSummary), nil
+}
+
+func (c *Cache) GetMovie(ctx context.Context, tmdbID int) (MovieDetails, error) {
+	key := fmt.Sprintf("movie:%d", tmdbID)
+	v, err := c.get(ctx, key, c.movie, func(ctx context.Context) (any, error) {
+		return c.client.GetMovie(ctx, tmdbID)
+	})
+	if err != nil {
+		return MovieDetails{}, err
+	}
+	return v.(MovieDetails), nil
+}
+
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	entries := c.lru.Len()
+	c.mu.Unlock()
+
+	return CacheStats{
+		Entries:   entries,
+		Hits:      c.hits.Load(),
+		StaleHits: c.staleHits.Load(),
+		Misses:    c.misses.Load(),
+		Coalesced: c.coalesced.Load(),
+		Errors:    c.failures.Load(),
+		Evictions: c.evictions.Load(),
+	}
+}
+
+// get serves key from the cache. A fresh entry is returned as is; a stale
+// one (within StaleTTL past its TTL) is returned at once and refreshed in
+// the background; otherwise the caller waits for load. If load fails
+// because TMDB is unreachable, an entry of any age is better than nothing.
+func (c *Cache) get(ctx context.Context, key string, ttl time.Duration, load func(context.Context) (any, error)) (any, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	entry := c.lookup(key)
+	switch {
+	case entry != nil && now.Before(entry.expiresAt):
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return entry.value, nil
+	case entry != nil && now.Before(entry.expiresAt.Add(c.staleTTL)):
+		c.startFetch(key, ttl, load)
+		c.mu.Unlock()
+		c.staleHits.Add(1)
+		return entry.value, nil
+	}
+	f, shared := c.startFetch(key, ttl, load)
+	c.mu.Unlock()
+
+	if shared {
+		c.coalesced.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if f.err != nil && entry != nil && upstreamDown(f.err) {
+		c.staleHits.Add(1)
+		return entry.value, nil
+	}
+	return f.value, f.err
+}
+
+// startFetch returns the running fetch for key, or starts one. It reports
+// whether the fetch was already running. Callers hold c.mu.
+func (c *Cache) startFetch(key string, ttl time.Duration, load func(context.Context) (any, error)) (*fetch, bool) {
+	if f, ok := c.inflight[key]; ok {
+		return f, true
+	}
+
+	f := &fetch{done: make(chan struct{})}
+	c.inflight[key] = f
+
+	go func() {
+		// The fetch outlives whichever request started it; the others
+		// waiting on it must not fail because that one went away.
+		ctx, cancel := context.WithTimeout(context.Background(), revalidateTimeout)
+		defer cancel()
+
+		value, err := load(ctx)
+
+		c.mu.Lock()
+		delete(c.inflight, key)
+		if err == nil {
+			c.store(key, value, time.Now().Add(ttl))
+		}
+		c.mu.Unlock()
+
+		if err != nil {
+			c.failures.Add(1)
+			log.Printf("tmdb cache: fetch %s: %v", key, err)
+		}
+
+		f.value, f.err = value, err
+		close(f.done)
+	}()
+
+	return f, false
+}
+
+// lookup returns key's entry and marks it recently used. Callers hold c.mu.
+func (c *Cache) lookup(key string) *cacheEntry {
+	el, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(el)
+	return el.Value.(*cacheEntry)
+}
+
+// store adds or replaces key's entry, evicting the least recently used
+// entries beyond MaxEntries. Callers hold c.mu.
+func (c *Cache) store(key string, value any, expiresAt time.Time) {
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*cacheEntry)
+		entry.value, entry.expiresAt = value, expiresAt
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, value: value, expiresAt: expiresAt})
+	for c.lru.Len() > c.max {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.evictions.Add(1)
+	}
+}
+
+// upstreamDown reports whether err means TMDB could not answer, as opposed
+// to answering that the movie does not exist.
+func upstreamDown(err error) bool {
+	return errors.Is(err, ErrUnavailable) ||
+		errors.Is(err, ErrRateLimited) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
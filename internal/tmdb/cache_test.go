@@ -0,0 +1,261 @@
+package tmdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMovies is a fake TMDB serving /3/movie/{id} with the title
+// "<title> <id>", counting requests per id. While down, it answers 503.
+type fakeMovies struct {
+	mu    sync.Mutex
+	calls map[string]int
+	title string
+	down  bool
+	// gate, when set, holds every response until it is closed.
+	gate chan struct{}
+}
+
+func newFakeMovies() *fakeMovies {
+	return &fakeMovies{calls: make(map[string]int), title: "Movie"}
+}
+
+func (f *fakeMovies) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/3/movie/")
+
+	f.mu.Lock()
+	f.calls[id]++
+	title, down, gate := f.title, f.down, f.gate
+	f.mu.Unlock()
+
+	if gate != nil {
+		<-gate
+	}
+	if down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if id == "404" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	fmt.Fprintf(w, `{"id": %s, "title": "%s %s"}`, id, title, id)
+}
+
+func (f *fakeMovies) set(fn func(f *fakeMovies)) {
+	f.mu.Lock()
+	fn(f)
+	f.mu.Unlock()
+}
+
+func (f *fakeMovies) callsFor(id int) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[fmt.Sprint(id)]
+}
+
+func newTestCache(t *testing.T, fake *fakeMovies, cfg CacheConfig) *Cache {
+	t.Helper()
+	return NewCache(newTestClient(t, fake.ServeHTTP, Config{}), cfg)
+}
+
+// refreshed reports whether movie id has been fetched calls times and no
+// fetch for it is still running.
+func refreshed(c *Cache, fake *fakeMovies, id, calls int) bool {
+	c.mu.Lock()
+	_, running := c.inflight[fmt.Sprintf("movie:%d", id)]
+	c.mu.Unlock()
+	return !running && fake.callsFor(id) >= calls
+}
+
+func getTitle(t *testing.T, c *Cache, id int) string {
+	t.Helper()
+	m, err := c.GetMovie(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetMovie(%d): %v", id, err)
+	}
+	return m.Title
+}
+
+func TestCacheHit(t *testing.T) {
+	fake := newFakeMovies()
+	c := newTestCache(t, fake, CacheConfig{})
+
+	for range 3 {
+		if got := getTitle(t, c, 1); got != "Movie 1" {
+			t.Errorf("title = %q, want Movie 1", got)
+		}
+	}
+	if n := fake.callsFor(1); n != 1 {
+		t.Errorf("made %d requests, want 1", n)
+	}
+	if s := c.Stats(); s.Entries != 1 || s.Misses != 1 || s.Hits != 2 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestCacheCoalescesMisses(t *testing.T) {
+	fake := newFakeMovies()
+	gate := make(chan struct{})
+	fake.set(func(f *fakeMovies) { f.gate = gate })
+	c := newTestCache(t, fake, CacheConfig{})
+
+	const callers = 10
+	var wg sync.WaitGroup
+	titles := make([]string, callers)
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m, err := c.GetMovie(context.Background(), 1)
+			if err != nil {
+				t.Error(err)
+			}
+			titles[i] = m.Title
+		}()
+	}
+
+	// Hold the response until every caller is waiting on the fetch.
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		s := c.Stats()
+		if s.Misses+s.Coalesced == callers {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("callers never all waited: %+v", s)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(gate)
+	wg.Wait()
+
+	if n := fake.callsFor(1); n != 1 {
+		t.Errorf("made %d requests, want 1", n)
+	}
+	if s := c.Stats(); s.Misses != 1 || s.Coalesced != callers-1 {
+		t.Errorf("stats = %+v", s)
+	}
+	for i, title := range titles {
+		if title != "Movie 1" {
+			t.Errorf("caller %d got %q", i, title)
+		}
+	}
+}
+
+func TestCacheServesStaleWhileRevalidating(t *testing.T) {
+	fake := newFakeMovies()
+	c := newTestCache(t, fake, CacheConfig{MovieTTL: 100 * time.Millisecond, StaleTTL: time.Hour})
+
+	getTitle(t, c, 1)
+	time.Sleep(110 * time.Millisecond)
+	fake.set(func(f *fakeMovies) { f.title = "Renamed" })
+
+	// The expired entry comes back at once; the refresh lands after.
+	if got := getTitle(t, c, 1); got != "Movie 1" {
+		t.Errorf("title = %q, want the stale Movie 1", got)
+	}
+	for deadline := time.Now().Add(5 * time.Second); !refreshed(c, fake, 1, 2); {
+		if time.Now().After(deadline) {
+			t.Fatal("entry was never refreshed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := getTitle(t, c, 1); got != "Renamed 1" {
+		t.Errorf("title = %q, want the refreshed Renamed 1", got)
+	}
+	if s := c.Stats(); s.StaleHits != 1 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestCacheServesStaleWhenTMDBIsDown(t *testing.T) {
+	fake := newFakeMovies()
+	c := newTestCache(t, fake, CacheConfig{MovieTTL: time.Millisecond})
+
+	getTitle(t, c, 1)
+	time.Sleep(5 * time.Millisecond)
+	fake.set(func(f *fakeMovies) { f.down = true })
+
+	if got := getTitle(t, c, 1); got != "Movie 1" {
+		t.Errorf("title = %q, want the stale Movie 1", got)
+	}
+	if s := c.Stats(); s.StaleHits != 1 || s.Errors != 1 {
+		t.Errorf("stats = %+v", s)
+	}
+
+	// With nothing cached, the outage is the caller's error.
+	if _, err := c.GetMovie(context.Background(), 2); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("uncached movie: got error %v, want ErrUnavailable", err)
+	}
+}
+
+func TestCacheDoesNotHideNotFound(t *testing.T) {
+	fake := newFakeMovies()
+	c := newTestCache(t, fake, CacheConfig{})
+
+	if _, err := c.GetMovie(context.Background(), 404); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, want ErrNotFound", err)
+	}
+	// Failures are not cached.
+	if _, err := c.GetMovie(context.Background(), 404); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, want ErrNotFound", err)
+	}
+	if n := fake.callsFor(404); n != 2 {
+		t.Errorf("made %d requests, want 2", n)
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	fake := newFakeMovies()
+	c := newTestCache(t, fake, CacheConfig{MaxEntries: 2})
+
+	getTitle(t, c, 1)
+	getTitle(t, c, 2)
+	getTitle(t, c, 1) // 2 is now the least recently used
+	getTitle(t, c, 3)
+
+	if s := c.Stats(); s.Entries != 2 || s.Evictions != 1 {
+		t.Errorf("stats = %+v", s)
+	}
+	getTitle(t, c, 1)
+	getTitle(t, c, 3)
+	if n1, n3 := fake.callsFor(1), fake.callsFor(3); n1 != 1 || n3 != 1 {
+		t.Errorf("movies 1 and 3 fetched %d and %d times, want once each", n1, n3)
+	}
+	getTitle(t, c, 2)
+	if n := fake.callsFor(2); n != 2 {
+		t.Errorf("evicted movie 2 fetched %d times, want 2", n)
+	}
+}
+
+func TestCacheCallerGivesUp(t *testing.T) {
+	fake := newFakeMovies()
+	gate := make(chan struct{})
+	fake.set(func(f *fakeMovies) { f.gate = gate })
+	c := newTestCache(t, fake, CacheConfig{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetMovie(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want DeadlineExceeded", err)
+	}
+
+	// The fetch carries on without the caller and fills the cache.
+	fake.set(func(f *fakeMovies) { f.gate = nil })
+	close(gate)
+	for deadline := time.Now().Add(5 * time.Second); c.Stats().Entries == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("abandoned fetch never filled the cache")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := getTitle(t, c, 1); got != "Movie 1" || fake.callsFor(1) != 1 {
+		t.Errorf("title = %q after %d requests, want Movie 1 from the first", got, fake.callsFor(1))
+	}
+}
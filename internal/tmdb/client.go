@@ -1,24 +1,159 @@
 package tmdb
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math/rand/v2"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
-var ErrTMDBRequestFailed = errors.New("tmdb request failed")
+const (
+	DefaultBaseURL = "https://api.themoviedb.org"
+
+	defaultTimeout         = 10 * time.Second
+	defaultRetryBackoff    = 500 * time.Millisecond
+	defaultMaxRetryBackoff = 10 * time.Second
+	defaultRateLimit       = 20
+	defaultRateBurst       = 10
+)
+
+type Config struct {
+	Token string
+	// BaseURL is the API root without the /3 version prefix; tests point it
+	// at an httptest server. Defaults to DefaultBaseURL.
+	BaseURL string
+	// HTTPClient sends the requests. Defaults to a client with no timeout of
+	// its own: every attempt is bounded by Timeout instead.
+	HTTPClient *http.Client
+	Timeout    time.Duration
+	// MaxRetries is how many times a network error, 429 or 5xx is retried.
+	// Zero disables retries.
+	MaxRetries      int
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	// RateLimit (requests per second) and RateBurst configure the client-side
+	// token bucket every attempt, retries included, goes through.
+	RateLimit float64
+	RateBurst int
+}
 
 type Client struct {
-	token string
+	token      string
+	baseURL    string
+	httpClient *http.Client
+	timeout    time.Duration
+
+	maxRetries      int
+	retryBackoff    time.Duration
+	maxRetryBackoff time.Duration
+
+	limiter *limiter
 }
 
-func NewClient(token string) *Client {
-	return &Client{token: token}
+func NewClient(cfg Config) *Client {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultBaseURL
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{}
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = defaultRetryBackoff
+	}
+	if cfg.MaxRetryBackoff < cfg.RetryBackoff {
+		cfg.MaxRetryBackoff = max(defaultMaxRetryBackoff, cfg.RetryBackoff)
+	}
+	if cfg.RateLimit <= 0 {
+		cfg.RateLimit = defaultRateLimit
+	}
+	if cfg.RateBurst <= 0 {
+		cfg.RateBurst = defaultRateBurst
+	}
+
+	return &Client{
+		token:           cfg.Token,
+		baseURL:         strings.TrimRight(cfg.BaseURL, "/"),
+		httpClient:      cfg.HTTPClient,
+		timeout:         cfg.Timeout,
+		maxRetries:      cfg.MaxRetries,
+		retryBackoff:    cfg.RetryBackoff,
+		maxRetryBackoff: cfg.MaxRetryBackoff,
+		limiter:         newLimiter(cfg.RateLimit, cfg.RateBurst),
+	}
+}
+
+// doRequest GETs path (relative to /3) and decodes the JSON response into
+// target, retrying transient failures. Errors are a *StatusError for HTTP
+// failures, ErrUnavailable wrapping the cause when TMDB could not be reached,
+// or ctx's error once ctx is done.
+func (c *Client) doRequest(ctx context.Context, path string, query url.Values, target any) error {
+	u := c.baseURL + "/3" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		err := c.attempt(ctx, u, target)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var statusErr *StatusError
+		isStatus := errors.As(err, &statusErr)
+		if isStatus && !statusErr.retryable() {
+			return err
+		}
+		if !isStatus {
+			err = fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+		if attempt >= c.maxRetries {
+			return err
+		}
+
+		delay := c.backoff(attempt)
+		if isStatus && statusErr.RetryAfter > 0 {
+			delay = statusErr.RetryAfter
+		}
+		// Waiting past the caller's deadline only to fail is pointless.
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
 }
 
-func (c *Client) doRequest(url string, target any) error {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+func (c *Client) attempt(ctx context.Context, rawURL string, target any) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
 	if err != nil {
 		return err
 	}
@@ -26,65 +161,67 @@ func (c *Client) doRequest(url string, target any) error {
 	req.Header.Set("Authorization", "Bearer "+c.token)
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return ErrTMDBRequestFailed
+		// Drain the body so the connection can be reused by the retry.
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return statusError(resp)
 	}
 
 	return json.NewDecoder(resp.Body).Decode(target)
 }
 
-func (c *Client) GetPopularMovies() ([]TMDBMovieResponse, error) {
-	var result struct {
-		Results []TMDBMovieResponse `json:"results"`
+// backoff returns the delay before retry number attempt+1: exponential from
+// retryBackoff, capped at maxRetryBackoff, with full jitter so that clients
+// that failed together do not retry together.
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.maxRetryBackoff
+	if attempt < 30 {
+		d = min(c.retryBackoff<<attempt, c.maxRetryBackoff)
 	}
+	return d/2 + rand.N(d/2+1)
+}
 
-	url := "https://api.themoviedb.org/3/movie/popular?language=en-US&page=1"
-
-	if err := c.doRequest(url, &result); err != nil {
+func (c *Client) GetPopularMovies(ctx context.Context) ([]MovieSummary, error) {
+	page, err := c.GetMovieList(ctx, ListPopular, 1)
+	if err != nil {
 		return nil, err
 	}
 
-	return result.Results, nil
+	return page.Results, nil
 }
 
-func (c *Client) GetMovie(tmdbID int) (TMDBMovieResponse, error) {
-	var movie TMDBMovieResponse
+// GetMovieList returns one page (1-based) of a movie list.
+func (c *Client) GetMovieList(ctx context.Context, list MovieList, page int) (MoviePage, error) {
+	var result MoviePage
 
-	url := fmt.Sprintf(
-		"https://api.themoviedb.org/3/movie/%d?language=en-US",
-		tmdbID,
-	)
+	query := url.Values{"language": {"en-US"}, "page": {strconv.Itoa(page)}}
 
-	if err := c.doRequest(url, &movie); err != nil {
-		return TMDBMovieResponse{}, err
+	if err := c.doRequest(ctx, "/movie/"+string(list), query, &result); err != nil {
+		return MoviePage{}, err
 	}
 
-	return movie, nil
+	return result, nil
 }
 
-func (c *Client) GetTrailerKey(tmdbID int) (string, error) {
-	var videos TMDBVideosResponse
-
-	url := fmt.Sprintf(
-		"https://api.themoviedb.org/3/movie/%d/videos",
-		tmdbID,
-	)
+// GetMovie returns the movie's details together with its credits, videos and
+// release dates, fetched in one request.
+func (c *Client) GetMovie(ctx context.Context, tmdbID int) (MovieDetails, error) {
+	var movie MovieDetails
 
-	if err := c.doRequest(url, &videos); err != nil {
-		return "", err
+	query := url.Values{
+		"language":           {"en-US"},
+		"append_to_response": {"credits,videos,release_dates"},
 	}
 
-	for _, v := range videos.Results {
-		if v.Site == "YouTube" && v.Type == "Trailer" {
-			return v.Key, nil
-		}
+	if err := c.doRequest(ctx, fmt.Sprintf("/movie/%d", tmdbID), query, &movie); err != nil {
+		return MovieDetails{}, err
 	}
 
-	return "", nil
+	return movie, nil
 }
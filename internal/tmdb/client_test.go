@@ -0,0 +1,281 @@
+package tmdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient points a client at a fake TMDB serving handler. Retries wait
+// a millisecond and the rate limit is out of the way unless cfg says
+// otherwise.
+func newTestClient(t *testing.T, handler http.HandlerFunc, cfg Config) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	cfg.BaseURL = srv.URL
+	if cfg.Token == "" {
+		cfg.Token = "test-token"
+	}
+	if cfg.RetryBackoff == 0 {
+		cfg.RetryBackoff = time.Millisecond
+		cfg.MaxRetryBackoff = time.Millisecond
+	}
+	if cfg.RateLimit == 0 {
+		cfg.RateLimit = 1000
+		cfg.RateBurst = 1000
+	}
+	return NewClient(cfg)
+}
+
+func TestGetMovie(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path != "/3/movie/550":
+			t.Errorf("path = %q, want /3/movie/550", r.URL.Path)
+		case r.Header.Get("Authorization") != "Bearer test-token":
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		case r.URL.Query().Get("append_to_response") != "credits,videos,release_dates":
+			t.Errorf("append_to_response = %q", r.URL.Query().Get("append_to_response"))
+		}
+		fmt.Fprint(w, `{
+			"id": 550, "title": "Fight Club", "release_date": "1999-10-15", "runtime": 139,
+			"genres": [{"id": 18, "name": "Drama"}],
+			"credits": {"cast": [{"id": 819, "name": "Edward Norton", "character": "Narrator"}]},
+			"videos": {"results": [{"key": "abc", "site": "YouTube", "type": "Trailer"}]}
+		}`)
+	}, Config{})
+
+	m, err := c.GetMovie(context.Background(), 550)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != 550 || m.Title != "Fight Club" || m.Year() != 1999 || m.Runtime != 139 || len(m.Genres) != 1 {
+		t.Errorf("got %+v", m)
+	}
+	if len(m.Credits.Cast) != 1 || m.Credits.Cast[0].Name != "Edward Norton" {
+		t.Errorf("cast = %+v", m.Credits.Cast)
+	}
+	if key := m.Videos.TrailerKey(); key != "abc" {
+		t.Errorf("trailer key = %q, want abc", key)
+	}
+}
+
+func TestRetriesTransientFailures(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			fmt.Fprint(w, `{"page": 1, "results": [{"id": 1, "title": "One"}]}`)
+		}
+	}, Config{MaxRetries: 3})
+
+	movies, err := c.GetPopularMovies(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(movies) != 1 || movies[0].Title != "One" {
+		t.Errorf("got %+v", movies)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("made %d requests, want 3", n)
+	}
+}
+
+func TestGivesUpAfterMaxRetries(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, Config{MaxRetries: 2})
+
+	_, err := c.GetMovie(context.Background(), 1)
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("got error %v, want ErrUnavailable", err)
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("got error %v, want a 503 StatusError", err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("made %d requests, want 3", n)
+	}
+}
+
+func TestStatusErrors(t *testing.T) {
+	for _, tc := range []struct {
+		status int
+		want   error
+	}{
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrUnauthorized},
+		{http.StatusBadRequest, ErrTMDBRequestFailed},
+	} {
+		t.Run(http.StatusText(tc.status), func(t *testing.T) {
+			var calls atomic.Int32
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(tc.status)
+			}, Config{MaxRetries: 3})
+
+			_, err := c.GetMovie(context.Background(), 1)
+			if !errors.Is(err, tc.want) {
+				t.Errorf("got error %v, want %v", err, tc.want)
+			}
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != tc.status {
+				t.Errorf("got error %v, want a %d StatusError", err, tc.status)
+			}
+			if n := calls.Load(); n != 1 {
+				t.Errorf("made %d requests, want 1: client errors are not retried", n)
+			}
+		})
+	}
+}
+
+func TestNetworkErrorsAreUnavailable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	c := NewClient(Config{BaseURL: srv.URL, MaxRetries: 1, RetryBackoff: time.Millisecond})
+
+	_, err := c.GetMovie(context.Background(), 1)
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("got error %v, want ErrUnavailable", err)
+	}
+}
+
+func TestRetryAfterReplacesBackoff(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"id": 1}`)
+	}, Config{MaxRetries: 1})
+
+	start := time.Now()
+	if _, err := c.GetMovie(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want the 1s Retry-After", elapsed)
+	}
+}
+
+func TestRetryAfterPastDeadline(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}, Config{MaxRetries: 3})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err := c.GetMovie(ctx, 1)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("got error %v, want ErrRateLimited", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("gave up after %v, want at once", elapsed)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("made %d requests, want 1", n)
+	}
+}
+
+func TestRetryAfterHeader(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"7", 7 * time.Second},
+		{"-1", 0},
+		{"soon", 0},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+	} {
+		if got := retryAfter(tc.header, now); got != tc.want {
+			t.Errorf("retryAfter(%q) = %v, want %v", tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	l := newLimiter(20, 2)
+
+	start := time.Now()
+	for range 2 {
+		if err := l.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Millisecond {
+		t.Errorf("burst took %v, want no wait", elapsed)
+	}
+
+	if err := l.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("third request went after %v, want about 50ms at 20/s", elapsed)
+	}
+}
+
+func TestLimiterDeadline(t *testing.T) {
+	l := newLimiter(1, 1)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next token is a second away, past the deadline: fail at once and
+	// leave the reservation for the next caller.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("gave up after %v, want at once", elapsed)
+	}
+	l.mu.Lock()
+	tokens := l.tokens
+	l.mu.Unlock()
+	if tokens < -0.1 {
+		t.Errorf("tokens = %v after giving up, want the reservation returned", tokens)
+	}
+}
+
+func TestClientRateLimit(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 1}`)
+	}, Config{RateLimit: 20, RateBurst: 1})
+
+	start := time.Now()
+	for range 3 {
+		if _, err := c.GetMovie(context.Background(), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 requests at 20/s with a burst of 1 took %v, want about 100ms", elapsed)
+	}
+}
@@ -1,18 +1,197 @@
 package tmdb
 
-type TMDBMovieResponse struct {
+import (
+	"strconv"
+)
+
+// ImageBaseURL serves the poster, backdrop and profile paths TMDB returns.
+const ImageBaseURL = "https://image.tmdb.org/t/p/"
+
+// ImageURL turns an image path such as "/abc.jpg" into a URL at the given
+// size ("w500", "original", ...). It returns "" for an empty path.
+func ImageURL(path, size string) string {
+	if path == "" {
+		return ""
+	}
+	return ImageBaseURL + size + path
+}
+
+// MovieList names one of TMDB's curated movie lists.
+type MovieList string
+
+const (
+	ListPopular    MovieList = "popular"
+	ListNowPlaying MovieList = "now_playing"
+	ListTopRated   MovieList = "top_rated"
+	ListUpcoming   MovieList = "upcoming"
+)
+
+// MaxListPages is the last page TMDB serves for any list, however many
+// total_pages it reports.
+const MaxListPages = 500
+
+// MoviePage is one page of a movie list.
+type MoviePage struct {
+	Page         int            `json:"page"`
+	TotalPages   int            `json:"total_pages"`
+	TotalResults int            `json:"total_results"`
+	Results      []MovieSummary `json:"results"`
+}
+
+// MovieSummary is a movie as list endpoints (popular, search) return it.
+type MovieSummary struct {
+	ID           int     `json:"id"`
+	Title        string  `json:"title"`
+	Overview     string  `json:"overview"`
+	ReleaseDate  string  `json:"release_date"`
+	GenreIDs     []int   `json:"genre_ids"`
+	PosterPath   string  `json:"poster_path"`
+	BackdropPath string  `json:"backdrop_path"`
+	VoteAverage  float64 `json:"vote_average"`
+	VoteCount    int     `json:"vote_count"`
+}
+
+// Year returns the release year, or 0 when the date is missing.
+func (m MovieSummary) Year() int {
+	return releaseYear(m.ReleaseDate)
+}
+
+// MovieDetails is the /movie/{id} response. GetMovie asks for credits,
+// videos and release dates in the same request, so those are filled too.
+type MovieDetails struct {
+	ID            int     `json:"id"`
+	IMDbID        string  `json:"imdb_id"`
+	Title         string  `json:"title"`
+	OriginalTitle string  `json:"original_title"`
+	Tagline       string  `json:"tagline"`
+	Overview      string  `json:"overview"`
+	ReleaseDate   string  `json:"release_date"`
+	Runtime       int     `json:"runtime"` // minutes
+	Status        string  `json:"status"`
+	Genres        []Genre `json:"genres"`
+	PosterPath    string  `json:"poster_path"`
+	BackdropPath  string  `json:"backdrop_path"`
+	VoteAverage   float64 `json:"vote_average"`
+	VoteCount     int     `json:"vote_count"`
+
+	Credits      Credits      `json:"credits"`
+	Videos       Videos       `json:"videos"`
+	ReleaseDates ReleaseDates `json:"release_dates"`
+}
+
+// Year returns the release year, or 0 when the date is missing.
+func (m MovieDetails) Year() int {
+	return releaseYear(m.ReleaseDate)
+}
+
+type Genre struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type Credits struct {
+	Cast []CastMember `json:"cast"`
+	Crew []CrewMember `json:"crew"`
+}
+
+type CastMember struct {
 	ID          int    `json:"id"`
-	Title       string `json:"title"`
-	Overview    string `json:"overview"`
-	ReleaseDate string `json:"release_date"`
+	Name        string `json:"name"`
+	Character   string `json:"character"`
+	Order       int    `json:"order"`
+	ProfilePath string `json:"profile_path"`
+}
+
+type CrewMember struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Job         string `json:"job"`
+	Department  string `json:"department"`
+	ProfilePath string `json:"profile_path"`
+}
+
+type Videos struct {
+	Results []Video `json:"results"`
+}
+
+type Video struct {
+	Key         string `json:"key"`
+	Name        string `json:"name"`
+	Site        string `json:"site"`
+	Type        string `json:"type"`
+	Official    bool   `json:"official"`
+	PublishedAt string `json:"published_at"`
+}
+
+// TrailerKey returns the YouTube key of the movie's trailer, preferring
+// official ones, or "" if there is none.
+func (v Videos) TrailerKey() string {
+	key := ""
+	for _, video := range v.Results {
+		if video.Site != "YouTube" || video.Type != "Trailer" {
+			continue
+		}
+		if video.Official {
+			return video.Key
+		}
+		if key == "" {
+			key = video.Key
+		}
+	}
+	return key
+}
+
+type ReleaseDates struct {
+	Results []CountryReleaseDates `json:"results"`
+}
+
+type CountryReleaseDates struct {
+	Country      string        `json:"iso_3166_1"`
+	ReleaseDates []ReleaseDate `json:"release_dates"`
+}
+
+// Release types, as numbered by TMDB.
+const (
+	ReleasePremiere ReleaseType = iota + 1
+	ReleaseTheatricalLimited
+	ReleaseTheatrical
+	ReleaseDigital
+	ReleasePhysical
+	ReleaseTV
+)
+
+type ReleaseType int
+
+var releaseTypeNames = map[ReleaseType]string{
+	ReleasePremiere:          "premiere",
+	ReleaseTheatricalLimited: "theatrical_limited",
+	ReleaseTheatrical:        "theatrical",
+	ReleaseDigital:           "digital",
+	ReleasePhysical:          "physical",
+	ReleaseTV:                "tv",
+}
+
+func (t ReleaseType) String() string {
+	if name, ok := releaseTypeNames[t]; ok {
+		return name
+	}
+	return "type " + strconv.Itoa(int(t))
 }
 
-type TMDBVideosResponse struct {
-	Results []TMDBVideo `json:"results"`
+type ReleaseDate struct {
+	Certification string      `json:"certification"`
+	Note          string      `json:"note"`
+	ReleaseDate   string      `json:"release_date"` // RFC 3339
+	Type          ReleaseType `json:"type"`
 }
 
-type TMDBVideo struct {
-	Key  string `json:"key"`
-	Site string `json:"site"`
-	Type string `json:"type"`
+func releaseYear(date string) int {
+	if len(date) < 4 {
+		return 0
+	}
+	y, err := strconv.Atoi(date[:4])
+	if err != nil {
+		return 0
+	}
+	return y
 }
@@ -0,0 +1,84 @@
+package tmdb
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrTMDBRequestFailed is returned for responses that fit none of the
+	// errors below, such as 400 Bad Request.
+	ErrTMDBRequestFailed = errors.New("tmdb request failed")
+
+	ErrNotFound     = errors.New("tmdb resource not found")
+	ErrUnauthorized = errors.New("tmdb rejected the access token")
+	ErrRateLimited  = errors.New("tmdb rate limit exceeded")
+	ErrUnavailable  = errors.New("tmdb unavailable")
+)
+
+// StatusError is a failed TMDB response. It unwraps to one of the errors
+// above, so callers match it with errors.Is and only reach for the struct
+// when they need the status or the Retry-After delay.
+type StatusError struct {
+	StatusCode int
+	// RetryAfter is the delay TMDB asked for on 429 and 503, if any.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%v (status %d)", e.Err, e.StatusCode)
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+func statusError(resp *http.Response) *StatusError {
+	e := &StatusError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: retryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		e.Err = ErrNotFound
+	case resp.StatusCode == http.StatusUnauthorized, resp.StatusCode == http.StatusForbidden:
+		e.Err = ErrUnauthorized
+	case resp.StatusCode == http.StatusTooManyRequests:
+		e.Err = ErrRateLimited
+	case resp.StatusCode >= 500:
+		e.Err = ErrUnavailable
+	default:
+		e.Err = ErrTMDBRequestFailed
+	}
+	return e
+}
+
+// retryable reports whether the request may succeed if sent again.
+func (e *StatusError) retryable() bool {
+	return errors.Is(e.Err, ErrRateLimited) || errors.Is(e.Err, ErrUnavailable)
+}
+
+// retryAfter parses a Retry-After header, which holds either a number of
+// seconds or an HTTP date. It returns 0 when the header is absent or invalid.
+func retryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(header); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
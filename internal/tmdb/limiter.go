@@ -0,0 +1,72 @@
+package tmdb
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// limiter is a token bucket: it holds up to burst tokens, refills at rate
+// tokens per second, and every request takes one.
+type limiter struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(rate float64, burst int) *limiter {
+	return &limiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a token is available or ctx is done. A token is reserved
+// before sleeping, so concurrent callers are served in arrival order.
+func (l *limiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		l.giveBack()
+		return context.DeadlineExceeded
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		l.giveBack()
+		return ctx.Err()
+	}
+}
+
+// giveBack returns a reserved token that was not used.
+func (l *limiter) giveBack() {
+	l.mu.Lock()
+	l.tokens++
+	l.mu.Unlock()
+}
@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+// DiaryEntry is one watch of a movie. WatchedOn is a YYYY-MM-DD date. Score
+// is from 1 to 5, or nil for a watch that was not rated; the movie's rating
+// counts each user's latest scored entry.
+type DiaryEntry struct {
+	ID        int       `json:"id"`
+	Movie     Movie     `json:"movie"`
+	WatchedOn string    `json:"watched_on"`
+	Rewatch   bool      `json:"rewatch"`
+	Score     *int      `json:"score"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DiaryFilter narrows a diary to one movie and to the watches from From to
+// To, both YYYY-MM-DD and inclusive. Zero values leave it open.
+type DiaryFilter struct {
+	MovieID int
+	From    string
+	To      string
+}
+
+// DiaryDay is the number of watches logged for one YYYY-MM-DD date.
+type DiaryDay struct {
+	Date    string `json:"date"`
+	Watches int    `json:"watches"`
+}
+
+// DiaryMonth is one YYYY-MM month of a calendar: its total and the days
+// that have watches.
+type DiaryMonth struct {
+	Month   string     `json:"month"`
+	Watches int        `json:"watches"`
+	Days    []DiaryDay `json:"days"`
+}
+
+// DiaryCalendar counts a user's watches per month and day between two dates.
+// Every month in the range is listed, with or without watches.
+type DiaryCalendar struct {
+	From    string       `json:"from"`
+	To      string       `json:"to"`
+	Watches int          `json:"watches"`
+	Months  []DiaryMonth `json:"months"`
+}
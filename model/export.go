@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// ExportFilter selects the movies an export covers, with the same q, year
+// and genre semantics as movie search. MovieID narrows it to one movie and
+// UserID narrows a review export to one author. Sort and Desc order the rows
+// by the sort keys the list endpoints accept; by default rows come by id.
+type ExportFilter struct {
+	Query string
+	MovieFilter
+	MovieID int
+	UserID  int
+	Sort    string
+	Desc    bool
+}
+
+// ExportedReview is a review with the movie and author it belongs to.
+type ExportedReview struct {
+	ID         int       `json:"id"`
+	MovieID    int       `json:"movie_id"`
+	TMDBID     int       `json:"tmdb_id"`
+	MovieTitle string    `json:"movie_title"`
+	UserID     int       `json:"user_id"`
+	Username   string    `json:"username"`
+	Score      int       `json:"score"`
+	Text       string    `json:"text"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// MovieRating is a movie's stored rating next to the review scores it is
+// built from. Movies without reviews have zero counts and scores.
+type MovieRating struct {
+	MovieID      int     `json:"movie_id"`
+	TMDBID       int     `json:"tmdb_id"`
+	Title        string  `json:"title"`
+	Year         int     `json:"year"`
+	Rating       float64 `json:"rating"`
+	Reviews      int     `json:"reviews"`
+	AverageScore float64 `json:"average_score"`
+	MinScore     int     `json:"min_score"`
+	MaxScore     int     `json:"max_score"`
+}
@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+// Activity kinds.
+const (
+	ActivityReview      = "review"
+	ActivityDiary       = "diary"
+	ActivityListCreated = "list_created"
+	ActivityListEntry   = "list_entry"
+)
+
+// FeedBackfill is how many of a user's latest activities land in a new
+// follower's feed.
+const FeedBackfill = 50
+
+// Follow is the other side of a follow: the follower or the followed user,
+// and since when.
+type Follow struct {
+	UserID   int       `json:"user_id"`
+	Username string    `json:"username"`
+	Since    time.Time `json:"since"`
+}
+
+// Activity is something a user did, as shown in their followers' feeds.
+// Which fields are set depends on the kind; they are read from the review,
+// diary entry or list when the feed is, so later edits show. Diary notes
+// stay private.
+type Activity struct {
+	ID        int       `json:"id"`
+	Kind      string    `json:"kind"`
+	UserID    int       `json:"user_id"`
+	Username  string    `json:"username"`
+	Movie     *Movie    `json:"movie,omitempty"`
+	Score     *int      `json:"score,omitempty"`
+	Text      string    `json:"text,omitempty"`
+	WatchedOn string    `json:"watched_on,omitempty"`
+	Rewatch   bool      `json:"rewatch,omitempty"`
+	List      *ListRef  `json:"list,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ListRef struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+// ActivitySubject is what an activity is about: the review, the diary
+// entry, or the list, with the entry for list_entry activities.
+type ActivitySubject struct {
+	ReviewID     int
+	DiaryEntryID int
+	ListID       int
+	ListEntryID  int
+}
@@ -0,0 +1,20 @@
+package model
+
+// Genre is a genre of the local catalog. TMDBID links it to TMDB's genre of
+// the same meaning, and is 0 for genres created by hand. Movies is the
+// number of movies in the genre, filled in by genre listings only.
+type Genre struct {
+	ID     int    `json:"id"`
+	TMDBID int    `json:"tmdb_id,omitempty"`
+	Name   string `json:"name"`
+	Movies int    `json:"movies,omitempty"`
+}
+
+// MovieFilter narrows movie listings and searches. Zero fields do not
+// filter; Year is an exact year, YearFrom and YearTo an inclusive range.
+type MovieFilter struct {
+	Year     int
+	YearFrom int
+	YearTo   int
+	GenreID  int
+}
@@ -0,0 +1,52 @@
+package model
+
+import "time"
+
+// Import job statuses. A partial import stored some rows and failed others.
+const (
+	ImportRunning   = "running"
+	ImportSucceeded = "succeeded"
+	ImportPartial   = "partial"
+	ImportFailed    = "failed"
+)
+
+// Import row outcomes. In a dry run they say what the import would do.
+const (
+	ImportRowPending   = "pending"
+	ImportRowCreated   = "created"
+	ImportRowUpdated   = "updated"
+	ImportRowUnchanged = "unchanged"
+	ImportRowDuplicate = "duplicate"
+	ImportRowFailed    = "failed"
+)
+
+// ImportJob is a bulk import of movies by TMDB id. Rows is left out of job
+// listings.
+type ImportJob struct {
+	ID         int         `json:"id"`
+	Format     string      `json:"format"`
+	DryRun     bool        `json:"dry_run"`
+	Status     string      `json:"status"`
+	StartedAt  time.Time   `json:"started_at"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+	Total      int         `json:"total"`
+	Processed  int         `json:"processed"`
+	Created    int         `json:"created"`
+	Updated    int         `json:"updated"`
+	Unchanged  int         `json:"unchanged"`
+	Duplicates int         `json:"duplicates"`
+	Failed     int         `json:"failed"`
+	Rows       []ImportRow `json:"rows,omitempty"`
+}
+
+// ImportRow is one entry of the import file. Line is its line in the file,
+// or its position for JSON arrays. MovieID is the local movie, or 0 when a
+// dry run would create it.
+type ImportRow struct {
+	Line    int    `json:"line"`
+	TMDBID  int    `json:"tmdb_id,omitempty"`
+	Status  string `json:"status"`
+	MovieID int    `json:"movie_id,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
@@ -0,0 +1,62 @@
+package model
+
+import "time"
+
+// List visibilities. Public lists show up in discovery; unlisted ones can be
+// read by anyone who has the id; private ones only by their owner and
+// collaborators.
+const (
+	ListPublic   = "public"
+	ListUnlisted = "unlisted"
+	ListPrivate  = "private"
+)
+
+// The caller's role on a list, when they have one.
+const (
+	ListRoleOwner        = "owner"
+	ListRoleCollaborator = "collaborator"
+)
+
+// MovieList is a named, ordered list of movies curated by its owner and
+// collaborators. Entries is the number of movies on it. Collaborators are
+// only loaded for a single list.
+type MovieList struct {
+	ID            int                `json:"id"`
+	OwnerID       int                `json:"owner_id"`
+	Owner         string             `json:"owner"`
+	Title         string             `json:"title"`
+	Description   string             `json:"description"`
+	Visibility    string             `json:"visibility"`
+	ClonedFrom    *int               `json:"cloned_from,omitempty"`
+	Entries       int                `json:"entries"`
+	Collaborators []ListCollaborator `json:"collaborators,omitempty"`
+	Role          string             `json:"role,omitempty"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+}
+
+type ListCollaborator struct {
+	UserID   int       `json:"user_id"`
+	Username string    `json:"username"`
+	AddedAt  time.Time `json:"added_at"`
+}
+
+// ListEntry is a movie on a list, with the curator's comment. AddedBy is 0
+// once the user who added it has been deleted.
+type ListEntry struct {
+	ID       int       `json:"id"`
+	Movie    Movie     `json:"movie"`
+	Position int       `json:"position"`
+	Comment  string    `json:"comment"`
+	AddedBy  int       `json:"added_by,omitempty"`
+	AddedAt  time.Time `json:"added_at"`
+}
+
+// ListFilter narrows lists to those MemberID owns or collaborates on, those
+// containing MovieID, and with PublicOnly to public ones. Zero values leave
+// it open.
+type ListFilter struct {
+	MemberID   int
+	MovieID    int
+	PublicOnly bool
+}
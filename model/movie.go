@@ -1,5 +1,15 @@
 package model
 
+import "slices"
+
+// Movie fields the TMDB sync refreshes, unless an admin has overridden them.
+const (
+	FieldTitle       = "title"
+	FieldYear        = "year"
+	FieldDescription = "description"
+	FieldGenres      = "genres"
+)
+
 type Movie struct {
 	ID          int     `json:"id"`
 	TMDBID      int     `json:"tmdb_id"`
@@ -7,4 +17,49 @@ type Movie struct {
 	Year        int     `json:"year"`
 	Description string  `json:"description"`
 	Rating      float64 `json:"rating"`
+	// Overrides lists the fields an admin has edited; the TMDB sync keeps
+	// their local values.
+	Overrides []string `json:"overrides,omitempty"`
+	// Genres are loaded by the service, not by the movie repositories.
+	Genres []Genre `json:"genres"`
+}
+
+// Overridden reports whether field is in m.Overrides.
+func (m Movie) Overridden(field string) bool {
+	return slices.Contains(m.Overrides, field)
+}
+
+// MergeTMDB returns m with TMDB's title, year and description from src,
+// except for overridden fields, and whether anything changed.
+func (m Movie) MergeTMDB(src Movie) (Movie, bool) {
+	next := m
+	if !m.Overridden(FieldTitle) {
+		next.Title = src.Title
+	}
+	if !m.Overridden(FieldYear) {
+		next.Year = src.Year
+	}
+	if !m.Overridden(FieldDescription) {
+		next.Description = src.Description
+	}
+	changed := next.Title != m.Title || next.Year != m.Year || next.Description != m.Description
+	return next, changed
+}
+
+// UpsertResult says what an upsert did to the stored row.
+type UpsertResult int
+
+const (
+	UpsertCreated UpsertResult = iota
+	UpsertUpdated
+	UpsertUnchanged
+)
+
+// MovieSearchHit is a movie matched by full-text search. Snippet is an
+// HTML-escaped excerpt of the description with matched terms wrapped in
+// <mark> tags.
+type MovieSearchHit struct {
+	Movie
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
 }
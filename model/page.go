@@ -0,0 +1,16 @@
+package model
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// ListOptions describes one page of a sorted listing. Sort is one of the
+// sort keys the repository knows for that listing; an empty Sort means the
+// repository default.
+type ListOptions struct {
+	Limit  int
+	Offset int
+	Sort   string
+	Desc   bool
+}
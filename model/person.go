@@ -0,0 +1,82 @@
+package model
+
+import (
+	"cmp"
+	"strings"
+)
+
+// Credit roles.
+const (
+	RoleCast = "cast"
+	RoleCrew = "crew"
+)
+
+// Person is someone credited on a movie of the local catalog. People come
+// from TMDB credits; TMDBID is TMDB's person id.
+type Person struct {
+	ID         int    `json:"id"`
+	TMDBID     int    `json:"tmdb_id"`
+	Name       string `json:"name"`
+	ProfileURL string `json:"profile_url,omitempty"`
+}
+
+// Credit is one person's part in a movie. Cast credits have a Character,
+// crew credits a Job and Department. Order is the billing order for cast
+// and TMDB's listing order for crew.
+type Credit struct {
+	PersonID   int    `json:"person_id"`
+	TMDBID     int    `json:"tmdb_id"`
+	Name       string `json:"name"`
+	ProfileURL string `json:"profile_url,omitempty"`
+	Role       string `json:"role"`
+	Character  string `json:"character,omitempty"`
+	Job        string `json:"job,omitempty"`
+	Department string `json:"department,omitempty"`
+	Order      int    `json:"order"`
+}
+
+// FilmographyEntry is one credit of a person, on a local movie.
+type FilmographyEntry struct {
+	MovieID    int    `json:"movie_id"`
+	TMDBID     int    `json:"tmdb_id"`
+	Title      string `json:"title"`
+	Year       int    `json:"year"`
+	Role       string `json:"role"`
+	Character  string `json:"character,omitempty"`
+	Job        string `json:"job,omitempty"`
+	Department string `json:"department,omitempty"`
+}
+
+// PersonDetail is a person with every credit in the catalog, newest movie
+// first.
+type PersonDetail struct {
+	Person
+	Filmography []FilmographyEntry `json:"filmography"`
+}
+
+// MovieDetail is a movie with its top-billed cast and its directors.
+type MovieDetail struct {
+	Movie
+	Cast      []Credit `json:"cast"`
+	Directors []Credit `json:"directors"`
+}
+
+// CompareCredits orders credits the way a movie lists them: cast before
+// crew, then by Order. Ties go by TMDB person id, job and character, so
+// any list of credits sorts the same way every time.
+func CompareCredits(a, b Credit) int {
+	return cmp.Or(
+		strings.Compare(a.Role, b.Role),
+		cmp.Compare(a.Order, b.Order),
+		cmp.Compare(a.TMDBID, b.TMDBID),
+		strings.Compare(a.Job, b.Job),
+		strings.Compare(a.Character, b.Character),
+	)
+}
+
+// SamePart reports whether c and o credit the same TMDB person with the
+// same part, whatever the person's local id, name and photo.
+func (c Credit) SamePart(o Credit) bool {
+	return c.TMDBID == o.TMDBID && c.Role == o.Role && c.Character == o.Character &&
+		c.Job == o.Job && c.Department == o.Department && c.Order == o.Order
+}
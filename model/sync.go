@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// Sync run statuses. A partial run stored what it could but hit errors.
+const (
+	SyncRunning   = "running"
+	SyncSucceeded = "succeeded"
+	SyncPartial   = "partial"
+	SyncFailed    = "failed"
+)
+
+// Sync run triggers.
+const (
+	SyncTriggerSchedule = "schedule"
+	SyncTriggerManual   = "manual"
+)
+
+// SyncRun is one pass of the TMDB catalog sync.
+type SyncRun struct {
+	ID         int        `json:"id"`
+	Trigger    string     `json:"trigger"`
+	Status     string     `json:"status"`
+	Lists      []string   `json:"lists"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Pages      int        `json:"pages"`
+	Fetched    int        `json:"fetched"`
+	Created    int        `json:"created"`
+	Updated    int        `json:"updated"`
+	Unchanged  int        `json:"unchanged"`
+	Failed     int        `json:"failed"`
+	Errors     []string   `json:"errors"`
+}
@@ -0,0 +1,64 @@
+package model
+
+// TMDBMovie is a movie's metadata as fetched from TMDB, for the movie page.
+// Description and ReleaseDate keep the names the page has always used.
+type TMDBMovie struct {
+	ID            int         `json:"id"`
+	IMDbID        string      `json:"imdb_id,omitempty"`
+	Title         string      `json:"title"`
+	OriginalTitle string      `json:"original_title,omitempty"`
+	Tagline       string      `json:"tagline,omitempty"`
+	Description   string      `json:"description"`
+	ReleaseDate   string      `json:"release_date"`
+	Year          int         `json:"year,omitempty"`
+	Runtime       int         `json:"runtime,omitempty"` // minutes
+	Genres        []TMDBGenre `json:"genres"`
+	PosterURL     string      `json:"poster_url,omitempty"`
+	BackdropURL   string      `json:"backdrop_url,omitempty"`
+	VoteAverage   float64     `json:"vote_average"`
+	VoteCount     int         `json:"vote_count"`
+
+	Cast         []CastCredit  `json:"cast"`
+	Crew         []CrewCredit  `json:"crew"`
+	Videos       []Video       `json:"videos"`
+	ReleaseDates []ReleaseDate `json:"release_dates"`
+	TrailerURL   string        `json:"trailer_url"`
+}
+
+// TMDBGenre is a genre as TMDB names it; ID is TMDB's genre id.
+type TMDBGenre struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type CastCredit struct {
+	PersonID   int    `json:"person_id"`
+	Name       string `json:"name"`
+	Character  string `json:"character"`
+	Order      int    `json:"order"`
+	ProfileURL string `json:"profile_url,omitempty"`
+}
+
+type CrewCredit struct {
+	PersonID   int    `json:"person_id"`
+	Name       string `json:"name"`
+	Job        string `json:"job"`
+	Department string `json:"department"`
+	ProfileURL string `json:"profile_url,omitempty"`
+}
+
+type Video struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Official bool   `json:"official"`
+	URL      string `json:"url"`
+}
+
+// ReleaseDate is one release of the movie in one country. Type is premiere,
+// theatrical_limited, theatrical, digital, physical or tv.
+type ReleaseDate struct {
+	Country       string `json:"country"`
+	Date          string `json:"date"`
+	Type          string `json:"type"`
+	Certification string `json:"certification,omitempty"`
+}
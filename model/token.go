@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// RefreshToken is a stored refresh token. Only the SHA-256 hash of the token
+// is kept; every token issued from one login shares a FamilyID so that the
+// whole chain can be revoked when a rotated token is replayed.
+type RefreshToken struct {
+	ID         int
+	UserID     int
+	TokenHash  string
+	FamilyID   string
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+	ReplacedBy *int
+	CreatedAt  time.Time
+}
@@ -8,5 +8,6 @@ type User struct {
 	Email        string
 	PasswordHash string
 	Role         string
+	TokenVersion int
 	CreatedAt    time.Time
 }
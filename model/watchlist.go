@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// WatchlistEntry is a movie on a user's watchlist. Entries are ordered by
+// Position. PlannedFor is a YYYY-MM-DD date, or empty; Due is set once that
+// date has come. The note is visible to its owner only.
+type WatchlistEntry struct {
+	ID         int       `json:"id"`
+	Movie      Movie     `json:"movie"`
+	Position   int       `json:"position"`
+	Note       string    `json:"note"`
+	PlannedFor string    `json:"planned_for,omitempty"`
+	Due        bool      `json:"due"`
+	AddedAt    time.Time `json:"added_at"`
+}
+
+// WatchlistFilter narrows a watchlist by its movies and, with DueBy (a
+// YYYY-MM-DD date), to entries planned for that day or earlier.
+type WatchlistFilter struct {
+	MovieFilter
+	DueBy string
+}
@@ -0,0 +1,170 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/AlikhanF2006/Final_project/configs"
+)
+
+const (
+	defaultMaxConns          = 10
+	defaultMinConns          = 1
+	defaultMaxConnLifetime   = time.Hour
+	defaultMaxConnIdleTime   = 30 * time.Minute
+	defaultHealthCheckPeriod = time.Minute
+	defaultConnectTimeout    = 5 * time.Second
+	defaultConnectAttempts   = 5
+	defaultConnectBackoff    = 500 * time.Millisecond
+	maxConnectBackoff        = 10 * time.Second
+)
+
+var ErrEmptyURL = errors.New("database url is empty")
+
+// Op is the kind of database operation a query belongs to; each kind has
+// its own deadline.
+type Op int
+
+const (
+	OpRead Op = iota
+	OpWrite
+	OpSearch
+)
+
+const defaultQueryTimeout = 5 * time.Second
+
+// DB is the shared connection pool handed to the repositories.
+type DB struct {
+	*pgxpool.Pool
+	timeouts map[Op]time.Duration
+}
+
+// WithTimeout derives the context a repository runs one operation under:
+// the caller's context (request or job) bounded by the deadline for op.
+func (d *DB) WithTimeout(ctx context.Context, op Op) (context.Context, context.CancelFunc) {
+	timeout := d.timeouts[op]
+	if timeout <= 0 {
+		timeout = defaultQueryTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Connect builds a pool from cfg and retries the first ping with exponential
+// backoff, so the server can start while Postgres is still coming up.
+func Connect(ctx context.Context, cfg configs.DatabaseConfig) (*DB, error) {
+	if cfg.URL == "" {
+		return nil, ErrEmptyURL
+	}
+
+	poolCfg, err := pgxpool.ParseConfig(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse database url: %w", err)
+	}
+	applyPoolConfig(poolCfg, cfg)
+
+	attempts := cfg.ConnectAttempts
+	if attempts <= 0 {
+		attempts = defaultConnectAttempts
+	}
+	backoff := cfg.ConnectBackoff
+	if backoff <= 0 {
+		backoff = defaultConnectBackoff
+	}
+	timeout := cfg.ConnectTimeout
+	if timeout <= 0 {
+		timeout = defaultConnectTimeout
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+		if err == nil {
+			d := &DB{
+				Pool: pool,
+				timeouts: map[Op]time.Duration{
+					OpRead:   cfg.ReadTimeout,
+					OpWrite:  cfg.WriteTimeout,
+					OpSearch: cfg.SearchTimeout,
+				},
+			}
+			pingCtx, cancel := context.WithTimeout(ctx, timeout)
+			err = d.Ping(pingCtx)
+			cancel()
+			if err == nil {
+				return d, nil
+			}
+			pool.Close()
+		}
+		lastErr = err
+
+		if attempt == attempts {
+			break
+		}
+
+		log.Printf("db: connect attempt %d/%d failed: %v (retrying in %s)", attempt, attempts, err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxConnectBackoff {
+			backoff = maxConnectBackoff
+		}
+	}
+
+	return nil, fmt.Errorf("connect to database after %d attempts: %w", attempts, lastErr)
+}
+
+// Ping checks that the database is reachable through the pool.
+func (d *DB) Ping(ctx context.Context) error {
+	return d.Pool.Ping(ctx)
+}
+
+func (d *DB) Close() {
+	if d != nil && d.Pool != nil {
+		d.Pool.Close()
+	}
+}
+
+func applyPoolConfig(poolCfg *pgxpool.Config, cfg configs.DatabaseConfig) {
+	poolCfg.MaxConns = defaultMaxConns
+	if cfg.MaxConns > 0 {
+		poolCfg.MaxConns = cfg.MaxConns
+	}
+
+	poolCfg.MinConns = defaultMinConns
+	if cfg.MinConns > 0 {
+		poolCfg.MinConns = cfg.MinConns
+	}
+	if poolCfg.MinConns > poolCfg.MaxConns {
+		poolCfg.MinConns = poolCfg.MaxConns
+	}
+
+	poolCfg.MaxConnLifetime = defaultMaxConnLifetime
+	if cfg.MaxConnLifetime > 0 {
+		poolCfg.MaxConnLifetime = cfg.MaxConnLifetime
+	}
+
+	poolCfg.MaxConnIdleTime = defaultMaxConnIdleTime
+	if cfg.MaxConnIdleTime > 0 {
+		poolCfg.MaxConnIdleTime = cfg.MaxConnIdleTime
+	}
+
+	poolCfg.HealthCheckPeriod = defaultHealthCheckPeriod
+	if cfg.HealthCheckPeriod > 0 {
+		poolCfg.HealthCheckPeriod = cfg.HealthCheckPeriod
+	}
+
+	poolCfg.ConnConfig.ConnectTimeout = defaultConnectTimeout
+	if cfg.ConnectTimeout > 0 {
+		poolCfg.ConnConfig.ConnectTimeout = cfg.ConnectTimeout
+	}
+}
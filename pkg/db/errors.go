@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrTimeout  = errors.New("database operation timed out")
+	ErrCanceled = errors.New("database operation canceled")
+)
+
+// queryCanceledCode is the SQLSTATE Postgres reports when statement_timeout
+// or a cancel request stops a query.
+const queryCanceledCode = "57014"
+
+// Classify wraps deadline and cancellation failures in ErrTimeout or
+// ErrCanceled so callers can tell them apart from "not found" and other
+// errors. Any other error is returned unchanged.
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch {
+	case errors.Is(err, ErrTimeout), errors.Is(err, ErrCanceled):
+		return err
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	case errors.Is(err, context.Canceled):
+		return fmt.Errorf("%w: %w", ErrCanceled, err)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == queryCanceledCode {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+
+	return err
+}
+
+// IsContextError reports whether err is a timeout or cancellation.
+func IsContextError(err error) bool {
+	err = Classify(err)
+	return errors.Is(err, ErrTimeout) || errors.Is(err, ErrCanceled)
+}
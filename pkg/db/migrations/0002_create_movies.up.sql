@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS movies (
+    id          SERIAL PRIMARY KEY,
+    tmdb_id     INT NOT NULL DEFAULT 0,
+    title       TEXT NOT NULL,
+    year        INT NOT NULL DEFAULT 0,
+    description TEXT NOT NULL DEFAULT '',
+    rating      DOUBLE PRECISION NOT NULL DEFAULT 0,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Movies created by hand have tmdb_id = 0, so only real TMDB ids are unique.
+CREATE UNIQUE INDEX IF NOT EXISTS movies_tmdb_id_key ON movies (tmdb_id) WHERE tmdb_id <> 0;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS reviews (
+    id         SERIAL PRIMARY KEY,
+    movie_id   INT NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    user_id    INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    score      INT NOT NULL CHECK (score >= 1 AND score <= 5),
+    text       TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (movie_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS reviews_user_id_idx ON reviews (user_id);
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS roles (
+    name TEXT PRIMARY KEY
+);
+
+INSERT INTO roles (name) VALUES ('user'), ('moderator'), ('admin')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role       TEXT NOT NULL REFERENCES roles (name) ON DELETE CASCADE,
+    permission TEXT NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('user',      'review:write'),
+    ('moderator', 'review:write'),
+    ('moderator', 'review:moderate'),
+    ('moderator', 'movie:write'),
+    ('admin',     'review:write'),
+    ('admin',     'review:moderate'),
+    ('admin',     'movie:write'),
+    ('admin',     'user:admin')
+ON CONFLICT DO NOTHING;
+
+UPDATE users SET role = 'user' WHERE role NOT IN (SELECT name FROM roles);
+
+ALTER TABLE users
+    ADD CONSTRAINT users_role_fkey FOREIGN KEY (role) REFERENCES roles (name);
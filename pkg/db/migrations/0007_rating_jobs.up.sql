@@ -0,0 +1,16 @@
+-- Outbox of movies whose rating must be recomputed. One row per movie, so
+-- repeated review changes coalesce into a single job.
+CREATE TABLE IF NOT EXISTS rating_jobs (
+    movie_id        INT PRIMARY KEY REFERENCES movies (id) ON DELETE CASCADE,
+    enqueued_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    attempts        INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error      TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS rating_jobs_next_attempt_idx ON rating_jobs (next_attempt_at);
+
+-- Recompute every existing rating once so ratings written by the old
+-- in-memory worker cannot stay out of sync.
+INSERT INTO rating_jobs (movie_id) SELECT id FROM movies
+ON CONFLICT DO NOTHING;
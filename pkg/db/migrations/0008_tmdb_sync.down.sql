@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS tmdb_sync_runs;
+ALTER TABLE movies DROP COLUMN IF EXISTS overrides;
@@ -0,0 +1,20 @@
+-- Fields an admin has edited by hand; the TMDB sync leaves them alone.
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS overrides TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE TABLE IF NOT EXISTS tmdb_sync_runs (
+    id          SERIAL PRIMARY KEY,
+    triggered_by TEXT NOT NULL,
+    status      TEXT NOT NULL,
+    lists       TEXT[] NOT NULL DEFAULT '{}',
+    started_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    finished_at TIMESTAMPTZ,
+    pages       INT NOT NULL DEFAULT 0,
+    fetched     INT NOT NULL DEFAULT 0,
+    created     INT NOT NULL DEFAULT 0,
+    updated     INT NOT NULL DEFAULT 0,
+    unchanged   INT NOT NULL DEFAULT 0,
+    failed      INT NOT NULL DEFAULT 0,
+    errors      TEXT[] NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS tmdb_sync_runs_started_at_idx ON tmdb_sync_runs (started_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS movies_year_idx;
+DROP TABLE IF EXISTS movie_genres;
+DROP TABLE IF EXISTS genres;
@@ -0,0 +1,18 @@
+-- tmdb_id is TMDB's genre id, NULL for genres created by hand.
+CREATE TABLE IF NOT EXISTS genres (
+    id         SERIAL PRIMARY KEY,
+    tmdb_id    INT UNIQUE,
+    name       TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS genres_name_key ON genres (lower(name));
+
+CREATE TABLE IF NOT EXISTS movie_genres (
+    movie_id INT NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    genre_id INT NOT NULL REFERENCES genres (id) ON DELETE CASCADE,
+    PRIMARY KEY (movie_id, genre_id)
+);
+
+CREATE INDEX IF NOT EXISTS movie_genres_genre_id_idx ON movie_genres (genre_id);
+CREATE INDEX IF NOT EXISTS movies_year_idx ON movies (year);
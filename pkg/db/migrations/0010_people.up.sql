@@ -0,0 +1,27 @@
+-- People come from TMDB credits, so tmdb_id is always set.
+CREATE TABLE IF NOT EXISTS people (
+    id          SERIAL PRIMARY KEY,
+    tmdb_id     INT NOT NULL UNIQUE,
+    name        TEXT NOT NULL,
+    profile_url TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS people_name_trgm_idx ON people USING GIN (name gin_trgm_ops);
+
+-- role is cast or crew; cast credits have a character, crew credits a job
+-- and department. credit_order is TMDB's billing order for cast and TMDB's
+-- listing order for crew.
+CREATE TABLE IF NOT EXISTS movie_credits (
+    id           SERIAL PRIMARY KEY,
+    movie_id     INT NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    person_id    INT NOT NULL REFERENCES people (id) ON DELETE CASCADE,
+    role         TEXT NOT NULL CHECK (role IN ('cast', 'crew')),
+    character    TEXT NOT NULL DEFAULT '',
+    job          TEXT NOT NULL DEFAULT '',
+    department   TEXT NOT NULL DEFAULT '',
+    credit_order INT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS movie_credits_movie_id_idx ON movie_credits (movie_id, role, credit_order);
+CREATE INDEX IF NOT EXISTS movie_credits_person_id_idx ON movie_credits (person_id);
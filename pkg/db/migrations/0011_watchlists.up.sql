@@ -0,0 +1,15 @@
+-- position orders a user's watchlist; it is renumbered from 1 whenever an
+-- entry moves, so gaps left by removals do not last.
+CREATE TABLE IF NOT EXISTS watchlist_entries (
+    id          SERIAL PRIMARY KEY,
+    user_id     INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    movie_id    INT NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    position    INT NOT NULL,
+    note        TEXT NOT NULL DEFAULT '',
+    planned_for DATE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT watchlist_entries_user_movie_key UNIQUE (user_id, movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS watchlist_entries_user_position_idx ON watchlist_entries (user_id, position);
+CREATE INDEX IF NOT EXISTS watchlist_entries_movie_id_idx ON watchlist_entries (movie_id);
@@ -0,0 +1,16 @@
+-- A diary entry is one watch of a movie; a user can log the same movie any
+-- number of times. A movie's rating counts each user's latest scored entry,
+-- falling back to their review.
+CREATE TABLE IF NOT EXISTS diary_entries (
+    id         SERIAL PRIMARY KEY,
+    user_id    INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    movie_id   INT NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    watched_on DATE NOT NULL,
+    rewatch    BOOLEAN NOT NULL DEFAULT false,
+    score      INT CHECK (score BETWEEN 1 AND 5),
+    note       TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS diary_entries_user_watched_idx ON diary_entries (user_id, watched_on);
+CREATE INDEX IF NOT EXISTS diary_entries_movie_user_idx ON diary_entries (movie_id, user_id);
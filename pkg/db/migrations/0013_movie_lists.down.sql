@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS movie_list_collaborators;
+DROP TABLE IF EXISTS movie_list_entries;
+DROP TABLE IF EXISTS movie_lists;
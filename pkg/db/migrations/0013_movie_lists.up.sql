@@ -0,0 +1,39 @@
+-- User-curated movie lists. Unlisted lists are readable by anyone who has
+-- the id but are left out of discovery. Collaborators can edit a list's
+-- entries; only the owner changes the list itself.
+CREATE TABLE IF NOT EXISTS movie_lists (
+    id          SERIAL PRIMARY KEY,
+    owner_id    INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    title       TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    visibility  TEXT NOT NULL DEFAULT 'private' CHECK (visibility IN ('public', 'unlisted', 'private')),
+    cloned_from INT REFERENCES movie_lists (id) ON DELETE SET NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS movie_lists_owner_id_idx ON movie_lists (owner_id);
+
+-- position works as on watchlist_entries: renumbered from 1 on every move.
+CREATE TABLE IF NOT EXISTS movie_list_entries (
+    id         SERIAL PRIMARY KEY,
+    list_id    INT NOT NULL REFERENCES movie_lists (id) ON DELETE CASCADE,
+    movie_id   INT NOT NULL REFERENCES movies (id) ON DELETE CASCADE,
+    position   INT NOT NULL,
+    comment    TEXT NOT NULL DEFAULT '',
+    added_by   INT REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT movie_list_entries_list_movie_key UNIQUE (list_id, movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS movie_list_entries_list_position_idx ON movie_list_entries (list_id, position);
+CREATE INDEX IF NOT EXISTS movie_list_entries_movie_id_idx ON movie_list_entries (movie_id);
+
+CREATE TABLE IF NOT EXISTS movie_list_collaborators (
+    list_id  INT NOT NULL REFERENCES movie_lists (id) ON DELETE CASCADE,
+    user_id  INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    added_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (list_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS movie_list_collaborators_user_id_idx ON movie_list_collaborators (user_id);
@@ -0,0 +1,40 @@
+-- Who follows whom, and each user's feed. Activities are fanned out on
+-- write: publishing one copies its id into feed_items for every follower,
+-- so reading a feed is one index range scan however many accounts the
+-- reader follows. Following someone backfills their recent activities;
+-- unfollowing removes them. An activity references what it is about and
+-- goes when that does.
+CREATE TABLE IF NOT EXISTS follows (
+    follower_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    followee_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (follower_id, followee_id),
+    CONSTRAINT follows_not_self CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX IF NOT EXISTS follows_followee_id_idx ON follows (followee_id);
+
+CREATE TABLE IF NOT EXISTS activities (
+    id             BIGSERIAL PRIMARY KEY,
+    actor_id       INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    kind           TEXT NOT NULL CHECK (kind IN ('review', 'diary', 'list_created', 'list_entry')),
+    review_id      INT REFERENCES reviews (id) ON DELETE CASCADE,
+    diary_entry_id INT REFERENCES diary_entries (id) ON DELETE CASCADE,
+    list_id        INT REFERENCES movie_lists (id) ON DELETE CASCADE,
+    list_entry_id  INT REFERENCES movie_list_entries (id) ON DELETE CASCADE,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS activities_actor_id_idx ON activities (actor_id, id);
+CREATE INDEX IF NOT EXISTS activities_review_id_idx ON activities (review_id) WHERE review_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS activities_diary_entry_id_idx ON activities (diary_entry_id) WHERE diary_entry_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS activities_list_id_idx ON activities (list_id) WHERE list_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS activities_list_entry_id_idx ON activities (list_entry_id) WHERE list_entry_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS feed_items (
+    user_id     INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    activity_id BIGINT NOT NULL REFERENCES activities (id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, activity_id)
+);
+
+CREATE INDEX IF NOT EXISTS feed_items_activity_id_idx ON feed_items (activity_id);
@@ -0,0 +1,3 @@
+UPDATE users SET role = 'user' WHERE role = 'analyst';
+DELETE FROM role_permissions WHERE permission = 'data:export';
+DELETE FROM roles WHERE name = 'analyst';
@@ -0,0 +1,8 @@
+INSERT INTO roles (name) VALUES ('analyst')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('analyst', 'review:write'),
+    ('analyst', 'data:export'),
+    ('admin',   'data:export')
+ON CONFLICT DO NOTHING;
@@ -13,6 +13,8 @@ const API = {
 
     authRegister: "/api/auth/register",
     authLogin: "/api/auth/login",
+    authRefresh: "/api/auth/refresh",
+    authLogout: "/api/auth/logout",
 
     me: "/api/me",
     mePassword: "/api/me/password",
@@ -22,6 +24,7 @@ const API = {
 
 const state = {
     token: localStorage.getItem("token") || "",
+    refreshToken: localStorage.getItem("refreshToken") || "",
     me: null,
     movies: [],
     selectedMovie: null,
@@ -42,7 +45,7 @@ function toast(msg) {
 function openModal(id) { $(id).classList.remove("hidden"); }
 function closeModal(id) { $(id).classList.add("hidden"); }
 
-async function apiFetch(path, { method="GET", body=null, auth=false } = {}) {
+async function apiFetch(path, { method="GET", body=null, auth=false, retried=false } = {}) {
     const headers = { "Accept": "application/json" };
     if (body !== null) headers["Content-Type"] = "application/json";
     if (auth && state.token) headers["Authorization"] = `Bearer ${state.token}`;
@@ -59,6 +62,14 @@ async function apiFetch(path, { method="GET", body=null, auth=false } = {}) {
         try { data = await res.json(); } catch { data = null; }
     }
 
+    // Access tokens are short-lived: on 401 trade the refresh token for a new
+    // pair once and replay the request.
+    if (res.status === 401 && auth && !retried && state.refreshToken) {
+        if (await refreshTokens()) {
+            return apiFetch(path, { method, body, auth, retried: true });
+        }
+    }
+
     if (!res.ok) {
         const errMsg = (data && (data.error || data.message))
             ? (data.error || data.message)
@@ -136,10 +147,27 @@ function toYouTubeEmbed(data) {
 
 /* ---------- Auth ---------- */
 
-function setToken(token) {
+function setToken(token, refreshToken="") {
     state.token = token || "";
+    state.refreshToken = refreshToken || "";
     if (state.token) localStorage.setItem("token", state.token);
     else localStorage.removeItem("token");
+    if (state.refreshToken) localStorage.setItem("refreshToken", state.refreshToken);
+    else localStorage.removeItem("refreshToken");
+}
+
+async function refreshTokens() {
+    try {
+        const out = await apiFetch(API.authRefresh, {
+            method:"POST",
+            body:{ refresh_token: state.refreshToken },
+        });
+        setToken(out.access_token, out.refresh_token);
+        return true;
+    } catch {
+        setToken("");
+        return false;
+    }
 }
 
 async function loadMe() {
@@ -173,7 +201,7 @@ function renderAuthUI() {
 
 async function login(email, password) {
     const out = await apiFetch(API.authLogin, { method:"POST", body:{ email, password } });
-    setToken(out.token);
+    setToken(out.access_token, out.refresh_token);
     await loadMe();
     renderAuthUI();
     updateComposerVisibility();
@@ -186,6 +214,13 @@ async function register(username, email, password) {
 }
 
 function logout() {
+    if (state.token) {
+        apiFetch(API.authLogout, {
+            method:"POST",
+            body:{ refresh_token: state.refreshToken },
+            auth:true,
+        }).catch(() => {});
+    }
     setToken("");
     state.me = null;
     state.userCache.clear();
@@ -234,7 +269,8 @@ function renderMovieList() {
 }
 
 async function loadMovies() {
-    state.movies = await apiFetch(API.movies);
+    const page = await apiFetch(`${API.movies}?limit=100`);
+    state.movies = page.items || [];
     renderMovieList();
 }
 
@@ -242,7 +278,9 @@ async function searchMovies(title, year) {
     const qs = new URLSearchParams();
     if (title) qs.set("title", title);
     if (year) qs.set("year", String(year));
-    state.movies = await apiFetch(`${API.search}?${qs.toString()}`);
+    qs.set("limit", "100");
+    const page = await apiFetch(`${API.search}?${qs.toString()}`);
+    state.movies = page.items || [];
     renderMovieList();
 }
 
@@ -394,7 +432,8 @@ async function selectMovie(id) {
 
 async function loadReviews(movieId) {
     try {
-        state.selectedReviews = await apiFetch(API.reviews(movieId));
+        const page = await apiFetch(`${API.reviews(movieId)}?limit=100`);
+        state.selectedReviews = page.items || [];
     } catch (e) {
         state.selectedReviews = [];
     }
@@ -594,11 +633,12 @@ async function saveProfile() {
 async function changePassword() {
     const password = $("mePass").value;
     try {
-        await apiFetch(API.mePassword, {
+        const out = await apiFetch(API.mePassword, {
             method:"PUT",
             body:{ password },
             auth:true
         });
+        setToken(out.access_token, out.refresh_token);
         $("mePass").value = "";
         toast("Password changed ✅");
     } catch (e) {